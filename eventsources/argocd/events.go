@@ -0,0 +1,255 @@
+package argocd
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"strings"
+	"time"
+)
+
+const (
+	phaseRunning     string = "Running"
+	phaseTerminating string = "Terminating"
+	phaseSucceeded   string = "Succeeded"
+	phaseFailed      string = "Failed"
+	phaseError       string = "Error"
+
+	healthHealthy  string = "Healthy"
+	healthDegraded string = "Degraded"
+
+	// environmentLabel names the environment an application deploys to
+	environmentLabel string = "environment"
+)
+
+type Metadata struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Labels    map[string]string `json:"labels"`
+}
+
+type AppSource struct {
+	RepoURL        string `json:"repoURL"`
+	Path           string `json:"path"`
+	Chart          string `json:"chart"`
+	TargetRevision string `json:"targetRevision"`
+}
+
+type Destination struct {
+	Server    string `json:"server"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+type Spec struct {
+	Project     string      `json:"project"`
+	Source      AppSource   `json:"source"`
+	Destination Destination `json:"destination"`
+}
+
+type OperationState struct {
+	Phase     string `json:"phase"`
+	Message   string `json:"message"`
+	Operation struct {
+		Sync struct {
+			Revision string `json:"revision"`
+		} `json:"sync"`
+	} `json:"operation"`
+	SyncResult struct {
+		Revision string `json:"revision"`
+	} `json:"syncResult"`
+	StartedAt  *time.Time `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+}
+
+type Status struct {
+	Sync struct {
+		Status   string `json:"status"`
+		Revision string `json:"revision"`
+	} `json:"sync"`
+	Health struct {
+		Status string `json:"status"`
+	} `json:"health"`
+	OperationState *OperationState `json:"operationState"`
+}
+
+type Application struct {
+	Metadata Metadata `json:"metadata"`
+	Spec     Spec     `json:"spec"`
+	Status   Status   `json:"status"`
+}
+
+// AppEvent is the Application an argo cd notification was sent for.  A
+// deploy starts when a sync of the application starts running, and
+// finishes once the sync has succeeded and the application is healthy,
+// or the sync fails or the application becomes degraded.
+type AppEvent struct {
+	App Application `json:"app"`
+}
+
+func (ae AppEvent) phase() string {
+	if ae.App.Status.OperationState == nil {
+		return ""
+	}
+	return ae.App.Status.OperationState.Phase
+}
+
+func (ae AppEvent) health() string {
+	return ae.App.Status.Health.Status
+}
+
+// Revision is the commit being synced, the one the last sync deployed
+// or, when the application hasn't been synced, the one it's compared to.
+func (ae AppEvent) Revision() string {
+	if op := ae.App.Status.OperationState; op != nil {
+		if op.SyncResult.Revision != "" {
+			return op.SyncResult.Revision
+		}
+		if op.Operation.Sync.Revision != "" {
+			return op.Operation.Sync.Revision
+		}
+	}
+	return ae.App.Status.Sync.Revision
+}
+
+// Environment is the application's `environment` label, or else the
+// cluster, by its name or else its server, and the namespace the
+// application is deployed to, ie `production/shop`.
+func (ae AppEvent) Environment() string {
+	if env := ae.App.Metadata.Labels[environmentLabel]; env != "" {
+		return env
+	}
+
+	d := ae.App.Spec.Destination
+	cluster := d.Name
+	if cluster == "" {
+		cluster = d.Server
+	}
+	return cluster + "/" + d.Namespace
+}
+
+// ServiceName is the name of the application being deployed.
+func (ae AppEvent) ServiceName() string {
+	return ae.App.Metadata.Name
+}
+
+func (ae AppEvent) OperationName() string {
+	return types.DeployEventType
+}
+
+func (ae AppEvent) SpanID() (string, error) {
+	revision := ae.Revision()
+	if ae.App.Metadata.Name == "" || revision == "" {
+		return "", fmt.Errorf("event does not contain an application name and revision")
+	}
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		types.DeployEventType,
+		ae.App.Metadata.Name,
+		revision,
+	}, "-"), nil
+}
+
+// References are resolved by the webhook from the span of the build or
+// pull request of the Revision.
+func (ae AppEvent) References() ([]eventsources.Reference, error) {
+	return nil, nil
+}
+
+func (ae AppEvent) IsError() (bool, error) {
+	switch ae.phase() {
+	case phaseFailed, phaseError:
+		return true, nil
+	}
+	return ae.health() == healthDegraded, nil
+}
+
+func (ae AppEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	switch ae.phase() {
+	case phaseRunning, phaseTerminating:
+		if prev == nil {
+			return eventsources.StartState, nil
+		}
+		return eventsources.IntermediaryState, nil
+
+	case phaseFailed, phaseError:
+		if prev == nil {
+			return eventsources.CompleteState, nil
+		}
+		return eventsources.EndState, nil
+
+	case phaseSucceeded:
+		switch ae.health() {
+		case healthHealthy:
+			if prev == nil {
+				return eventsources.CompleteState, nil
+			}
+			return eventsources.EndState, nil
+		case healthDegraded:
+			// degraded long after the deploy finished isn't the deploy
+			if prev == nil {
+				return eventsources.UnknownState, nil
+			}
+			return eventsources.EndState, nil
+		}
+		// still progressing towards healthy
+		if prev == nil {
+			return eventsources.StartState, nil
+		}
+		return eventsources.IntermediaryState, nil
+	}
+
+	return eventsources.UnknownState, nil
+}
+
+// Timings start the deploy when the sync started.  Successful deploys end
+// once the application is healthy, which is when the notification is
+// sent, while failed ones end when their sync did.
+func (ae AppEvent) Timings() (eventsources.EventTimings, error) {
+	var t eventsources.EventTimings
+	op := ae.App.Status.OperationState
+	if op == nil {
+		return t, nil
+	}
+	t.StartTime = op.StartedAt
+	if op.Phase == phaseFailed || op.Phase == phaseError {
+		t.EndTime = op.FinishedAt
+	}
+	return t, nil
+}
+
+func (ae AppEvent) Tags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+	tags["deploy.application"] = ae.App.Metadata.Name
+	tags["deploy.namespace"] = ae.App.Metadata.Namespace
+	tags["deploy.project"] = ae.App.Spec.Project
+	tags["deploy.revision"] = ae.Revision()
+	tags["deploy.destination.server"] = ae.App.Spec.Destination.Server
+	tags["deploy.destination.namespace"] = ae.App.Spec.Destination.Namespace
+	tags["deploy.environment"] = ae.Environment()
+	tags["scm.url"] = ae.App.Spec.Source.RepoURL
+	tags["scm.target_revision"] = ae.App.Spec.Source.TargetRevision
+
+	if ae.App.Spec.Source.Path != "" {
+		tags["scm.path"] = ae.App.Spec.Source.Path
+	}
+	if ae.App.Spec.Source.Chart != "" {
+		tags["deploy.chart"] = ae.App.Spec.Source.Chart
+	}
+	return tags, nil
+}
+
+// EndTags record the outcome of the sync and the health of the
+// application when the deploy finished.
+func (ae AppEvent) EndTags() (map[string]interface{}, error) {
+	tags := map[string]interface{}{
+		"deploy.sync.phase":    ae.phase(),
+		"deploy.health.status": ae.health(),
+	}
+	if op := ae.App.Status.OperationState; op != nil && op.Message != "" {
+		tags["deploy.sync.message"] = op.Message
+	}
+	return tags, nil
+}
@@ -0,0 +1,161 @@
+// +build service
+
+package argocd
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/tracers"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+var baseURL string
+var argocdPath string
+var jenkinsPath string
+
+var urlEnvVar = "TEST_EVENTS_URL"
+var argocdPathEnvVar = "TEST_EVENTS_ARGOCD_PATH"
+var jenkinsPathEnvVar = "TEST_EVENTS_JENKINS_PATH"
+
+func init() {
+	ok := true
+	baseURL, ok = os.LookupEnv(urlEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", urlEnvVar))
+	}
+	argocdPath, ok = os.LookupEnv(argocdPathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", argocdPathEnvVar))
+	}
+	jenkinsPath, ok = os.LookupEnv(jenkinsPathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", jenkinsPathEnvVar))
+	}
+}
+
+func deployTags(overrides map[string]interface{}) map[string]interface{} {
+	tags := map[string]interface{}{
+		"deploy.application":           "valuestream",
+		"deploy.destination.namespace": "valuestream",
+		"deploy.destination.server":    "https://kubernetes.default.svc",
+		"deploy.environment":           "https://kubernetes.default.svc/valuestream",
+		"deploy.health.status":         "Healthy",
+		"deploy.namespace":             "argocd",
+		"deploy.project":               "default",
+		"deploy.revision":              "5d1c9a3e2b7f4a6c8e0d1f2a3b4c5d6e7f8a9b0c",
+		"deploy.sync.message":          "successfully synced (all tasks run)",
+		"deploy.sync.phase":            "Succeeded",
+		"error":                        false,
+		"scm.path":                     "overlays/production",
+		"scm.target_revision":          "HEAD",
+		"scm.url":                      "https://github.com/ImpactInsights/valuestream-deploy.git",
+		"service":                      "argocd",
+	}
+	for k, v := range overrides {
+		tags[k] = v
+	}
+	return tags
+}
+
+var eventTests = []struct {
+	Name          string
+	EventPaths    []string
+	ExpectedSpans int
+	ExpectedTags  map[string]interface{}
+}{
+	{
+		Name: "deploy_succeeded",
+		EventPaths: []string{
+			"fixtures/events/sync_running.json",
+			"fixtures/events/sync_succeeded_progressing.json",
+			"fixtures/events/deployed.json",
+		},
+		ExpectedSpans: 1,
+		ExpectedTags:  deployTags(nil),
+	},
+	{
+		Name: "deploy_degraded",
+		EventPaths: []string{
+			"fixtures/events/sync_running.json",
+			"fixtures/events/sync_succeeded_progressing.json",
+			"fixtures/events/health_degraded.json",
+		},
+		ExpectedSpans: 1,
+		ExpectedTags: deployTags(map[string]interface{}{
+			"deploy.health.status": "Degraded",
+			"error":                true,
+		}),
+	},
+	{
+		Name: "sync_failed",
+		EventPaths: []string{
+			"fixtures/events/sync_running.json",
+			"fixtures/events/sync_failed.json",
+		},
+		ExpectedSpans: 1,
+		ExpectedTags: deployTags(map[string]interface{}{
+			"deploy.health.status": "Progressing",
+			"deploy.sync.message":  "one or more objects failed to apply, reason: Deployment.apps \"valuestream\" is invalid",
+			"deploy.sync.phase":    "Failed",
+			"error":                true,
+		}),
+	},
+	{
+		Name: "deployed_only",
+		EventPaths: []string{
+			"fixtures/events/deployed.json",
+		},
+		ExpectedSpans: 1,
+		ExpectedTags:  deployTags(nil),
+	},
+	{
+		Name: "degraded_after_deploy",
+		EventPaths: []string{
+			"fixtures/events/health_degraded.json",
+		},
+		ExpectedSpans: 0,
+	},
+}
+
+func TestServiceEvent_ArgoCD(t *testing.T) {
+	for _, tt := range eventTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			tracers.ResetTracer(t, baseURL)
+
+			for _, eventPath := range tt.EventPaths {
+				tracers.PostFixture(t, baseURL, argocdPath, eventPath)
+			}
+
+			spans := tracers.FinishedSpans(t, baseURL)
+
+			assert.Equal(t, tt.ExpectedSpans, len(spans))
+			if len(spans) == 1 {
+				assert.Equal(t, "deploy", spans[0].Span.OperationName)
+				assert.Equal(t, tt.ExpectedTags, spans[0].Tags)
+			}
+		})
+	}
+}
+
+func TestServiceTrace_ArgoCD_DeployBeneathBuildOfRevision(t *testing.T) {
+	tracers.ResetTracer(t, baseURL)
+
+	tracers.PostFixture(t, baseURL, jenkinsPath, "fixtures/traces/jenkins_build_inprogress.json")
+	tracers.PostFixture(t, baseURL, argocdPath, "fixtures/events/sync_running.json")
+	tracers.PostFixture(t, baseURL, argocdPath, "fixtures/events/deployed.json")
+	tracers.PostFixture(t, baseURL, jenkinsPath, "fixtures/traces/jenkins_build_success.json")
+
+	spans := tracers.FinishedSpans(t, baseURL)
+
+	// queued, deploy and build
+	assert.Equal(t, 3, len(spans))
+	if len(spans) != 3 {
+		t.FailNow()
+	}
+
+	deploy, build := spans[1], spans[2]
+	assert.Equal(t, "deploy", deploy.Span.OperationName)
+	assert.Equal(t, "build", build.Span.OperationName)
+	assert.Equal(t, build.Span.SpanContext.SpanID, deploy.Span.ParentID)
+}
@@ -0,0 +1,103 @@
+package argocd
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func testAppEvent(phase, health string) AppEvent {
+	ae := AppEvent{}
+	ae.App.Metadata.Name = "valuestream"
+	ae.App.Status.Health.Status = health
+	if phase != "" {
+		ae.App.Status.OperationState = &OperationState{Phase: phase}
+	}
+	return ae
+}
+
+func TestAppEvent_State(t *testing.T) {
+	started := eventsources.EventState(eventsources.StartState)
+
+	testCases := []struct {
+		name     string
+		phase    string
+		health   string
+		prev     *eventsources.EventState
+		expected eventsources.SpanState
+	}{
+		{"never_synced", "", "Healthy", nil, eventsources.UnknownState},
+		{"sync_running", phaseRunning, "Progressing", nil, eventsources.StartState},
+		{"sync_running_again", phaseRunning, "Progressing", &started, eventsources.IntermediaryState},
+		{"synced_progressing", phaseSucceeded, "Progressing", &started, eventsources.IntermediaryState},
+		{"synced_progressing_not_started", phaseSucceeded, "Progressing", nil, eventsources.StartState},
+		{"deployed", phaseSucceeded, healthHealthy, &started, eventsources.EndState},
+		{"deployed_not_started", phaseSucceeded, healthHealthy, nil, eventsources.CompleteState},
+		{"degraded", phaseSucceeded, healthDegraded, &started, eventsources.EndState},
+		{"degraded_after_deploy", phaseSucceeded, healthDegraded, nil, eventsources.UnknownState},
+		{"sync_failed", phaseFailed, "Progressing", &started, eventsources.EndState},
+		{"sync_error_not_started", phaseError, "Missing", nil, eventsources.CompleteState},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := testAppEvent(tt.phase, tt.health).State(tt.prev)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, s)
+		})
+	}
+}
+
+func TestAppEvent_IsError(t *testing.T) {
+	testCases := []struct {
+		phase    string
+		health   string
+		expected bool
+	}{
+		{phaseSucceeded, healthHealthy, false},
+		{phaseSucceeded, healthDegraded, true},
+		{phaseFailed, "Progressing", true},
+		{phaseError, "Healthy", true},
+	}
+	for _, tt := range testCases {
+		isErr, err := testAppEvent(tt.phase, tt.health).IsError()
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, isErr, tt.phase+"/"+tt.health)
+	}
+}
+
+func TestAppEvent_Revision(t *testing.T) {
+	ae := testAppEvent("", "")
+	ae.App.Status.Sync.Revision = "compared"
+	assert.Equal(t, "compared", ae.Revision())
+
+	ae = testAppEvent(phaseRunning, "")
+	ae.App.Status.Sync.Revision = "compared"
+	ae.App.Status.OperationState.Operation.Sync.Revision = "syncing"
+	assert.Equal(t, "syncing", ae.Revision())
+
+	ae.App.Status.OperationState.SyncResult.Revision = "synced"
+	assert.Equal(t, "synced", ae.Revision())
+
+	id, err := ae.SpanID()
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-argocd-deploy-valuestream-synced", id)
+}
+
+func TestAppEvent_Environment(t *testing.T) {
+	ae := testAppEvent("", "")
+	ae.App.Spec.Destination = Destination{
+		Server:    "https://kubernetes.default.svc",
+		Namespace: "shop",
+	}
+	assert.Equal(t, "https://kubernetes.default.svc/shop", ae.Environment())
+
+	ae.App.Spec.Destination.Name = "production"
+	assert.Equal(t, "production/shop", ae.Environment())
+
+	tags, err := ae.Tags()
+	assert.NoError(t, err)
+	assert.Equal(t, "production/shop", tags["deploy.environment"])
+
+	ae.App.Metadata.Labels = map[string]string{"environment": "staging"}
+	assert.Equal(t, "staging", ae.Environment())
+}
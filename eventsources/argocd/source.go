@@ -0,0 +1,80 @@
+package argocd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/opentracing/opentracing-go"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	sourceName string = "argocd"
+)
+
+type Source struct {
+	tracer    opentracing.Tracer
+	secretKey []byte
+}
+
+func (s Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *Source) SecretKey() []byte {
+	return s.secretKey
+}
+
+// ValidatePayload checks the token argo cd notifications are configured
+// to send as a header of the webhook service:
+// `Authorization: Bearer <token>`
+func (s *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body")
+	}
+
+	if secretKey == nil {
+		return body, nil
+	}
+
+	if err := eventsources.BearerToken.Validate(r, secretKey); err != nil {
+		return nil, eventsources.InvalidSignatureError{Err: err}
+	}
+
+	return body, nil
+}
+
+func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
+	var ae AppEvent
+	if err := json.Unmarshal(payload, &ae); err != nil {
+		return nil, err
+	}
+
+	if ae.App.Metadata.Name == "" {
+		return nil, fmt.Errorf("payload does not contain an application")
+	}
+
+	return ae, nil
+}
+
+func NewSource(tracer opentracing.Tracer, secretKey []byte) (*Source, error) {
+	return &Source{
+		tracer:    tracer,
+		secretKey: secretKey,
+	}, nil
+}
+
+func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
+	var secretKey []byte
+	if token := c.String("argocd-token"); token != "" {
+		secretKey = []byte(token)
+	}
+	return NewSource(tracer, secretKey)
+}
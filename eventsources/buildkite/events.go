@@ -0,0 +1,331 @@
+package buildkite
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"github.com/ImpactInsights/valuestream/traces"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	eventBuildScheduled string = "build.scheduled"
+	eventBuildRunning   string = "build.running"
+	eventBuildFinished  string = "build.finished"
+	eventJobScheduled   string = "job.scheduled"
+	eventJobStarted     string = "job.started"
+	eventJobFinished    string = "job.finished"
+
+	// jobTypeScript is a command step, the other job types are waits,
+	// block steps and triggers of other pipelines which don't do work.
+	jobTypeScript string = "script"
+
+	// metaDataType is the build meta-data key which marks a build as a
+	// deploy: `buildkite-agent meta-data set type deploy`
+	metaDataType    string = "type"
+	metaDataTraceID string = "vstrace-trace-id"
+)
+
+type Pipeline struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Slug       string `json:"slug"`
+	Repository string `json:"repository"`
+	WebURL     string `json:"web_url"`
+}
+
+type Person struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type Build struct {
+	ID          string            `json:"id"`
+	WebURL      string            `json:"web_url"`
+	Number      int               `json:"number"`
+	State       string            `json:"state"`
+	Message     string            `json:"message"`
+	Commit      string            `json:"commit"`
+	Branch      string            `json:"branch"`
+	Tag         *string           `json:"tag"`
+	Source      string            `json:"source"`
+	Creator     *Person           `json:"creator"`
+	MetaData    map[string]string `json:"meta_data"`
+	CreatedAt   *time.Time        `json:"created_at"`
+	ScheduledAt *time.Time        `json:"scheduled_at"`
+	StartedAt   *time.Time        `json:"started_at"`
+	FinishedAt  *time.Time        `json:"finished_at"`
+}
+
+type Agent struct {
+	Name     string `json:"name"`
+	Hostname string `json:"hostname"`
+}
+
+type Job struct {
+	ID          string     `json:"id"`
+	Type        string     `json:"type"`
+	Name        string     `json:"name"`
+	StepKey     *string    `json:"step_key"`
+	State       string     `json:"state"`
+	WebURL      string     `json:"web_url"`
+	ExitStatus  *int       `json:"exit_status"`
+	SoftFailed  bool       `json:"soft_failed"`
+	Agent       *Agent     `json:"agent"`
+	CreatedAt   *time.Time `json:"created_at"`
+	ScheduledAt *time.Time `json:"scheduled_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
+
+func isError(state string) bool {
+	switch state {
+	case "failed", "canceled", "timed_out", "expired", "broken":
+		return true
+	}
+	return false
+}
+
+// state maps the lifecycle of a build or job onto its span.  Builds and
+// jobs which finish before their span was started, ie because the
+// webhook was configured part way through, are placed by their timings.
+func state(event string, prev *eventsources.EventState) eventsources.SpanState {
+	switch {
+	case event == eventBuildScheduled || event == eventJobScheduled:
+		return eventsources.StartState
+	case event == eventBuildRunning || event == eventJobStarted:
+		if prev == nil {
+			return eventsources.StartState
+		}
+		return eventsources.IntermediaryState
+	case event == eventBuildFinished || event == eventJobFinished:
+		if prev == nil {
+			return eventsources.CompleteState
+		}
+		return eventsources.EndState
+	}
+	return eventsources.UnknownState
+}
+
+func timings(scheduled, started, finished *time.Time) eventsources.EventTimings {
+	t := eventsources.EventTimings{
+		StartTime: scheduled,
+		EndTime:   finished,
+	}
+	if t.StartTime == nil {
+		t.StartTime = started
+	}
+	if t.StartTime != nil && t.EndTime != nil {
+		d := t.EndTime.Sub(*t.StartTime)
+		t.Duration = &d
+	}
+	return t
+}
+
+// BuildEvent is sent as a build is scheduled, starts running and finishes.
+type BuildEvent struct {
+	Event    string   `json:"event"`
+	Build    Build    `json:"build"`
+	Pipeline Pipeline `json:"pipeline"`
+	Sender   *Person  `json:"sender"`
+
+	references traces.References
+}
+
+// OperationName determines if the build is a `deploy` or a `build`
+// based on the `type` build meta-data.
+func (be BuildEvent) OperationName() string {
+	if be.Build.MetaData[metaDataType] == types.DeployEventType {
+		return types.DeployEventType
+	}
+	return types.BuildEventType
+}
+
+// ServiceName is the slug of a deploy's pipeline, builds don't deploy a
+// service.
+func (be BuildEvent) ServiceName() string {
+	if be.OperationName() != types.DeployEventType {
+		return ""
+	}
+	return be.Pipeline.Slug
+}
+
+func (be BuildEvent) SpanID() (string, error) {
+	if be.Pipeline.Slug == "" || be.Build.Number == 0 {
+		return "", fmt.Errorf("event does not contain a pipeline slug and build number")
+	}
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		be.OperationName(),
+		be.Pipeline.Slug,
+		strconv.Itoa(be.Build.Number),
+	}, "-"), nil
+}
+
+// References uses the trace id set in the build's meta-data, otherwise
+// the trace id in the name of the branch being built.
+func (be BuildEvent) References() ([]eventsources.Reference, error) {
+	if id, ok := be.Build.MetaData[metaDataTraceID]; ok {
+		return eventsources.ChildOf(&id), nil
+	}
+
+	vars := map[string]string{
+		"pipeline": be.Pipeline.Slug,
+	}
+	return eventsources.ChildOf(be.references.First(sourceName, vars, be.Build.Branch)), nil
+}
+
+func (be BuildEvent) IsError() (bool, error) {
+	return isError(be.Build.State), nil
+}
+
+func (be BuildEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	return state(be.Event, prev), nil
+}
+
+func (be BuildEvent) Timings() (eventsources.EventTimings, error) {
+	return timings(be.Build.ScheduledAt, be.Build.StartedAt, be.Build.FinishedAt), nil
+}
+
+func (be BuildEvent) Tags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+	tags["build.id"] = be.Build.ID
+	tags["build.number"] = be.Build.Number
+	tags["build.state"] = be.Build.State
+	tags["build.url"] = be.Build.WebURL
+	tags["build.message"] = be.Build.Message
+	tags["build.source"] = be.Build.Source
+	tags["pipeline.name"] = be.Pipeline.Name
+	tags["pipeline.slug"] = be.Pipeline.Slug
+	tags["scm.url"] = be.Pipeline.Repository
+	tags["scm.branch"] = be.Build.Branch
+	tags["scm.head.sha"] = be.Build.Commit
+
+	if be.Build.Tag != nil {
+		tags["scm.tag"] = *be.Build.Tag
+	}
+
+	if be.Build.Creator != nil {
+		tags["build.creator.name"] = be.Build.Creator.Name
+	}
+
+	for k, v := range be.Build.MetaData {
+		tags[fmt.Sprintf("build.meta_data.%s", k)] = v
+	}
+	return tags, nil
+}
+
+// EndTags record the state the build finished in.
+func (be BuildEvent) EndTags() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"build.state": be.Build.State,
+	}, nil
+}
+
+// JobEvent is sent as each job of a build is scheduled, starts and
+// finishes.  Only command steps are traced.
+type JobEvent struct {
+	Event    string   `json:"event"`
+	Job      Job      `json:"job"`
+	Build    Build    `json:"build"`
+	Pipeline Pipeline `json:"pipeline"`
+	Sender   *Person  `json:"sender"`
+}
+
+func (je JobEvent) build() BuildEvent {
+	return BuildEvent{
+		Build:    je.Build,
+		Pipeline: je.Pipeline,
+	}
+}
+
+func (je JobEvent) OperationName() string {
+	return types.JobEventType
+}
+
+func (je JobEvent) SpanID() (string, error) {
+	if je.Job.ID == "" {
+		return "", fmt.Errorf("event does not contain a job id")
+	}
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		types.JobEventType,
+		je.Pipeline.Slug,
+		je.Job.ID,
+	}, "-"), nil
+}
+
+func (je JobEvent) References() ([]eventsources.Reference, error) {
+	id, err := je.build().SpanID()
+	if err != nil {
+		return nil, err
+	}
+	return eventsources.ChildOf(&id), nil
+}
+
+func (je JobEvent) IsError() (bool, error) {
+	if isError(je.Job.State) {
+		return true, nil
+	}
+	return je.Job.ExitStatus != nil && *je.Job.ExitStatus != 0 && !je.Job.SoftFailed, nil
+}
+
+func (je JobEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	if je.Job.Type != jobTypeScript {
+		return eventsources.UnknownState, nil
+	}
+	return state(je.Event, prev), nil
+}
+
+func (je JobEvent) Timings() (eventsources.EventTimings, error) {
+	return timings(je.Job.ScheduledAt, je.Job.StartedAt, je.Job.FinishedAt), nil
+}
+
+func (je JobEvent) Tags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+	tags["job.id"] = je.Job.ID
+	tags["job.name"] = je.Job.Name
+	tags["job.state"] = je.Job.State
+	tags["job.url"] = je.Job.WebURL
+	tags["job.soft_failed"] = je.Job.SoftFailed
+	tags["build.id"] = je.Build.ID
+	tags["build.number"] = je.Build.Number
+	tags["pipeline.slug"] = je.Pipeline.Slug
+
+	if je.Job.StepKey != nil {
+		tags["job.step_key"] = *je.Job.StepKey
+	}
+
+	for k, v := range je.runTags() {
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+// EndTags record the state the job finished in, along with the agent
+// which ran it as jobs are only assigned one once they start.
+func (je JobEvent) EndTags() (map[string]interface{}, error) {
+	tags := je.runTags()
+	tags["job.state"] = je.Job.State
+	return tags, nil
+}
+
+func (je JobEvent) runTags() map[string]interface{} {
+	tags := make(map[string]interface{})
+	if je.Job.ExitStatus != nil {
+		tags["job.exit_status"] = *je.Job.ExitStatus
+	}
+
+	if je.Job.Agent != nil {
+		tags["job.agent.name"] = je.Job.Agent.Name
+		tags["job.agent.hostname"] = je.Job.Agent.Hostname
+	}
+	return tags
+}
@@ -0,0 +1,219 @@
+// +build service
+
+package buildkite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/tracers"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+)
+
+var baseURL string
+var buildkitePath string
+
+var urlEnvVar = "TEST_EVENTS_URL"
+var buildkitePathEnvVar = "TEST_EVENTS_BUILDKITE_PATH"
+
+func init() {
+	ok := true
+	baseURL, ok = os.LookupEnv(urlEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", urlEnvVar))
+	}
+	buildkitePath, ok = os.LookupEnv(buildkitePathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", buildkitePathEnvVar))
+	}
+}
+
+type expectedSpan struct {
+	OperationName string
+	Tags          map[string]interface{}
+}
+
+func buildTags(overrides map[string]interface{}) map[string]interface{} {
+	tags := map[string]interface{}{
+		"build.creator.name": "Keith Pitt",
+		"build.id":           "f62a1b4d-10f9-4790-bc1c-e2c3a0c80983",
+		"build.message":      "Add buildkite source",
+		"build.number":       float64(14),
+		"build.source":       "webhook",
+		"build.state":        "passed",
+		"build.url":          "https://buildkite.com/impactinsights/valuestream/builds/14",
+		"error":              false,
+		"pipeline.name":      "valuestream",
+		"pipeline.slug":      "valuestream",
+		"scm.branch":         "vstrace-github-pull_request-valuestream-8-buildkite",
+		"scm.head.sha":       "a65572555600c07c7ee79a1bd29fc1b2b6b1c2f5",
+		"scm.url":            "git@github.com:ImpactInsights/valuestream.git",
+		"service":            "buildkite",
+	}
+	for k, v := range overrides {
+		tags[k] = v
+	}
+	return tags
+}
+
+var eventTests = []struct {
+	Name          string
+	EventPaths    []string
+	ExpectedSpans []expectedSpan
+}{
+	{
+		Name: "build_passed",
+		EventPaths: []string{
+			"fixtures/events/build/scheduled.json",
+			"fixtures/events/build/running.json",
+			"fixtures/events/build/passed.json",
+		},
+		ExpectedSpans: []expectedSpan{
+			{OperationName: "build", Tags: buildTags(nil)},
+		},
+	},
+	{
+		Name: "build_failed",
+		EventPaths: []string{
+			"fixtures/events/build/scheduled.json",
+			"fixtures/events/build/running.json",
+			"fixtures/events/build/failed.json",
+		},
+		ExpectedSpans: []expectedSpan{
+			{
+				OperationName: "build",
+				Tags: buildTags(map[string]interface{}{
+					"build.state": "failed",
+					"error":       true,
+				}),
+			},
+		},
+	},
+	{
+		Name: "build_finished_only",
+		EventPaths: []string{
+			"fixtures/events/build/passed.json",
+		},
+		ExpectedSpans: []expectedSpan{
+			{OperationName: "build", Tags: buildTags(nil)},
+		},
+	},
+	{
+		Name: "build_with_job",
+		EventPaths: []string{
+			"fixtures/events/build/scheduled.json",
+			"fixtures/events/build/running.json",
+			"fixtures/events/job/scheduled.json",
+			"fixtures/events/job/started.json",
+			"fixtures/events/job/finished.json",
+			"fixtures/events/build/passed.json",
+		},
+		ExpectedSpans: []expectedSpan{
+			{
+				OperationName: "job",
+				Tags: map[string]interface{}{
+					"build.id":           "f62a1b4d-10f9-4790-bc1c-e2c3a0c80983",
+					"build.number":       float64(14),
+					"error":              false,
+					"job.agent.hostname": "ci-agent-1.internal",
+					"job.agent.name":     "ci-agent-1",
+					"job.exit_status":    float64(0),
+					"job.id":             "0b461f65-e7be-4c80-888a-ef11d81fd971",
+					"job.name":           ":go: test",
+					"job.soft_failed":    false,
+					"job.state":          "passed",
+					"job.step_key":       "test",
+					"job.url":            "https://buildkite.com/impactinsights/valuestream/builds/14#0b461f65-e7be-4c80-888a-ef11d81fd971",
+					"pipeline.slug":      "valuestream",
+					"service":            "buildkite",
+				},
+			},
+			{OperationName: "build", Tags: buildTags(nil)},
+		},
+	},
+	{
+		Name: "deploy_passed",
+		EventPaths: []string{
+			"fixtures/events/deploy/running.json",
+			"fixtures/events/deploy/passed.json",
+		},
+		ExpectedSpans: []expectedSpan{
+			{
+				OperationName: "deploy",
+				Tags: buildTags(map[string]interface{}{
+					"build.meta_data.type": "deploy",
+					"build.number":         float64(15),
+					"build.url":            "https://buildkite.com/impactinsights/valuestream/builds/15",
+				}),
+			},
+		},
+	},
+}
+
+func TestServiceEvent_Buildkite(t *testing.T) {
+	client := &http.Client{}
+	u, err := url.Parse(baseURL + buildkitePath)
+	assert.NoError(t, err)
+
+	for _, tt := range eventTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			// reset the tracer
+			resp, err := http.Get(baseURL + "/mocktracer/reset")
+			assert.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			for _, eventPath := range tt.EventPaths {
+				te, err := eventsources.NewTestEventFromFixturePath(eventPath)
+				assert.NoError(t, err)
+
+				rawPayload, err := json.Marshal(te.Payload)
+				assert.NoError(t, err)
+
+				req, err := http.NewRequest("POST", u.String(), bytes.NewReader(rawPayload))
+				assert.NoError(t, err)
+				for k, v := range te.Headers {
+					req.Header.Set(k, v)
+				}
+
+				eventResp, err := client.Do(req)
+				assert.NoError(t, err)
+				eventResp.Body.Close()
+				assert.Equal(t, http.StatusOK, eventResp.StatusCode, eventPath)
+			}
+
+			spansResp, err := http.Get(baseURL + "/mocktracer/finished-spans")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, spansResp.StatusCode)
+
+			bs, err := ioutil.ReadAll(spansResp.Body)
+			assert.NoError(t, err)
+			spansResp.Body.Close()
+
+			var spans []tracers.TestSpan
+
+			err = json.Unmarshal(bs, &spans)
+			assert.NoError(t, err)
+
+			assert.Equal(t, len(tt.ExpectedSpans), len(spans))
+			if len(spans) != len(tt.ExpectedSpans) {
+				t.FailNow()
+			}
+
+			build := spans[len(spans)-1]
+			for i, expected := range tt.ExpectedSpans {
+				assert.Equal(t, expected.OperationName, spans[i].Span.OperationName)
+				assert.Equal(t, expected.Tags, spans[i].Tags)
+				if expected.OperationName == "job" {
+					assert.Equal(t, build.Span.SpanContext.SpanID, spans[i].Span.ParentID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package buildkite
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBuildEvent_State(t *testing.T) {
+	started := eventsources.EventState(eventsources.StartState)
+
+	testCases := []struct {
+		name     string
+		event    string
+		prev     *eventsources.EventState
+		expected eventsources.SpanState
+	}{
+		{"scheduled", eventBuildScheduled, nil, eventsources.StartState},
+		{"running", eventBuildRunning, &started, eventsources.IntermediaryState},
+		{"running_not_scheduled", eventBuildRunning, nil, eventsources.StartState},
+		{"finished", eventBuildFinished, &started, eventsources.EndState},
+		{"finished_not_started", eventBuildFinished, nil, eventsources.CompleteState},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := BuildEvent{Event: tt.event}.State(tt.prev)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, s)
+		})
+	}
+}
+
+func TestBuildEvent_OperationName(t *testing.T) {
+	assert.Equal(t, "build", BuildEvent{}.OperationName())
+
+	be := BuildEvent{Build: Build{MetaData: map[string]string{"type": "deploy"}}}
+	assert.Equal(t, "deploy", be.OperationName())
+}
+
+func TestBuildEvent_ParentSpanID(t *testing.T) {
+	testCases := []struct {
+		name     string
+		build    Build
+		expected *string
+	}{
+		{"no_trace", Build{Branch: "master"}, nil},
+		{
+			"branch",
+			Build{Branch: "vstrace-github-pull_request-valuestream-8-buildkite"},
+			strPtr("vstrace-github-pull_request-valuestream-8-buildkite"),
+		},
+		{
+			"meta_data",
+			Build{
+				Branch:   "master",
+				MetaData: map[string]string{"vstrace-trace-id": "vstrace-jenkins-build-upstream-1"},
+			},
+			strPtr("vstrace-jenkins-build-upstream-1"),
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			parentID, err := eventsources.ParentSpanID(BuildEvent{Build: tt.build})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, parentID)
+		})
+	}
+}
+
+func TestJobEvent_State_IgnoresNonCommandSteps(t *testing.T) {
+	je := JobEvent{Event: eventJobFinished, Job: Job{Type: "waiter"}}
+	s, err := je.State(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, eventsources.UnknownState, s)
+}
+
+func TestJobEvent_IsError(t *testing.T) {
+	exit := 1
+
+	isErr, err := JobEvent{Job: Job{State: "passed", ExitStatus: &exit, SoftFailed: true}}.IsError()
+	assert.NoError(t, err)
+	assert.False(t, isErr)
+
+	isErr, err = JobEvent{Job: Job{State: "failed", ExitStatus: &exit}}.IsError()
+	assert.NoError(t, err)
+	assert.True(t, isErr)
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,146 @@
+package buildkite
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/traces"
+	"github.com/opentracing/opentracing-go"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	sourceName string = "buildkite"
+
+	eventHeader     string = "X-Buildkite-Event"
+	tokenHeader     string = "X-Buildkite-Token"
+	signatureHeader string = "X-Buildkite-Signature"
+)
+
+var signature = eventsources.HMACSignature{
+	Hash:   sha256.New,
+	Header: signatureHeader,
+	Prefix: "signature=",
+}
+
+var token = eventsources.SecretToken{Header: tokenHeader}
+
+type Source struct {
+	tracer     opentracing.Tracer
+	secretKey  []byte
+	references traces.References
+}
+
+func (s Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *Source) SecretKey() []byte {
+	return s.secretKey
+}
+
+// ValidatePayload checks the webhook's token, sent in the
+// `X-Buildkite-Token` header, or when the webhook is configured to sign
+// its payloads the `X-Buildkite-Signature` header:
+// `timestamp=<unix time>,signature=<hex hmac of "<timestamp>.<body>">`
+func (s *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body")
+	}
+
+	if secretKey == nil {
+		return body, nil
+	}
+
+	if err := validateRequest(r, body, secretKey); err != nil {
+		return nil, eventsources.InvalidSignatureError{Err: err}
+	}
+
+	return body, nil
+}
+
+func validateRequest(r *http.Request, body []byte, secretKey []byte) error {
+	if sig := r.Header.Get(signatureHeader); sig != "" {
+		return validateSignature(sig, body, secretKey)
+	}
+
+	return token.Validate(r, secretKey)
+}
+
+func validateSignature(header string, body []byte, secretKey []byte) error {
+	var timestamp string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) == 2 && kv[0] == "timestamp" {
+			timestamp = kv[1]
+		}
+	}
+
+	if timestamp == "" {
+		return fmt.Errorf("unsupported signature: %q", header)
+	}
+
+	return signature.Verify(header, append([]byte(timestamp+"."), body...), secretKey)
+}
+
+// Event handles the `build.*` and `job.*` events, which are identified by
+// the `X-Buildkite-Event` header, or the event named in the payload.
+func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
+	var e struct {
+		Event string `json:"event"`
+	}
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, err
+	}
+
+	event := e.Event
+	if r != nil && r.Header.Get(eventHeader) != "" {
+		event = r.Header.Get(eventHeader)
+	}
+
+	switch event {
+	case eventBuildScheduled, eventBuildRunning, eventBuildFinished:
+		be := BuildEvent{}
+		err := json.Unmarshal(payload, &be)
+		be.Event = event
+		be.references = s.references
+		return be, err
+	case eventJobScheduled, eventJobStarted, eventJobFinished:
+		je := JobEvent{}
+		err := json.Unmarshal(payload, &je)
+		je.Event = event
+		return je, err
+	}
+
+	return nil, fmt.Errorf("event: %q, not supported", event)
+}
+
+func NewSource(tracer opentracing.Tracer, secretKey []byte, references traces.References) (*Source, error) {
+	return &Source{
+		tracer:     tracer,
+		secretKey:  secretKey,
+		references: references,
+	}, nil
+}
+
+func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
+	var secretKey []byte
+	if token := c.String("buildkite-token"); token != "" {
+		secretKey = []byte(token)
+	}
+
+	references, err := traces.ReferencesFromCLI(c)
+	if err != nil {
+		return nil, err
+	}
+	return NewSource(tracer, secretKey, references)
+}
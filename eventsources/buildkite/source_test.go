@@ -0,0 +1,73 @@
+package buildkite
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestSource_ValidatePayload(t *testing.T) {
+	secretKey := []byte("secret")
+	body := []byte(`{"event": "build.running"}`)
+
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write([]byte("1630576800."))
+	mac.Write(body)
+	signature := "timestamp=1630576800,signature=" + hex.EncodeToString(mac.Sum(nil))
+
+	testCases := []struct {
+		name        string
+		headers     map[string]string
+		secretKey   []byte
+		expectedErr bool
+	}{
+		{"token", map[string]string{tokenHeader: "secret"}, secretKey, false},
+		{"signed", map[string]string{signatureHeader: signature}, secretKey, false},
+		{"unsigned", nil, secretKey, true},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/buildkite", bytes.NewReader(body))
+			assert.NoError(t, err)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			s, err := NewSource(nil, tt.secretKey, nil)
+			assert.NoError(t, err)
+
+			payload, err := s.ValidatePayload(req, s.SecretKey())
+			if tt.expectedErr {
+				assert.IsType(t, eventsources.InvalidSignatureError{}, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, body, payload)
+		})
+	}
+}
+
+func TestSource_Event(t *testing.T) {
+	s, err := NewSource(nil, nil, nil)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("POST", "/buildkite", nil)
+	assert.NoError(t, err)
+	req.Header.Set(eventHeader, eventJobStarted)
+
+	e, err := s.Event(req, []byte(`{"event": "job.started"}`))
+	assert.NoError(t, err)
+	assert.IsType(t, JobEvent{}, e)
+
+	e, err = s.Event(nil, []byte(`{"event": "build.finished"}`))
+	assert.NoError(t, err)
+	assert.IsType(t, BuildEvent{}, e)
+
+	_, err = s.Event(nil, []byte(`{"event": "ping"}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,259 @@
+package circleci
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"github.com/ImpactInsights/valuestream/traces"
+	"strings"
+	"time"
+)
+
+const (
+	eventTypeWorkflowCompleted string = "workflow-completed"
+	eventTypeJobCompleted      string = "job-completed"
+)
+
+type Event struct {
+	Type       string    `json:"type"`
+	ID         string    `json:"id"`
+	HappenedAt time.Time `json:"happened_at"`
+}
+
+type Project struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type Person struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type Commit struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	Author  Person `json:"author"`
+}
+
+type VCS struct {
+	ProviderName        string  `json:"provider_name"`
+	OriginRepositoryURL string  `json:"origin_repository_url"`
+	TargetRepositoryURL string  `json:"target_repository_url"`
+	Revision            string  `json:"revision"`
+	Branch              string  `json:"branch"`
+	Tag                 string  `json:"tag"`
+	Commit              *Commit `json:"commit"`
+}
+
+type Pipeline struct {
+	ID        string    `json:"id"`
+	Number    int       `json:"number"`
+	CreatedAt time.Time `json:"created_at"`
+	VCS       *VCS      `json:"vcs"`
+}
+
+type Workflow struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt *time.Time `json:"created_at"`
+	StoppedAt *time.Time `json:"stopped_at"`
+	URL       string     `json:"url"`
+	Status    string     `json:"status"`
+}
+
+type Job struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Number    int        `json:"number"`
+	StartedAt *time.Time `json:"started_at"`
+	StoppedAt *time.Time `json:"stopped_at"`
+	Status    string     `json:"status"`
+}
+
+func isError(status string) bool {
+	switch status {
+	case "failed", "error", "canceled":
+		return true
+	}
+	return false
+}
+
+func spanID(eventType string, project Project, id string) string {
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		eventType,
+		project.Name,
+		id,
+	}, "-")
+}
+
+// timings places a span retroactively, circleci only sends events once a
+// workflow or job has completed.
+func timings(start, stop *time.Time) eventsources.EventTimings {
+	t := eventsources.EventTimings{
+		StartTime: start,
+		EndTime:   stop,
+	}
+	if start != nil && stop != nil {
+		d := stop.Sub(*start)
+		t.Duration = &d
+	}
+	return t
+}
+
+func vcsTags(tags map[string]interface{}, vcs *VCS) {
+	if vcs == nil {
+		return
+	}
+	tags["scm.provider"] = vcs.ProviderName
+	tags["scm.url"] = vcs.OriginRepositoryURL
+	tags["scm.head.sha"] = vcs.Revision
+	if vcs.Branch != "" {
+		tags["scm.branch"] = vcs.Branch
+	}
+	if vcs.Tag != "" {
+		tags["scm.tag"] = vcs.Tag
+	}
+	if vcs.Commit != nil {
+		tags["scm.commit.subject"] = vcs.Commit.Subject
+		tags["scm.commit.author.name"] = vcs.Commit.Author.Name
+	}
+}
+
+// WorkflowEvent is a completed workflow, which is traced as a build with
+// the workflow's jobs as its children.
+type WorkflowEvent struct {
+	Event
+	Workflow Workflow `json:"workflow"`
+	Pipeline Pipeline `json:"pipeline"`
+	Project  Project  `json:"project"`
+
+	jobs       []JobEvent
+	references traces.References
+}
+
+func (we WorkflowEvent) SpanID() (string, error) {
+	return spanID(types.BuildEventType, we.Project, we.Workflow.ID), nil
+}
+
+func (we WorkflowEvent) OperationName() string {
+	return types.BuildEventType
+}
+
+// References links the workflow to the pull request which triggered it,
+// through a trace id either in the name of its branch or its commit.
+func (we WorkflowEvent) References() ([]eventsources.Reference, error) {
+	vcs := we.Pipeline.VCS
+	if vcs == nil {
+		return nil, nil
+	}
+
+	candidates := []string{vcs.Branch}
+	if vcs.Commit != nil {
+		candidates = append(candidates, vcs.Commit.Subject, vcs.Commit.Body)
+	}
+
+	vars := map[string]string{
+		"project": we.Project.Name,
+	}
+	return eventsources.ChildOf(we.references.First(sourceName, vars, candidates...)), nil
+}
+
+func (we WorkflowEvent) IsError() (bool, error) {
+	return isError(we.Workflow.Status), nil
+}
+
+func (we WorkflowEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	return eventsources.CompleteState, nil
+}
+
+func (we WorkflowEvent) Timings() (eventsources.EventTimings, error) {
+	return timings(we.Workflow.CreatedAt, we.Workflow.StoppedAt), nil
+}
+
+func (we WorkflowEvent) Tags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+	tags["build.id"] = we.Workflow.ID
+	tags["build.name"] = we.Workflow.Name
+	tags["build.status"] = we.Workflow.Status
+	tags["build.url"] = we.Workflow.URL
+	tags["pipeline.id"] = we.Pipeline.ID
+	tags["pipeline.number"] = we.Pipeline.Number
+	tags["project.name"] = we.Project.Name
+	tags["project.slug"] = we.Project.Slug
+	vcsTags(tags, we.Pipeline.VCS)
+	return tags, nil
+}
+
+// Children are the jobs of the workflow that completed before it did.
+func (we WorkflowEvent) Children() ([]eventsources.Event, error) {
+	children := make([]eventsources.Event, 0, len(we.jobs))
+	for _, je := range we.jobs {
+		children = append(children, je)
+	}
+	return children, nil
+}
+
+// JobEvent is a completed job.  Jobs complete before their workflow
+// so they're held back until the workflow's span is created.  Jobs which
+// are delivered late, after their workflow completed, are traced beneath
+// the span their workflow was nested beneath.
+type JobEvent struct {
+	Event
+	Job      Job      `json:"job"`
+	Workflow Workflow `json:"workflow"`
+	Pipeline Pipeline `json:"pipeline"`
+	Project  Project  `json:"project"`
+
+	pending          bool
+	late             bool
+	workflowParentID *string
+}
+
+func (je JobEvent) SpanID() (string, error) {
+	return spanID(types.JobEventType, je.Project, je.Job.ID), nil
+}
+
+func (je JobEvent) OperationName() string {
+	return types.JobEventType
+}
+
+func (je JobEvent) References() ([]eventsources.Reference, error) {
+	if je.late {
+		return eventsources.ChildOf(je.workflowParentID), nil
+	}
+	id := spanID(types.BuildEventType, je.Project, je.Workflow.ID)
+	return eventsources.ChildOf(&id), nil
+}
+
+func (je JobEvent) IsError() (bool, error) {
+	return isError(je.Job.Status), nil
+}
+
+func (je JobEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	if je.pending {
+		return eventsources.UnknownState, nil
+	}
+	return eventsources.CompleteState, nil
+}
+
+func (je JobEvent) Timings() (eventsources.EventTimings, error) {
+	return timings(je.Job.StartedAt, je.Job.StoppedAt), nil
+}
+
+func (je JobEvent) Tags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+	tags["job.id"] = je.Job.ID
+	tags["job.name"] = je.Job.Name
+	tags["job.number"] = je.Job.Number
+	tags["job.status"] = je.Job.Status
+	tags["build.id"] = je.Workflow.ID
+	tags["build.name"] = je.Workflow.Name
+	tags["project.name"] = je.Project.Name
+	tags["project.slug"] = je.Project.Slug
+	return tags, nil
+}
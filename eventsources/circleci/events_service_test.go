@@ -0,0 +1,207 @@
+// +build service
+
+package circleci
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/tracers"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+)
+
+var baseURL string
+var circleciPath string
+
+var urlEnvVar = "TEST_EVENTS_URL"
+var circleciPathEnvVar = "TEST_EVENTS_CIRCLECI_PATH"
+
+func init() {
+	ok := true
+	baseURL, ok = os.LookupEnv(urlEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", urlEnvVar))
+	}
+	circleciPath, ok = os.LookupEnv(circleciPathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", circleciPathEnvVar))
+	}
+}
+
+type expectedSpan struct {
+	OperationName string
+	Tags          map[string]interface{}
+}
+
+var workflowTags = map[string]interface{}{
+	"build.id":               "fda08377-fe7e-46b1-8992-3a7aaecac9c3",
+	"build.name":             "build-and-test",
+	"build.status":           "success",
+	"build.url":              "https://app.circleci.com/pipelines/github/ImpactInsights/valuestream/130/workflows/fda08377-fe7e-46b1-8992-3a7aaecac9c3",
+	"error":                  false,
+	"pipeline.id":            "1285fe1d-d3a6-44fc-8886-8979558254c4",
+	"pipeline.number":        float64(130),
+	"project.name":           "valuestream",
+	"project.slug":           "github/ImpactInsights/valuestream",
+	"scm.branch":             "vstrace-github-pull_request-valuestream-7-feature",
+	"scm.commit.author.name": "Author Name",
+	"scm.commit.subject":     "Add circleci source",
+	"scm.head.sha":           "1dc6aa69429bff4806ad6afe58d3d8f57e25973e",
+	"scm.provider":           "github",
+	"scm.url":                "https://github.com/ImpactInsights/valuestream",
+	"service":                "circleci",
+}
+
+func failedWorkflowTags() map[string]interface{} {
+	tags := make(map[string]interface{})
+	for k, v := range workflowTags {
+		tags[k] = v
+	}
+	tags["build.id"] = "8bd2ab27-0b9a-4ad9-9d4e-e5a0f1c6bbd4"
+	tags["build.status"] = "failed"
+	tags["build.url"] = "https://app.circleci.com/pipelines/github/ImpactInsights/valuestream/130/workflows/8bd2ab27-0b9a-4ad9-9d4e-e5a0f1c6bbd4"
+	tags["error"] = true
+	return tags
+}
+
+var eventTests = []struct {
+	Name          string
+	EventPaths    []string
+	ExpectedSpans []expectedSpan
+}{
+	{
+		Name: "workflow_success",
+		EventPaths: []string{
+			"fixtures/events/success/job_test.json",
+			"fixtures/events/success/job_deploy.json",
+			"fixtures/events/success/workflow.json",
+		},
+		ExpectedSpans: []expectedSpan{
+			{
+				OperationName: "job",
+				Tags: map[string]interface{}{
+					"build.id":     "fda08377-fe7e-46b1-8992-3a7aaecac9c3",
+					"build.name":   "build-and-test",
+					"error":        false,
+					"job.id":       "f8b1a3c4-0d6e-4b7a-9c2f-5e1d3a7b9c01",
+					"job.name":     "test",
+					"job.number":   float64(101),
+					"job.status":   "success",
+					"project.name": "valuestream",
+					"project.slug": "github/ImpactInsights/valuestream",
+					"service":      "circleci",
+				},
+			},
+			{
+				OperationName: "job",
+				Tags: map[string]interface{}{
+					"build.id":     "fda08377-fe7e-46b1-8992-3a7aaecac9c3",
+					"build.name":   "build-and-test",
+					"error":        false,
+					"job.id":       "f8b1a3c4-0d6e-4b7a-9c2f-5e1d3a7b9c02",
+					"job.name":     "deploy",
+					"job.number":   float64(102),
+					"job.status":   "success",
+					"project.name": "valuestream",
+					"project.slug": "github/ImpactInsights/valuestream",
+					"service":      "circleci",
+				},
+			},
+			{
+				OperationName: "build",
+				Tags:          workflowTags,
+			},
+		},
+	},
+	{
+		Name: "workflow_failed",
+		EventPaths: []string{
+			"fixtures/events/failed/job_test.json",
+			"fixtures/events/failed/workflow.json",
+		},
+		ExpectedSpans: []expectedSpan{
+			{
+				OperationName: "job",
+				Tags: map[string]interface{}{
+					"build.id":     "8bd2ab27-0b9a-4ad9-9d4e-e5a0f1c6bbd4",
+					"build.name":   "build-and-test",
+					"error":        true,
+					"job.id":       "f8b1a3c4-0d6e-4b7a-9c2f-5e1d3a7b9c03",
+					"job.name":     "test",
+					"job.number":   float64(101),
+					"job.status":   "failed",
+					"project.name": "valuestream",
+					"project.slug": "github/ImpactInsights/valuestream",
+					"service":      "circleci",
+				},
+			},
+			{
+				OperationName: "build",
+				Tags:          failedWorkflowTags(),
+			},
+		},
+	},
+}
+
+func TestServiceEvent_CircleCI(t *testing.T) {
+	client := &http.Client{}
+	u, err := url.Parse(baseURL + circleciPath)
+	assert.NoError(t, err)
+
+	for _, tt := range eventTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			// reset the tracer
+			resp, err := http.Get(baseURL + "/mocktracer/reset")
+			assert.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			for _, eventPath := range tt.EventPaths {
+				te, err := eventsources.NewTestEventFromFixturePath(eventPath)
+				assert.NoError(t, err)
+
+				rawPayload, err := json.Marshal(te.Payload)
+				assert.NoError(t, err)
+
+				eventResp, err := client.Post(u.String(), "application/json", bytes.NewReader(rawPayload))
+				assert.NoError(t, err)
+				eventResp.Body.Close()
+				assert.Equal(t, http.StatusOK, eventResp.StatusCode, eventPath)
+			}
+
+			spansResp, err := http.Get(baseURL + "/mocktracer/finished-spans")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, spansResp.StatusCode)
+
+			bs, err := ioutil.ReadAll(spansResp.Body)
+			assert.NoError(t, err)
+			spansResp.Body.Close()
+
+			var spans []tracers.TestSpan
+
+			err = json.Unmarshal(bs, &spans)
+			assert.NoError(t, err)
+
+			// jobs are finished before the workflow's build span
+			assert.Equal(t, len(tt.ExpectedSpans), len(spans))
+			if len(spans) != len(tt.ExpectedSpans) {
+				t.FailNow()
+			}
+
+			build := spans[len(spans)-1]
+			for i, expected := range tt.ExpectedSpans {
+				assert.Equal(t, expected.OperationName, spans[i].Span.OperationName)
+				assert.Equal(t, expected.Tags, spans[i].Tags)
+				if expected.OperationName == "job" {
+					assert.Equal(t, build.Span.SpanContext.SpanID, spans[i].Span.ParentID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package circleci
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWorkflowEvent_ParentSpanID(t *testing.T) {
+	testCases := []struct {
+		name     string
+		vcs      *VCS
+		expected *string
+	}{
+		{"no_vcs", nil, nil},
+		{"no_trace", &VCS{Branch: "master"}, nil},
+		{
+			"branch",
+			&VCS{Branch: "vstrace-github-pull_request-valuestream-7"},
+			strPtr("vstrace-github-pull_request-valuestream-7"),
+		},
+		{
+			"commit_body",
+			&VCS{
+				Branch: "feature",
+				Commit: &Commit{
+					Subject: "Add circleci source",
+					Body:    "Closes: vstrace-github-issue-valuestream-12",
+				},
+			},
+			strPtr("vstrace-github-issue-valuestream-12"),
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			we := WorkflowEvent{Pipeline: Pipeline{VCS: tt.vcs}}
+			parentID, err := eventsources.ParentSpanID(we)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, parentID)
+		})
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,104 @@
+package circleci
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxWorkflows int           = 1000
+	defaultMaxAge       time.Duration = 24 * time.Hour
+)
+
+type pendingWorkflow struct {
+	seenAt time.Time
+	jobs   []JobEvent
+
+	// completed workflows are remembered for the jobs delivered after
+	// them, which are nested beneath the workflow's parent
+	completed bool
+	parentID  *string
+}
+
+// pendingJobs holds the completed jobs of each workflow until the workflow
+// completes.  Only the last maxWorkflows workflows are held, for no longer
+// than maxAge, so the jobs of workflows whose completion is never received
+// are dropped.
+type pendingJobs struct {
+	mu           *sync.Mutex
+	workflows    map[string]*pendingWorkflow
+	order        []string
+	maxWorkflows int
+	maxAge       time.Duration
+	now          func() time.Time
+}
+
+// hold keeps a job until its workflow completes.  It's false when the
+// workflow already completed, along with the span the workflow was
+// nested beneath.
+func (p *pendingJobs) hold(je JobEvent) (bool, *string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	w := p.workflow(je.Workflow.ID)
+	if w.completed {
+		return false, w.parentID
+	}
+	w.jobs = append(w.jobs, je)
+	return true, nil
+}
+
+// release are the jobs held for a workflow as it completes.
+func (p *pendingJobs) release(workflowID string, parentID *string) []JobEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	w := p.workflow(workflowID)
+	jobs := w.jobs
+	w.jobs = nil
+	w.completed = true
+	w.parentID = parentID
+	return jobs
+}
+
+// len is the number of workflows held.
+func (p *pendingJobs) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.workflows)
+}
+
+// workflow is the workflow held by id, evicting the workflows held for
+// longer than maxAge, and the oldest beyond maxWorkflows; p.mu must be
+// held.
+func (p *pendingJobs) workflow(id string) *pendingWorkflow {
+	now := p.now()
+	for len(p.order) > 0 && now.Sub(p.workflows[p.order[0]].seenAt) > p.maxAge {
+		delete(p.workflows, p.order[0])
+		p.order = p.order[1:]
+	}
+
+	if w, ok := p.workflows[id]; ok {
+		return w
+	}
+
+	w := &pendingWorkflow{seenAt: now}
+	p.workflows[id] = w
+	p.order = append(p.order, id)
+
+	for len(p.order) > p.maxWorkflows {
+		delete(p.workflows, p.order[0])
+		p.order = p.order[1:]
+	}
+	return w
+}
+
+func newPendingJobs(maxWorkflows int, maxAge time.Duration) *pendingJobs {
+	return &pendingJobs{
+		mu:           &sync.Mutex{},
+		workflows:    make(map[string]*pendingWorkflow),
+		maxWorkflows: maxWorkflows,
+		maxAge:       maxAge,
+		now:          time.Now,
+	}
+}
@@ -0,0 +1,122 @@
+package circleci
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/traces"
+	"github.com/opentracing/opentracing-go"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	sourceName string = "circleci"
+
+	signatureHeader  string = "circleci-signature"
+	signatureVersion string = "v1="
+)
+
+var signature = eventsources.HMACSignature{
+	Hash:   sha256.New,
+	Header: signatureHeader,
+	Prefix: signatureVersion,
+}
+
+type Source struct {
+	tracer     opentracing.Tracer
+	secretKey  []byte
+	references traces.References
+
+	pending *pendingJobs
+}
+
+func (s Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *Source) SecretKey() []byte {
+	return s.secretKey
+}
+
+// ValidatePayload checks the `circleci-signature` header, which holds one or
+// more comma separated signatures: `v1=<hex encoded hmac of the body>`.
+func (s *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body")
+	}
+
+	if secretKey == nil {
+		return body, nil
+	}
+
+	if err := signature.Validate(r, body, secretKey); err != nil {
+		return nil, eventsources.InvalidSignatureError{Err: err}
+	}
+
+	return body, nil
+}
+
+func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
+	var e Event
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, err
+	}
+
+	switch e.Type {
+	case eventTypeJobCompleted:
+		var je JobEvent
+		if err := json.Unmarshal(payload, &je); err != nil {
+			return nil, err
+		}
+		// jobs delivered after their workflow completed are traced
+		// straight away, beneath what their workflow was traced beneath
+		je.pending, je.workflowParentID = s.pending.hold(je)
+		je.late = !je.pending
+		return je, nil
+
+	case eventTypeWorkflowCompleted:
+		var we WorkflowEvent
+		if err := json.Unmarshal(payload, &we); err != nil {
+			return nil, err
+		}
+		we.references = s.references
+		parentID, err := eventsources.ParentSpanID(we)
+		if err != nil {
+			return nil, err
+		}
+		we.jobs = s.pending.release(we.Workflow.ID, parentID)
+		return we, nil
+	}
+
+	return nil, fmt.Errorf("event type: %q, not supported", e.Type)
+}
+
+func NewSource(tracer opentracing.Tracer, secretKey []byte, references traces.References) (*Source, error) {
+	return &Source{
+		tracer:     tracer,
+		secretKey:  secretKey,
+		references: references,
+		pending:    newPendingJobs(defaultMaxWorkflows, defaultMaxAge),
+	}, nil
+}
+
+func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
+	var secretKey []byte
+	if secret := c.String("circleci-secret"); secret != "" {
+		secretKey = []byte(secret)
+	}
+
+	references, err := traces.ReferencesFromCLI(c)
+	if err != nil {
+		return nil, err
+	}
+	return NewSource(tracer, secretKey, references)
+}
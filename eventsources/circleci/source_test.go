@@ -0,0 +1,159 @@
+package circleci
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSource_ValidatePayload(t *testing.T) {
+	secretKey := []byte("secret")
+	body := []byte(`{"type": "workflow-completed"}`)
+
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write(body)
+	signature := signatureVersion + hex.EncodeToString(mac.Sum(nil))
+
+	testCases := []struct {
+		name        string
+		signature   string
+		secretKey   []byte
+		expectedErr bool
+	}{
+		{"signed", signature, secretKey, false},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/circleci", bytes.NewReader(body))
+			assert.NoError(t, err)
+			if tt.signature != "" {
+				req.Header.Set(signatureHeader, tt.signature)
+			}
+
+			s, err := NewSource(nil, tt.secretKey, nil)
+			assert.NoError(t, err)
+
+			payload, err := s.ValidatePayload(req, s.SecretKey())
+			if tt.expectedErr {
+				assert.IsType(t, eventsources.InvalidSignatureError{}, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, body, payload)
+		})
+	}
+}
+
+func TestSource_Event_HoldsJobsUntilWorkflowCompletes(t *testing.T) {
+	s, err := NewSource(nil, nil, nil)
+	assert.NoError(t, err)
+
+	job, err := s.Event(nil, []byte(`{
+		"type": "job-completed",
+		"project": {"name": "valuestream"},
+		"workflow": {"id": "w1"},
+		"job": {"id": "j1", "status": "success"}
+	}`))
+	assert.NoError(t, err)
+
+	state, err := job.State(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, eventsources.UnknownState, state)
+
+	e, err := s.Event(nil, []byte(`{
+		"type": "workflow-completed",
+		"project": {"name": "valuestream"},
+		"workflow": {"id": "w1", "status": "success"}
+	}`))
+	assert.NoError(t, err)
+
+	children, err := e.(eventsources.ParentEvent).Children()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(children))
+
+	state, err = children[0].State(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, eventsources.CompleteState, state)
+
+	parentID, err := eventsources.ParentSpanID(children[0])
+	assert.NoError(t, err)
+	workflowID, err := e.SpanID()
+	assert.NoError(t, err)
+	assert.Equal(t, workflowID, *parentID)
+
+	jobs := s.pending.release("w1", nil)
+	assert.Equal(t, 0, len(jobs))
+}
+
+func TestSource_Event_JobAfterWorkflowCompletes(t *testing.T) {
+	s, err := NewSource(nil, nil, nil)
+	assert.NoError(t, err)
+
+	e, err := s.Event(nil, []byte(`{
+		"type": "workflow-completed",
+		"project": {"name": "valuestream"},
+		"workflow": {"id": "w1", "status": "success"},
+		"pipeline": {"vcs": {"branch": "feature/vstrace-github-pull_request-valuestream-1"}}
+	}`))
+	assert.NoError(t, err)
+
+	children, err := e.(eventsources.ParentEvent).Children()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(children))
+
+	job, err := s.Event(nil, []byte(`{
+		"type": "job-completed",
+		"project": {"name": "valuestream"},
+		"workflow": {"id": "w1"},
+		"job": {"id": "j1", "status": "success"}
+	}`))
+	assert.NoError(t, err)
+
+	state, err := job.State(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, eventsources.CompleteState, state)
+
+	workflowParentID, err := eventsources.ParentSpanID(e)
+	assert.NoError(t, err)
+	parentID, err := eventsources.ParentSpanID(job)
+	assert.NoError(t, err)
+	if assert.NotNil(t, parentID) {
+		assert.Equal(t, *workflowParentID, *parentID)
+	}
+}
+
+func TestPendingJobs_Evicts(t *testing.T) {
+	now := time.Date(2019, 11, 22, 17, 13, 0, 0, time.UTC)
+	p := newPendingJobs(2, time.Hour)
+	p.now = func() time.Time { return now }
+
+	hold := func(workflowID string) {
+		held, _ := p.hold(JobEvent{Workflow: Workflow{ID: workflowID}})
+		assert.True(t, held)
+	}
+
+	hold("w1")
+	hold("w2")
+	hold("w3")
+	assert.Equal(t, 2, p.len())
+	assert.Equal(t, 0, len(p.release("w1", nil)), "the oldest workflow is evicted")
+
+	now = now.Add(2 * time.Hour)
+	hold("w4")
+	assert.Equal(t, 1, p.len(), "workflows held beyond the max age are evicted")
+	assert.Equal(t, 1, len(p.release("w4", nil)))
+}
+
+func TestSource_Event_UnsupportedType(t *testing.T) {
+	s, err := NewSource(nil, nil, nil)
+	assert.NoError(t, err)
+
+	_, err = s.Event(nil, []byte(`{"type": "ping"}`))
+	assert.Error(t, err)
+}
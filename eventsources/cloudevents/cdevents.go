@@ -0,0 +1,200 @@
+package cloudevents
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/jsonpath"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"strings"
+	"sync"
+)
+
+const (
+	cdeventsTypePrefix string = "dev.cdevents."
+
+	// cdeventsParent is the field of an event's customData holding the span
+	// id of its parent.
+	cdeventsParent string = "data.customData.vstraceparent"
+
+	maxContexts int = 1000
+)
+
+// cdeventsType is the pattern matching every version of a cdevents type
+func cdeventsType(subject string, predicates ...string) string {
+	types := make([]string, len(predicates))
+	for i, p := range predicates {
+		types[i] = cdeventsTypePrefix + subject + "." + p + ".*"
+	}
+	return strings.Join(types, "|")
+}
+
+// CDEventsRules map the cdevents vocabulary onto spans, each span is
+// identified by the `subject.id` of its events.  They're tried after any
+// configured rules, which can override them.
+func CDEventsRules() Rules {
+	failed := map[string]string{
+		"data.subject.content.outcome": "failure|error",
+	}
+
+	rs := Rules{
+		{
+			Type:      cdeventsType("change", "created"),
+			Operation: types.PullRequestEventType,
+			State:     eventsources.StartState,
+			Tags: map[string]string{
+				"scm.repository": "data.subject.content.repository.id",
+			},
+		},
+		{
+			Type:      cdeventsType("change", "updated", "reviewed"),
+			Operation: types.PullRequestEventType,
+			State:     eventsources.StartState,
+		},
+		{
+			Type:      cdeventsType("change", "merged", "abandoned"),
+			Operation: types.PullRequestEventType,
+			State:     eventsources.EndState,
+		},
+		{
+			Type:      cdeventsType("build", "queued", "started"),
+			Operation: types.BuildEventType,
+			State:     eventsources.StartState,
+		},
+		{
+			Type:      cdeventsType("build", "finished"),
+			Operation: types.BuildEventType,
+			State:     eventsources.EndState,
+			Error:     failed,
+			Tags: map[string]string{
+				"build.artifact": "data.subject.content.artifactId",
+			},
+		},
+		{
+			Type:      cdeventsType("artifact", "packaged"),
+			Operation: types.ArtifactEventType,
+			State:     eventsources.StartState,
+		},
+		{
+			Type:      cdeventsType("artifact", "published"),
+			Operation: types.ArtifactEventType,
+			State:     eventsources.EndState,
+		},
+		{
+			Type:      cdeventsType("service", "deployed", "upgraded", "rolledback"),
+			Operation: types.DeployEventType,
+			State:     eventsources.EndState,
+			Service:   "data.subject.id",
+			Tags: map[string]string{
+				"deploy.environment": "data.subject.content.environment.id",
+				"deploy.artifact":    "data.subject.content.artifactId",
+			},
+		},
+		{
+			Type:      cdeventsType("incident", "detected", "reported"),
+			Operation: types.IncidentEventType,
+			State:     eventsources.StartState,
+			Service:   "data.subject.content.service.id",
+			Tags: map[string]string{
+				"incident.description": "data.subject.content.description",
+				"incident.environment": "data.subject.content.environment.id",
+			},
+		},
+		{
+			Type:      cdeventsType("incident", "resolved"),
+			Operation: types.IncidentEventType,
+			State:     eventsources.EndState,
+			Service:   "data.subject.content.service.id",
+		},
+	}
+
+	for i := range rs {
+		rs[i].ID = "data.subject.id"
+		rs[i].Parent = cdeventsParent
+		if rs[i].Tags == nil {
+			rs[i].Tags = make(map[string]string)
+		}
+		rs[i].Tags["cdevents.subject.source"] = "data.subject.source"
+	}
+
+	if err := rs.Compile(); err != nil {
+		panic(err)
+	}
+	return rs
+}
+
+func isCDEvent(ce CloudEvent) bool {
+	return strings.HasPrefix(ce.Type, cdeventsTypePrefix)
+}
+
+// cdeventsLinks are the links of a cdevent's data, each linking from or
+// to the context of another event.
+var (
+	cdeventsLinks      = jsonpath.MustCompile("$.links")
+	cdeventsLinkFrom   = jsonpath.MustCompile("$.from.context_id")
+	cdeventsLinkTarget = jsonpath.MustCompile("$.target.context_id")
+)
+
+// linkedContexts are the context ids of the events a cdevent links to, in
+// the order they're linked.
+func linkedContexts(ce CloudEvent) []string {
+	links, ok := cdeventsLinks.Select(ce.data)
+	if !ok {
+		return nil
+	}
+	ls, ok := links.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var ids []string
+	for _, l := range ls {
+		for _, p := range []*jsonpath.Path{cdeventsLinkFrom, cdeventsLinkTarget} {
+			if id, ok := p.Eval(l); ok && id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// contexts remembers the span of the most recent cdevents, so events
+// linking to them can be placed beneath it.
+type contexts struct {
+	mu    *sync.Mutex
+	spans map[string]string
+	order []string
+}
+
+func (c *contexts) set(contextID string, spanID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.spans[contextID]; !ok {
+		c.order = append(c.order, contextID)
+	}
+	c.spans[contextID] = spanID
+
+	if len(c.order) > maxContexts {
+		delete(c.spans, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// parent is the span of the first linked event which was seen.
+func (c *contexts) parent(contextIDs []string) *string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range contextIDs {
+		if spanID, ok := c.spans[id]; ok {
+			return &spanID
+		}
+	}
+	return nil
+}
+
+func newContexts() *contexts {
+	return &contexts{
+		mu:    &sync.Mutex{},
+		spans: make(map[string]string),
+	}
+}
@@ -0,0 +1,147 @@
+package cloudevents
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCDEventsRules(t *testing.T) {
+	rs := CDEventsRules()
+
+	testCases := []struct {
+		eventType string
+		operation string
+		state     eventsources.SpanState
+	}{
+		{"dev.cdevents.change.created.0.1.2", types.PullRequestEventType, eventsources.StartState},
+		{"dev.cdevents.change.merged.0.1.2", types.PullRequestEventType, eventsources.EndState},
+		{"dev.cdevents.build.queued.0.1.1", types.BuildEventType, eventsources.StartState},
+		{"dev.cdevents.build.started.0.1.1", types.BuildEventType, eventsources.StartState},
+		{"dev.cdevents.build.finished.0.1.1", types.BuildEventType, eventsources.EndState},
+		{"dev.cdevents.artifact.packaged.0.1.1", types.ArtifactEventType, eventsources.StartState},
+		{"dev.cdevents.artifact.published.0.1.1", types.ArtifactEventType, eventsources.EndState},
+		{"dev.cdevents.service.deployed.0.1.1", types.DeployEventType, eventsources.EndState},
+		{"dev.cdevents.service.upgraded.0.1.1", types.DeployEventType, eventsources.EndState},
+		{"dev.cdevents.service.rolledback.0.1.1", types.DeployEventType, eventsources.EndState},
+		{"dev.cdevents.incident.detected.0.1.0", types.IncidentEventType, eventsources.StartState},
+		{"dev.cdevents.incident.resolved.0.1.0", types.IncidentEventType, eventsources.EndState},
+	}
+	for _, tt := range testCases {
+		r, ok := rs.match(CloudEvent{Type: tt.eventType})
+		assert.True(t, ok, tt.eventType)
+		assert.Equal(t, tt.operation, r.Operation, tt.eventType)
+		assert.Equal(t, tt.state, r.State, tt.eventType)
+	}
+
+	_, ok := rs.match(CloudEvent{Type: "dev.cdevents.taskrun.started.0.1.1"})
+	assert.False(t, ok)
+}
+
+func TestCDEventsRules_Fields(t *testing.T) {
+	rs := CDEventsRules()
+
+	ce := decoded(CloudEvent{
+		ID:   "f81d4fae",
+		Type: "dev.cdevents.incident.detected.0.1.0",
+		Data: []byte(`{
+			"context": {"id": "f81d4fae", "type": "dev.cdevents.incident.detected.0.1.0"},
+			"subject": {"id": "incident-42", "source": "/monitoring", "content": {
+				"description": "checkout error rate above 5%",
+				"environment": {"id": "production"},
+				"service": {"id": "valuestream"}
+			}}
+		}`),
+	})
+	r, ok := rs.match(ce)
+	assert.True(t, ok)
+
+	e := Event{CloudEvent: ce, rule: &r}
+
+	id, err := e.SpanID()
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-cloudevents-incident-incident-42", id)
+	assert.Equal(t, "valuestream", e.ServiceName())
+
+	tags, err := e.Tags()
+	assert.NoError(t, err)
+	assert.Equal(t, "checkout error rate above 5%", tags["incident.description"])
+	assert.Equal(t, "production", tags["incident.environment"])
+	assert.Equal(t, "/monitoring", tags["cdevents.subject.source"])
+
+	incident, ok := incidentOf(e).(eventsources.IncidentEvent)
+	if assert.True(t, ok) {
+		assert.Equal(t, "production", incident.Environment())
+	}
+}
+
+func TestCDEventsRules_BuildFailed(t *testing.T) {
+	rs := CDEventsRules()
+
+	for outcome, expected := range map[string]bool{
+		"success": false,
+		"failure": true,
+		"error":   true,
+	} {
+		ce := decoded(CloudEvent{
+			Type: "dev.cdevents.build.finished.0.1.1",
+			Data: []byte(`{"subject": {"id": "1", "content": {"outcome": "` + outcome + `"}}}`),
+		})
+		r, ok := rs.match(ce)
+		assert.True(t, ok)
+
+		isErr, err := Event{CloudEvent: ce, rule: &r}.IsError()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, isErr, outcome)
+	}
+}
+
+func TestSource_event_CDEventsLinks(t *testing.T) {
+	s, err := NewSource(nil, nil, nil)
+	assert.NoError(t, err)
+
+	s.event(CloudEvent{
+		ID:   "build-started-1",
+		Type: "dev.cdevents.build.started.0.1.1",
+		Data: []byte(`{"subject": {"id": "build-1"}}`),
+	})
+
+	artifact := s.event(CloudEvent{
+		ID:   "artifact-packaged-1",
+		Type: "dev.cdevents.artifact.packaged.0.1.1",
+		Data: []byte(`{
+			"subject": {"id": "pkg:oci/valuestream@sha256:0b1c"},
+			"links": [{"link_type": "PATH", "from": {"context_id": "build-started-1"}}]
+		}`),
+	})
+	parent, err := eventsources.ParentSpanID(artifact)
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-cloudevents-build-build-1", *parent)
+
+	// customData takes precedence over links
+	deploy := s.event(CloudEvent{
+		ID:   "service-deployed-1",
+		Type: "dev.cdevents.service.deployed.0.1.1",
+		Data: []byte(`{
+			"subject": {"id": "valuestream"},
+			"customData": {"vstraceparent": "vstrace-github-pull_request-valuestream-12"},
+			"links": [{"link_type": "PATH", "from": {"context_id": "artifact-packaged-1"}}]
+		}`),
+	})
+	parent, err = eventsources.ParentSpanID(deploy)
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-github-pull_request-valuestream-12", *parent)
+
+	orphan := s.event(CloudEvent{
+		ID:   "service-deployed-2",
+		Type: "dev.cdevents.service.deployed.0.1.1",
+		Data: []byte(`{
+			"subject": {"id": "valuestream"},
+			"links": [{"link_type": "PATH", "from": {"context_id": "unknown"}}]
+		}`),
+	})
+	parent, err = eventsources.ParentSpanID(orphan)
+	assert.NoError(t, err)
+	assert.Nil(t, parent)
+}
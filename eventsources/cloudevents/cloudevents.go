@@ -0,0 +1,310 @@
+package cloudevents
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources/jsonpath"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	structuredContentType string = "application/cloudevents+json"
+	batchContentType      string = "application/cloudevents-batch+json"
+
+	// binaryHeaderPrefix prefixes the attributes of events sent in binary
+	// mode, ie `ce-type`, the data is the body of the request.
+	binaryHeaderPrefix string = "Ce-"
+
+	// dataPrefix prefixes references to a field of the event's data,
+	// ie `data.subject.id`
+	dataPrefix string = "data."
+)
+
+// CloudEvent holds the context attributes of a cloud event along with its
+// data.  Attributes which aren't part of the spec are its extensions.
+type CloudEvent struct {
+	SpecVersion     string
+	ID              string
+	Source          string
+	Type            string
+	Subject         string
+	DataContentType string
+	Time            *time.Time
+	Extensions      map[string]string
+	Data            []byte
+
+	// data is the decoded json Data, which fields are resolved against
+	data interface{}
+}
+
+var attributes = map[string]bool{
+	"specversion":     true,
+	"id":              true,
+	"source":          true,
+	"type":            true,
+	"subject":         true,
+	"datacontenttype": true,
+	"dataschema":      true,
+	"time":            true,
+	"data":            true,
+	"data_base64":     true,
+}
+
+func (ce CloudEvent) validate() error {
+	if ce.SpecVersion == "" {
+		return fmt.Errorf("event is missing specversion")
+	}
+	if ce.ID == "" || ce.Source == "" || ce.Type == "" {
+		return fmt.Errorf("event is missing one of id, source or type")
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes an event in the structured json format, where its
+// attributes and extensions share the top level of the object.
+func (ce *CloudEvent) UnmarshalJSON(bs []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(bs, &raw); err != nil {
+		return err
+	}
+
+	str := func(name string) (string, error) {
+		v, ok := raw[name]
+		if !ok {
+			return "", nil
+		}
+		return attributeString(v)
+	}
+
+	var err error
+	if ce.SpecVersion, err = str("specversion"); err != nil {
+		return err
+	}
+	if ce.ID, err = str("id"); err != nil {
+		return err
+	}
+	if ce.Source, err = str("source"); err != nil {
+		return err
+	}
+	if ce.Type, err = str("type"); err != nil {
+		return err
+	}
+	if ce.Subject, err = str("subject"); err != nil {
+		return err
+	}
+	if ce.DataContentType, err = str("datacontenttype"); err != nil {
+		return err
+	}
+
+	t, err := str("time")
+	if err != nil {
+		return err
+	}
+	if ce.Time, err = parseTime(t); err != nil {
+		return err
+	}
+
+	if data, ok := raw["data"]; ok {
+		ce.Data = data
+	}
+	if encoded, ok := raw["data_base64"]; ok {
+		s, err := attributeString(encoded)
+		if err != nil {
+			return err
+		}
+		if ce.Data, err = base64.StdEncoding.DecodeString(s); err != nil {
+			return fmt.Errorf("invalid data_base64: %s", err)
+		}
+	}
+
+	ce.Extensions = make(map[string]string)
+	for name, v := range raw {
+		if attributes[name] {
+			continue
+		}
+		if ce.Extensions[name], err = attributeString(v); err != nil {
+			return err
+		}
+	}
+
+	ce.decodeData()
+
+	return nil
+}
+
+// attributeString renders an attribute as a string, extensions may also
+// be integers or booleans.
+func attributeString(v json.RawMessage) (string, error) {
+	var i interface{}
+	if err := json.Unmarshal(v, &i); err != nil {
+		return "", err
+	}
+	switch t := i.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return t, nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	}
+	return "", fmt.Errorf("attribute: %s, is not a string, integer or boolean", string(v))
+}
+
+func parseTime(t string) (*time.Time, error) {
+	if t == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, t)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time: %q", t)
+	}
+	return &parsed, nil
+}
+
+// ParseRequest reads the cloud events of a request in any of the http
+// content modes:
+//   - structured: the event is the body, `application/cloudevents+json`
+//   - batch: the body is an array of structured events,
+//     `application/cloudevents-batch+json`
+//   - binary: the attributes are `ce-` headers and the body is the data
+func ParseRequest(h http.Header, body []byte) ([]CloudEvent, error) {
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+
+	switch {
+	case mediaType == batchContentType:
+		var ces []CloudEvent
+		if err := json.Unmarshal(body, &ces); err != nil {
+			return nil, err
+		}
+		for _, ce := range ces {
+			if err := ce.validate(); err != nil {
+				return nil, err
+			}
+		}
+		return ces, nil
+	case mediaType == structuredContentType:
+		var ce CloudEvent
+		if err := json.Unmarshal(body, &ce); err != nil {
+			return nil, err
+		}
+		if err := ce.validate(); err != nil {
+			return nil, err
+		}
+		return []CloudEvent{ce}, nil
+	case h.Get(binaryHeaderPrefix+"Specversion") != "":
+		ce, err := parseBinary(h, body)
+		if err != nil {
+			return nil, err
+		}
+		return []CloudEvent{ce}, nil
+	}
+
+	return nil, fmt.Errorf("request is not a cloud event, content type: %q", mediaType)
+}
+
+func parseBinary(h http.Header, body []byte) (CloudEvent, error) {
+	ce := CloudEvent{
+		DataContentType: h.Get("Content-Type"),
+		Extensions:      make(map[string]string),
+		Data:            body,
+	}
+
+	for k := range h {
+		if !strings.HasPrefix(k, binaryHeaderPrefix) {
+			continue
+		}
+
+		name := strings.ToLower(strings.TrimPrefix(k, binaryHeaderPrefix))
+		// header values are percent encoded
+		v, err := url.PathUnescape(h.Get(k))
+		if err != nil {
+			return CloudEvent{}, err
+		}
+
+		switch name {
+		case "specversion":
+			ce.SpecVersion = v
+		case "id":
+			ce.ID = v
+		case "source":
+			ce.Source = v
+		case "type":
+			ce.Type = v
+		case "subject":
+			ce.Subject = v
+		case "dataschema":
+		case "time":
+			if ce.Time, err = parseTime(v); err != nil {
+				return CloudEvent{}, err
+			}
+		default:
+			ce.Extensions[name] = v
+		}
+	}
+
+	ce.decodeData()
+	return ce, ce.validate()
+}
+
+// isJSON is true when the event's data can be referenced by rules.
+func (ce CloudEvent) isJSON() bool {
+	if ce.DataContentType == "" {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(ce.DataContentType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// decodeData decodes json Data once, so its fields can be resolved
+// without decoding it for every reference.
+func (ce *CloudEvent) decodeData() {
+	ce.data = nil
+	if !ce.isJSON() || len(ce.Data) == 0 {
+		return
+	}
+	if err := json.Unmarshal(ce.Data, &ce.data); err != nil {
+		ce.data = nil
+	}
+}
+
+// Field resolves a reference to an attribute, extension or field of the
+// event's json data, ie `subject`, `myextension` or `data.subject.id`.
+// Array elements of the data are referenced by their index.
+func (ce CloudEvent) Field(ref string) (string, bool) {
+	switch ref {
+	case "specversion":
+		return ce.SpecVersion, ce.SpecVersion != ""
+	case "id":
+		return ce.ID, ce.ID != ""
+	case "source":
+		return ce.Source, ce.Source != ""
+	case "type":
+		return ce.Type, ce.Type != ""
+	case "subject":
+		return ce.Subject, ce.Subject != ""
+	case "time":
+		if ce.Time == nil {
+			return "", false
+		}
+		return ce.Time.Format(time.RFC3339Nano), true
+	}
+
+	if !strings.HasPrefix(ref, dataPrefix) {
+		v, ok := ce.Extensions[ref]
+		return v, ok
+	}
+
+	path, err := jsonpath.Compile(jsonpath.Root + "." + strings.TrimPrefix(ref, dataPrefix))
+	if err != nil {
+		return "", false
+	}
+	return path.Eval(ce.data)
+}
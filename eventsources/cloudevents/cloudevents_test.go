@@ -0,0 +1,168 @@
+package cloudevents
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+const structuredEvent = `{
+	"specversion": "1.0",
+	"id": "271069a8",
+	"source": "/tekton/pipelines",
+	"type": "dev.tekton.event.pipelinerun.started.v1",
+	"subject": "build-valuestream-7x8kq",
+	"time": "2021-08-02T10:00:00Z",
+	"datacontenttype": "application/json",
+	"vstraceparent": "vstrace-github-pull_request-valuestream-12",
+	"attempt": 2,
+	"data": {"pipelineRun": {"metadata": {"name": "build-valuestream-7x8kq"}, "status": {"conditions": [{"reason": "Running"}]}}}
+}`
+
+// decoded is the event as it's parsed, with its data decoded.
+func decoded(ce CloudEvent) CloudEvent {
+	ce.decodeData()
+	return ce
+}
+
+func TestParseRequest(t *testing.T) {
+	testCases := []struct {
+		name     string
+		headers  map[string]string
+		body     string
+		expected []string
+		err      bool
+	}{
+		{
+			"structured",
+			map[string]string{"Content-Type": "application/cloudevents+json; charset=utf-8"},
+			structuredEvent,
+			[]string{"271069a8"},
+			false,
+		},
+		{
+			"batch",
+			map[string]string{"Content-Type": "application/cloudevents-batch+json"},
+			"[" + structuredEvent + "," + structuredEvent + "]",
+			[]string{"271069a8", "271069a8"},
+			false,
+		},
+		{
+			"binary",
+			map[string]string{
+				"Content-Type":   "application/json",
+				"Ce-Specversion": "1.0",
+				"Ce-Id":          "271069a9",
+				"Ce-Source":      "/tekton/pipelines",
+				"Ce-Type":        "dev.tekton.event.pipelinerun.successful.v1",
+			},
+			`{"pipelineRun": {}}`,
+			[]string{"271069a9"},
+			false,
+		},
+		{
+			"binary_missing_type",
+			map[string]string{
+				"Ce-Specversion": "1.0",
+				"Ce-Id":          "271069a9",
+				"Ce-Source":      "/tekton/pipelines",
+			},
+			`{}`,
+			nil,
+			true,
+		},
+		{
+			"not_a_cloud_event",
+			map[string]string{"Content-Type": "application/json"},
+			`{"id": "1"}`,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+
+			ces, err := ParseRequest(h, []byte(tt.body))
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			var ids []string
+			for _, ce := range ces {
+				ids = append(ids, ce.ID)
+			}
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+}
+
+func TestParseRequest_BinaryExtensions(t *testing.T) {
+	h := http.Header{}
+	h.Set("Ce-Specversion", "1.0")
+	h.Set("Ce-Id", "1")
+	h.Set("Ce-Source", "/ci")
+	h.Set("Ce-Type", "build.started")
+	h.Set("Ce-Subject", "main%2Fbuild%201")
+	h.Set("Ce-Time", "2021-08-02T10:00:00Z")
+	h.Set("Ce-Vstraceparent", "vstrace-github-issue-valuestream-1")
+
+	ces, err := ParseRequest(h, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(ces))
+	assert.Equal(t, "main/build 1", ces[0].Subject)
+	assert.Equal(t, int64(1627898400), ces[0].Time.Unix())
+	assert.Equal(t, map[string]string{
+		"vstraceparent": "vstrace-github-issue-valuestream-1",
+	}, ces[0].Extensions)
+}
+
+func TestCloudEvent_Field(t *testing.T) {
+	h := http.Header{}
+	h.Set("Content-Type", structuredContentType)
+	ces, err := ParseRequest(h, []byte(structuredEvent))
+	assert.NoError(t, err)
+	ce := ces[0]
+
+	testCases := []struct {
+		ref      string
+		expected string
+		ok       bool
+	}{
+		{"type", "dev.tekton.event.pipelinerun.started.v1", true},
+		{"subject", "build-valuestream-7x8kq", true},
+		{"time", "2021-08-02T10:00:00Z", true},
+		{"vstraceparent", "vstrace-github-pull_request-valuestream-12", true},
+		{"attempt", "2", true},
+		{"missing", "", false},
+		{"data.pipelineRun.metadata.name", "build-valuestream-7x8kq", true},
+		{"data.pipelineRun.status.conditions.0.reason", "Running", true},
+		{"data.pipelineRun.status.conditions.1.reason", "", false},
+		{"data.pipelineRun.metadata", `{"name":"build-valuestream-7x8kq"}`, true},
+		{"data.missing.name", "", false},
+	}
+	for _, tt := range testCases {
+		v, ok := ce.Field(tt.ref)
+		assert.Equal(t, tt.ok, ok, tt.ref)
+		assert.Equal(t, tt.expected, v, tt.ref)
+	}
+}
+
+func TestCloudEvent_Field_DataBase64(t *testing.T) {
+	h := http.Header{}
+	h.Set("Content-Type", structuredContentType)
+	ces, err := ParseRequest(h, []byte(`{
+		"specversion": "1.0", "id": "1", "source": "/ci", "type": "build.started",
+		"data_base64": "eyJuYW1lIjogImJ1aWxkIn0="
+	}`))
+	assert.NoError(t, err)
+
+	v, ok := ces[0].Field("data.name")
+	assert.True(t, ok)
+	assert.Equal(t, "build", v)
+}
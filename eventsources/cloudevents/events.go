@@ -0,0 +1,216 @@
+package cloudevents
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"strings"
+)
+
+const batchOperationName string = "batch"
+
+// Event is a cloud event mapped by the first rule it matched, events
+// without a rule are ignored.
+type Event struct {
+	CloudEvent CloudEvent
+	rule       *Rule
+
+	// linkedParent is the span of an event this event links to, it's
+	// used when the rule doesn't reference a parent.
+	linkedParent *string
+}
+
+func (e Event) field(ref string) string {
+	if ref == "" {
+		return ""
+	}
+	v, _ := e.CloudEvent.Field(ref)
+	return v
+}
+
+func (e Event) OperationName() string {
+	if e.rule == nil {
+		return e.CloudEvent.Type
+	}
+	return e.rule.Operation
+}
+
+func (e Event) SpanID() (string, error) {
+	id := e.CloudEvent.ID
+	if e.rule != nil {
+		var ok bool
+		if id, ok = e.CloudEvent.Field(e.rule.ID); !ok || id == "" {
+			return "", fmt.Errorf("event: %q does not contain a span id: %q", e.CloudEvent.ID, e.rule.ID)
+		}
+	}
+
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		e.OperationName(),
+		id,
+	}, "-"), nil
+}
+
+func (e Event) References() ([]eventsources.Reference, error) {
+	if e.rule == nil {
+		return nil, nil
+	}
+	if parent := e.field(e.rule.Parent); parent != "" {
+		return eventsources.ChildOf(&parent), nil
+	}
+	return eventsources.ChildOf(e.linkedParent), nil
+}
+
+// Revision is the commit the event was produced for, when its rule
+// references one.
+func (e Event) Revision() string {
+	if e.rule == nil {
+		return ""
+	}
+	return e.field(e.rule.Revision)
+}
+
+// ServiceName is the deployed service the event concerns, when its rule
+// references one.
+func (e Event) ServiceName() string {
+	if e.rule == nil {
+		return ""
+	}
+	return e.field(e.rule.Service)
+}
+
+// Incident is an event mapped to an incident or defect, it follows from
+// the most recent deploy of the service it concerns to the environment
+// named by its `incident.environment` or `defect.environment` tag.
+type Incident struct {
+	Event
+}
+
+func (i Incident) Environment() string {
+	return eventsources.TaggedEnvironment(i.ruleTags())
+}
+
+// incidentOf marks the incidents and defects, other spans concerning a
+// service don't follow from its deploys.
+func incidentOf(e Event) eventsources.Event {
+	switch e.OperationName() {
+	case types.IncidentEventType, types.DefectEventType:
+		return Incident{e}
+	}
+	return e
+}
+
+func (e Event) IsError() (bool, error) {
+	if e.rule == nil {
+		return false, nil
+	}
+	return e.rule.isError(e.CloudEvent), nil
+}
+
+func (e Event) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	if e.rule == nil {
+		return eventsources.UnknownState, nil
+	}
+	switch {
+	case e.rule.State == eventsources.StartState && prev != nil:
+		return eventsources.IntermediaryState, nil
+	case e.rule.State == eventsources.EndState && prev == nil:
+		return eventsources.CompleteState, nil
+	}
+	return e.rule.State, nil
+}
+
+func (e Event) Timings() (eventsources.EventTimings, error) {
+	t := e.CloudEvent.Time
+	if t == nil || e.rule == nil {
+		return eventsources.EventTimings{}, nil
+	}
+	switch e.rule.State {
+	case eventsources.EndState:
+		return eventsources.EventTimings{
+			StartTime: t,
+			EndTime:   t,
+		}, nil
+	case eventsources.StartState, eventsources.TransitionState:
+		return eventsources.EventTimings{
+			StartTime: t,
+		}, nil
+	}
+	return eventsources.EventTimings{}, nil
+}
+
+func (e Event) ruleTags() map[string]interface{} {
+	tags := make(map[string]interface{})
+	if e.rule == nil {
+		return tags
+	}
+	for name, ref := range e.rule.Tags {
+		if v, ok := e.CloudEvent.Field(ref); ok {
+			tags[name] = v
+		}
+	}
+	return tags
+}
+
+func (e Event) Tags() (map[string]interface{}, error) {
+	tags := e.ruleTags()
+	tags["service"] = sourceName
+	tags["cloudevents.source"] = e.CloudEvent.Source
+	tags["cloudevents.type"] = e.CloudEvent.Type
+	if e.CloudEvent.Subject != "" {
+		tags["cloudevents.subject"] = e.CloudEvent.Subject
+	}
+	return tags, nil
+}
+
+// EndTags are the rule's tags as of the event ending the span, along
+// with the type of that event.
+func (e Event) EndTags() (map[string]interface{}, error) {
+	tags := e.ruleTags()
+	tags["cloudevents.type"] = e.CloudEvent.Type
+	return tags, nil
+}
+
+// BatchEvent holds the events of a batch, they're handled in order as
+// its children.
+type BatchEvent struct {
+	Events []eventsources.Event
+}
+
+func (be BatchEvent) OperationName() string {
+	return batchOperationName
+}
+
+func (be BatchEvent) SpanID() (string, error) {
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		batchOperationName,
+	}, "-"), nil
+}
+
+func (be BatchEvent) References() ([]eventsources.Reference, error) {
+	return nil, nil
+}
+
+func (be BatchEvent) IsError() (bool, error) {
+	return false, nil
+}
+
+// State never starts a span for the batch itself, only its children.
+func (be BatchEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	return eventsources.IntermediaryState, nil
+}
+
+func (be BatchEvent) Tags() (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (be BatchEvent) Timings() (eventsources.EventTimings, error) {
+	return eventsources.EventTimings{}, nil
+}
+
+func (be BatchEvent) Children() ([]eventsources.Event, error) {
+	return be.Events, nil
+}
@@ -0,0 +1,72 @@
+// +build service
+
+package cloudevents
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/tracers"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+var baseURL string
+var cloudeventsPath string
+
+var urlEnvVar = "TEST_EVENTS_URL"
+var cloudeventsPathEnvVar = "TEST_EVENTS_CLOUDEVENTS_PATH"
+
+func init() {
+	ok := true
+	baseURL, ok = os.LookupEnv(urlEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", urlEnvVar))
+	}
+	cloudeventsPath, ok = os.LookupEnv(cloudeventsPathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", cloudeventsPathEnvVar))
+	}
+}
+
+func TestServiceEvent_CloudEvents_CDEventsBuild(t *testing.T) {
+	tracers.ResetTracer(t, baseURL)
+
+	tracers.PostFixture(t, baseURL, cloudeventsPath, "fixtures/events/build_started_binary.json")
+	tracers.PostFixture(t, baseURL, cloudeventsPath, "fixtures/events/build_finished_structured.json")
+
+	spans := tracers.FinishedSpans(t, baseURL)
+
+	assert.Equal(t, 1, len(spans))
+	if len(spans) != 1 {
+		t.FailNow()
+	}
+
+	assert.Equal(t, "build", spans[0].Span.OperationName)
+	assert.Equal(t, map[string]interface{}{
+		"build.artifact":          "pkg:oci/valuestream@sha256%3A0b1c5d2e",
+		"cdevents.subject.source": "/tekton/valuestream",
+		"cloudevents.source":      "/tekton/valuestream",
+		"cloudevents.type":        "dev.cdevents.build.finished.0.1.1",
+		"error":                   true,
+		"service":                 "cloudevents",
+	}, spans[0].Tags)
+}
+
+func TestServiceTrace_CloudEvents_IncidentFollowsFromDeploy(t *testing.T) {
+	tracers.ResetTracer(t, baseURL)
+
+	tracers.PostFixture(t, baseURL, cloudeventsPath, "fixtures/events/deploy_incident_batch.json")
+
+	spans := tracers.FinishedSpans(t, baseURL)
+
+	assert.Equal(t, 2, len(spans))
+	if len(spans) != 2 {
+		t.FailNow()
+	}
+
+	deploy, incident := spans[0], spans[1]
+	assert.Equal(t, "deploy", deploy.Span.OperationName)
+	assert.Equal(t, "incident", incident.Span.OperationName)
+	assert.Equal(t, deploy.Span.SpanContext.SpanID, incident.Span.ParentID)
+	assert.Equal(t, "production", incident.Tags["incident.environment"])
+}
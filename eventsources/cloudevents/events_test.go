@@ -0,0 +1,92 @@
+package cloudevents
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestEvent_State(t *testing.T) {
+	started := eventsources.EventState(eventsources.StartState)
+
+	testCases := []struct {
+		name     string
+		rule     *Rule
+		prev     *eventsources.EventState
+		expected eventsources.SpanState
+	}{
+		{"start", &Rule{State: eventsources.StartState}, nil, eventsources.StartState},
+		{"already_started", &Rule{State: eventsources.StartState}, &started, eventsources.IntermediaryState},
+		{"end", &Rule{State: eventsources.EndState}, &started, eventsources.EndState},
+		{"end_without_start", &Rule{State: eventsources.EndState}, nil, eventsources.CompleteState},
+		{"intermediary", &Rule{State: eventsources.IntermediaryState}, &started, eventsources.IntermediaryState},
+		{"unmatched", nil, nil, eventsources.UnknownState},
+	}
+	for _, tt := range testCases {
+		s, err := Event{rule: tt.rule}.State(tt.prev)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, s, tt.name)
+	}
+}
+
+func TestEvent_SpanID(t *testing.T) {
+	rule := &Rule{Operation: "build", ID: "data.run.id"}
+
+	e := Event{
+		CloudEvent: decoded(CloudEvent{ID: "1", Data: []byte(`{"run": {"id": 42}}`)}),
+		rule:       rule,
+	}
+	id, err := e.SpanID()
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-cloudevents-build-42", id)
+
+	_, err = Event{CloudEvent: CloudEvent{ID: "1"}, rule: rule}.SpanID()
+	assert.Error(t, err)
+}
+
+func TestEvent_Tags(t *testing.T) {
+	e := Event{
+		CloudEvent: decoded(CloudEvent{
+			Source:     "/ci",
+			Type:       "build.finished",
+			Extensions: map[string]string{"branch": "main"},
+			Data:       []byte(`{"outcome": "success"}`),
+		}),
+		rule: &Rule{Tags: map[string]string{
+			"scm.branch":    "branch",
+			"build.outcome": "data.outcome",
+			"build.missing": "data.missing",
+		}},
+	}
+
+	tags, err := e.Tags()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"service":            "cloudevents",
+		"cloudevents.source": "/ci",
+		"cloudevents.type":   "build.finished",
+		"scm.branch":         "main",
+		"build.outcome":      "success",
+	}, tags)
+}
+
+func TestEvent_Timings(t *testing.T) {
+	at := time.Date(2021, 8, 2, 10, 0, 0, 0, time.UTC)
+
+	timings, err := Event{
+		CloudEvent: CloudEvent{Time: &at},
+		rule:       &Rule{State: eventsources.EndState},
+	}.Timings()
+	assert.NoError(t, err)
+	assert.Equal(t, at, *timings.StartTime)
+	assert.Equal(t, at, *timings.EndTime)
+
+	timings, err = Event{
+		CloudEvent: CloudEvent{Time: &at},
+		rule:       &Rule{State: eventsources.StartState},
+	}.Timings()
+	assert.NoError(t, err)
+	assert.Equal(t, at, *timings.StartTime)
+	assert.Nil(t, timings.EndTime)
+}
@@ -0,0 +1,162 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// Rule maps the cloud events it matches onto a span.  Events are matched
+// on their `type`, `subject` and extensions using patterns where `*`
+// matches any characters and `|` separates alternatives, ie
+// `dev.cdevents.pipelinerun.started.*`.
+//
+// The remaining fields reference attributes, extensions or fields of the
+// event's data (see CloudEvent.Field):
+//   - id: identifies the span, events sharing it start and end the same
+//     span.  Defaults to the event's `subject`
+//   - parent: the span id of the event's parent
+//   - revision: the commit the event was produced for
+//   - service: the deployed service the event concerns
+//   - error: patterns of referenced values, the span is an error when any
+//     of them match
+//   - tags: tag names to the referenced values they're set to
+//
+// The state can be `start`, `end`, `transition` or `intermediary`.  A
+// `start` of a span which was already started leaves it untouched, an
+// `end` without a preceding `start` records the span at the time of the
+// event.
+type Rule struct {
+	Type       string            `json:"type"`
+	Subject    string            `json:"subject"`
+	Extensions map[string]string `json:"extensions"`
+
+	Operation string                 `json:"operation"`
+	State     eventsources.SpanState `json:"state"`
+	ID        string                 `json:"id"`
+	Parent    string                 `json:"parent"`
+	Revision  string                 `json:"revision"`
+	Service   string                 `json:"service"`
+	Error     map[string]string      `json:"error"`
+	Tags      map[string]string      `json:"tags"`
+
+	patterns map[string]*regexp.Regexp
+	errors   map[string]*regexp.Regexp
+}
+
+// compile the rule's patterns, keyed by the field they match.
+func (r *Rule) compile() error {
+	if r.Operation == "" {
+		return fmt.Errorf("rule for type: %q is missing an operation", r.Type)
+	}
+
+	switch r.State {
+	case eventsources.StartState, eventsources.EndState,
+		eventsources.TransitionState, eventsources.IntermediaryState:
+	default:
+		return fmt.Errorf("rule for type: %q has unsupported state: %q", r.Type, r.State)
+	}
+
+	if r.ID == "" {
+		r.ID = "subject"
+	}
+
+	r.patterns = make(map[string]*regexp.Regexp)
+	if r.Type != "" {
+		r.patterns["type"] = compilePattern(r.Type)
+	}
+	if r.Subject != "" {
+		r.patterns["subject"] = compilePattern(r.Subject)
+	}
+	for ext, p := range r.Extensions {
+		r.patterns[ext] = compilePattern(p)
+	}
+
+	r.errors = make(map[string]*regexp.Regexp)
+	for ref, p := range r.Error {
+		r.errors[ref] = compilePattern(p)
+	}
+
+	return nil
+}
+
+func compilePattern(p string) *regexp.Regexp {
+	alternatives := strings.Split(p, "|")
+	for i, alt := range alternatives {
+		parts := strings.Split(alt, "*")
+		for j, part := range parts {
+			parts[j] = regexp.QuoteMeta(part)
+		}
+		alternatives[i] = strings.Join(parts, ".*")
+	}
+	return regexp.MustCompile("^(?:" + strings.Join(alternatives, "|") + ")$")
+}
+
+func matchAll(ce CloudEvent, patterns map[string]*regexp.Regexp) bool {
+	for ref, p := range patterns {
+		v, _ := ce.Field(ref)
+		if !p.MatchString(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r Rule) matches(ce CloudEvent) bool {
+	return matchAll(ce, r.patterns)
+}
+
+func (r Rule) isError(ce CloudEvent) bool {
+	for ref, p := range r.errors {
+		if v, ok := ce.Field(ref); ok && p.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rules are tried in order, an event is mapped by the first rule it
+// matches.  Events which don't match any rule are ignored.
+type Rules []Rule
+
+func (rs Rules) match(ce CloudEvent) (Rule, bool) {
+	for _, r := range rs {
+		if r.matches(ce) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Compile validates the rules and compiles their patterns, it must be
+// called before the rules are used.
+func (rs Rules) Compile() error {
+	for i := range rs {
+		if err := rs[i].compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadRules reads rules from a json file holding an array of them.
+func LoadRules(path string) (Rules, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rs Rules
+	if err := json.Unmarshal(bs, &rs); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Compile(); err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
@@ -0,0 +1,117 @@
+package cloudevents
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRules_match(t *testing.T) {
+	rs := Rules{
+		{
+			Type:       "dev.cdevents.pipelinerun.started.*",
+			Extensions: map[string]string{"team": "platform"},
+			Operation:  "build",
+			State:      eventsources.StartState,
+		},
+		{
+			Type:      "dev.cdevents.pipelinerun.*",
+			Subject:   "deploy-*",
+			Operation: "deploy",
+			State:     eventsources.StartState,
+		},
+		{
+			Type:      "dev.cdevents.pipelinerun.*",
+			Operation: "build",
+			State:     eventsources.IntermediaryState,
+		},
+	}
+	assert.NoError(t, rs.Compile())
+
+	testCases := []struct {
+		name     string
+		ce       CloudEvent
+		expected eventsources.SpanState
+		op       string
+		ok       bool
+	}{
+		{
+			"type_and_extension",
+			CloudEvent{Type: "dev.cdevents.pipelinerun.started.0.1.0", Extensions: map[string]string{"team": "platform"}},
+			eventsources.StartState, "build", true,
+		},
+		{
+			"subject",
+			CloudEvent{Type: "dev.cdevents.pipelinerun.started.0.1.0", Subject: "deploy-valuestream"},
+			eventsources.StartState, "deploy", true,
+		},
+		{
+			"fallthrough",
+			CloudEvent{Type: "dev.cdevents.pipelinerun.queued.0.1.0", Subject: "build/valuestream"},
+			eventsources.IntermediaryState, "build", true,
+		},
+		{
+			"unmatched",
+			CloudEvent{Type: "dev.cdevents.taskrun.started.0.1.0"},
+			"", "", false,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := rs.match(tt.ce)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, r.State)
+			assert.Equal(t, tt.op, r.Operation)
+		})
+	}
+}
+
+func TestRule_isError(t *testing.T) {
+	rs := Rules{{
+		Operation: "build",
+		State:     eventsources.EndState,
+		Error: map[string]string{
+			"data.outcome": "fail*",
+			"errored":      "true",
+		},
+	}}
+	assert.NoError(t, rs.Compile())
+
+	assert.True(t, rs[0].isError(decoded(CloudEvent{Data: []byte(`{"outcome": "failure"}`)})))
+	assert.True(t, rs[0].isError(CloudEvent{Extensions: map[string]string{"errored": "true"}}))
+	assert.False(t, rs[0].isError(decoded(CloudEvent{Data: []byte(`{"outcome": "success"}`)})))
+}
+
+func TestLoadRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		name     string
+		contents string
+		err      bool
+	}{
+		{"valid", `[{"type": "build.started", "operation": "build", "state": "start"}]`, false},
+		{"missing_operation", `[{"type": "build.started", "state": "start"}]`, true},
+		{"unsupported_state", `[{"type": "build.started", "operation": "build", "state": "complete"}]`, true},
+		{"invalid_json", `{"type": "build.started"`, true},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".json")
+			assert.NoError(t, ioutil.WriteFile(path, []byte(tt.contents), 0644))
+
+			rs, err := LoadRules(path)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "subject", rs[0].ID)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package cloudevents
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/opentracing/opentracing-go"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	sourceName string = "cloudevents"
+)
+
+type Source struct {
+	tracer    opentracing.Tracer
+	secretKey []byte
+	rules     Rules
+	contexts  *contexts
+}
+
+func (s Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *Source) SecretKey() []byte {
+	return s.secretKey
+}
+
+// ValidatePayload checks the access token cloud events web hooks are
+// delivered with: `Authorization: Bearer <token>`
+func (s *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body")
+	}
+
+	if secretKey == nil {
+		return body, nil
+	}
+
+	if err := eventsources.BearerToken.Validate(r, secretKey); err != nil {
+		return nil, eventsources.InvalidSignatureError{Err: err}
+	}
+
+	return body, nil
+}
+
+// Event maps the cloud events of the request by the source's rules, a
+// batch of events is returned as a BatchEvent.
+func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
+	ces, err := ParseRequest(r.Header, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]eventsources.Event, 0, len(ces))
+	for _, ce := range ces {
+		events = append(events, incidentOf(s.event(ce)))
+	}
+
+	if len(events) == 1 {
+		return events[0], nil
+	}
+
+	return BatchEvent{Events: events}, nil
+}
+
+func (s *Source) event(ce CloudEvent) Event {
+	if ce.data == nil {
+		ce.decodeData()
+	}
+
+	e := Event{CloudEvent: ce}
+	if rule, ok := s.rules.match(ce); ok {
+		e.rule = &rule
+	}
+
+	if e.rule == nil || !isCDEvent(ce) {
+		return e
+	}
+
+	// cdevents are linked by their context ids rather than their subjects
+	e.linkedParent = s.contexts.parent(linkedContexts(ce))
+	if spanID, err := e.SpanID(); err == nil {
+		s.contexts.set(ce.ID, spanID)
+	}
+
+	return e
+}
+
+// NewSource maps events by the configured rules followed by the cdevents
+// vocabulary.
+func NewSource(tracer opentracing.Tracer, secretKey []byte, rules Rules) (*Source, error) {
+	return &Source{
+		tracer:    tracer,
+		secretKey: secretKey,
+		rules:     append(append(Rules{}, rules...), CDEventsRules()...),
+		contexts:  newContexts(),
+	}, nil
+}
+
+func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
+	var secretKey []byte
+	if token := c.String("cloudevents-token"); token != "" {
+		secretKey = []byte(token)
+	}
+
+	var rules Rules
+	if path := c.String("cloudevents-rules"); path != "" {
+		var err error
+		if rules, err = LoadRules(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewSource(tracer, secretKey, rules)
+}
@@ -0,0 +1,49 @@
+package cloudevents
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestSource_Event(t *testing.T) {
+	rules := Rules{{
+		Type:      "dev.tekton.event.pipelinerun.started.*",
+		Operation: "build",
+		State:     eventsources.StartState,
+		Parent:    "vstraceparent",
+	}}
+	assert.NoError(t, rules.Compile())
+
+	s, err := NewSource(nil, nil, rules)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("POST", "/cloudevents", nil)
+	assert.NoError(t, err)
+
+	req.Header.Set("Content-Type", structuredContentType)
+	e, err := s.Event(req, []byte(structuredEvent))
+	assert.NoError(t, err)
+	assert.IsType(t, Event{}, e)
+
+	state, err := e.State(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, eventsources.StartState, state)
+
+	parent, err := eventsources.ParentSpanID(e)
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-github-pull_request-valuestream-12", *parent)
+
+	req.Header.Set("Content-Type", batchContentType)
+	e, err = s.Event(req, []byte(`[`+structuredEvent+`, {"specversion": "1.0", "id": "2", "source": "/ci", "type": "unmatched"}]`))
+	assert.NoError(t, err)
+
+	children, err := e.(BatchEvent).Children()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(children))
+
+	state, err = children[1].State(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, eventsources.UnknownState, state)
+}
@@ -0,0 +1,78 @@
+package eventsources
+
+import (
+	"sync"
+)
+
+const (
+	defaultMaxBranches int = 1000
+	// defaultMaxCommits is as many commits as github lists for a pull
+	// request.
+	defaultMaxCommits int = 250
+)
+
+// BranchCommits remembers the messages of the commits pushed to each
+// branch, so the pull requests of a branch, whose payloads don't include
+// their commits, can be linked to the issues the commits refer to.  Only
+// the last maxCommits messages of the last maxBranches branches pushed to
+// are held.
+type BranchCommits struct {
+	mu          *sync.Mutex
+	branches    map[string][]string
+	order       []string
+	maxBranches int
+	maxCommits  int
+}
+
+func branchKey(repo, branch string) string {
+	return repo + "/" + branch
+}
+
+// Push records the messages of the commits pushed to the repository's
+// branch, a forced push replaces the messages of the commits it
+// rewrote.
+func (bc *BranchCommits) Push(repo, branch string, forced bool, messages ...string) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	key := branchKey(repo, branch)
+	held, ok := bc.branches[key]
+	if !ok {
+		bc.order = append(bc.order, key)
+	}
+	if forced {
+		held = nil
+	}
+
+	held = append(held, messages...)
+	if len(held) > bc.maxCommits {
+		held = held[len(held)-bc.maxCommits:]
+	}
+	bc.branches[key] = held
+
+	for len(bc.order) > bc.maxBranches {
+		delete(bc.branches, bc.order[0])
+		bc.order = bc.order[1:]
+	}
+}
+
+// Messages are the messages of the commits pushed to the repository's
+// branch, in the order they were pushed.
+func (bc *BranchCommits) Messages(repo, branch string) []string {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	held := bc.branches[branchKey(repo, branch)]
+	messages := make([]string, len(held))
+	copy(messages, held)
+	return messages
+}
+
+func NewBranchCommits() *BranchCommits {
+	return &BranchCommits{
+		mu:          &sync.Mutex{},
+		branches:    make(map[string][]string),
+		maxBranches: defaultMaxBranches,
+		maxCommits:  defaultMaxCommits,
+	}
+}
@@ -0,0 +1,202 @@
+package drone
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"github.com/ImpactInsights/valuestream/traces"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	eventBuild string = "build"
+
+	// buildEventPromote is a build promoted to an environment, drone's
+	// deployments.
+	buildEventPromote string = "promote"
+)
+
+type Repo struct {
+	ID         int64  `json:"id"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	Slug       string `json:"slug"`
+	SCM        string `json:"scm"`
+	GitHTTPURL string `json:"git_http_url"`
+	Link       string `json:"link"`
+}
+
+type Build struct {
+	ID          int64  `json:"id"`
+	Trigger     string `json:"trigger"`
+	Number      int64  `json:"number"`
+	Parent      int64  `json:"parent"`
+	Status      string `json:"status"`
+	Error       string `json:"error"`
+	Event       string `json:"event"`
+	Action      string `json:"action"`
+	Link        string `json:"link"`
+	Message     string `json:"message"`
+	Before      string `json:"before"`
+	After       string `json:"after"`
+	Ref         string `json:"ref"`
+	Source      string `json:"source"`
+	Target      string `json:"target"`
+	AuthorLogin string `json:"author_login"`
+	AuthorName  string `json:"author_name"`
+	Sender      string `json:"sender"`
+	Deploy      string `json:"deploy_to"`
+	Started     int64  `json:"started"`
+	Finished    int64  `json:"finished"`
+	Created     int64  `json:"created"`
+	Updated     int64  `json:"updated"`
+}
+
+// finished reports if the build is in one of drone's terminal statuses.
+func (b Build) finished() bool {
+	switch b.Status {
+	case "success", "failure", "killed", "error", "skipped", "declined":
+		return true
+	}
+	return false
+}
+
+func unixToTime(s int64) *time.Time {
+	if s <= 0 {
+		return nil
+	}
+	t := time.Unix(s, 0).UTC()
+	return &t
+}
+
+// BuildEvent is the payload drone sends to its global webhook as a
+// build is created and each time it's updated.
+type BuildEvent struct {
+	Event  string `json:"event"`
+	Action string `json:"action"`
+	Repo   Repo   `json:"repo"`
+	Build  Build  `json:"build"`
+
+	references traces.References
+}
+
+// OperationName is `deploy` for promoted builds, otherwise `build`.
+func (be BuildEvent) OperationName() string {
+	if be.Build.Event == buildEventPromote {
+		return types.DeployEventType
+	}
+	return types.BuildEventType
+}
+
+// ServiceName is the name of a deploy's repo, builds don't deploy a
+// service.
+func (be BuildEvent) ServiceName() string {
+	if be.OperationName() != types.DeployEventType {
+		return ""
+	}
+	return be.Repo.Name
+}
+
+func (be BuildEvent) SpanID() (string, error) {
+	if be.Repo.Slug == "" || be.Build.Number == 0 {
+		return "", fmt.Errorf("event does not contain a repo slug and build number")
+	}
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		be.OperationName(),
+		be.Repo.Slug,
+		strconv.FormatInt(be.Build.Number, 10),
+	}, "-"), nil
+}
+
+// References uses the trace id in the name of the branch being built,
+// for pull requests this is the source branch of the pull request.
+func (be BuildEvent) References() ([]eventsources.Reference, error) {
+	vars := map[string]string{
+		"repo": be.Repo.Name,
+	}
+	return eventsources.ChildOf(be.references.First(sourceName, vars, be.Build.Source)), nil
+}
+
+func (be BuildEvent) IsError() (bool, error) {
+	switch be.Build.Status {
+	case "failure", "killed", "error", "declined":
+		return true, nil
+	}
+	return false, nil
+}
+
+// State starts the span when the build is created, and ends it once the
+// build reaches a terminal status.  Builds which finish before their span
+// was started are placed by their timings.
+func (be BuildEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	if be.Event != eventBuild {
+		return eventsources.UnknownState, nil
+	}
+
+	if be.Build.finished() {
+		if prev == nil {
+			return eventsources.CompleteState, nil
+		}
+		return eventsources.EndState, nil
+	}
+
+	if prev == nil {
+		return eventsources.StartState, nil
+	}
+	return eventsources.IntermediaryState, nil
+}
+
+func (be BuildEvent) Timings() (eventsources.EventTimings, error) {
+	t := eventsources.EventTimings{
+		StartTime: unixToTime(be.Build.Created),
+		EndTime:   unixToTime(be.Build.Finished),
+	}
+	if t.StartTime != nil && t.EndTime != nil {
+		d := t.EndTime.Sub(*t.StartTime)
+		t.Duration = &d
+	}
+	return t, nil
+}
+
+func (be BuildEvent) Tags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+	tags["build.id"] = be.Build.ID
+	tags["build.number"] = be.Build.Number
+	tags["build.status"] = be.Build.Status
+	tags["build.event"] = be.Build.Event
+	tags["build.trigger"] = be.Build.Trigger
+	tags["build.url"] = be.Build.Link
+	tags["build.message"] = be.Build.Message
+	tags["build.author.login"] = be.Build.AuthorLogin
+	tags["repo.slug"] = be.Repo.Slug
+	tags["scm.url"] = be.Repo.GitHTTPURL
+	tags["scm.ref"] = be.Build.Ref
+	tags["scm.branch"] = be.Build.Source
+	tags["scm.target"] = be.Build.Target
+	tags["scm.head.sha"] = be.Build.After
+
+	if be.Build.Deploy != "" {
+		tags["deploy.target"] = be.Build.Deploy
+	}
+
+	if be.Build.Parent != 0 {
+		tags["build.parent"] = be.Build.Parent
+	}
+	return tags, nil
+}
+
+// EndTags record the status the build finished with.
+func (be BuildEvent) EndTags() (map[string]interface{}, error) {
+	tags := map[string]interface{}{
+		"build.status": be.Build.Status,
+	}
+	if be.Build.Error != "" {
+		tags["build.error"] = be.Build.Error
+	}
+	return tags, nil
+}
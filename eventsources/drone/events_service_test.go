@@ -0,0 +1,165 @@
+// +build service
+
+package drone
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/tracers"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+)
+
+var baseURL string
+var dronePath string
+
+var urlEnvVar = "TEST_EVENTS_URL"
+var dronePathEnvVar = "TEST_EVENTS_DRONE_PATH"
+
+func init() {
+	ok := true
+	baseURL, ok = os.LookupEnv(urlEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", urlEnvVar))
+	}
+	dronePath, ok = os.LookupEnv(dronePathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", dronePathEnvVar))
+	}
+}
+
+func buildTags(overrides map[string]interface{}) map[string]interface{} {
+	tags := map[string]interface{}{
+		"build.author.login": "octocat",
+		"build.event":        "pull_request",
+		"build.id":           float64(141),
+		"build.message":      "Add drone source",
+		"build.number":       float64(21),
+		"build.status":       "success",
+		"build.trigger":      "@hook",
+		"build.url":          "https://github.com/ImpactInsights/valuestream/pull/9",
+		"error":              false,
+		"repo.slug":          "ImpactInsights/valuestream",
+		"scm.branch":         "vstrace-github-pull_request-valuestream-9-drone",
+		"scm.head.sha":       "9f2a1c7d3b5e8f0a1b2c3d4e5f6a7b8c9d0e1f2a",
+		"scm.ref":            "refs/pull/9/head",
+		"scm.target":         "master",
+		"scm.url":            "https://github.com/ImpactInsights/valuestream.git",
+		"service":            "drone",
+	}
+	for k, v := range overrides {
+		tags[k] = v
+	}
+	return tags
+}
+
+var eventTests = []struct {
+	Name                  string
+	EventPaths            []string
+	ExpectedOperationName string
+	ExpectedTags          map[string]interface{}
+}{
+	{
+		Name: "build_success",
+		EventPaths: []string{
+			"fixtures/events/build/pending.json",
+			"fixtures/events/build/running.json",
+			"fixtures/events/build/success.json",
+		},
+		ExpectedOperationName: "build",
+		ExpectedTags:          buildTags(nil),
+	},
+	{
+		Name: "build_failure",
+		EventPaths: []string{
+			"fixtures/events/build/pending.json",
+			"fixtures/events/build/running.json",
+			"fixtures/events/build/failure.json",
+		},
+		ExpectedOperationName: "build",
+		ExpectedTags: buildTags(map[string]interface{}{
+			"build.status": "failure",
+			"error":        true,
+		}),
+	},
+	{
+		Name: "build_finished_only",
+		EventPaths: []string{
+			"fixtures/events/build/success.json",
+		},
+		ExpectedOperationName: "build",
+		ExpectedTags:          buildTags(nil),
+	},
+	{
+		Name: "deploy_success",
+		EventPaths: []string{
+			"fixtures/events/deploy/running.json",
+			"fixtures/events/deploy/success.json",
+		},
+		ExpectedOperationName: "deploy",
+		ExpectedTags: buildTags(map[string]interface{}{
+			"build.event":   "promote",
+			"build.id":      float64(142),
+			"build.number":  float64(22),
+			"build.parent":  float64(21),
+			"build.url":     "https://github.com/ImpactInsights/valuestream/commit/9f2a1c7d3b5e8f0a1b2c3d4e5f6a7b8c9d0e1f2a",
+			"deploy.target": "production",
+			"scm.branch":    "master",
+			"scm.ref":       "refs/heads/master",
+		}),
+	},
+}
+
+func TestServiceEvent_Drone(t *testing.T) {
+	client := &http.Client{}
+	u, err := url.Parse(baseURL + dronePath)
+	assert.NoError(t, err)
+
+	for _, tt := range eventTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			// reset the tracer
+			resp, err := http.Get(baseURL + "/mocktracer/reset")
+			assert.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			for _, eventPath := range tt.EventPaths {
+				te, err := eventsources.NewTestEventFromFixturePath(eventPath)
+				assert.NoError(t, err)
+
+				rawPayload, err := json.Marshal(te.Payload)
+				assert.NoError(t, err)
+
+				eventResp, err := client.Post(u.String(), "application/json", bytes.NewReader(rawPayload))
+				assert.NoError(t, err)
+				eventResp.Body.Close()
+				assert.Equal(t, http.StatusOK, eventResp.StatusCode, eventPath)
+			}
+
+			spansResp, err := http.Get(baseURL + "/mocktracer/finished-spans")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, spansResp.StatusCode)
+
+			bs, err := ioutil.ReadAll(spansResp.Body)
+			assert.NoError(t, err)
+			spansResp.Body.Close()
+
+			var spans []tracers.TestSpan
+
+			err = json.Unmarshal(bs, &spans)
+			assert.NoError(t, err)
+
+			assert.Equal(t, 1, len(spans))
+			if len(spans) == 1 {
+				assert.Equal(t, tt.ExpectedOperationName, spans[0].Span.OperationName)
+				assert.Equal(t, tt.ExpectedTags, spans[0].Tags)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package drone
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBuildEvent_State(t *testing.T) {
+	started := eventsources.EventState(eventsources.StartState)
+
+	testCases := []struct {
+		name     string
+		status   string
+		prev     *eventsources.EventState
+		expected eventsources.SpanState
+	}{
+		{"pending", "pending", nil, eventsources.StartState},
+		{"running", "running", &started, eventsources.IntermediaryState},
+		{"running_not_started", "running", nil, eventsources.StartState},
+		{"success", "success", &started, eventsources.EndState},
+		{"killed", "killed", &started, eventsources.EndState},
+		{"success_not_started", "success", nil, eventsources.CompleteState},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			be := BuildEvent{Event: eventBuild, Build: Build{Status: tt.status}}
+			s, err := be.State(tt.prev)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, s)
+		})
+	}
+}
+
+func TestBuildEvent_OperationName(t *testing.T) {
+	assert.Equal(t, "build", BuildEvent{Build: Build{Event: "push"}}.OperationName())
+	assert.Equal(t, "deploy", BuildEvent{Build: Build{Event: "promote"}}.OperationName())
+}
+
+func TestBuildEvent_ParentSpanID(t *testing.T) {
+	parentID, err := eventsources.ParentSpanID(BuildEvent{Build: Build{Source: "master"}})
+	assert.NoError(t, err)
+	assert.Nil(t, parentID)
+
+	parentID, err = eventsources.ParentSpanID(BuildEvent{
+		Build: Build{Source: "vstrace-github-pull_request-valuestream-9-drone"},
+	})
+	assert.NoError(t, err)
+	if assert.NotNil(t, parentID) {
+		assert.Equal(t, "vstrace-github-pull_request-valuestream-9-drone", *parentID)
+	}
+}
@@ -0,0 +1,180 @@
+package drone
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/traces"
+	"github.com/opentracing/opentracing-go"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	sourceName string = "drone"
+
+	signatureHeader    string = "Signature"
+	digestHeader       string = "Digest"
+	digestPrefix       string = "SHA-256="
+	signatureAlgorithm string = "hmac-sha256"
+)
+
+// signature is the base64 encoded hmac-sha256 of the signed headers, held
+// by the `signature` parameter of the Signature header.
+var signature = eventsources.HMACSignature{
+	Hash:   sha256.New,
+	Base64: true,
+}
+
+type Source struct {
+	tracer     opentracing.Tracer
+	secretKey  []byte
+	references traces.References
+}
+
+func (s Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *Source) SecretKey() []byte {
+	return s.secretKey
+}
+
+// ValidatePayload checks the http signature drone signs its webhooks
+// with (DRONE_WEBHOOK_SECRET):
+//
+//	Digest: SHA-256=<base64 sha256 of the body>
+//	Signature: keyId="hmac-key",algorithm="hmac-sha256",signature="<base64 hmac>",headers="date digest"
+//
+// The signature covers each of the listed headers, so the digest ties
+// it to the body.
+func (s *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body")
+	}
+
+	if secretKey == nil {
+		return body, nil
+	}
+
+	if err := validateDigest(r.Header.Get(digestHeader), body); err != nil {
+		return nil, eventsources.InvalidSignatureError{Err: err}
+	}
+
+	if err := validateSignature(r, secretKey); err != nil {
+		return nil, eventsources.InvalidSignatureError{Err: err}
+	}
+
+	return body, nil
+}
+
+func validateDigest(header string, body []byte) error {
+	if !strings.HasPrefix(header, digestPrefix) {
+		return fmt.Errorf("unsupported digest: %q", header)
+	}
+
+	received, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, digestPrefix))
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(body)
+	if !hmac.Equal(received, sum[:]) {
+		return fmt.Errorf("digest does not match body")
+	}
+	return nil
+}
+
+func validateSignature(r *http.Request, secretKey []byte) error {
+	header := r.Header.Get(signatureHeader)
+	if header == "" {
+		return fmt.Errorf("request is not signed")
+	}
+
+	params := signatureParams(header)
+	if params["algorithm"] != signatureAlgorithm {
+		return fmt.Errorf("unsupported signature algorithm: %q", params["algorithm"])
+	}
+
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+
+	signsDigest := false
+	lines := make([]string, 0, len(headers))
+	for _, h := range headers {
+		if h == "(request-target)" {
+			lines = append(lines, fmt.Sprintf("%s: %s %s", h, strings.ToLower(r.Method), r.URL.RequestURI()))
+			continue
+		}
+		if h == "digest" {
+			signsDigest = true
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", h, r.Header.Get(h)))
+	}
+
+	if !signsDigest {
+		return fmt.Errorf("signature does not cover the digest")
+	}
+
+	return signature.Verify(params["signature"], []byte(strings.Join(lines, "\n")), secretKey)
+}
+
+// signatureParams parses the `key="value"` pairs of a signature header.
+func signatureParams(header string) map[string]string {
+	params := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		params[kv[0]] = strings.Trim(kv[1], `"`)
+	}
+	return params
+}
+
+func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
+	var be BuildEvent
+	if err := json.Unmarshal(payload, &be); err != nil {
+		return nil, err
+	}
+
+	if be.Event != eventBuild {
+		return nil, fmt.Errorf("event: %q, not supported", be.Event)
+	}
+
+	be.references = s.references
+	return be, nil
+}
+
+func NewSource(tracer opentracing.Tracer, secretKey []byte, references traces.References) (*Source, error) {
+	return &Source{
+		tracer:     tracer,
+		secretKey:  secretKey,
+		references: references,
+	}, nil
+}
+
+func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
+	var secretKey []byte
+	if secret := c.String("drone-secret"); secret != "" {
+		secretKey = []byte(secret)
+	}
+
+	references, err := traces.ReferencesFromCLI(c)
+	if err != nil {
+		return nil, err
+	}
+	return NewSource(tracer, secretKey, references)
+}
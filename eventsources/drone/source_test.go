@@ -0,0 +1,109 @@
+package drone
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/traces"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestSource_ValidatePayload(t *testing.T) {
+	secretKey := []byte("secret")
+	body := []byte(`{"event": "build"}`)
+	date := "Thu, 02 Sep 2021 10:00:00 GMT"
+
+	sum := sha256.Sum256(body)
+	digest := digestPrefix + base64.StdEncoding.EncodeToString(sum[:])
+
+	sign := func(digest string) string {
+		mac := hmac.New(sha256.New, secretKey)
+		mac.Write([]byte(fmt.Sprintf("date: %s\ndigest: %s", date, digest)))
+		return fmt.Sprintf(
+			`keyId="hmac-key",algorithm="hmac-sha256",signature="%s",headers="date digest"`,
+			base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+		)
+	}
+
+	otherSum := sha256.Sum256([]byte(`{"event": "other"}`))
+	otherDigest := digestPrefix + base64.StdEncoding.EncodeToString(otherSum[:])
+
+	testCases := []struct {
+		name        string
+		digest      string
+		signature   string
+		secretKey   []byte
+		expectedErr bool
+	}{
+		{"signed", digest, sign(digest), secretKey, false},
+		{"digest_mismatch", otherDigest, sign(otherDigest), secretKey, true},
+		{"invalid_signature", digest, sign(otherDigest), secretKey, true},
+		{"unsigned", digest, "", secretKey, true},
+		{"no_digest", "", "", secretKey, true},
+		{
+			"digest_not_signed",
+			digest,
+			`keyId="hmac-key",algorithm="hmac-sha256",signature="AA==",headers="date"`,
+			secretKey,
+			true,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/drone", bytes.NewReader(body))
+			assert.NoError(t, err)
+			req.Header.Set("Date", date)
+			if tt.digest != "" {
+				req.Header.Set(digestHeader, tt.digest)
+			}
+			if tt.signature != "" {
+				req.Header.Set(signatureHeader, tt.signature)
+			}
+
+			s, err := NewSource(nil, tt.secretKey, nil)
+			assert.NoError(t, err)
+
+			payload, err := s.ValidatePayload(req, s.SecretKey())
+			if tt.expectedErr {
+				assert.IsType(t, eventsources.InvalidSignatureError{}, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, body, payload)
+		})
+	}
+}
+
+func TestSource_Event_UnsupportedEvent(t *testing.T) {
+	s, err := NewSource(nil, nil, nil)
+	assert.NoError(t, err)
+
+	_, err = s.Event(nil, []byte(`{"event": "repo", "action": "enabled"}`))
+	assert.Error(t, err)
+}
+
+func TestSource_Event_References(t *testing.T) {
+	rs := traces.References{
+		{Pattern: `(?P<key>[A-Z]+-\d+)`, Template: "vstrace-jiraserver-issue-${key}"},
+	}
+	assert.NoError(t, rs.Compile())
+
+	s, err := NewSource(nil, nil, rs)
+	assert.NoError(t, err)
+
+	e, err := s.Event(nil, []byte(`{
+		"event": "build", "action": "created",
+		"repo": {"name": "valuestream"},
+		"build": {"number": 1, "source": "OPS-7-login"}
+	}`))
+	assert.NoError(t, err)
+
+	parentID, err := eventsources.ParentSpanID(e)
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-jiraserver-issue-OPS-7", *parentID)
+}
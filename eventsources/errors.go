@@ -0,0 +1,11 @@
+package eventsources
+
+// InvalidSignatureError is returned by an EventSource when a request
+// fails authentication against the configured secret.
+type InvalidSignatureError struct {
+	Err error
+}
+
+func (s InvalidSignatureError) Error() string {
+	return s.Err.Error()
+}
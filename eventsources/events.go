@@ -1,6 +1,7 @@
 package eventsources
 
 import (
+	"context"
 	"github.com/opentracing/opentracing-go"
 	"net/http"
 	"time"
@@ -19,6 +20,7 @@ const (
 	EndState          SpanState = "end"
 	IntermediaryState SpanState = "intermediary"
 	TransitionState   SpanState = "transition"
+	CompleteState     SpanState = "complete" // started and ended by a single event, placed in time by its Timings
 	UnknownState      SpanState = "unknown"
 )
 
@@ -28,16 +30,137 @@ type EventTimings struct {
 	Duration  *time.Duration
 }
 
+// Relationship is how an event's span relates to a span it references.
+type Relationship string
+
+const (
+	// ChildOfRelationship spans are nested beneath the span, ie a job
+	// beneath its pipeline.
+	ChildOfRelationship Relationship = "child_of"
+	// ContainsRelationship spans include the change of the span, ie a
+	// deploy shipping a pull request.
+	ContainsRelationship Relationship = "contains"
+	// FixesRelationship spans resolve the span, ie a pull request closing
+	// an issue.
+	FixesRelationship Relationship = "fixes"
+	// DeploysRelationship spans deploy the span's commit, ie a rollout of
+	// the image a build produced.
+	DeploysRelationship Relationship = "deploys"
+	// FollowsRelationship spans were caused by the span, ie an incident
+	// following a deploy.
+	FollowsRelationship Relationship = "follows"
+)
+
+// Reference is a span an event's span relates to.
+type Reference struct {
+	SpanID       string
+	Relationship Relationship
+}
+
+// ChildOf references the parent span, if any.
+func ChildOf(parentID *string) []Reference {
+	if parentID == nil {
+		return nil
+	}
+	return []Reference{{SpanID: *parentID, Relationship: ChildOfRelationship}}
+}
+
+// ParentSpanID is the span the event's span is preferred to be nested
+// beneath, the first of its references.
+func ParentSpanID(e Event) (*string, error) {
+	refs, err := e.References()
+	if err != nil || len(refs) == 0 {
+		return nil, err
+	}
+	return &refs[0].SpanID, nil
+}
+
+// Fixes references the issues a change resolves.
+func Fixes(spanIDs []string) []Reference {
+	var refs []Reference
+	for _, id := range spanIDs {
+		refs = append(refs, Reference{SpanID: id, Relationship: FixesRelationship})
+	}
+	return refs
+}
+
 type Event interface {
 	SpanID() (string, error)
 	OperationName() string
-	ParentSpanID() (*string, error)
+	// References are every span the event's span relates to, in the
+	// order they're preferred as its parent.
+	References() ([]Reference, error)
 	IsError() (bool, error)
 	State(prev *EventState) (SpanState, error)
 	Tags() (map[string]interface{}, error)
 	Timings() (EventTimings, error)
 }
 
+// ParentEvent is implemented by events which also drive spans nested
+// beneath their own.  Children are only handled when the parent event
+// itself resulted in its span being started, updated or ended.
+type ParentEvent interface {
+	Children() ([]Event, error)
+}
+
+// EndTagger is implemented by events which only know some of their tags
+// once their span ends, ie the outcome of a sprint.
+type EndTagger interface {
+	EndTags() (map[string]interface{}, error)
+}
+
+// RevisionEvent is implemented by events which know the commit they were
+// produced for, but not the span of the build or pull request which
+// produced it, ie deploys made by gitops tools.
+type RevisionEvent interface {
+	Revision() string
+}
+
+// ServiceEvent is implemented by events which concern a deployed service,
+// ie deploys of it and incidents affecting it.  Services are matched by
+// name, ignoring case.
+type ServiceEvent interface {
+	ServiceName() string
+}
+
+// IncidentEvent is implemented by events which report a failure of a
+// deployed service, ie incidents and defects, they follow from the most
+// recent deploy of the service to the environment they affect.  Events
+// which don't know the environment return an empty one, and follow from
+// the deploy to production.
+type IncidentEvent interface {
+	ServiceEvent
+	Environment() string
+}
+
+// ReleaseEvent is implemented by events which were observed in a release,
+// ie defects, but don't know the deploy which shipped it.  Releases are
+// the release names and commits, in the order they're preferred.
+type ReleaseEvent interface {
+	Releases() []string
+}
+
+// HandledEvent is implemented by events which update their source once
+// they've been traced, ie the progress of a sprint.  Handled is only called
+// when the event was handled without error.
+type HandledEvent interface {
+	Handled(ctx context.Context)
+}
+
+// CallbackSource is implemented by sources which verify their callback url
+// with a HEAD request before sending any events to it, ie trello.
+type CallbackSource interface {
+	VerifiesCallback() bool
+}
+
+// CreatedSource is implemented by sources which can look up when the
+// subject of one of their span ids was created, ie an issue opened before
+// valuestream was installed, so a placeholder started for it is placed at
+// that time.  A nil time is returned when it can't be looked up.
+type CreatedSource interface {
+	CreatedAt(ctx context.Context, spanID string) (*time.Time, error)
+}
+
 type EventSource interface {
 	Name() string
 	ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error)
@@ -0,0 +1,158 @@
+package flux
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"strings"
+	"time"
+)
+
+const (
+	severityError string = "error"
+
+	reasonProgressing string = "Progressing"
+
+	metadataRevision    string = "revision"
+	metadataEnvironment string = "environment"
+)
+
+type ObjectReference struct {
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	APIVersion string `json:"apiVersion"`
+}
+
+// Event is the event the notification-controller forwards to generic
+// webhook providers, for each reconciliation of a Kustomization or a
+// HelmRelease which changed the cluster.
+type Event struct {
+	InvolvedObject      ObjectReference   `json:"involvedObject"`
+	Severity            string            `json:"severity"`
+	Timestamp           *time.Time        `json:"timestamp"`
+	Message             string            `json:"message"`
+	Reason              string            `json:"reason"`
+	Metadata            map[string]string `json:"metadata"`
+	ReportingController string            `json:"reportingController"`
+
+	environment string
+}
+
+// Environment is the `environment` of the event's metadata, set by the
+// alert's event metadata, or else the environment of the source.
+func (e Event) Environment() string {
+	if environment := e.Metadata[metadataEnvironment]; environment != "" {
+		return environment
+	}
+	return e.environment
+}
+
+func (e Event) application() string {
+	return strings.Join([]string{
+		e.InvolvedObject.Namespace,
+		e.InvolvedObject.Name,
+	}, "/")
+}
+
+// Revision is the commit which was applied.  Flux reports revisions as
+// either `<branch>@sha1:<sha>` or, in older versions, `<branch>/<sha>`.
+func (e Event) Revision() string {
+	revision := e.Metadata[metadataRevision]
+	if i := strings.LastIndex(revision, ":"); i != -1 {
+		return revision[i+1:]
+	}
+	if i := strings.LastIndex(revision, "/"); i != -1 {
+		return revision[i+1:]
+	}
+	return revision
+}
+
+// succeeded and failed report if the reason is the successful or failed
+// outcome of a reconciliation, ie `ReconciliationSucceeded`,
+// `UpgradeFailed` or `HealthCheckFailed`.
+func (e Event) succeeded() bool {
+	return strings.HasSuffix(e.Reason, "Succeeded")
+}
+
+func (e Event) failed() bool {
+	return strings.HasSuffix(e.Reason, "Failed")
+}
+
+// ServiceName is the name of the Kustomization or HelmRelease.
+func (e Event) ServiceName() string {
+	return e.InvolvedObject.Name
+}
+
+func (e Event) OperationName() string {
+	return types.DeployEventType
+}
+
+func (e Event) SpanID() (string, error) {
+	revision := e.Revision()
+	if e.InvolvedObject.Name == "" || revision == "" {
+		return "", fmt.Errorf("event does not contain an object and revision")
+	}
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		types.DeployEventType,
+		e.application(),
+		revision,
+	}, "-"), nil
+}
+
+// References are resolved by the webhook from the span of the build or
+// pull request of the Revision.
+func (e Event) References() ([]eventsources.Reference, error) {
+	return nil, nil
+}
+
+func (e Event) IsError() (bool, error) {
+	return e.failed() || e.Severity == severityError, nil
+}
+
+func (e Event) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	switch {
+	case e.Reason == reasonProgressing:
+		if prev == nil {
+			return eventsources.StartState, nil
+		}
+		return eventsources.IntermediaryState, nil
+	case e.succeeded(), e.failed():
+		if prev == nil {
+			return eventsources.CompleteState, nil
+		}
+		return eventsources.EndState, nil
+	}
+	return eventsources.UnknownState, nil
+}
+
+// Timings start the deploy when flux reported it, the outcome of the
+// deploy is sent as soon as the reconciliation finishes.
+func (e Event) Timings() (eventsources.EventTimings, error) {
+	return eventsources.EventTimings{
+		StartTime: e.Timestamp,
+	}, nil
+}
+
+func (e Event) Tags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+	tags["deploy.application"] = e.application()
+	tags["deploy.kind"] = e.InvolvedObject.Kind
+	tags["deploy.revision"] = e.Revision()
+	tags["deploy.controller"] = e.ReportingController
+	if environment := e.Environment(); environment != "" {
+		tags["deploy.environment"] = environment
+	}
+	return tags, nil
+}
+
+// EndTags record the outcome flux reported for the reconciliation.
+func (e Event) EndTags() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"deploy.reason":  e.Reason,
+		"deploy.message": e.Message,
+	}, nil
+}
@@ -0,0 +1,149 @@
+// +build service
+
+package flux
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/tracers"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+)
+
+var baseURL string
+var fluxPath string
+
+var urlEnvVar = "TEST_EVENTS_URL"
+var fluxPathEnvVar = "TEST_EVENTS_FLUX_PATH"
+
+func init() {
+	ok := true
+	baseURL, ok = os.LookupEnv(urlEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", urlEnvVar))
+	}
+	fluxPath, ok = os.LookupEnv(fluxPathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", fluxPathEnvVar))
+	}
+}
+
+var kustomizationTags = map[string]interface{}{
+	"deploy.application": "flux-system/apps",
+	"deploy.controller":  "kustomize-controller",
+	"deploy.kind":        "Kustomization",
+	"deploy.message":     "Reconciliation finished in 21.3s, next run in 10m0s",
+	"deploy.reason":      "ReconciliationSucceeded",
+	"deploy.revision":    "5d1c9a3e2b7f4a6c8e0d1f2a3b4c5d6e7f8a9b0c",
+	"error":              false,
+	"service":            "flux",
+}
+
+var eventTests = []struct {
+	Name         string
+	EventPaths   []string
+	ExpectedTags map[string]interface{}
+}{
+	{
+		Name: "kustomization_succeeded",
+		EventPaths: []string{
+			"fixtures/events/kustomization_progressing.json",
+			"fixtures/events/kustomization_succeeded.json",
+		},
+		ExpectedTags: kustomizationTags,
+	},
+	{
+		Name: "kustomization_health_check_failed",
+		EventPaths: []string{
+			"fixtures/events/kustomization_progressing.json",
+			"fixtures/events/kustomization_health_check_failed.json",
+		},
+		ExpectedTags: map[string]interface{}{
+			"deploy.application": "flux-system/apps",
+			"deploy.controller":  "kustomize-controller",
+			"deploy.kind":        "Kustomization",
+			"deploy.message":     "Health check failed after 5m0s: timeout waiting for: [Deployment/valuestream/valuestream status: 'InProgress']",
+			"deploy.reason":      "HealthCheckFailed",
+			"deploy.revision":    "5d1c9a3e2b7f4a6c8e0d1f2a3b4c5d6e7f8a9b0c",
+			"error":              true,
+			"service":            "flux",
+		},
+	},
+	{
+		Name: "kustomization_succeeded_only",
+		EventPaths: []string{
+			"fixtures/events/kustomization_succeeded.json",
+		},
+		ExpectedTags: kustomizationTags,
+	},
+	{
+		Name: "helmrelease_upgrade_failed",
+		EventPaths: []string{
+			"fixtures/events/helmrelease_upgrade_failed.json",
+		},
+		ExpectedTags: map[string]interface{}{
+			"deploy.application": "flux-system/podinfo",
+			"deploy.controller":  "helm-controller",
+			"deploy.kind":        "HelmRelease",
+			"deploy.message":     "Helm upgrade failed: context deadline exceeded",
+			"deploy.reason":      "UpgradeFailed",
+			"deploy.revision":    "6.0.3",
+			"error":              true,
+			"service":            "flux",
+		},
+	},
+}
+
+func TestServiceEvent_Flux(t *testing.T) {
+	client := &http.Client{}
+	u, err := url.Parse(baseURL + fluxPath)
+	assert.NoError(t, err)
+
+	for _, tt := range eventTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			// reset the tracer
+			resp, err := http.Get(baseURL + "/mocktracer/reset")
+			assert.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			for _, eventPath := range tt.EventPaths {
+				te, err := eventsources.NewTestEventFromFixturePath(eventPath)
+				assert.NoError(t, err)
+
+				rawPayload, err := json.Marshal(te.Payload)
+				assert.NoError(t, err)
+
+				eventResp, err := client.Post(u.String(), "application/json", bytes.NewReader(rawPayload))
+				assert.NoError(t, err)
+				eventResp.Body.Close()
+				assert.Equal(t, http.StatusOK, eventResp.StatusCode, eventPath)
+			}
+
+			spansResp, err := http.Get(baseURL + "/mocktracer/finished-spans")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, spansResp.StatusCode)
+
+			bs, err := ioutil.ReadAll(spansResp.Body)
+			assert.NoError(t, err)
+			spansResp.Body.Close()
+
+			var spans []tracers.TestSpan
+
+			err = json.Unmarshal(bs, &spans)
+			assert.NoError(t, err)
+
+			assert.Equal(t, 1, len(spans))
+			if len(spans) == 1 {
+				assert.Equal(t, "deploy", spans[0].Span.OperationName)
+				assert.Equal(t, tt.ExpectedTags, spans[0].Tags)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package flux
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEvent_Revision(t *testing.T) {
+	testCases := []struct {
+		revision string
+		expected string
+	}{
+		{"main@sha1:5d1c9a3e", "5d1c9a3e"},
+		{"main/5d1c9a3e", "5d1c9a3e"},
+		{"6.0.3", "6.0.3"},
+		{"", ""},
+	}
+	for _, tt := range testCases {
+		e := Event{Metadata: map[string]string{metadataRevision: tt.revision}}
+		assert.Equal(t, tt.expected, e.Revision(), tt.revision)
+	}
+}
+
+func TestEvent_State(t *testing.T) {
+	started := eventsources.EventState(eventsources.StartState)
+
+	testCases := []struct {
+		reason   string
+		prev     *eventsources.EventState
+		expected eventsources.SpanState
+	}{
+		{"Progressing", nil, eventsources.StartState},
+		{"Progressing", &started, eventsources.IntermediaryState},
+		{"ReconciliationSucceeded", &started, eventsources.EndState},
+		{"ReconciliationSucceeded", nil, eventsources.CompleteState},
+		{"HealthCheckFailed", &started, eventsources.EndState},
+		{"DependencyNotReady", nil, eventsources.UnknownState},
+	}
+	for _, tt := range testCases {
+		s, err := Event{Reason: tt.reason}.State(tt.prev)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, s, tt.reason)
+	}
+}
+
+func TestEvent_IsError(t *testing.T) {
+	isErr, err := Event{Reason: "UpgradeFailed", Severity: "error"}.IsError()
+	assert.NoError(t, err)
+	assert.True(t, isErr)
+
+	isErr, err = Event{Reason: "ReconciliationSucceeded", Severity: "info"}.IsError()
+	assert.NoError(t, err)
+	assert.False(t, isErr)
+}
+
+func TestEvent_Environment(t *testing.T) {
+	e := Event{environment: "production"}
+	assert.Equal(t, "production", e.Environment())
+
+	e.Metadata = map[string]string{metadataEnvironment: "staging"}
+	assert.Equal(t, "staging", e.Environment())
+
+	tags, err := e.Tags()
+	assert.NoError(t, err)
+	assert.Equal(t, "staging", tags["deploy.environment"])
+
+	tags, err = Event{}.Tags()
+	assert.NoError(t, err)
+	assert.NotContains(t, tags, "deploy.environment")
+}
@@ -0,0 +1,98 @@
+package flux
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/opentracing/opentracing-go"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	sourceName string = "flux"
+
+	signatureHeader string = "X-Signature"
+	signaturePrefix string = "sha256="
+)
+
+var signature = eventsources.HMACSignature{
+	Hash:   sha256.New,
+	Header: signatureHeader,
+	Prefix: signaturePrefix,
+}
+
+type Source struct {
+	tracer    opentracing.Tracer
+	secretKey []byte
+	// environment names the cluster the notification-controller reports
+	// from, when its events don't.
+	environment string
+}
+
+func (s Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *Source) SecretKey() []byte {
+	return s.secretKey
+}
+
+// ValidatePayload checks the signature of flux's `generic-hmac` provider:
+// `X-Signature: sha256=<hex encoded hmac of the body>`
+func (s *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body")
+	}
+
+	if secretKey == nil {
+		return body, nil
+	}
+
+	if err := signature.Validate(r, body, secretKey); err != nil {
+		return nil, eventsources.InvalidSignatureError{Err: err}
+	}
+
+	return body, nil
+}
+
+func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
+	var e Event
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, err
+	}
+
+	if e.InvolvedObject.Name == "" {
+		return nil, fmt.Errorf("payload does not contain an involved object")
+	}
+
+	e.environment = s.environment
+	return e, nil
+}
+
+func NewSource(tracer opentracing.Tracer, secretKey []byte) (*Source, error) {
+	return &Source{
+		tracer:    tracer,
+		secretKey: secretKey,
+	}, nil
+}
+
+func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
+	var secretKey []byte
+	if secret := c.String("flux-secret"); secret != "" {
+		secretKey = []byte(secret)
+	}
+	s, err := NewSource(tracer, secretKey)
+	if err != nil {
+		return nil, err
+	}
+	s.environment = c.String("flux-environment")
+	return s, nil
+}
@@ -0,0 +1,51 @@
+package flux
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestSource_ValidatePayload(t *testing.T) {
+	secretKey := []byte("secret")
+	body := []byte(`{"reason": "ReconciliationSucceeded"}`)
+
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write(body)
+	signature := signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+
+	testCases := []struct {
+		name        string
+		signature   string
+		secretKey   []byte
+		expectedErr bool
+	}{
+		{"signed", signature, secretKey, false},
+		{"unsupported_signature", "sha1=00", secretKey, true},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/flux", bytes.NewReader(body))
+			assert.NoError(t, err)
+			if tt.signature != "" {
+				req.Header.Set(signatureHeader, tt.signature)
+			}
+
+			s, err := NewSource(nil, tt.secretKey)
+			assert.NoError(t, err)
+
+			payload, err := s.ValidatePayload(req, s.SecretKey())
+			if tt.expectedErr {
+				assert.IsType(t, eventsources.InvalidSignatureError{}, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, body, payload)
+		})
+	}
+}
@@ -79,7 +79,7 @@ func (ie IssuesEvent) IsError() (bool, error) {
 
 // TODO - Issues can reference other issues inside their body
 // to model 'epics' or issues of issues
-func (ie IssuesEvent) ParentSpanID() (*string, error) {
+func (ie IssuesEvent) References() ([]eventsources.Reference, error) {
 	return nil, nil
 }
 
@@ -113,6 +113,10 @@ func (ie IssuesEvent) Tags() (map[string]interface{}, error) {
 
 type PREvent struct {
 	*github.PullRequestEvent
+	references traces.References
+	// commits are the messages of the commits pushed to the pull
+	// request's head branch
+	commits []string
 }
 
 func (pr PREvent) Timings() (eventsources.EventTimings, error) {
@@ -176,6 +180,10 @@ func (pr PREvent) Tags() (map[string]interface{}, error) {
 			tags["scm.head.sha"] = pr.PullRequest.Head.GetSHA()
 		}
 
+		if sha := pr.PullRequest.GetMergeCommitSHA(); sha != "" {
+			tags["scm.merge.sha"] = sha
+		}
+
 		if pr.PullRequest.GetBase() != nil {
 			tags["scm.base.label"] = pr.PullRequest.Base.GetLabel()
 			tags["scm.base.ref"] = pr.PullRequest.Base.GetRef()
@@ -200,23 +208,26 @@ func (pr PREvent) Tags() (map[string]interface{}, error) {
 	return tags, nil
 }
 
-// ParentSpanID inspects the PullRequestEvent payload for any references to a parent trace
-func (pr PREvent) ParentSpanID() (*string, error) {
-	matches, err := traces.Matches(pr.PullRequest.Head.GetRef())
-	log.Debugf("github.PREvent.ParentSpanID(): %q. Matches: %+v, err: %q",
-		pr.PullRequest.Head.GetRef(),
-		matches,
-		err,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(matches) == 0 {
-		return nil, err
+// References inspects the name of the PullRequestEvent's branch, its
+// title, body and the messages of the commits pushed to its branch for
+// any references to the issues it fixes, ie closing keywords
+// (`Fixes #12`) and jira keys.
+func (pr PREvent) References() ([]eventsources.Reference, error) {
+	vars := map[string]string{
+		"repo":  pr.GetRepo().GetName(),
+		"owner": pr.GetRepo().GetOwner().GetLogin(),
 	}
-
-	return &matches[0], nil
+	texts := append([]string{
+		pr.PullRequest.GetHead().GetRef(),
+		pr.PullRequest.GetTitle(),
+		pr.PullRequest.GetBody(),
+	}, pr.commits...)
+	parents := pr.references.All(sourceName, vars, texts...)
+	log.Debugf("github.PREvent.References(): %q. Parents: %v",
+		pr.PullRequest.GetHead().GetRef(),
+		parents,
+	)
+	return eventsources.Fixes(parents), nil
 }
 
 func (pr PREvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
@@ -239,3 +250,62 @@ func (pr PREvent) State(prev *eventsources.EventState) (eventsources.SpanState,
 func (pr PREvent) IsError() (bool, error) {
 	return false, nil
 }
+
+// PushEvent records the messages of the commits pushed to a branch, for
+// the pull requests of the branch, it isn't traced itself.
+type PushEvent struct {
+	*github.PushEvent
+}
+
+// branch is the name of the branch pushed to, it's empty for tags.
+func (pe PushEvent) branch() string {
+	ref := pe.GetRef()
+	if !strings.HasPrefix(ref, branchPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(ref, branchPrefix)
+}
+
+func (pe PushEvent) messages() []string {
+	var messages []string
+	for _, c := range pe.Commits {
+		if m := c.GetMessage(); m != "" {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+func (pe PushEvent) SpanID() (string, error) {
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		"push",
+		pe.GetRepo().GetName(),
+		pe.GetAfter(),
+	}, "-"), nil
+}
+
+func (pe PushEvent) OperationName() string {
+	return "push"
+}
+
+func (pe PushEvent) References() ([]eventsources.Reference, error) {
+	return nil, nil
+}
+
+func (pe PushEvent) IsError() (bool, error) {
+	return false, nil
+}
+
+func (pe PushEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	return eventsources.UnknownState, nil
+}
+
+func (pe PushEvent) Tags() (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func (pe PushEvent) Timings() (eventsources.EventTimings, error) {
+	return eventsources.EventTimings{}, nil
+}
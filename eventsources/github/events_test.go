@@ -1,14 +1,17 @@
 package github
 
 import (
+	"github.com/ImpactInsights/valuestream/eventsources"
 	"github.com/google/go-github/github"
 	"github.com/stretchr/testify/assert"
+	"net/http"
 	"testing"
 	"time"
 )
 
 func TestPullRequestEvent_ParentSpanID(t *testing.T) {
 	branchName := "feature/vstrace-github-pull_request-valuestream-1/hi"
+	jiraBranchName := "feature/ABC-123-login"
 
 	traceIDTests := []struct {
 		name     string
@@ -18,7 +21,7 @@ func TestPullRequestEvent_ParentSpanID(t *testing.T) {
 		{
 			name: "branch_name_contains",
 			pr: PREvent{
-				&github.PullRequestEvent{
+				PullRequestEvent: &github.PullRequestEvent{
 					PullRequest: &github.PullRequest{
 						Head: &github.PullRequestBranch{
 							Ref: &branchName,
@@ -28,10 +31,23 @@ func TestPullRequestEvent_ParentSpanID(t *testing.T) {
 			},
 			expected: "vstrace-github-pull_request-valuestream-1",
 		},
+		{
+			name: "branch_name_jira_key",
+			pr: PREvent{
+				PullRequestEvent: &github.PullRequestEvent{
+					PullRequest: &github.PullRequest{
+						Head: &github.PullRequestBranch{
+							Ref: &jiraBranchName,
+						},
+					},
+				},
+			},
+			expected: "vstrace-jiracloud-issue-ABC-123",
+		},
 	}
 	for _, tt := range traceIDTests {
 		t.Run(tt.name, func(t *testing.T) {
-			match, err := tt.pr.ParentSpanID()
+			match, err := eventsources.ParentSpanID(tt.pr)
 			assert.NoError(t, err)
 			assert.NotNil(t, match)
 			assert.Equal(t, tt.expected, *match)
@@ -39,6 +55,39 @@ func TestPullRequestEvent_ParentSpanID(t *testing.T) {
 	}
 }
 
+func TestPullRequestEvent_References(t *testing.T) {
+	branchName := "feature/login"
+	title := "ABC-1: Add login"
+	body := "Fixes #3, closes ImpactInsights/docs#4 and #5"
+	repoName := "valuestream"
+
+	pr := PREvent{
+		PullRequestEvent: &github.PullRequestEvent{
+			Repo: &github.Repository{Name: &repoName},
+			PullRequest: &github.PullRequest{
+				Title: &title,
+				Body:  &body,
+				Head: &github.PullRequestBranch{
+					Ref: &branchName,
+				},
+			},
+		},
+	}
+
+	refs, err := pr.References()
+	assert.NoError(t, err)
+	assert.Equal(t, eventsources.Fixes([]string{
+		"vstrace-jiracloud-issue-ABC-1",
+		"vstrace-github-issue-docs-4",
+		"vstrace-github-issue-valuestream-3",
+		"vstrace-github-issue-valuestream-5",
+	}), refs)
+
+	parentID, err := eventsources.ParentSpanID(pr)
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-jiracloud-issue-ABC-1", *parentID)
+}
+
 func TestIssuesEvent_Duration(t *testing.T) {
 	created := time.Date(
 		2000, 12, 1, 0, 0, 0, 0, time.UTC,
@@ -70,7 +119,7 @@ func TestPREvent_Duration(t *testing.T) {
 	)
 	closed := "closed"
 	pr := PREvent{
-		&github.PullRequestEvent{
+		PullRequestEvent: &github.PullRequestEvent{
 			Action: &closed,
 			PullRequest: &github.PullRequest{
 				CreatedAt: &created,
@@ -82,3 +131,54 @@ func TestPREvent_Duration(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, float64(1), (*timings.Duration).Hours())
 }
+
+func TestSource_Event_PullRequestPushedCommits(t *testing.T) {
+	s, err := NewSource(nil, nil)
+	assert.NoError(t, err)
+
+	event := func(eventType string, payload string) eventsources.Event {
+		r, err := http.NewRequest("POST", "/github", nil)
+		assert.NoError(t, err)
+		r.Header.Set("X-GitHub-Event", eventType)
+
+		e, err := s.Event(r, []byte(payload))
+		assert.NoError(t, err)
+		return e
+	}
+
+	event("push", `{
+		"ref": "refs/heads/login",
+		"after": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+		"repository": {"name": "valuestream", "full_name": "ImpactInsights/valuestream"},
+		"commits": [{"message": "Add login form, fixes #8"}, {"message": "ABC-1: validate passwords"}]
+	}`)
+	// pushes to other branches aren't the pull request's commits
+	event("push", `{
+		"ref": "refs/heads/signup",
+		"repository": {"name": "valuestream", "full_name": "ImpactInsights/valuestream"},
+		"commits": [{"message": "Fixes #9"}]
+	}`)
+
+	pr := event("pull_request", `{
+		"action": "opened",
+		"repository": {"name": "valuestream", "owner": {"login": "ImpactInsights"}},
+		"pull_request": {
+			"title": "Add login",
+			"head": {"ref": "login", "repo": {"full_name": "ImpactInsights/valuestream"}}
+		}
+	}`)
+	refs, err := pr.References()
+	assert.NoError(t, err)
+	assert.Equal(t, eventsources.Fixes([]string{
+		"vstrace-github-issue-valuestream-8",
+		"vstrace-jiracloud-issue-ABC-1",
+	}), refs)
+
+	// a forced push rewrites the branch's commits
+	event("push", `{
+		"ref": "refs/heads/login", "forced": true,
+		"repository": {"name": "valuestream", "full_name": "ImpactInsights/valuestream"},
+		"commits": [{"message": "ABC-1: add login"}]
+	}`)
+	assert.Equal(t, []string{"ABC-1: add login"}, s.(*Source).commits.Messages("ImpactInsights/valuestream", "login"))
+}
@@ -3,6 +3,7 @@ package github
 import (
 	"fmt"
 	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/traces"
 	"github.com/google/go-github/github"
 	"github.com/opentracing/opentracing-go"
 	"github.com/urfave/cli"
@@ -11,11 +12,14 @@ import (
 )
 
 const (
-	sourceName string = "github"
+	sourceName   string = "github"
+	branchPrefix string = "refs/heads/"
 )
 
 type Source struct {
-	tracer opentracing.Tracer
+	tracer     opentracing.Tracer
+	references traces.References
+	commits    *eventsources.BranchCommits
 }
 
 func (s Source) Name() string {
@@ -45,7 +49,18 @@ func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, err
 	case *github.IssuesEvent:
 		return IssuesEvent{event}, nil
 	case *github.PullRequestEvent:
-		return PREvent{event}, nil
+		head := event.GetPullRequest().GetHead()
+		return PREvent{
+			PullRequestEvent: event,
+			references:       s.references,
+			commits:          s.commits.Messages(head.GetRepo().GetFullName(), head.GetRef()),
+		}, nil
+	case *github.PushEvent:
+		pe := PushEvent{event}
+		if branch := pe.branch(); branch != "" {
+			s.commits.Push(event.GetRepo().GetFullName(), branch, event.GetForced(), pe.messages()...)
+		}
+		return pe, nil
 	default:
 		err = fmt.Errorf("event type not supported, %+v", event)
 	}
@@ -56,12 +71,18 @@ func (s *Source) SecretKey() []byte {
 	return nil
 }
 
-func NewSource(tracer opentracing.Tracer) (eventsources.EventSource, error) {
+func NewSource(tracer opentracing.Tracer, references traces.References) (eventsources.EventSource, error) {
 	return &Source{
-		tracer: tracer,
+		tracer:     tracer,
+		references: references,
+		commits:    eventsources.NewBranchCommits(),
 	}, nil
 }
 
 func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
-	return NewSource(tracer)
+	references, err := traces.ReferencesFromCLI(c)
+	if err != nil {
+		return nil, err
+	}
+	return NewSource(tracer, references)
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/ImpactInsights/valuestream/traces"
 	log "github.com/sirupsen/logrus"
 	"github.com/xanzy/go-gitlab"
+	"net/url"
 	"strconv"
 	"strings"
 )
@@ -61,7 +62,7 @@ func (ie IssueEvent) IsError() (bool, error) {
 	return false, nil
 }
 
-func (ie IssueEvent) ParentSpanID() (*string, error) {
+func (ie IssueEvent) References() ([]eventsources.Reference, error) {
 	return nil, nil
 }
 
@@ -97,6 +98,10 @@ func (ie IssueEvent) Tags() (map[string]interface{}, error) {
 
 type MergeEvent struct {
 	*gitlab.MergeEvent
+	references traces.References
+	// commits are the messages of the commits pushed to the merge
+	// request's source branch
+	commits []string
 }
 
 func (me MergeEvent) Timings() (eventsources.EventTimings, error) {
@@ -145,18 +150,27 @@ func (me MergeEvent) IsError() (bool, error) {
 	return false, nil
 }
 
-func (me MergeEvent) ParentSpanID() (*string, error) {
-	matches, err := traces.Matches(me.ObjectAttributes.Description)
-	if err != nil {
-		return nil, err
+// References inspects the merge request's source branch, title,
+// description and the messages of the commits pushed to its source
+// branch, or else its last commit, for any references to the issues it
+// fixes, ie `Closes #12` and jira keys.
+func (me MergeEvent) References() ([]eventsources.Reference, error) {
+	vars := map[string]string{
+		"project":   me.Project.Name,
+		"namespace": me.Project.Namespace,
 	}
-	log.Debugf("MergeEvent.ParentSpanID() matches %+v", matches)
-
-	if len(matches) == 0 {
-		return nil, nil
+	commits := me.commits
+	if len(commits) == 0 {
+		commits = []string{me.ObjectAttributes.LastCommit.Message}
 	}
-
-	return &matches[0], nil
+	texts := append([]string{
+		me.ObjectAttributes.SourceBranch,
+		me.ObjectAttributes.Title,
+		me.ObjectAttributes.Description,
+	}, commits...)
+	parents := me.references.All(sourceName, vars, texts...)
+	log.Debugf("MergeEvent.References() parents %v", parents)
+	return eventsources.Fixes(parents), nil
 }
 
 func (me MergeEvent) Tags() (map[string]interface{}, error) {
@@ -176,12 +190,18 @@ func (me MergeEvent) Tags() (map[string]interface{}, error) {
 
 	tags["scm.base.label"] = me.ObjectAttributes.SourceBranch
 	tags["scm.target.label"] = me.ObjectAttributes.TargetBranch
+	tags["scm.head.ref"] = me.ObjectAttributes.SourceBranch
+	tags["scm.head.sha"] = me.ObjectAttributes.LastCommit.ID
+	if me.ObjectAttributes.MergeCommitSHA != "" {
+		tags["scm.merge.sha"] = me.ObjectAttributes.MergeCommitSHA
+	}
 
 	return tags, nil
 }
 
 type PipelineEvent struct {
 	*gitlab.PipelineEvent
+	references traces.References
 }
 
 func (pe PipelineEvent) Timings() (eventsources.EventTimings, error) {
@@ -189,17 +209,23 @@ func (pe PipelineEvent) Timings() (eventsources.EventTimings, error) {
 }
 
 func (pe PipelineEvent) OperationName() string {
-	return "pipeline"
+	return types.PipelineEventType
 }
 
-func (pe PipelineEvent) SpanID() (string, error) {
+// pipelineSpanID identifies a pipeline of the project at the path, jobs
+// reference their pipeline through this id.
+func pipelineSpanID(projectPath string, id int) string {
 	return strings.Join([]string{
 		"vstrace",
 		sourceName,
 		types.BuildEventType,
-		pe.Project.Name,
-		strconv.Itoa(pe.ObjectAttributes.ID),
-	}, "-"), nil
+		projectPath,
+		strconv.Itoa(id),
+	}, "-")
+}
+
+func (pe PipelineEvent) SpanID() (string, error) {
+	return pipelineSpanID(pe.Project.PathWithNamespace, pe.ObjectAttributes.ID), nil
 }
 
 func (pe PipelineEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
@@ -238,11 +264,28 @@ func (pe PipelineEvent) IsError() (bool, error) {
 	return isErr, nil
 }
 
-// ParentSpanID inspects the pipeline payload for the causing event:
-// - Pull Request
-// - Issue
-func (pe PipelineEvent) ParentSpanID() (*string, error) {
-	return nil, nil
+// References inspects the pipeline payload for the causing event:
+// - Merge Request, when the pipeline was triggered for one
+// - Any trace referenced by the pipeline's branch name
+func (pe PipelineEvent) References() ([]eventsources.Reference, error) {
+	if pe.MergeRequest.IID != 0 {
+		id := strings.Join([]string{
+			"vstrace",
+			sourceName,
+			types.PullRequestEventType,
+			pe.Project.Name,
+			strconv.Itoa(pe.MergeRequest.IID),
+		}, "-")
+		return eventsources.ChildOf(&id), nil
+	}
+
+	vars := map[string]string{
+		"project":   pe.Project.Name,
+		"namespace": pe.Project.Namespace,
+	}
+	parent := pe.references.First(sourceName, vars, pe.ObjectAttributes.Ref)
+	log.Debugf("PipelineEvent.References() parent %v", parent)
+	return eventsources.ChildOf(parent), nil
 }
 
 func (pe PipelineEvent) Tags() (map[string]interface{}, error) {
@@ -273,6 +316,10 @@ func (pe PipelineEvent) Tags() (map[string]interface{}, error) {
 	sID, _ := pe.SpanID()
 	tags["vstrace.span.id"] = sID
 
+	if parentID, _ := eventsources.ParentSpanID(pe); parentID != nil {
+		tags["vstrace.parent.id"] = *parentID
+	}
+
 	state, _ := pe.State(nil)
 	tags["vstrace.state"] = state
 
@@ -281,6 +328,11 @@ func (pe PipelineEvent) Tags() (map[string]interface{}, error) {
 
 type JobEvent struct {
 	*gitlab.JobEvent
+
+	// PipelineID isn't exposed by the go-gitlab client
+	PipelineID int `json:"pipeline_id"`
+
+	pipelineSpanID *string
 }
 
 func (je JobEvent) Timings() (eventsources.EventTimings, error) {
@@ -292,12 +344,30 @@ func (je JobEvent) OperationName() string {
 
 }
 
+// projectPath is the path of the job's project, like its pipeline's
+// `path_with_namespace`.  Job payloads don't include it, so it's taken
+// from the repository's homepage.
+func (je JobEvent) projectPath() string {
+	if je.Repository == nil {
+		return je.ProjectName
+	}
+	if je.Repository.PathWithNamespace != "" {
+		return je.Repository.PathWithNamespace
+	}
+	if u, err := url.Parse(je.Repository.Homepage); err == nil && u.Path != "" {
+		return strings.Trim(u.Path, "/")
+	}
+	return je.ProjectName
+}
+
+// SpanID is prefixed apart from the pipelines' ids, as jobs and pipelines
+// are numbered separately.
 func (je JobEvent) SpanID() (string, error) {
 	return strings.Join([]string{
 		"vstrace",
 		sourceName,
-		types.BuildEventType,
-		je.ProjectName,
+		types.JobEventType,
+		je.projectPath(),
 		strconv.Itoa(je.BuildID),
 	}, "-"), nil
 }
@@ -344,17 +414,16 @@ func (je JobEvent) IsError() (bool, error) {
 	return isErr, nil
 }
 
-func (je JobEvent) ParentSpanID() (*string, error) {
-	// TODO
-	id := strings.Join([]string{
-		"vstrace",
-		sourceName,
-		types.BuildEventType,
-		je.Repository.Name,
-		strconv.Itoa(je.Commit.ID),
-	}, "-")
+// References the pipeline the job is a part of.  Older gitlab
+// versions don't include the pipeline_id in the payload, in which case
+// the pipeline is found from the jobs the Source has seen it report.
+func (je JobEvent) References() ([]eventsources.Reference, error) {
+	if je.PipelineID == 0 || je.Repository == nil {
+		return eventsources.ChildOf(je.pipelineSpanID), nil
+	}
 
-	return &id, nil
+	id := pipelineSpanID(je.projectPath(), je.PipelineID)
+	return eventsources.ChildOf(&id), nil
 }
 
 func (je JobEvent) Tags() (map[string]interface{}, error) {
@@ -386,11 +455,396 @@ func (je JobEvent) Tags() (map[string]interface{}, error) {
 	sID, _ := je.SpanID()
 	tags["vstrace.span.id"] = sID
 
-	parentID, _ := je.ParentSpanID()
-	tags["vstrace.parent.id"] = *parentID
+	if parentID, _ := eventsources.ParentSpanID(je); parentID != nil {
+		tags["vstrace.parent.id"] = *parentID
+	}
 
 	state, _ := je.State(nil)
 	tags["vstrace.state"] = state
 
 	return tags, nil
 }
+
+const (
+	eventTypeDeployment gitlab.EventType = "Deployment Hook"
+	eventTypeRelease    gitlab.EventType = "Release Hook"
+
+	emptySHA     string = "0000000000000000000000000000000000000000"
+	tagPrefix    string = "refs/tags/"
+	branchPrefix string = "refs/heads/"
+)
+
+type project struct {
+	ID                int    `json:"id"`
+	Name              string `json:"name"`
+	Namespace         string `json:"namespace"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	WebURL            string `json:"web_url"`
+	Visibility        string `json:"visibility"`
+}
+
+// Deployment is the payload of a gitlab `Deployment Hook`, which the
+// go-gitlab client doesn't model yet.
+type Deployment struct {
+	ObjectKind      string  `json:"object_kind"`
+	Status          string  `json:"status"`
+	StatusChangedAt string  `json:"status_changed_at"`
+	DeploymentID    int     `json:"deployment_id"`
+	DeployableID    int     `json:"deployable_id"`
+	DeployableURL   string  `json:"deployable_url"`
+	Environment     string  `json:"environment"`
+	Ref             string  `json:"ref"`
+	ShortSHA        string  `json:"short_sha"`
+	CommitURL       string  `json:"commit_url"`
+	CommitTitle     string  `json:"commit_title"`
+	Project         project `json:"project"`
+	User            struct {
+		Name     string `json:"name"`
+		Username string `json:"username"`
+	} `json:"user"`
+}
+
+type DeploymentEvent struct {
+	*Deployment
+
+	pipelineSpanID *string
+}
+
+func (de DeploymentEvent) Timings() (eventsources.EventTimings, error) {
+	return eventsources.EventTimings{}, nil
+}
+
+func (de DeploymentEvent) OperationName() string {
+	return types.DeployEventType
+}
+
+// ServiceName is the name of the project.
+func (de DeploymentEvent) ServiceName() string {
+	return de.Project.Name
+}
+
+func (de DeploymentEvent) SpanID() (string, error) {
+	if de.DeploymentID == 0 {
+		return "", fmt.Errorf("event does not contain deployment_id")
+	}
+
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		types.DeployEventType,
+		de.Project.Name,
+		strconv.Itoa(de.DeploymentID),
+	}, "-"), nil
+}
+
+// State identifies the current state of the deployment, valid states are:
+// - running
+// - success
+// - failed
+// - canceled
+func (de DeploymentEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	if de.Status == "" {
+		return eventsources.UnknownState, fmt.Errorf("event does not contain status")
+	}
+
+	log.Debugf("event state: %q", de.Status)
+
+	switch de.Status {
+	case "running":
+		return eventsources.StartState, nil
+	case "success", "failed", "canceled":
+		return eventsources.EndState, nil
+	}
+
+	return eventsources.IntermediaryState, nil
+}
+
+func (de DeploymentEvent) IsError() (bool, error) {
+	return de.Status == "failed" || de.Status == "canceled", nil
+}
+
+// References the pipeline which ran the deployable job.
+func (de DeploymentEvent) References() ([]eventsources.Reference, error) {
+	return eventsources.ChildOf(de.pipelineSpanID), nil
+}
+
+func (de DeploymentEvent) Tags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+	tags["event.type"] = "deployment"
+	tags["event.state"] = de.Status
+
+	tags["project.id"] = de.Project.ID
+	tags["project.name"] = de.Project.Name
+	tags["project.namespace"] = de.Project.Namespace
+	tags["project.path_with_namespace"] = de.Project.PathWithNamespace
+	tags["project.url"] = de.Project.WebURL
+
+	tags["user.name"] = de.User.Name
+	tags["user.username"] = de.User.Username
+
+	tags["deploy.id"] = de.DeploymentID
+	tags["deploy.environment"] = de.Environment
+	tags["deploy.status"] = de.Status
+	tags["deploy.ref"] = de.Ref
+	tags["deploy.deployable.id"] = de.DeployableID
+	tags["deploy.deployable.url"] = de.DeployableURL
+
+	tags["scm.commit.short_sha"] = de.ShortSHA
+	tags["scm.commit.url"] = de.CommitURL
+	tags["scm.commit.title"] = de.CommitTitle
+
+	sID, _ := de.SpanID()
+	tags["vstrace.span.id"] = sID
+
+	if de.pipelineSpanID != nil {
+		tags["vstrace.parent.id"] = *de.pipelineSpanID
+	}
+
+	return tags, nil
+}
+
+// tagSpanID identifies the span of a tag of the project, the deploy of a
+// pushed tag or the release created for it.
+func tagSpanID(operationName string, projectName string, tag string) (string, error) {
+	if tag == "" {
+		return "", fmt.Errorf("event does not contain tag")
+	}
+
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		operationName,
+		projectName,
+		tag,
+	}, "-"), nil
+}
+
+type TagEvent struct {
+	*gitlab.TagEvent
+
+	pipelineSpanID *string
+}
+
+func (te TagEvent) Timings() (eventsources.EventTimings, error) {
+	return eventsources.EventTimings{}, nil
+}
+
+func (te TagEvent) OperationName() string {
+	return types.DeployEventType
+}
+
+func (te TagEvent) tag() string {
+	return strings.TrimPrefix(te.Ref, tagPrefix)
+}
+
+func (te TagEvent) deleted() bool {
+	return te.After == emptySHA
+}
+
+func (te TagEvent) SpanID() (string, error) {
+	return tagSpanID(types.DeployEventType, te.Project.Name, te.tag())
+}
+
+// State deploys the tag when it's pushed, the deploy starts and ends with
+// the push so it's never left open waiting on a release which may never be
+// created.  Deleting a tag deploys nothing.
+func (te TagEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	if te.Ref == "" {
+		return eventsources.UnknownState, fmt.Errorf("event does not contain ref")
+	}
+
+	if te.deleted() {
+		return eventsources.UnknownState, nil
+	}
+
+	return eventsources.CompleteState, nil
+}
+
+func (te TagEvent) IsError() (bool, error) {
+	return te.deleted(), nil
+}
+
+// References the pipeline currently running for the tagged commit.
+func (te TagEvent) References() ([]eventsources.Reference, error) {
+	return eventsources.ChildOf(te.pipelineSpanID), nil
+}
+
+func (te TagEvent) Tags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+	tags["event.type"] = "tag_push"
+
+	tags["project.id"] = te.ProjectID
+	tags["project.name"] = te.Project.Name
+	tags["project.namespace"] = te.Project.Namespace
+	tags["project.path_with_namespace"] = te.Project.PathWithNamespace
+	tags["project.url"] = te.Project.WebURL
+
+	tags["user.id"] = te.UserID
+	tags["user.name"] = te.UserName
+
+	tags["deploy.tag"] = te.tag()
+	tags["deploy.message"] = te.Message
+
+	tags["scm.commit.sha"] = te.CheckoutSHA
+	tags["scm.before_sha"] = te.Before
+	tags["scm.after_sha"] = te.After
+
+	sID, _ := te.SpanID()
+	tags["vstrace.span.id"] = sID
+
+	if te.pipelineSpanID != nil {
+		tags["vstrace.parent.id"] = *te.pipelineSpanID
+	}
+
+	return tags, nil
+}
+
+// Release is the payload of a gitlab `Release Hook`, which the
+// go-gitlab client doesn't model yet.
+type Release struct {
+	ID          int     `json:"id"`
+	ObjectKind  string  `json:"object_kind"`
+	Action      string  `json:"action"`
+	Name        string  `json:"name"`
+	Tag         string  `json:"tag"`
+	Description string  `json:"description"`
+	URL         string  `json:"url"`
+	CreatedAt   string  `json:"created_at"`
+	ReleasedAt  string  `json:"released_at"`
+	Project     project `json:"project"`
+	Commit      struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+		Title   string `json:"title"`
+		URL     string `json:"url"`
+	} `json:"commit"`
+}
+
+type ReleaseEvent struct {
+	*Release
+}
+
+func (re ReleaseEvent) Timings() (eventsources.EventTimings, error) {
+	return eventsources.EventTimings{}, nil
+}
+
+// OperationName is a release rather than a deploy, the push of the release's
+// tag already traced its deploy.
+func (re ReleaseEvent) OperationName() string {
+	return types.ReleaseEventType
+}
+
+func (re ReleaseEvent) SpanID() (string, error) {
+	return tagSpanID(types.ReleaseEventType, re.Project.Name, re.Tag)
+}
+
+// State traces the release when it's created, updates to the release are
+// ignored.
+func (re ReleaseEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	if re.Action == "" {
+		return eventsources.UnknownState, fmt.Errorf("event does not contain action")
+	}
+
+	log.Debugf("event action: %q", re.Action)
+
+	if re.Action == "create" {
+		return eventsources.CompleteState, nil
+	}
+
+	return eventsources.UnknownState, nil
+}
+
+func (re ReleaseEvent) IsError() (bool, error) {
+	return false, nil
+}
+
+func (re ReleaseEvent) References() ([]eventsources.Reference, error) {
+	return nil, nil
+}
+
+func (re ReleaseEvent) Tags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+	tags["event.type"] = "release"
+	tags["event.action"] = re.Action
+
+	tags["project.id"] = re.Project.ID
+	tags["project.name"] = re.Project.Name
+	tags["project.namespace"] = re.Project.Namespace
+	tags["project.path_with_namespace"] = re.Project.PathWithNamespace
+	tags["project.url"] = re.Project.WebURL
+
+	tags["deploy.tag"] = re.Tag
+	tags["deploy.release.name"] = re.Name
+	tags["deploy.release.url"] = re.URL
+	tags["deploy.release.released_at"] = re.ReleasedAt
+
+	tags["scm.commit.sha"] = re.Commit.ID
+	tags["scm.commit.url"] = re.Commit.URL
+
+	sID, _ := re.SpanID()
+	tags["vstrace.span.id"] = sID
+
+	return tags, nil
+}
+
+// PushEvent records the messages of the commits pushed to a branch, for
+// the merge requests of the branch, it isn't traced itself.
+type PushEvent struct {
+	*gitlab.PushEvent
+}
+
+// branch is the name of the branch pushed to, it's empty when the branch
+// was deleted.
+func (pe PushEvent) branch() string {
+	if pe.After == emptySHA || !strings.HasPrefix(pe.Ref, branchPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(pe.Ref, branchPrefix)
+}
+
+func (pe PushEvent) messages() []string {
+	var messages []string
+	for _, c := range pe.Commits {
+		if c != nil && c.Message != "" {
+			messages = append(messages, c.Message)
+		}
+	}
+	return messages
+}
+
+func (pe PushEvent) SpanID() (string, error) {
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		"push",
+		pe.Project.Name,
+		pe.After,
+	}, "-"), nil
+}
+
+func (pe PushEvent) OperationName() string {
+	return "push"
+}
+
+func (pe PushEvent) References() ([]eventsources.Reference, error) {
+	return nil, nil
+}
+
+func (pe PushEvent) IsError() (bool, error) {
+	return false, nil
+}
+
+func (pe PushEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	return eventsources.UnknownState, nil
+}
+
+func (pe PushEvent) Tags() (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func (pe PushEvent) Timings() (eventsources.EventTimings, error) {
+	return eventsources.EventTimings{}, nil
+}
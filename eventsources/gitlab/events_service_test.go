@@ -1,3 +1,4 @@
+//go:build service
 // +build service
 
 package gitlab
@@ -54,7 +55,7 @@ var eventTests = []struct {
 			"build.tag":                   false,
 			"event.state":                 "pending",
 			"project.id":                  float64(1.5119184e+07),
-			"vstrace.span.id":             "vstrace-gitlab-build-test-project-96963426",
+			"vstrace.span.id":             "vstrace-gitlab-build-dm03514/test-project-96963426",
 			"build.id":                    float64(9.6963426e+07),
 			"build.sha":                   "304839c04c12d78a94b9b521c237c83ec84e826d",
 			"user.username":               "dm03514",
@@ -67,6 +68,7 @@ var eventTests = []struct {
 			"error":                       false,
 			"merge_request.id":            float64(1),
 			"merge_request.url":           "https://gitlab.com/dm03514/test-project/merge_requests/1",
+			"vstrace.parent.id":           "vstrace-gitlab-pull_request-test-project-1",
 		},
 	},
 	{
@@ -83,7 +85,7 @@ var eventTests = []struct {
 			"build.tag":                   false,
 			"event.state":                 "running",
 			"project.id":                  float64(1.5119184e+07),
-			"vstrace.span.id":             "vstrace-gitlab-build-test-project-96963426",
+			"vstrace.span.id":             "vstrace-gitlab-build-dm03514/test-project-96963426",
 			"build.id":                    float64(9.6963426e+07),
 			"build.sha":                   "304839c04c12d78a94b9b521c237c83ec84e826d",
 			"user.username":               "dm03514",
@@ -96,6 +98,7 @@ var eventTests = []struct {
 			"error":                       false,
 			"merge_request.id":            float64(1),
 			"merge_request.url":           "https://gitlab.com/dm03514/test-project/merge_requests/1",
+			"vstrace.parent.id":           "vstrace-gitlab-pull_request-test-project-1",
 		},
 	},
 	{
@@ -166,6 +169,8 @@ var eventTests = []struct {
 			"project.path_with_namespace": "dm03514/test-project",
 			"project.visibility":          "",
 			"scm.base.label":              "feature/test",
+			"scm.head.ref":                "feature/test",
+			"scm.head.sha":                "304839c04c12d78a94b9b521c237c83ec84e826d",
 		},
 	},
 	{
@@ -186,6 +191,113 @@ var eventTests = []struct {
 			"project.path_with_namespace": "dm03514/test-project",
 			"project.visibility":          "",
 			"scm.base.label":              "feature/test",
+			"scm.head.ref":                "feature/test",
+			"scm.head.sha":                "304839c04c12d78a94b9b521c237c83ec84e826d",
+		},
+	},
+	{
+		Name:                  "deployment_running_success",
+		StartEventPath:        "fixtures/events/deployment/running.json",
+		EndEventPath:          "fixtures/events/deployment/success.json",
+		ExpectedOperationName: "deploy",
+		ExpectedTags: map[string]interface{}{
+			"service":                     "gitlab",
+			"event.type":                  "deployment",
+			"event.state":                 "running",
+			"project.id":                  float64(1.5119184e+07),
+			"project.name":                "test-project",
+			"project.namespace":           "Daniel Mican",
+			"project.path_with_namespace": "dm03514/test-project",
+			"project.url":                 "https://gitlab.com/dm03514/test-project",
+			"user.name":                   "Daniel Mican",
+			"user.username":               "dm03514",
+			"deploy.id":                   float64(15),
+			"deploy.environment":          "staging",
+			"deploy.status":               "running",
+			"deploy.ref":                  "master",
+			"deploy.deployable.id":        float64(3.556219e+08),
+			"deploy.deployable.url":       "https://gitlab.com/dm03514/test-project/-/jobs/355621900",
+			"scm.commit.short_sha":        "304839c0",
+			"scm.commit.url":              "https://gitlab.com/dm03514/test-project/-/commit/304839c04c12d78a94b9b521c237c83ec84e826d",
+			"scm.commit.title":            "Add deploy stage",
+			"vstrace.span.id":             "vstrace-gitlab-deploy-test-project-15",
+			"error":                       false,
+		},
+	},
+	{
+		Name:                  "deployment_running_failed",
+		StartEventPath:        "fixtures/events/deployment/running.json",
+		EndEventPath:          "fixtures/events/deployment/failed.json",
+		ExpectedOperationName: "deploy",
+		ExpectedTags: map[string]interface{}{
+			"service":                     "gitlab",
+			"event.type":                  "deployment",
+			"event.state":                 "running",
+			"project.id":                  float64(1.5119184e+07),
+			"project.name":                "test-project",
+			"project.namespace":           "Daniel Mican",
+			"project.path_with_namespace": "dm03514/test-project",
+			"project.url":                 "https://gitlab.com/dm03514/test-project",
+			"user.name":                   "Daniel Mican",
+			"user.username":               "dm03514",
+			"deploy.id":                   float64(15),
+			"deploy.environment":          "staging",
+			"deploy.status":               "running",
+			"deploy.ref":                  "master",
+			"deploy.deployable.id":        float64(3.556219e+08),
+			"deploy.deployable.url":       "https://gitlab.com/dm03514/test-project/-/jobs/355621900",
+			"scm.commit.short_sha":        "304839c0",
+			"scm.commit.url":              "https://gitlab.com/dm03514/test-project/-/commit/304839c04c12d78a94b9b521c237c83ec84e826d",
+			"scm.commit.title":            "Add deploy stage",
+			"vstrace.span.id":             "vstrace-gitlab-deploy-test-project-15",
+			"error":                       true,
+		},
+	},
+	{
+		Name:                  "tag_push_deleted",
+		StartEventPath:        "fixtures/events/release/tag_push.json",
+		EndEventPath:          "fixtures/events/release/tag_deleted.json",
+		ExpectedOperationName: "deploy",
+		ExpectedTags: map[string]interface{}{
+			"service":                     "gitlab",
+			"event.type":                  "tag_push",
+			"project.id":                  float64(1.5119184e+07),
+			"project.name":                "test-project",
+			"project.namespace":           "Daniel Mican",
+			"project.path_with_namespace": "dm03514/test-project",
+			"project.url":                 "https://gitlab.com/dm03514/test-project",
+			"user.id":                     float64(4.890303e+06),
+			"user.name":                   "Daniel Mican",
+			"deploy.tag":                  "v1.0.0",
+			"deploy.message":              "first release",
+			"scm.commit.sha":              "304839c04c12d78a94b9b521c237c83ec84e826d",
+			"scm.before_sha":              "0000000000000000000000000000000000000000",
+			"scm.after_sha":               "82b3d5ae55f7080f1e6022629cdb57bfae7cccc7",
+			"vstrace.span.id":             "vstrace-gitlab-deploy-test-project-v1.0.0",
+			"error":                       false,
+		},
+	},
+	{
+		Name:                  "release_created",
+		StartEventPath:        "fixtures/events/release/created.json",
+		ExpectedOperationName: "release",
+		ExpectedTags: map[string]interface{}{
+			"service":                     "gitlab",
+			"event.type":                  "release",
+			"event.action":                "create",
+			"project.id":                  float64(1.5119184e+07),
+			"project.name":                "test-project",
+			"project.namespace":           "Daniel Mican",
+			"project.path_with_namespace": "dm03514/test-project",
+			"project.url":                 "https://gitlab.com/dm03514/test-project",
+			"deploy.tag":                  "v1.0.0",
+			"deploy.release.name":         "v1.0.0",
+			"deploy.release.url":          "https://gitlab.com/dm03514/test-project/-/releases/v1.0.0",
+			"deploy.release.released_at":  "2019-11-19 12:50:00 UTC",
+			"scm.commit.sha":              "304839c04c12d78a94b9b521c237c83ec84e826d",
+			"scm.commit.url":              "https://gitlab.com/dm03514/test-project/-/commit/304839c04c12d78a94b9b521c237c83ec84e826d",
+			"vstrace.span.id":             "vstrace-gitlab-release-test-project-v1.0.0",
+			"error":                       false,
 		},
 	},
 	{
@@ -197,8 +309,8 @@ var eventTests = []struct {
 			"build.project.id":       float64(1.5119184e+07),
 			"event.state":            "created",
 			"scm.commit.id":          float64(9.7138643e+07),
-			"vstrace.parent.id":      "vstrace-gitlab-build-test-project-97138643",
-			"vstrace.span.id":        "vstrace-gitlab-build-Daniel Mican / test-project-355621877",
+			"vstrace.parent.id":      "vstrace-gitlab-build-dm03514/test-project-97138643",
+			"vstrace.span.id":        "vstrace-gitlab-job-dm03514/test-project-355621877",
 			"build.allow_failure":    false,
 			"build.name":             "install_dependencies",
 			"build.sha":              "304839c04c12d78a94b9b521c237c83ec84e826d",
@@ -229,8 +341,8 @@ var eventTests = []struct {
 			"build.project.id":       float64(1.5119184e+07),
 			"event.state":            "running",
 			"scm.commit.id":          float64(9.7138643e+07),
-			"vstrace.parent.id":      "vstrace-gitlab-build-test-project-97138643",
-			"vstrace.span.id":        "vstrace-gitlab-build-Daniel Mican / test-project-355621877",
+			"vstrace.parent.id":      "vstrace-gitlab-build-dm03514/test-project-97138643",
+			"vstrace.span.id":        "vstrace-gitlab-job-dm03514/test-project-355621877",
 			"build.allow_failure":    false,
 			"build.name":             "install_dependencies",
 			"build.sha":              "304839c04c12d78a94b9b521c237c83ec84e826d",
@@ -274,6 +386,10 @@ func TestServiceEvent_Gitlab(t *testing.T) {
 				tt.EndEventPath,
 			}
 			for _, eventPath := range eventPaths {
+				// events completing their spans on their own have no end event
+				if eventPath == "" {
+					continue
+				}
 				te, err = eventsources.NewTestEventFromFixturePath(eventPath)
 
 				rawPayload, err := json.Marshal(te.Payload)
@@ -312,3 +428,77 @@ func TestServiceEvent_Gitlab(t *testing.T) {
 		})
 	}
 }
+
+func TestServiceTrace_Gitlab_MergeRequestPipeline(t *testing.T) {
+	client := &http.Client{}
+	u, err := url.Parse(baseURL + gitlabPath)
+	assert.NoError(t, err)
+
+	resp, err := http.Get(baseURL + "/mocktracer/reset")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	eventPaths := []string{
+		"fixtures/traces/merge_request_pipeline/01_issue_opened.json",
+		"fixtures/traces/merge_request_pipeline/02_merge_request_opened.json",
+		"fixtures/traces/merge_request_pipeline/03_pipeline_pending.json",
+		"fixtures/traces/merge_request_pipeline/04_job_created.json",
+		"fixtures/traces/merge_request_pipeline/05_job_success.json",
+		"fixtures/traces/merge_request_pipeline/06_pipeline_success.json",
+		"fixtures/traces/merge_request_pipeline/07_merge_request_closed.json",
+		"fixtures/traces/merge_request_pipeline/08_issue_closed.json",
+	}
+	for _, eventPath := range eventPaths {
+		te, err := eventsources.NewTestEventFromFixturePath(eventPath)
+		assert.NoError(t, err)
+
+		rawPayload, err := json.Marshal(te.Payload)
+		assert.NoError(t, err)
+
+		eventResp, err := PostEvent(
+			rawPayload,
+			te.Headers["X-Gitlab-Event"],
+			u,
+			client,
+		)
+
+		assert.NoError(t, err)
+		eventResp.Body.Close()
+		assert.Equal(t, http.StatusOK, eventResp.StatusCode, eventPath)
+	}
+
+	spansResp, err := http.Get(baseURL + "/mocktracer/finished-spans")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, spansResp.StatusCode)
+
+	bs, err := ioutil.ReadAll(spansResp.Body)
+	assert.NoError(t, err)
+	spansResp.Body.Close()
+
+	var spans []tracers.TestSpan
+
+	err = json.Unmarshal(bs, &spans)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 4, len(spans))
+
+	byOperation := make(map[string]tracers.TestSpan)
+	for _, s := range spans {
+		byOperation[s.Span.OperationName] = s
+	}
+
+	issue, mr, pipeline, job := byOperation["issue"], byOperation["pull_request"], byOperation["pipeline"], byOperation["build"]
+	if issue.Span == nil || mr.Span == nil || pipeline.Span == nil || job.Span == nil {
+		t.Fatalf("expected issue, pull_request, pipeline and build spans, received: %+v", byOperation)
+	}
+
+	assert.Equal(t, 0, issue.Span.ParentID)
+	assert.Equal(t, issue.Span.SpanContext.SpanID, mr.Span.ParentID)
+	assert.Equal(t, mr.Span.SpanContext.SpanID, pipeline.Span.ParentID)
+	assert.Equal(t, pipeline.Span.SpanContext.SpanID, job.Span.ParentID)
+
+	for _, s := range spans {
+		assert.Equal(t, issue.Span.SpanContext.TraceID, s.Span.SpanContext.TraceID)
+	}
+}
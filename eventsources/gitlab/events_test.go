@@ -1,9 +1,116 @@
 package gitlab
 
 import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"github.com/xanzy/go-gitlab"
 	"testing"
 )
 
 func TestIssueEvent_Timings_Duration(t *testing.T) {
 	t.Skip()
 }
+
+func TestPipelineEvent_ParentSpanID(t *testing.T) {
+	mergeRequest := &gitlab.PipelineEvent{}
+	mergeRequest.Project.Name = "test-project"
+	mergeRequest.MergeRequest.IID = 3
+
+	branch := &gitlab.PipelineEvent{}
+	branch.Project.Name = "test-project"
+	branch.ObjectAttributes.Ref = "feature/vstrace-gitlab-issue-test-project-8"
+
+	testCases := []struct {
+		name     string
+		pe       PipelineEvent
+		expected *string
+	}{
+		{"merge_request", PipelineEvent{PipelineEvent: mergeRequest}, strPtr("vstrace-gitlab-pull_request-test-project-3")},
+		{"branch_name_contains", PipelineEvent{PipelineEvent: branch}, strPtr("vstrace-gitlab-issue-test-project-8")},
+		{"no_parent", PipelineEvent{PipelineEvent: &gitlab.PipelineEvent{}}, nil},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			parentID, err := eventsources.ParentSpanID(tt.pe)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, parentID)
+		})
+	}
+}
+
+func TestMergeEvent_ParentSpanID(t *testing.T) {
+	me := &gitlab.MergeEvent{}
+	me.Project.Name = "test-project"
+	me.ObjectAttributes.Description = "Adds login, closes #8"
+
+	parentID, err := eventsources.ParentSpanID(MergeEvent{MergeEvent: me})
+	assert.NoError(t, err)
+	assert.Equal(t, strPtr("vstrace-gitlab-issue-test-project-8"), parentID)
+}
+
+func TestMergeEvent_References(t *testing.T) {
+	me := &gitlab.MergeEvent{}
+	me.Project.Name = "test-project"
+	me.ObjectAttributes.SourceBranch = "ABC-1-login"
+	me.ObjectAttributes.Title = "Add login"
+	me.ObjectAttributes.Description = "Closes #8"
+	me.ObjectAttributes.LastCommit.Message = "Fixes #9, closes #8"
+
+	refs, err := MergeEvent{MergeEvent: me}.References()
+	assert.NoError(t, err)
+	assert.Equal(t, eventsources.Fixes([]string{
+		"vstrace-jiracloud-issue-ABC-1",
+		"vstrace-gitlab-issue-test-project-8",
+		"vstrace-gitlab-issue-test-project-9",
+	}), refs)
+}
+
+func TestJobEvent_ParentSpanID(t *testing.T) {
+	je := JobEvent{
+		JobEvent: &gitlab.JobEvent{
+			Repository: &gitlab.Repository{
+				Name:     "test-project",
+				Homepage: "https://gitlab.com/dm03514/test-project",
+			},
+		},
+		PipelineID: 96963426,
+	}
+	parentID, err := eventsources.ParentSpanID(je)
+	assert.NoError(t, err)
+	assert.Equal(t, strPtr("vstrace-gitlab-build-dm03514/test-project-96963426"), parentID)
+
+	// without a pipeline_id the pipeline the Source observed is used
+	je = JobEvent{
+		JobEvent:       &gitlab.JobEvent{},
+		pipelineSpanID: strPtr("vstrace-gitlab-build-dm03514/test-project-1"),
+	}
+	parentID, err = eventsources.ParentSpanID(je)
+	assert.NoError(t, err)
+	assert.Equal(t, strPtr("vstrace-gitlab-build-dm03514/test-project-1"), parentID)
+}
+
+func TestJobEvent_SpanID(t *testing.T) {
+	je := JobEvent{
+		JobEvent: &gitlab.JobEvent{
+			BuildID:     96963426,
+			ProjectName: "Daniel Mican / test-project",
+			Repository: &gitlab.Repository{
+				Name:     "test-project",
+				Homepage: "https://gitlab.com/dm03514/test-project",
+			},
+		},
+		PipelineID: 96963426,
+	}
+	spanID, err := je.SpanID()
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-gitlab-job-dm03514/test-project-96963426", spanID)
+
+	// a job and a pipeline numbered alike are different spans
+	parentID, err := eventsources.ParentSpanID(je)
+	assert.NoError(t, err)
+	assert.NotEqual(t, spanID, *parentID)
+}
+
+func strPtr(s string) *string {
+	return &s
+}
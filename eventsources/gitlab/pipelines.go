@@ -0,0 +1,83 @@
+package gitlab
+
+import "sync"
+
+// pipelineIndex remembers which pipeline span produced a job or a commit
+// while that pipeline is in flight.  Gitlab deployment and tag hooks (and
+// job hooks from older gitlab versions) don't reference their pipeline
+// directly so this is what's used to link them back to it.
+type pipelineIndex struct {
+	mu   *sync.Mutex
+	jobs map[int]string
+	shas map[string]string
+}
+
+func (pi *pipelineIndex) job(id int) *string {
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	spanID, ok := pi.jobs[id]
+	if !ok {
+		return nil
+	}
+	return &spanID
+}
+
+func (pi *pipelineIndex) sha(sha string) *string {
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	spanID, ok := pi.shas[sha]
+	if !ok {
+		return nil
+	}
+	return &spanID
+}
+
+// observe records (or forgets, once the pipeline has finished) all
+// the jobs and the commit a pipeline is responsible for.
+func (pi *pipelineIndex) observe(pe PipelineEvent) error {
+	spanID, err := pe.SpanID()
+	if err != nil {
+		return err
+	}
+
+	ended := pipelineEnded(pe.ObjectAttributes.Status)
+
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+
+	for _, b := range pe.Builds {
+		if ended {
+			delete(pi.jobs, b.ID)
+			continue
+		}
+		pi.jobs[b.ID] = spanID
+	}
+
+	if sha := pe.ObjectAttributes.SHA; sha != "" {
+		if ended {
+			delete(pi.shas, sha)
+		} else {
+			pi.shas[sha] = spanID
+		}
+	}
+
+	return nil
+}
+
+// pipelineEnded is true for the terminal statuses of a pipeline, every
+// other status, ie manual or waiting_for_resource, is still in flight.
+func pipelineEnded(status string) bool {
+	switch status {
+	case "success", "failed", "canceled", "skipped":
+		return true
+	}
+	return false
+}
+
+func newPipelineIndex() *pipelineIndex {
+	return &pipelineIndex{
+		mu:   &sync.Mutex{},
+		jobs: make(map[int]string),
+		shas: make(map[string]string),
+	}
+}
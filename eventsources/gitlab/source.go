@@ -1,22 +1,32 @@
 package gitlab
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/traces"
 	"github.com/opentracing/opentracing-go"
 	"github.com/urfave/cli"
 	"github.com/xanzy/go-gitlab"
 	"io/ioutil"
 	"net/http"
 	"reflect"
+	"strconv"
 )
 
 const (
-	sourceName string = "gitlab"
+	sourceName  string = "gitlab"
+	tokenHeader string = "X-Gitlab-Token"
 )
 
+var token = eventsources.SecretToken{Header: tokenHeader}
+
 type Source struct {
-	tracer opentracing.Tracer
+	tracer     opentracing.Tracer
+	secretKey  []byte
+	pipelines  *pipelineIndex
+	references traces.References
+	commits    *eventsources.BranchCommits
 }
 
 func (s Source) Name() string {
@@ -28,19 +38,43 @@ func (s *Source) Tracer() opentracing.Tracer {
 }
 
 func (s *Source) SecretKey() []byte {
-	return nil
+	return s.secretKey
 }
 
+// ValidatePayload checks the secret token gitlab sends along with
+// every webhook request, gitlab doesn't sign the payload itself.
 func (s *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
-	if secretKey == nil {
-		return ioutil.ReadAll(r.Body)
+	if secretKey != nil {
+		if err := token.Validate(r, secretKey); err != nil {
+			return nil, eventsources.InvalidSignatureError{Err: err}
+		}
 	}
 
-	return nil, fmt.Errorf("does not support signing right now")
+	return ioutil.ReadAll(r.Body)
 }
 
 func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
 	var err error
+
+	// deployment and release hooks aren't supported by the go-gitlab client
+	switch gitlab.WebhookEventType(r) {
+	case eventTypeDeployment:
+		var d Deployment
+		if err = json.Unmarshal(payload, &d); err != nil {
+			return nil, err
+		}
+		return DeploymentEvent{
+			Deployment:     &d,
+			pipelineSpanID: s.pipelines.job(d.DeployableID),
+		}, nil
+	case eventTypeRelease:
+		var rel Release
+		if err = json.Unmarshal(payload, &rel); err != nil {
+			return nil, err
+		}
+		return ReleaseEvent{&rel}, nil
+	}
+
 	event, err := gitlab.ParseWebhook(gitlab.WebhookEventType(r), payload)
 	if err != nil {
 		return nil, err
@@ -50,23 +84,65 @@ func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, err
 	case *gitlab.IssueEvent:
 		return IssueEvent{event}, nil
 	case *gitlab.MergeEvent:
-		return MergeEvent{event}, nil
+		return MergeEvent{
+			MergeEvent: event,
+			references: s.references,
+			commits: s.commits.Messages(
+				strconv.Itoa(event.ObjectAttributes.SourceProjectID),
+				event.ObjectAttributes.SourceBranch,
+			),
+		}, nil
+	case *gitlab.PushEvent:
+		pe := PushEvent{event}
+		if branch := pe.branch(); branch != "" {
+			s.commits.Push(strconv.Itoa(event.ProjectID), branch, false, pe.messages()...)
+		}
+		return pe, nil
 	case *gitlab.PipelineEvent:
-		return PipelineEvent{event}, nil
+		pe := PipelineEvent{
+			PipelineEvent: event,
+			references:    s.references,
+		}
+		if err = s.pipelines.observe(pe); err != nil {
+			return nil, err
+		}
+		return pe, nil
 	case *gitlab.JobEvent:
-		return JobEvent{event}, nil
+		je := JobEvent{JobEvent: event}
+		if err = json.Unmarshal(payload, &je); err != nil {
+			return nil, err
+		}
+		je.pipelineSpanID = s.pipelines.job(je.BuildID)
+		return je, nil
+	case *gitlab.TagEvent:
+		return TagEvent{
+			TagEvent:       event,
+			pipelineSpanID: s.pipelines.sha(event.CheckoutSHA),
+		}, nil
 	default:
 		err = fmt.Errorf("event type not supported, %+v", reflect.TypeOf(event))
 	}
 	return nil, err
 }
 
-func NewSource(tracer opentracing.Tracer) (eventsources.EventSource, error) {
+func NewSource(tracer opentracing.Tracer, secretKey []byte, references traces.References) (eventsources.EventSource, error) {
 	return &Source{
-		tracer: tracer,
+		tracer:     tracer,
+		secretKey:  secretKey,
+		pipelines:  newPipelineIndex(),
+		references: references,
+		commits:    eventsources.NewBranchCommits(),
 	}, nil
 }
 
 func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
-	return NewSource(tracer)
+	var secretKey []byte
+	if token := c.String("gitlab-secret-token"); token != "" {
+		secretKey = []byte(token)
+	}
+	references, err := traces.ReferencesFromCLI(c)
+	if err != nil {
+		return nil, err
+	}
+	return NewSource(tracer, secretKey, references)
 }
@@ -1,6 +1,14 @@
 package gitlab
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
 
 func TestGitlabTrace_Pipeline_StartEnd(t *testing.T) {
 	t.Skip()
@@ -25,3 +33,179 @@ func TestGitlabTrace_Build_ReferencePipeline(t *testing.T) {
 func TestGitlabTrace_Build_StateTransition(t *testing.T) {
 	t.Skip()
 }
+
+func eventFromFixture(t *testing.T, s *Source, path string) eventsources.Event {
+	te, err := eventsources.NewTestEventFromFixturePath(path)
+	assert.NoError(t, err)
+
+	payload, err := json.Marshal(te.Payload)
+	assert.NoError(t, err)
+
+	r, err := http.NewRequest("POST", "/gitlab", bytes.NewReader(payload))
+	assert.NoError(t, err)
+	r.Header.Set("X-Gitlab-Event", te.Headers["X-Gitlab-Event"])
+
+	e, err := s.Event(r, payload)
+	assert.NoError(t, err)
+	return e
+}
+
+func TestSource_Event_Deployment_ParentPipeline(t *testing.T) {
+	es, err := NewSource(mocktracer.New(), nil, nil)
+	assert.NoError(t, err)
+	s := es.(*Source)
+
+	// the deployable job is unknown until its pipeline has been seen
+	e := eventFromFixture(t, s, "fixtures/events/deployment/running.json")
+	parentID, err := eventsources.ParentSpanID(e)
+	assert.NoError(t, err)
+	assert.Nil(t, parentID)
+
+	pe := eventFromFixture(t, s, "fixtures/events/pipeline/running.json").(PipelineEvent)
+	pe.Builds[0].ID = 355621900
+	assert.NoError(t, s.pipelines.observe(pe))
+
+	e = eventFromFixture(t, s, "fixtures/events/deployment/running.json")
+	parentID, err = eventsources.ParentSpanID(e)
+	assert.NoError(t, err)
+	assert.NotNil(t, parentID)
+	assert.Equal(t, "vstrace-gitlab-build-dm03514/test-project-96963426", *parentID)
+
+	// the tagged commit is being built by the same pipeline
+	e = eventFromFixture(t, s, "fixtures/events/release/tag_push.json")
+	parentID, err = eventsources.ParentSpanID(e)
+	assert.NoError(t, err)
+	assert.NotNil(t, parentID)
+	assert.Equal(t, "vstrace-gitlab-build-dm03514/test-project-96963426", *parentID)
+
+	// once the pipeline finishes it's no longer a candidate parent
+	eventFromFixture(t, s, "fixtures/events/pipeline/success.json")
+	e = eventFromFixture(t, s, "fixtures/events/release/tag_push.json")
+	parentID, err = eventsources.ParentSpanID(e)
+	assert.NoError(t, err)
+	assert.Nil(t, parentID)
+}
+
+func TestPipelineIndex_observe_InFlightStatuses(t *testing.T) {
+	es, err := NewSource(mocktracer.New(), nil, nil)
+	assert.NoError(t, err)
+	s := es.(*Source)
+
+	pe := eventFromFixture(t, s, "fixtures/events/pipeline/running.json").(PipelineEvent)
+	for _, status := range []string{"manual", "scheduled", "preparing", "waiting_for_resource"} {
+		pe.ObjectAttributes.Status = status
+		assert.NoError(t, s.pipelines.observe(pe))
+		assert.NotNil(t, s.pipelines.job(pe.Builds[0].ID), status)
+	}
+
+	for _, status := range []string{"success", "failed", "canceled", "skipped"} {
+		pe.ObjectAttributes.Status = "running"
+		assert.NoError(t, s.pipelines.observe(pe))
+		pe.ObjectAttributes.Status = status
+		assert.NoError(t, s.pipelines.observe(pe))
+		assert.Nil(t, s.pipelines.job(pe.Builds[0].ID), status)
+	}
+}
+
+func TestTagEvent_State(t *testing.T) {
+	testCases := []struct {
+		name          string
+		path          string
+		expectedState eventsources.SpanState
+		expectedErr   bool
+	}{
+		{"pushed", "fixtures/events/release/tag_push.json", eventsources.CompleteState, false},
+		{"deleted", "fixtures/events/release/tag_deleted.json", eventsources.UnknownState, true},
+		{"released", "fixtures/events/release/created.json", eventsources.CompleteState, false},
+		{"deployment_running", "fixtures/events/deployment/running.json", eventsources.StartState, false},
+		{"deployment_failed", "fixtures/events/deployment/failed.json", eventsources.EndState, true},
+	}
+	s, err := NewSource(mocktracer.New(), nil, nil)
+	assert.NoError(t, err)
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			e := eventFromFixture(t, s.(*Source), tt.path)
+			state, err := e.State(nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedState, state)
+
+			isErr, err := e.IsError()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedErr, isErr)
+		})
+	}
+}
+
+func TestSource_ValidatePayload(t *testing.T) {
+	testCases := []struct {
+		name        string
+		secretKey   []byte
+		token       string
+		expectedErr bool
+	}{
+		{"matching_token", []byte("secret"), "secret", false},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSource(mocktracer.New(), tt.secretKey, nil)
+			assert.NoError(t, err)
+
+			r, err := http.NewRequest("POST", "/gitlab", bytes.NewReader([]byte(`{}`)))
+			assert.NoError(t, err)
+			if tt.token != "" {
+				r.Header.Set("X-Gitlab-Token", tt.token)
+			}
+
+			payload, err := s.ValidatePayload(r, s.SecretKey())
+			if !tt.expectedErr {
+				assert.NoError(t, err)
+				assert.Equal(t, []byte(`{}`), payload)
+				return
+			}
+
+			assert.Nil(t, payload)
+			assert.IsType(t, eventsources.InvalidSignatureError{}, err)
+		})
+	}
+}
+
+func TestSource_Event_MergeRequestPushedCommits(t *testing.T) {
+	es, err := NewSource(mocktracer.New(), nil, nil)
+	assert.NoError(t, err)
+
+	event := func(eventType string, payload string) eventsources.Event {
+		r, err := http.NewRequest("POST", "/gitlab", nil)
+		assert.NoError(t, err)
+		r.Header.Set("X-Gitlab-Event", eventType)
+
+		e, err := es.Event(r, []byte(payload))
+		assert.NoError(t, err)
+		return e
+	}
+
+	push := event("Push Hook", `{
+		"object_kind": "push", "ref": "refs/heads/login", "project_id": 15,
+		"after": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+		"commits": [{"message": "Add login form, fixes #8"}, {"message": "ABC-1: validate passwords"}]
+	}`)
+	state, err := push.State(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, eventsources.UnknownState, state)
+
+	mr := event("Merge Request Hook", `{
+		"object_kind": "merge_request",
+		"project": {"name": "test-project"},
+		"object_attributes": {
+			"source_project_id": 15, "source_branch": "login", "title": "Add login",
+			"last_commit": {"message": "ABC-1: validate passwords"}
+		}
+	}`)
+	refs, err := mr.References()
+	assert.NoError(t, err)
+	// the first commit isn't the merge request's last commit
+	assert.Equal(t, eventsources.Fixes([]string{
+		"vstrace-gitlab-issue-test-project-8",
+		"vstrace-jiracloud-issue-ABC-1",
+	}), refs)
+}
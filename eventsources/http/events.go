@@ -2,6 +2,7 @@ package http
 
 import (
 	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/traces"
 	"strings"
 )
 
@@ -13,6 +14,8 @@ type Event struct {
 	Namespace  string
 	Type       string
 	Metadata   map[string]interface{}
+
+	references traces.References
 }
 
 func (e Event) Timings() (eventsources.EventTimings, error) { return eventsources.EventTimings{}, nil }
@@ -33,10 +36,20 @@ func (e Event) OperationName() string {
 	return e.Type
 }
 
-// ParentSpanID allows this HTTP event to reference any other
-// event.
-func (e Event) ParentSpanID() (*string, error) {
-	return e.ParentID, nil
+// References allows this HTTP event to reference any other
+// event, either by its span id or a reference to it, ie a jira key.
+func (e Event) References() ([]eventsources.Reference, error) {
+	if e.ParentID == nil {
+		return nil, nil
+	}
+
+	vars := map[string]string{
+		"namespace": e.Namespace,
+	}
+	if parent := e.references.First(sourceName, vars, *e.ParentID); parent != nil {
+		return eventsources.ChildOf(parent), nil
+	}
+	return eventsources.ChildOf(e.ParentID), nil
 }
 
 func (e Event) IsError() (bool, error) {
@@ -66,7 +79,7 @@ func (e Event) Tags() (map[string]interface{}, error) {
 	sID, _ := e.SpanID()
 	tags[eventsources.SpanIDTag] = sID
 
-	if pID, _ := e.ParentSpanID(); pID != nil {
+	if pID, _ := eventsources.ParentSpanID(e); pID != nil {
 		tags[eventsources.ParentSpanIDTag] = *pID
 	}
 
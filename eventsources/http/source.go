@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/ImpactInsights/valuestream/eventsources"
 	"github.com/ImpactInsights/valuestream/eventsources/webhooks"
+	"github.com/ImpactInsights/valuestream/traces"
 	"github.com/opentracing/opentracing-go"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
@@ -19,7 +20,8 @@ const (
 )
 
 type Source struct {
-	tracer opentracing.Tracer
+	tracer     opentracing.Tracer
+	references traces.References
 }
 
 func (es *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
@@ -36,7 +38,9 @@ func (es *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, er
 		mac.Write(body)
 		expectedMAC := mac.Sum(nil)
 		if !hmac.Equal([]byte(sig), expectedMAC) {
-			return nil, fmt.Errorf("invalid event signature")
+			return nil, eventsources.InvalidSignatureError{
+				Err: fmt.Errorf("invalid event signature"),
+			}
 		}
 	}
 
@@ -47,6 +51,7 @@ func (es *Source) Event(r *http.Request, payload []byte) (eventsources.Event, er
 	var e Event
 	log.Debugf("raw event: %q", string(payload))
 	err := json.Unmarshal(payload, &e)
+	e.references = es.references
 	return e, err
 }
 
@@ -62,12 +67,17 @@ func (es *Source) Name() string {
 	return "custom_http"
 }
 
-func NewSource(tracer opentracing.Tracer) (eventsources.EventSource, error) {
+func NewSource(tracer opentracing.Tracer, references traces.References) (eventsources.EventSource, error) {
 	return &Source{
-		tracer: tracer,
+		tracer:     tracer,
+		references: references,
 	}, nil
 }
 
 func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
-	return NewSource(tracer)
+	references, err := traces.ReferencesFromCLI(c)
+	if err != nil {
+		return nil, err
+	}
+	return NewSource(tracer, references)
 }
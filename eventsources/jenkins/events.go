@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"github.com/ImpactInsights/valuestream/eventsources"
 	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"github.com/ImpactInsights/valuestream/traces"
 	log "github.com/sirupsen/logrus"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type BuildState int
@@ -40,10 +42,31 @@ type BuildEvent struct {
 	StartedUserID string   `json:"startedUserId"`
 	Duration      int      `json:"duration"`
 	EndTime       int      `json:"endTime"`
+
+	references traces.References
+}
+
+// millisToTime converts the epoch milliseconds jenkins reports times in.
+func millisToTime(ms int) *time.Time {
+	t := time.Unix(0, int64(ms)*int64(time.Millisecond)).UTC()
+	return &t
 }
 
 func (be BuildEvent) Timings() (eventsources.EventTimings, error) {
-	return eventsources.EventTimings{}, nil
+	var timings eventsources.EventTimings
+
+	if be.StartTime > 0 {
+		timings.StartTime = millisToTime(be.StartTime)
+	}
+	if be.EndTime > 0 {
+		timings.EndTime = millisToTime(be.EndTime)
+	}
+	if be.Duration > 0 {
+		d := time.Duration(be.Duration) * time.Millisecond
+		timings.Duration = &d
+	}
+
+	return timings, nil
 }
 
 func (be BuildEvent) SpanID() (string, error) {
@@ -101,22 +124,44 @@ func (be BuildEvent) OperationName() string {
 	return op
 }
 
+// ServiceName is the name of a deploy's job, without its `deploy:`
+// prefix.  Builds don't deploy a service.
+func (be BuildEvent) ServiceName() string {
+	if be.OperationName() != types.DeployEventType {
+		return ""
+	}
+	return strings.TrimPrefix(be.JobName, "deploy:")
+}
+
 func (be BuildEvent) IsError() (bool, error) {
 	return be.Result != "SUCCESS", nil
 }
 
-// ParentSpanID inspects the Jenkins Event payload to
+// References inspects the Jenkins Event payload to
 // determine what the parent span is.
 // First will check to see if the parent is explicitly specified
 // in build params
-// Then will check if the build is part of SCM
-func (be BuildEvent) ParentSpanID() (*string, error) {
+// Then will check if the build's branch references a span, otherwise
+// the branch itself is the parent
+func (be BuildEvent) References() ([]eventsources.Reference, error) {
 	id, found := be.Parameters["vstrace-trace-id"]
 	if found {
-		return &id, nil
+		return eventsources.ChildOf(&id), nil
+	}
+
+	branch := be.branchID()
+	if branch == nil {
+		return nil, nil
+	}
+
+	vars := map[string]string{
+		"job": be.JobName,
+	}
+	if parent := be.references.First(sourceName, vars, *branch); parent != nil {
+		return eventsources.ChildOf(parent), nil
 	}
 
-	return be.branchID(), nil
+	return eventsources.ChildOf(branch), nil
 }
 
 func (be BuildEvent) String() (string, error) {
@@ -138,6 +183,10 @@ func (be BuildEvent) Tags() (map[string]interface{}, error) {
 	tags["build.started.user.name"] = be.StartedUsername
 	tags["build.started.user.id"] = be.StartedUserID
 
+	for k, v := range be.executorTags() {
+		tags[k] = v
+	}
+
 	if be.ScmInfo != nil {
 		tags["scm.head.url"] = be.ScmInfo.URL
 		tags["scm.head.sha"] = be.ScmInfo.Commit
@@ -149,3 +198,83 @@ func (be BuildEvent) Tags() (map[string]interface{}, error) {
 	}
 	return tags, nil
 }
+
+func (be BuildEvent) executorTags() map[string]interface{} {
+	tags := make(map[string]interface{})
+	if be.SlaveInfo.SlaveName == "" {
+		return tags
+	}
+	tags["build.executor.node"] = be.SlaveInfo.SlaveName
+	tags["build.executor.number"] = be.SlaveInfo.Executor
+	tags["build.executor.label"] = be.SlaveInfo.Label
+	return tags
+}
+
+// Children returns the time the build spent queued waiting for an
+// executor, which jenkins reports along with the start of the build.
+func (be BuildEvent) Children() ([]eventsources.Event, error) {
+	if be.Result != "INPROGRESS" || be.StartTime <= 0 || be.QueueTime <= 0 {
+		return nil, nil
+	}
+
+	return []eventsources.Event{
+		QueuedEvent{build: be},
+	}, nil
+}
+
+// QueuedEvent covers the time between a build being queued and
+// it starting on an executor.
+type QueuedEvent struct {
+	build BuildEvent
+}
+
+func (qe QueuedEvent) Timings() (eventsources.EventTimings, error) {
+	start := millisToTime(qe.build.StartTime - qe.build.QueueTime)
+	end := millisToTime(qe.build.StartTime)
+	d := time.Duration(qe.build.QueueTime) * time.Millisecond
+
+	return eventsources.EventTimings{
+		StartTime: start,
+		EndTime:   end,
+		Duration:  &d,
+	}, nil
+}
+
+func (qe QueuedEvent) SpanID() (string, error) {
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		qe.OperationName(),
+		qe.build.JobName,
+		strconv.Itoa(qe.build.Number),
+	}, "-"), nil
+}
+
+func (qe QueuedEvent) OperationName() string {
+	return types.QueuedEventType
+}
+
+func (qe QueuedEvent) References() ([]eventsources.Reference, error) {
+	id, err := qe.build.SpanID()
+	if err != nil {
+		return nil, err
+	}
+	return eventsources.ChildOf(&id), nil
+}
+
+func (qe QueuedEvent) IsError() (bool, error) {
+	return false, nil
+}
+
+func (qe QueuedEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	return eventsources.CompleteState, nil
+}
+
+func (qe QueuedEvent) Tags() (map[string]interface{}, error) {
+	tags := qe.build.executorTags()
+	tags["service"] = "jenkins"
+	tags["build.job.name"] = qe.build.JobName
+	tags["build.number"] = qe.build.Number
+	tags["build.queue_time_ms"] = qe.build.QueueTime
+	return tags, nil
+}
@@ -40,6 +40,7 @@ var eventTests = []struct {
 	EndEventPath          string
 	ExpectedOperationName string
 	ExpectedTags          map[string]interface{}
+	ExpectedQueuedTags    map[string]interface{}
 }{
 	{
 		Name:                  "build_aborted",
@@ -50,6 +51,9 @@ var eventTests = []struct {
 			"build.cause":                "Started by anonymous",
 			"build.ci.url":               "http://localhost:8080/jenkins/",
 			"build.context_id":           float64(1.02490963e+08),
+			"build.executor.label":       "master, windows,",
+			"build.executor.node":        "optimusprime",
+			"build.executor.number":      "1",
 			"build.job.full_name":        "jenkins_test",
 			"build.job.name":             "jenkins_test",
 			"build.number":               float64(252),
@@ -64,6 +68,16 @@ var eventTests = []struct {
 			"scm.head.url":               "aGithubUrl",
 			"service":                    "jenkins",
 		},
+		ExpectedQueuedTags: map[string]interface{}{
+			"build.executor.label":  "master, windows,",
+			"build.executor.node":   "optimusprime",
+			"build.executor.number": "1",
+			"build.job.name":        "jenkins_test",
+			"build.number":          float64(252),
+			"build.queue_time_ms":   float64(29),
+			"error":                 false,
+			"service":               "jenkins",
+		},
 	},
 	{
 		Name:                  "build_success",
@@ -74,6 +88,9 @@ var eventTests = []struct {
 			"build.cause":                "Started by anonymous",
 			"build.ci.url":               "http://localhost:8080/jenkins/",
 			"build.context_id":           float64(1.02490963e+08),
+			"build.executor.label":       "master, windows,",
+			"build.executor.node":        "optimusprime",
+			"build.executor.number":      "1",
 			"build.job.full_name":        "jenkins_test",
 			"build.job.name":             "jenkins_test",
 			"build.number":               float64(252),
@@ -88,6 +105,16 @@ var eventTests = []struct {
 			"scm.head.url":               "aGithubUrl",
 			"service":                    "jenkins",
 		},
+		ExpectedQueuedTags: map[string]interface{}{
+			"build.executor.label":  "master, windows,",
+			"build.executor.node":   "optimusprime",
+			"build.executor.number": "1",
+			"build.job.name":        "jenkins_test",
+			"build.number":          float64(252),
+			"build.queue_time_ms":   float64(29),
+			"error":                 false,
+			"service":               "jenkins",
+		},
 	},
 	{
 		Name:                  "deploy_success",
@@ -98,6 +125,9 @@ var eventTests = []struct {
 			"build.cause":                "Started by anonymous",
 			"build.ci.url":               "http://localhost:8080/jenkins/",
 			"build.context_id":           float64(1.02490963e+08),
+			"build.executor.label":       "master, windows,",
+			"build.executor.node":        "optimusprime",
+			"build.executor.number":      "1",
 			"build.job.full_name":        "jenkins_test",
 			"build.job.name":             "deploy:jenkins_test",
 			"build.number":               float64(252),
@@ -113,6 +143,16 @@ var eventTests = []struct {
 			"scm.head.url":               "aGithubUrl",
 			"service":                    "jenkins",
 		},
+		ExpectedQueuedTags: map[string]interface{}{
+			"build.executor.label":  "master, windows,",
+			"build.executor.node":   "optimusprime",
+			"build.executor.number": "1",
+			"build.job.name":        "deploy:jenkins_test",
+			"build.number":          float64(252),
+			"build.queue_time_ms":   float64(29),
+			"error":                 false,
+			"service":               "jenkins",
+		},
 	},
 }
 
@@ -169,12 +209,100 @@ func TestServiceEvent_Jenkins(t *testing.T) {
 			err = json.Unmarshal(bs, &spans)
 			assert.NoError(t, err)
 
-			assert.Equal(t, 1, len(spans))
+			// the queued span is finished as soon as the build starts
+			assert.Equal(t, 2, len(spans))
+
+			if len(spans) == 2 {
+				queued, build := spans[0], spans[1]
+				assert.Equal(t, "queued", queued.Span.OperationName)
+				assert.Equal(t, tt.ExpectedQueuedTags, queued.Tags)
+				assert.Equal(t, build.Span.SpanContext.SpanID, queued.Span.ParentID)
 
-			if len(spans) == 1 {
-				assert.Equal(t, tt.ExpectedOperationName, spans[0].Span.OperationName)
-				assert.Equal(t, tt.ExpectedTags, spans[0].Tags)
+				assert.Equal(t, tt.ExpectedOperationName, build.Span.OperationName)
+				assert.Equal(t, tt.ExpectedTags, build.Tags)
 			}
 		})
 	}
 }
+
+func TestServiceTrace_Jenkins_PipelineStages(t *testing.T) {
+	client := &http.Client{}
+	u, err := url.Parse(baseURL + jenkinsPath)
+	assert.NoError(t, err)
+
+	resp, err := http.Get(baseURL + "/mocktracer/reset")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	eventPaths := []string{
+		"fixtures/events/build/inprogress.json",
+		"fixtures/events/stage/test_inprogress.json",
+		"fixtures/events/stage/test_success.json",
+		"fixtures/events/stage/package_failure.json",
+		"fixtures/events/build/success.json",
+	}
+	for _, eventPath := range eventPaths {
+		te, err := eventsources.NewTestEventFromFixturePath(eventPath)
+		assert.NoError(t, err)
+
+		rawPayload, err := json.Marshal(te.Payload)
+		assert.NoError(t, err)
+
+		eventResp, err := client.Post(u.String(), "application/json", bytes.NewReader(rawPayload))
+		assert.NoError(t, err)
+		eventResp.Body.Close()
+		assert.Equal(t, http.StatusOK, eventResp.StatusCode, eventPath)
+	}
+
+	spansResp, err := http.Get(baseURL + "/mocktracer/finished-spans")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, spansResp.StatusCode)
+
+	bs, err := ioutil.ReadAll(spansResp.Body)
+	assert.NoError(t, err)
+	spansResp.Body.Close()
+
+	var spans []tracers.TestSpan
+
+	err = json.Unmarshal(bs, &spans)
+	assert.NoError(t, err)
+
+	// queued, test, package and build
+	assert.Equal(t, 4, len(spans))
+	if len(spans) != 4 {
+		t.FailNow()
+	}
+
+	test, pkg, build := spans[1], spans[2], spans[3]
+
+	assert.Equal(t, "build", build.Span.OperationName)
+
+	assert.Equal(t, "stage", test.Span.OperationName)
+	assert.Equal(t, build.Span.SpanContext.SpanID, test.Span.ParentID)
+	assert.Equal(t, map[string]interface{}{
+		"build.job.full_name": "jenkins_test",
+		"build.job.name":      "jenkins_test",
+		"build.number":        float64(252),
+		"error":               false,
+		"service":             "jenkins",
+		"stage.duration_ms":   float64(4200),
+		"stage.id":            "6",
+		"stage.name":          "test",
+		"stage.result":        "SUCCESS",
+	}, test.Tags)
+
+	assert.Equal(t, "stage", pkg.Span.OperationName)
+	assert.Equal(t, build.Span.SpanContext.SpanID, pkg.Span.ParentID)
+	assert.Equal(t, map[string]interface{}{
+		"build.job.full_name": "jenkins_test",
+		"build.job.name":      "jenkins_test",
+		"build.number":        float64(252),
+		"error":               true,
+		"service":             "jenkins",
+		"stage.duration_ms":   float64(3100),
+		"stage.id":            "14",
+		"stage.name":          "package",
+		"stage.result":        "FAILURE",
+	}, pkg.Tags)
+}
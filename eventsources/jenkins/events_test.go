@@ -1,8 +1,10 @@
 package jenkins
 
 import (
+	"github.com/ImpactInsights/valuestream/eventsources"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestBuildEvent_BranchID_Origin(t *testing.T) {
@@ -44,3 +46,41 @@ func TestBuildEvent_OperationName_Build(t *testing.T) {
 		},
 	}.OperationName())
 }
+
+func TestBuildEvent_Children_Queued(t *testing.T) {
+	be := BuildEvent{
+		Result:    "INPROGRESS",
+		JobName:   "jenkins_test",
+		Number:    252,
+		QueueTime: 1500,
+		StartTime: 1469453903000,
+	}
+
+	children, err := be.Children()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(children))
+
+	queued := children[0]
+	assert.Equal(t, "queued", queued.OperationName())
+
+	parentID, err := eventsources.ParentSpanID(queued)
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-jenkins-build-jenkins_test-252", *parentID)
+
+	timings, err := queued.Timings()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(1469453901, 5e8).UTC(), *timings.StartTime)
+	assert.Equal(t, time.Unix(1469453903, 0).UTC(), *timings.EndTime)
+	assert.Equal(t, 1500*time.Millisecond, *timings.Duration)
+}
+
+func TestBuildEvent_Children_NotQueued(t *testing.T) {
+	for _, be := range []BuildEvent{
+		{Result: "SUCCESS", QueueTime: 1500, StartTime: 1469453903000},
+		{Result: "INPROGRESS", StartTime: 1469453903000},
+	} {
+		children, err := be.Children()
+		assert.NoError(t, err)
+		assert.Empty(t, children)
+	}
+}
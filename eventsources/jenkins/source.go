@@ -1,8 +1,11 @@
 package jenkins
 
 import (
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
 	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/traces"
 	"github.com/opentracing/opentracing-go"
 	"github.com/urfave/cli"
 	"io/ioutil"
@@ -11,10 +14,27 @@ import (
 
 const (
 	sourceName string = "jenkins"
+
+	signatureHeader string = "X-Jenkins-Signature"
+	signaturePrefix string = "sha256="
+	tokenHeader     string = "X-Jenkins-Token"
+	tokenParam      string = "token"
 )
 
+var signature = eventsources.HMACSignature{
+	Hash:   sha256.New,
+	Header: signatureHeader,
+	Prefix: signaturePrefix,
+}
+
+// token is sent in its header, or as a query parameter by the plugins
+// which can't set headers.
+var token = eventsources.SecretToken{Header: tokenHeader}
+
 type Source struct {
-	tracer opentracing.Tracer
+	tracer     opentracing.Tracer
+	secretKey  []byte
+	references traces.References
 }
 
 func (s Source) Name() string {
@@ -25,26 +45,79 @@ func (s *Source) Tracer() opentracing.Tracer {
 	return s.tracer
 }
 
+// Event handles builds from the statistics gatherer plugin along with
+// pipeline stages, which are identified by their `stageId`.
 func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
+	var stage StageEvent
+	if err := json.Unmarshal(payload, &stage); err != nil {
+		return nil, err
+	}
+
+	if stage.StageID != "" {
+		return stage, nil
+	}
+
 	var be BuildEvent
 	err := json.Unmarshal(payload, &be)
+	be.references = s.references
 	return be, err
 }
 
 func (s *Source) SecretKey() []byte {
-	return nil
+	return s.secretKey
 }
 
+// ValidatePayload accepts payloads signed with an HMAC of the body:
+// `X-Jenkins-Signature: sha256=<hex encoded hmac>`, or carrying the secret
+// as a token, either in the `X-Jenkins-Token` header or the `token` query
+// parameter.  The statistics gatherer plugin only lets the url be
+// configured so the query parameter is the simplest way to secure it.
 func (s *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
-	return ioutil.ReadAll(r.Body)
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body")
+	}
+
+	if secretKey == nil {
+		return body, nil
+	}
+
+	if err := validateRequest(r, body, secretKey); err != nil {
+		return nil, eventsources.InvalidSignatureError{Err: err}
+	}
+
+	return body, nil
+}
+
+func validateRequest(r *http.Request, body []byte, secretKey []byte) error {
+	if r.Header.Get(signatureHeader) != "" {
+		return signature.Validate(r, body, secretKey)
+	}
+
+	value := r.Header.Get(tokenHeader)
+	if value == "" {
+		value = r.URL.Query().Get(tokenParam)
+	}
+
+	return token.Verify(value, secretKey)
 }
 
-func NewSource(tracer opentracing.Tracer) (*Source, error) {
+func NewSource(tracer opentracing.Tracer, secretKey []byte, references traces.References) (*Source, error) {
 	return &Source{
-		tracer: tracer,
+		tracer:     tracer,
+		secretKey:  secretKey,
+		references: references,
 	}, nil
 }
 
 func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
-	return NewSource(tracer)
+	var secretKey []byte
+	if secret := c.String("jenkins-secret"); secret != "" {
+		secretKey = []byte(secret)
+	}
+	references, err := traces.ReferencesFromCLI(c)
+	if err != nil {
+		return nil, err
+	}
+	return NewSource(tracer, secretKey, references)
 }
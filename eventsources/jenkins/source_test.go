@@ -0,0 +1,54 @@
+package jenkins
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestSource_ValidatePayload(t *testing.T) {
+	secretKey := []byte("secret")
+	body := []byte(`{"result": "INPROGRESS"}`)
+
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write(body)
+	signature := signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+
+	testCases := []struct {
+		name        string
+		url         string
+		headers     map[string]string
+		secretKey   []byte
+		expectedErr bool
+	}{
+		{"signed", "/jenkins", map[string]string{signatureHeader: signature}, secretKey, false},
+		{"token_header", "/jenkins", map[string]string{tokenHeader: "secret"}, secretKey, false},
+		{"token_param", "/jenkins?token=secret", nil, secretKey, false},
+		{"unsigned", "/jenkins", nil, secretKey, true},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", tt.url, bytes.NewReader(body))
+			assert.NoError(t, err)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			s, err := NewSource(nil, tt.secretKey, nil)
+			assert.NoError(t, err)
+
+			payload, err := s.ValidatePayload(req, s.SecretKey())
+			if tt.expectedErr {
+				assert.IsType(t, eventsources.InvalidSignatureError{}, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, body, payload)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package jenkins
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StageEvent is a single stage of a pipeline build.  Jenkins doesn't send
+// these itself, they're posted from the pipeline (ie using the http request
+// or generic event plugins) either when the stage starts and finishes, with
+// a result of `INPROGRESS` followed by its final result, or only once the
+// stage has finished:
+//
+//	{
+//	  "jobName": "jenkins_test",
+//	  "number": 252,
+//	  "stageId": "12",
+//	  "stageName": "test",
+//	  "result": "SUCCESS",
+//	  "startTime": 1469453903000,
+//	  "duration": 4200
+//	}
+//
+// Stages nested within another stage, ie parallel branches, reference it
+// using `parentStageId`.
+type StageEvent struct {
+	JobName       string            `json:"jobName"`
+	FullJobName   string            `json:"fullJobName"`
+	Number        int               `json:"number"`
+	Parameters    map[string]string `json:"parameters"`
+	StageID       string            `json:"stageId"`
+	StageName     string            `json:"stageName"`
+	ParentStageID string            `json:"parentStageId"`
+	Result        string            `json:"result"`
+	StartTime     int               `json:"startTime"`
+	Duration      int               `json:"duration"`
+}
+
+func (se StageEvent) build() BuildEvent {
+	return BuildEvent{
+		JobName:    se.JobName,
+		Number:     se.Number,
+		Parameters: se.Parameters,
+	}
+}
+
+func (se StageEvent) stageSpanID(stageID string) string {
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		types.StageEventType,
+		se.JobName,
+		strconv.Itoa(se.Number),
+		stageID,
+	}, "-")
+}
+
+func (se StageEvent) Timings() (eventsources.EventTimings, error) {
+	var timings eventsources.EventTimings
+
+	if se.StartTime > 0 {
+		timings.StartTime = millisToTime(se.StartTime)
+	}
+	if se.Duration > 0 {
+		d := time.Duration(se.Duration) * time.Millisecond
+		timings.Duration = &d
+	}
+	if timings.StartTime != nil && timings.Duration != nil {
+		end := timings.StartTime.Add(*timings.Duration)
+		timings.EndTime = &end
+	}
+
+	return timings, nil
+}
+
+func (se StageEvent) SpanID() (string, error) {
+	return se.stageSpanID(se.StageID), nil
+}
+
+func (se StageEvent) OperationName() string {
+	return types.StageEventType
+}
+
+// References the enclosing stage, if there is one, otherwise the build.
+func (se StageEvent) References() ([]eventsources.Reference, error) {
+	if se.ParentStageID != "" {
+		id := se.stageSpanID(se.ParentStageID)
+		return eventsources.ChildOf(&id), nil
+	}
+
+	id, err := se.build().SpanID()
+	if err != nil {
+		return nil, err
+	}
+	return eventsources.ChildOf(&id), nil
+}
+
+func (se StageEvent) IsError() (bool, error) {
+	return se.Result != "SUCCESS", nil
+}
+
+// State supports stages which are reported when they start and finish as
+// well as those only reported once they've finished, which are created
+// retroactively from their start time and duration.
+func (se StageEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	if se.Result == "INPROGRESS" {
+		return eventsources.StartState, nil
+	}
+
+	if prev == nil {
+		return eventsources.CompleteState, nil
+	}
+
+	return eventsources.EndState, nil
+}
+
+func (se StageEvent) Tags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+	tags["service"] = "jenkins"
+	tags["build.job.name"] = se.JobName
+	tags["build.job.full_name"] = se.FullJobName
+	tags["build.number"] = se.Number
+	tags["stage.id"] = se.StageID
+	tags["stage.name"] = se.StageName
+	if se.ParentStageID != "" {
+		tags["stage.parent_id"] = se.ParentStageID
+	}
+	return tags, nil
+}
+
+// EndTags records how the stage finished, which isn't known when
+// the stage starts.
+func (se StageEvent) EndTags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+	tags["stage.result"] = se.Result
+	if se.Duration > 0 {
+		tags["stage.duration_ms"] = se.Duration
+	}
+	return tags, nil
+}
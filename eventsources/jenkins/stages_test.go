@@ -0,0 +1,46 @@
+package jenkins
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStageEvent_State(t *testing.T) {
+	started := eventsources.EventState(eventsources.StartState)
+
+	testCases := []struct {
+		name     string
+		result   string
+		prev     *eventsources.EventState
+		expected eventsources.SpanState
+	}{
+		{"started", "INPROGRESS", nil, eventsources.StartState},
+		{"finished", "SUCCESS", &started, eventsources.EndState},
+		{"finished_not_started", "FAILURE", nil, eventsources.CompleteState},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := StageEvent{Result: tt.result}.State(tt.prev)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, state)
+		})
+	}
+}
+
+func TestStageEvent_ParentSpanID(t *testing.T) {
+	se := StageEvent{
+		JobName: "deploy:jenkins_test",
+		Number:  252,
+		StageID: "6",
+	}
+
+	parentID, err := eventsources.ParentSpanID(se)
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-jenkins-deploy-deploy:jenkins_test-252", *parentID)
+
+	se.ParentStageID = "4"
+	parentID, err = eventsources.ParentSpanID(se)
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-jenkins-stage-deploy:jenkins_test-252-4", *parentID)
+}
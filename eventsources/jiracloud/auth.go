@@ -0,0 +1,148 @@
+package jiracloud
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	signatureHeader     string = "X-Hub-Signature"
+	signaturePrefix     string = "sha256="
+	authorizationHeader string = "Authorization"
+	jwtPrefix           string = "JWT "
+)
+
+// signature is the HMAC jira sends when a webhook is registered with a
+// secret: `X-Hub-Signature: sha256=<hex encoded hmac of the body>`.
+var signature = eventsources.HMACSignature{
+	Hash:   sha256.New,
+	Header: signatureHeader,
+	Prefix: signaturePrefix,
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+}
+
+type jwtClaims struct {
+	Issuer    string `json:"iss"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	QSH       string `json:"qsh"`
+}
+
+// validateJWT checks the token jira cloud sends to Connect apps, which
+// is signed with the shared secret established when the app is installed.
+// The token is bound to the request it was sent with through its query
+// string hash (qsh) claim.
+func validateJWT(r *http.Request, token string, secretKey []byte, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed jwt")
+	}
+
+	var header jwtHeader
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return err
+	}
+
+	if header.Alg != "HS256" {
+		return fmt.Errorf("unsupported jwt alg: %q", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return fmt.Errorf("invalid jwt signature")
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return err
+	}
+
+	if claims.ExpiresAt == 0 || now.Unix() > claims.ExpiresAt {
+		return fmt.Errorf("jwt expired")
+	}
+
+	if claims.QSH != queryStringHash(r) {
+		return fmt.Errorf("jwt qsh does not match request")
+	}
+
+	return nil
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	bs, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(bs, v)
+}
+
+// queryStringHash builds the atlassian canonical request:
+// `<METHOD>&<path>&<sorted query string>` and hashes it.
+func queryStringHash(r *http.Request) string {
+	path := r.URL.Path
+	if path != "/" {
+		path = strings.TrimSuffix(path, "/")
+	}
+	if path == "" {
+		path = "/"
+	}
+	path = strings.Replace(path, "&", "%26", -1)
+
+	query := r.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		if k == "jwt" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	params := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values := make([]string, 0, len(query[k]))
+		for _, v := range query[k] {
+			values = append(values, percentEncode(v))
+		}
+		sort.Strings(values)
+		params = append(params, percentEncode(k)+"="+strings.Join(values, ","))
+	}
+
+	canonical := strings.Join([]string{
+		strings.ToUpper(r.Method),
+		path,
+		strings.Join(params, "&"),
+	}, "&")
+
+	sum := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(sum[:])
+}
+
+func percentEncode(s string) string {
+	return strings.NewReplacer(
+		"+", "%20",
+		"*", "%2A",
+		"%7E", "~",
+	).Replace(url.QueryEscape(s))
+}
@@ -0,0 +1,168 @@
+package jiracloud
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func sign(secretKey []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newJWT(secretKey []byte, claims string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(claims))
+
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write([]byte(header + "." + payload))
+
+	return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidateSignature(t *testing.T) {
+	body := []byte(`{"webhookEvent":"jira:issue_updated"}`)
+	secretKey := []byte("secret")
+
+	assert.NoError(t, signature.Verify(sign(secretKey, body), body, secretKey))
+	assert.Error(t, signature.Verify(sign([]byte("other"), body), body, secretKey))
+	assert.Error(t, signature.Verify("sha1=abc", body, secretKey))
+	assert.Error(t, signature.Verify("sha256=not-hex", body, secretKey))
+}
+
+func TestQueryStringHash(t *testing.T) {
+	r, err := http.NewRequest(
+		"post",
+		"https://valuestream.example.com/jira/?b=2&a=1&a=0&jwt=ignored&c=a%20b",
+		nil,
+	)
+	assert.NoError(t, err)
+
+	expected := sha256.Sum256([]byte("POST&/jira&a=0,1&b=2&c=a%20b"))
+	assert.Equal(t, hex.EncodeToString(expected[:]), queryStringHash(r))
+}
+
+func TestValidateJWT(t *testing.T) {
+	secretKey := []byte("shared-secret")
+	now := time.Unix(1574512928, 0)
+
+	r, err := http.NewRequest("POST", "https://valuestream.example.com/jira", nil)
+	assert.NoError(t, err)
+	qsh := queryStringHash(r)
+
+	testCases := []struct {
+		name        string
+		token       string
+		expectedErr bool
+	}{
+		{
+			"valid",
+			newJWT(secretKey, fmt.Sprintf(`{"iss":"jira","exp":%d,"qsh":%q}`, now.Unix()+60, qsh)),
+			false,
+		},
+		{
+			"expired",
+			newJWT(secretKey, fmt.Sprintf(`{"iss":"jira","exp":%d,"qsh":%q}`, now.Unix()-60, qsh)),
+			true,
+		},
+		{
+			"different_request",
+			newJWT(secretKey, fmt.Sprintf(`{"iss":"jira","exp":%d,"qsh":"abc"}`, now.Unix()+60)),
+			true,
+		},
+		{
+			"wrong_secret",
+			newJWT([]byte("other"), fmt.Sprintf(`{"iss":"jira","exp":%d,"qsh":%q}`, now.Unix()+60, qsh)),
+			true,
+		},
+		{
+			"malformed",
+			"not.a-jwt",
+			true,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateJWT(r, tt.token, secretKey, now)
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSource_ValidatePayload(t *testing.T) {
+	body := []byte(`{"webhookEvent":"jira:issue_updated"}`)
+	secretKey := []byte("secret")
+
+	newCloud := func() (*Source, error) {
+		return NewSource(nil, secretKey, DefaultWorkflows(), DefaultCustomFields(), nil)
+	}
+	newServer := func() (*Source, error) {
+		return NewServerSource(nil, secretKey, DefaultWorkflows(), DefaultCustomFields(), nil)
+	}
+
+	testCases := []struct {
+		name        string
+		newSource   func() (*Source, error)
+		headers     map[string]string
+		expectedErr bool
+	}{
+		{
+			"cloud_signed",
+			newCloud,
+			map[string]string{signatureHeader: sign(secretKey, body)},
+			false,
+		},
+		{
+			"server_signed",
+			newServer,
+			map[string]string{signatureHeader: sign(secretKey, body)},
+			false,
+		},
+		{
+			"cloud_unsigned",
+			newCloud,
+			map[string]string{},
+			true,
+		},
+		{
+			"server_jwt_unsupported",
+			newServer,
+			map[string]string{authorizationHeader: jwtPrefix + newJWT(secretKey, `{}`)},
+			true,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := tt.newSource()
+			assert.NoError(t, err)
+
+			r, err := http.NewRequest("POST", "/jira", bytes.NewReader(body))
+			assert.NoError(t, err)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			payload, err := s.ValidatePayload(r, s.SecretKey())
+			if tt.expectedErr {
+				assert.IsType(t, eventsources.InvalidSignatureError{}, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, body, payload)
+		})
+	}
+}
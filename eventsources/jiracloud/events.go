@@ -1,6 +1,7 @@
 package jiracloud
 
 import (
+	"context"
 	"fmt"
 	"github.com/ImpactInsights/valuestream/eventsources"
 	"github.com/ImpactInsights/valuestream/eventsources/types"
@@ -43,34 +44,81 @@ func (e Event) Type() eventType {
 }
 
 type SprintEvent struct {
-	Sprint jira.Sprint
+	WebhookEvent string `json:"webhookEvent"`
+	Sprint       jira.Sprint
+
+	source   string
+	progress *sprintProgress
+	sprints  *sprintTracker
+	issues   SprintIssueLister
+}
+
+// Handled starts tracking the issues of the sprint once its span has
+// started, and records its outcome once its span has finished.
+func (se SprintEvent) Handled(ctx context.Context) {
+	if se.sprints == nil {
+		return
+	}
+
+	switch se.WebhookEvent {
+	case "sprint_started":
+		committed, listed := se.committed()
+		se.sprints.start(se.Sprint.ID, committed, listed)
+	case "sprint_closed":
+		se.sprints.finish(se.Sprint.ID)
+		recordSprintClosed(ctx, se)
+	}
+}
+
+// committed are the issues in the sprint as it starts, if they can be
+// listed.
+func (se SprintEvent) committed() ([]string, bool) {
+	if se.issues == nil {
+		return nil, false
+	}
+
+	keys, err := se.issues(se.Sprint.ID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":  err.Error(),
+			"sprint": se.Sprint.ID,
+		}).Errorf("jira.SprintEvent.committed")
+		return nil, false
+	}
+	return keys, true
 }
 
 func (se SprintEvent) Timings() (eventsources.EventTimings, error) {
 	return eventsources.EventTimings{}, nil
 }
 
-func (se SprintEvent) SpanID() (string, error) {
+func sprintSpanID(source string, id int) string {
 	return strings.Join([]string{
 		"vstrace",
-		sourceName,
+		source,
 		types.SprintEventType,
-		strconv.Itoa(se.Sprint.ID),
-	}, "-"), nil
+		strconv.Itoa(id),
+	}, "-")
+}
+
+func (se SprintEvent) SpanID() (string, error) {
+	return sprintSpanID(se.source, se.Sprint.ID), nil
 }
 
 func (se SprintEvent) OperationName() string {
 	return types.SprintEventType
 }
 
-func (se SprintEvent) ParentSpanID() (*string, error) {
+func (se SprintEvent) References() ([]eventsources.Reference, error) {
 	return nil, nil
 }
 
 // IsError returns true if the sprint ended before its `endDate`
-// TODO
 func (se SprintEvent) IsError() (bool, error) {
-	return false, nil
+	if se.Sprint.CompleteDate == nil || se.Sprint.EndDate == nil {
+		return false, nil
+	}
+	return se.Sprint.CompleteDate.Before(*se.Sprint.EndDate), nil
 }
 
 func (se SprintEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
@@ -95,61 +143,232 @@ func (se SprintEvent) Tags() (map[string]interface{}, error) {
 	return tags, nil
 }
 
+// EndTags describes the outcome of the sprint, how long it ran compared to
+// what was planned and what happened to the issues in it while it was open.
+func (se SprintEvent) EndTags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+
+	start, end, complete := se.Sprint.StartDate, se.Sprint.EndDate, se.Sprint.CompleteDate
+	if complete != nil {
+		tags["sprint.complete_date"] = complete
+	}
+	if start != nil && end != nil {
+		tags["sprint.duration.planned_seconds"] = end.Sub(*start).Seconds()
+	}
+	if start != nil && complete != nil {
+		tags["sprint.duration.actual_seconds"] = complete.Sub(*start).Seconds()
+	}
+	if end != nil && complete != nil {
+		tags["sprint.closed_early"] = complete.Before(*end)
+		tags["sprint.closed_late"] = complete.After(*end)
+	}
+
+	if se.progress != nil {
+		for outcome, count := range se.progress.counts() {
+			tags["sprint.issues."+outcome] = count
+		}
+	}
+
+	return tags, nil
+}
+
+// Changelog is the set of fields changed by an issue update, jira webhooks
+// send these as a flat list of items rather than the histories returned by
+// the jira api.
+type Changelog struct {
+	ID    string                `json:"id"`
+	Items []jira.ChangelogItems `json:"items"`
+}
+
+func (c Changelog) change(field string) *jira.ChangelogItems {
+	for i, item := range c.Items {
+		if strings.EqualFold(item.Field, field) {
+			return &c.Items[i]
+		}
+	}
+	return nil
+}
+
+// statusChange returns the change to the issue's status, if there was one.
+func (c Changelog) statusChange() *jira.ChangelogItems {
+	return c.change("status")
+}
+
+// sprintChange returns the change to the sprints the issue is in, if there
+// was one.  The from and to of the change are comma separated sprint ids.
+func (c Changelog) sprintChange() *jira.ChangelogItems {
+	return c.change("sprint")
+}
+
 type IssueEvent struct {
 	User      jira.User
 	Issue     jira.Issue
-	Changelog jira.Changelog
+	Changelog Changelog
+
+	source       string
+	workflows    types.Workflows
+	customFields CustomFields
+	sprints      *sprintTracker
+}
+
+// Handled follows the issue in the sprints it's in once it has been
+// traced.
+func (ie IssueEvent) Handled(ctx context.Context) {
+	if ie.sprints != nil {
+		ie.sprints.observe(ie)
+	}
 }
 
 func (ie IssueEvent) Timings() (eventsources.EventTimings, error) {
 	return eventsources.EventTimings{}, nil
 }
 
-func (ie IssueEvent) SpanID() (string, error) {
+func issueSpanID(source string, key string) string {
 	return strings.Join([]string{
 		"vstrace",
-		sourceName,
+		source,
 		types.IssueEventType,
-		ie.Issue.Key,
-	}, "-"), nil
+		key,
+	}, "-")
+}
+
+func (ie IssueEvent) SpanID() (string, error) {
+	return issueSpanID(ie.source, ie.Issue.Key), nil
 }
 func (ie IssueEvent) OperationName() string {
 	return types.IssueEventType
 }
-func (ie IssueEvent) ParentSpanID() (*string, error) {
+
+// References nests sub-tasks beneath their parent issue and issues
+// beneath their epic.  Issues outside of an epic are nested beneath the
+// active sprint they're in.
+func (ie IssueEvent) References() ([]eventsources.Reference, error) {
+	if ie.Issue.Fields == nil {
+		return nil, nil
+	}
+
+	if parent := ie.Issue.Fields.Parent; parent != nil && parent.Key != "" {
+		id := issueSpanID(ie.source, parent.Key)
+		return eventsources.ChildOf(&id), nil
+	}
+
+	if epic := ie.customFields.epicKey(ie.Issue.Fields); epic != nil {
+		id := issueSpanID(ie.source, *epic)
+		return eventsources.ChildOf(&id), nil
+	}
+
+	if sprint := ie.customFields.activeSprintID(ie.Issue.Fields); sprint != nil {
+		id := sprintSpanID(ie.source, *sprint)
+		return eventsources.ChildOf(&id), nil
+	}
+
 	return nil, nil
 }
+
 func (ie IssueEvent) IsError() (bool, error) {
 	return false, nil
 }
+
+// workflowState maps the status the issue moved to using the workflow
+// configured for the issue's project.
+func (ie IssueEvent) workflowState() (eventsources.SpanState, bool) {
+	change := ie.Changelog.statusChange()
+	if change == nil {
+		return eventsources.UnknownState, false
+	}
+
+	var category, project string
+	if ie.Issue.Fields != nil {
+		if ie.Issue.Fields.Status != nil {
+			category = ie.Issue.Fields.Status.StatusCategory.Key
+		}
+		project = ie.Issue.Fields.Project.Key
+	}
+
+	return ie.workflows.State(change.ToString, category, project)
+}
+
+// completed is true when the issue moved to a status which finishes it.
+func (ie IssueEvent) completed() bool {
+	if ie.Changelog.statusChange() == nil {
+		return false
+	}
+	if state, ok := ie.workflowState(); ok {
+		return state == eventsources.EndState
+	}
+	return ie.Issue.Fields != nil && ie.Issue.Fields.Status != nil &&
+		ie.Issue.Fields.Status.StatusCategory.Key == "done"
+}
+
+// State is driven by the status change in the changelog.  Issues are only
+// ever started once, moving between working statuses is tracked by the
+// issue's status spans.
 func (ie IssueEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
-	if ie.Issue.Fields.Status == nil {
+	if ie.Issue.Fields == nil || ie.Issue.Fields.Status == nil {
 		return eventsources.UnknownState, fmt.Errorf("issue missing status")
 	}
+
+	state, ok := ie.workflowState()
+
 	log.WithFields(log.Fields{
-		"prev_state":  prev,
-		"status.name": ie.Issue.Fields.Status.Name,
-		"status.id":   ie.Issue.Fields.Status.ID,
+		"prev_state":    prev,
+		"status.name":   ie.Issue.Fields.Status.Name,
+		"status.id":     ie.Issue.Fields.Status.ID,
+		"workflow":      state,
+		"status_change": ok,
 	}).Debugf("jira.issueEvent.State()")
 
-	switch ie.Issue.Fields.Status.Name {
-	case kanbanSelectForDevelopment, kanbanInProgress:
-		return eventsources.TransitionState, nil
+	// the issue isn't being tracked yet and hasn't moved
+	// to a status that starts it
+	if prev == nil && (!ok || state == eventsources.EndState) {
+		return eventsources.UnknownState, nil
+	}
+
+	if !ok {
+		return eventsources.IntermediaryState, nil
+	}
 
-	case kanbanDone, kanbanBacklog: // and from status is missing...
-		// need to differentiate between created vs
-		// removed, right now we don't track the backlog state
+	switch state {
+	case eventsources.StartState, eventsources.TransitionState:
+		if prev == nil {
+			return eventsources.StartState, nil
+		}
+		return eventsources.IntermediaryState, nil
+	case eventsources.EndState:
 		return eventsources.EndState, nil
 	}
 
-	// if the state is unknown could be custom swimlanes assume intermediate
 	return eventsources.IntermediaryState, nil
 }
+
+// Children returns a span for the status the issue moved to, so that the
+// time spent in each status is visible.
+func (ie IssueEvent) Children() ([]eventsources.Event, error) {
+	change := ie.Changelog.statusChange()
+	if change == nil {
+		return nil, nil
+	}
+
+	return []eventsources.Event{
+		IssueStatusEvent{
+			issue:  ie,
+			change: *change,
+		},
+	}, nil
+}
+
 func (ie IssueEvent) Tags() (map[string]interface{}, error) {
 	tags := make(map[string]interface{})
 
-	tags["user.account_id"] = ie.User.AccountID
-	tags["user.account_type"] = ie.User.AccountType
+	// jira server doesn't have account ids, users are
+	// identified by their name and key instead
+	if ie.source == serverSourceName {
+		tags["user.name"] = ie.User.Name
+		tags["user.key"] = ie.User.Key
+	} else {
+		tags["user.account_id"] = ie.User.AccountID
+		tags["user.account_type"] = ie.User.AccountType
+	}
 	tags["user.display_name"] = ie.User.DisplayName
 
 	tags["issue.id"] = ie.Issue.ID
@@ -168,5 +387,84 @@ func (ie IssueEvent) Tags() (map[string]interface{}, error) {
 	tags["issue.status.id"] = ie.Issue.Fields.Status.ID
 	tags["issue.status.name"] = ie.Issue.Fields.Status.Name
 
+	if parent := ie.Issue.Fields.Parent; parent != nil && parent.Key != "" {
+		tags["issue.parent.key"] = parent.Key
+	}
+	if epic := ie.customFields.epicKey(ie.Issue.Fields); epic != nil {
+		tags["issue.epic.key"] = *epic
+	}
+	if sprint := ie.customFields.activeSprintID(ie.Issue.Fields); sprint != nil {
+		tags["sprint.id"] = *sprint
+	}
+
+	return tags, nil
+}
+
+// IssueStatusEvent tracks the time an issue spends in a single status,
+// all the statuses of an issue share a span id so that moving to a new
+// status ends the span of the previous one.
+type IssueStatusEvent struct {
+	issue  IssueEvent
+	change jira.ChangelogItems
+}
+
+func (ise IssueStatusEvent) Timings() (eventsources.EventTimings, error) {
+	return eventsources.EventTimings{}, nil
+}
+
+func (ise IssueStatusEvent) SpanID() (string, error) {
+	return strings.Join([]string{
+		"vstrace",
+		ise.issue.source,
+		types.IssueStatusEventType,
+		ise.issue.Issue.Key,
+	}, "-"), nil
+}
+
+func (ise IssueStatusEvent) OperationName() string {
+	return types.IssueStatusEventType
+}
+
+func (ise IssueStatusEvent) References() ([]eventsources.Reference, error) {
+	id, err := ise.issue.SpanID()
+	if err != nil {
+		return nil, err
+	}
+	return eventsources.ChildOf(&id), nil
+}
+
+func (ise IssueStatusEvent) IsError() (bool, error) {
+	return false, nil
+}
+
+func (ise IssueStatusEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	if state, ok := ise.issue.workflowState(); ok && state == eventsources.EndState {
+		if prev == nil {
+			return eventsources.UnknownState, nil
+		}
+		return eventsources.EndState, nil
+	}
+
+	if prev == nil {
+		return eventsources.StartState, nil
+	}
+
+	return eventsources.TransitionState, nil
+}
+
+func (ise IssueStatusEvent) Tags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+
+	tags["issue.id"] = ise.issue.Issue.ID
+	tags["issue.key"] = ise.issue.Issue.Key
+	tags["project.key"] = ise.issue.Issue.Fields.Project.Key
+
+	tags["issue.status.id"] = fmt.Sprintf("%v", ise.change.To)
+	tags["issue.status.name"] = ise.change.ToString
+	tags["issue.status.from"] = ise.change.FromString
+	if ise.issue.Issue.Fields.Status != nil {
+		tags["issue.status.category"] = ise.issue.Issue.Fields.Status.StatusCategory.Key
+	}
+
 	return tags, nil
 }
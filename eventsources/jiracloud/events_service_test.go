@@ -18,9 +18,11 @@ import (
 
 var baseURL string
 var jiraPath string
+var jiraServerPath string
 
 var urlEnvVar string = "TEST_EVENTS_URL"
 var jiraPathEnvVar string = "TEST_EVENTS_JIRA_PATH"
+var jiraServerPathEnvVar string = "TEST_EVENTS_JIRA_SERVER_PATH"
 
 func init() {
 	ok := true
@@ -32,109 +34,297 @@ func init() {
 	if !ok {
 		panic(fmt.Sprintf("requires: %q", jiraPathEnvVar))
 	}
+	jiraServerPath, ok = os.LookupEnv(jiraServerPathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", jiraServerPathEnvVar))
+	}
+}
+
+type expectedSpan struct {
+	OperationName string
+	Tags          map[string]interface{}
 }
 
-var eventTests = []struct {
-	Name                  string
-	StartEventPath        string
-	EndEventPath          string
-	ExpectedOperationName string
-	ExpectedTags          map[string]interface{}
-}{
+type serviceEventTest struct {
+	Name          string
+	EventPaths    []string
+	ExpectedSpans []expectedSpan
+}
+
+var eventTests = []serviceEventTest{
 	{
-		Name:                  "sprint_complete",
-		StartEventPath:        "fixtures/events/sprints/started.json",
-		EndEventPath:          "fixtures/events/sprints/closed.json",
-		ExpectedOperationName: "sprint",
-		ExpectedTags: map[string]interface{}{
-			"error":                  false,
-			"sprint.end_date":        "2019-12-06T17:13:00Z",
-			"sprint.id":              float64(1),
-			"sprint.name":            "TS Sprint 1",
-			"sprint.origin_board_id": float64(2),
-			"sprint.start_date":      "2019-11-22T17:13:15.221Z",
-			"state":                  "active",
+		Name: "sprint_complete",
+		EventPaths: []string{
+			"fixtures/events/sprints/started.json",
+			"fixtures/events/sprints/closed.json",
+		},
+		ExpectedSpans: []expectedSpan{
+			{
+				OperationName: "sprint",
+				Tags: map[string]interface{}{
+					"error":                  true,
+					"sprint.end_date":        "2019-12-06T17:13:00Z",
+					"sprint.id":              float64(1),
+					"sprint.name":            "TS Sprint 1",
+					"sprint.origin_board_id": float64(2),
+					"sprint.start_date":      "2019-11-22T17:13:15.221Z",
+					"state":                  "active",
+
+					"sprint.complete_date":            "2019-11-22T17:15:05.352Z",
+					"sprint.duration.planned_seconds": 1209584.779,
+					"sprint.duration.actual_seconds":  110.131,
+					"sprint.closed_early":             true,
+					"sprint.closed_late":              false,
+					"sprint.issues.committed":         float64(0),
+					"sprint.issues.added":             float64(0),
+					"sprint.issues.removed":           float64(0),
+					"sprint.issues.completed":         float64(0),
+				},
+			},
+		},
+	},
+	{
+		Name: "scrum_sprint_issue_completed",
+		EventPaths: []string{
+			"fixtures/events/sprints/sprint_2_started.json",
+			"fixtures/events/issues/scrum/in_progress_active_sprint.json",
+			"fixtures/events/issues/scrum/done_active_sprint.json",
+			"fixtures/events/sprints/sprint_2_closed.json",
+		},
+		ExpectedSpans: []expectedSpan{
+			{
+				OperationName: "issue_status",
+				Tags:          map[string]interface{}{
+					"error":                 false,
+					"issue.id":              "10003",
+					"issue.key":             "TS-1",
+					"project.key":           "TS",
+					"issue.status.id":       "3",
+					"issue.status.name":     "In Progress",
+					"issue.status.from":     "To Do",
+					"issue.status.category": "indeterminate",
+				},
+			},
+			{
+				OperationName: "issue",
+				Tags:          map[string]interface{}{
+					"error":               false,
+					"issue.id":            "10003",
+					"issue.key":           "TS-1",
+					"issue.priority.id":   "3",
+					"issue.priority.name": "Medium",
+					"issue.status.id":     "3",
+					"issue.status.name":   "In Progress",
+					"issue.type.id":       "10001",
+					"issue.type.name":     "Story",
+					"project.id":          "10001",
+					"project.key":         "TS",
+					"project.name":        "test-scrum",
+					"sprint.id":           float64(2),
+					"user.account_id":     "5dd5c77403eda50ef3873efd",
+					"user.account_type":   "atlassian",
+					"user.display_name":   "Daniel Mican",
+				},
+			},
+			{
+				OperationName: "sprint",
+				Tags:          map[string]interface{}{
+					"error":                  false,
+					"sprint.end_date":        "2019-12-06T17:53:00Z",
+					"sprint.id":              float64(2),
+					"sprint.name":            "TS Sprint 2",
+					"sprint.origin_board_id": float64(2),
+					"sprint.start_date":      "2019-11-22T17:53:32.384Z",
+					"state":                  "active",
+
+					"sprint.complete_date":            "2019-12-06T18:20:00Z",
+					"sprint.duration.planned_seconds": 1209567.616,
+					"sprint.duration.actual_seconds":  1211187.616,
+					"sprint.closed_early":             false,
+					"sprint.closed_late":              true,
+					"sprint.issues.committed":         float64(1),
+					"sprint.issues.added":             float64(0),
+					"sprint.issues.removed":           float64(0),
+					"sprint.issues.completed":         float64(1),
+				},
+			},
 		},
 	},
 	{
-		Name:                  "kanban_issue_in_progress",
-		StartEventPath:        "fixtures/events/issues/kanban/selected_for_dev.json",
-		EndEventPath:          "fixtures/events/issues/kanban/in_progress.json",
-		ExpectedOperationName: "issue",
-		ExpectedTags: map[string]interface{}{
-			"issue.status.name":   "Selected for Development",
-			"issue.type.name":     "Story",
-			"project.id":          "10000",
-			"user.account_type":   "atlassian",
-			"issue.key":           "TP-3",
-			"issue.priority.id":   "3",
-			"error":               false,
-			"issue.priority.name": "Medium",
-			"issue.status.id":     "10001",
-			"issue.type.id":       "10001",
-			"project.key":         "TP",
-			"project.name":        "test-project",
-			"user.account_id":     "5dd5c77403eda50ef3873efd",
-			"user.display_name":   "Daniel Mican",
-			"issue.id":            "10002",
+		Name: "kanban_issue_selected_in_progress_done",
+		EventPaths: []string{
+			"fixtures/events/issues/kanban/selected_for_dev.json",
+			"fixtures/events/issues/kanban/in_progress.json",
+			"fixtures/events/issues/kanban/done.json",
+		},
+		ExpectedSpans: []expectedSpan{
+			{
+				OperationName: "issue_status",
+				Tags: map[string]interface{}{
+					"error":                 false,
+					"issue.id":              "10002",
+					"issue.key":             "TP-3",
+					"project.key":           "TP",
+					"issue.status.id":       "10001",
+					"issue.status.name":     "Selected for Development",
+					"issue.status.from":     "Backlog",
+					"issue.status.category": "new",
+				},
+			},
+			{
+				OperationName: "issue_status",
+				Tags: map[string]interface{}{
+					"error":                 false,
+					"issue.id":              "10002",
+					"issue.key":             "TP-3",
+					"project.key":           "TP",
+					"issue.status.id":       "3",
+					"issue.status.name":     "In Progress",
+					"issue.status.from":     "Selected for Development",
+					"issue.status.category": "indeterminate",
+				},
+			},
+			{
+				OperationName: "issue",
+				Tags: map[string]interface{}{
+					"issue.status.name":   "Selected for Development",
+					"issue.type.name":     "Story",
+					"project.id":          "10000",
+					"user.account_type":   "atlassian",
+					"user.account_id":     "5dd5c77403eda50ef3873efd",
+					"issue.key":           "TP-3",
+					"issue.priority.id":   "3",
+					"error":               false,
+					"issue.priority.name": "Medium",
+					"issue.status.id":     "10001",
+					"issue.type.id":       "10001",
+					"project.key":         "TP",
+					"project.name":        "test-project",
+					"user.display_name":   "Daniel Mican",
+					"issue.id":            "10002",
+				},
+			},
 		},
 	},
 	{
-		Name:                  "kanban_selected_to_backlog",
-		StartEventPath:        "fixtures/events/issues/kanban/selected_for_dev.json",
-		EndEventPath:          "fixtures/events/issues/kanban/selected_to_backlog.json",
-		ExpectedOperationName: "issue",
-		ExpectedTags: map[string]interface{}{
-			"issue.priority.id":   "3",
-			"project.key":         "TP",
-			"user.account_id":     "5dd5c77403eda50ef3873efd",
-			"issue.key":           "TP-3",
-			"issue.type.name":     "Story",
-			"project.name":        "test-project",
-			"issue.id":            "10002",
-			"project.id":          "10000",
-			"issue.status.name":   "Selected for Development",
-			"issue.type.id":       "10001",
-			"user.account_type":   "atlassian",
-			"user.display_name":   "Daniel Mican",
-			"error":               false,
-			"issue.priority.name": "Medium",
-			"issue.status.id":     "10001",
+		Name: "kanban_selected_to_backlog",
+		EventPaths: []string{
+			"fixtures/events/issues/kanban/selected_for_dev.json",
+			"fixtures/events/issues/kanban/selected_to_backlog.json",
+		},
+		ExpectedSpans: []expectedSpan{
+			{
+				OperationName: "issue_status",
+				Tags: map[string]interface{}{
+					"error":                 false,
+					"issue.id":              "10002",
+					"issue.key":             "TP-3",
+					"project.key":           "TP",
+					"issue.status.id":       "10001",
+					"issue.status.name":     "Selected for Development",
+					"issue.status.from":     "Backlog",
+					"issue.status.category": "new",
+				},
+			},
+			{
+				OperationName: "issue",
+				Tags: map[string]interface{}{
+					"issue.status.name":   "Selected for Development",
+					"issue.type.name":     "Story",
+					"project.id":          "10000",
+					"user.account_type":   "atlassian",
+					"user.account_id":     "5dd5c77403eda50ef3873efd",
+					"issue.key":           "TP-3",
+					"issue.priority.id":   "3",
+					"error":               false,
+					"issue.priority.name": "Medium",
+					"issue.status.id":     "10001",
+					"issue.type.id":       "10001",
+					"project.key":         "TP",
+					"project.name":        "test-project",
+					"user.display_name":   "Daniel Mican",
+					"issue.id":            "10002",
+				},
+			},
 		},
 	},
+}
+
+var serverEventTests = []serviceEventTest{
 	{
-		Name:                  "kanban_in_progress_to_done",
-		StartEventPath:        "fixtures/events/issues/kanban/in_progress.json",
-		EndEventPath:          "fixtures/events/issues/kanban/done.json",
-		ExpectedOperationName: "issue",
-		ExpectedTags: map[string]interface{}{
-			"user.display_name":   "Daniel Mican",
-			"issue.priority.id":   "3",
-			"issue.status.name":   "In Progress",
-			"issue.type.id":       "10001",
-			"user.account_id":     "5dd5c77403eda50ef3873efd",
-			"user.account_type":   "atlassian",
-			"issue.priority.name": "Medium",
-			"issue.id":            "10002",
-			"project.key":         "TP",
-			"project.name":        "test-project",
-			"error":               false,
-			"issue.key":           "TP-3",
-			"issue.status.id":     "3",
-			"issue.type.name":     "Story",
-			"project.id":          "10000",
+		Name: "kanban_issue_selected_in_progress_done",
+		EventPaths: []string{
+			"fixtures/events/server/issues/kanban/selected_for_dev.json",
+			"fixtures/events/server/issues/kanban/in_progress.json",
+			"fixtures/events/server/issues/kanban/done.json",
+		},
+		ExpectedSpans: []expectedSpan{
+			{
+				OperationName: "issue_status",
+				Tags: map[string]interface{}{
+					"error":                 false,
+					"issue.id":              "10002",
+					"issue.key":             "TP-3",
+					"project.key":           "TP",
+					"issue.status.id":       "10001",
+					"issue.status.name":     "Selected for Development",
+					"issue.status.from":     "Backlog",
+					"issue.status.category": "new",
+				},
+			},
+			{
+				OperationName: "issue_status",
+				Tags: map[string]interface{}{
+					"error":                 false,
+					"issue.id":              "10002",
+					"issue.key":             "TP-3",
+					"project.key":           "TP",
+					"issue.status.id":       "3",
+					"issue.status.name":     "In Progress",
+					"issue.status.from":     "Selected for Development",
+					"issue.status.category": "indeterminate",
+				},
+			},
+			{
+				OperationName: "issue",
+				Tags: map[string]interface{}{
+					"issue.status.name":   "Selected for Development",
+					"issue.type.name":     "Story",
+					"project.id":          "10000",
+					"user.name":           "dmican",
+					"user.key":            "JIRAUSER10000",
+					"issue.key":           "TP-3",
+					"issue.priority.id":   "3",
+					"error":               false,
+					"issue.priority.name": "Medium",
+					"issue.status.id":     "10001",
+					"issue.type.id":       "10001",
+					"project.key":         "TP",
+					"project.name":        "test-project",
+					"user.display_name":   "Daniel Mican",
+					"issue.id":            "10002",
+				},
+			},
 		},
 	},
 }
 
 func TestServiceEvent_JiraCloud(t *testing.T) {
+	testServiceEvents(t, jiraPath, eventTests)
+}
+
+func TestServiceEvent_JiraServer(t *testing.T) {
+	testServiceEvents(t, jiraServerPath, serverEventTests)
+}
+
+func testServiceEvents(t *testing.T, path string, tests []serviceEventTest) {
 	client := &http.Client{}
-	u, err := url.Parse(baseURL + jiraPath)
+	u, err := url.Parse(baseURL + path)
 	assert.NoError(t, err)
 
 	var te *eventsources.TestEvent
 
-	for _, tt := range eventTests {
+	for _, tt := range tests {
 		t.Run(tt.Name, func(t *testing.T) {
 			// reset the tracer
 			resp, err := http.Get(baseURL + "/mocktracer/reset")
@@ -145,11 +335,7 @@ func TestServiceEvent_JiraCloud(t *testing.T) {
 			}()
 			assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-			eventPaths := []string{
-				tt.StartEventPath,
-				tt.EndEventPath,
-			}
-			for _, eventPath := range eventPaths {
+			for _, eventPath := range tt.EventPaths {
 				te, err = eventsources.NewTestEventFromFixturePath(eventPath)
 				assert.NoError(t, err)
 
@@ -181,12 +367,82 @@ func TestServiceEvent_JiraCloud(t *testing.T) {
 			err = json.Unmarshal(bs, &spans)
 			assert.NoError(t, err)
 
-			assert.Equal(t, 1, len(spans))
+			assert.Equal(t, len(tt.ExpectedSpans), len(spans))
 
-			if len(spans) > 0 {
-				assert.Equal(t, tt.ExpectedOperationName, spans[0].Span.OperationName)
-				assert.Equal(t, tt.ExpectedTags, spans[0].Tags)
+			for i := 0; i < len(spans) && i < len(tt.ExpectedSpans); i++ {
+				assert.Equal(t, tt.ExpectedSpans[i].OperationName, spans[i].Span.OperationName)
+				assert.Equal(t, tt.ExpectedSpans[i].Tags, spans[i].Tags)
 			}
 		})
 	}
 }
+
+func TestServiceTrace_JiraCloud_EpicStorySubtask(t *testing.T) {
+	client := &http.Client{}
+	u, err := url.Parse(baseURL + jiraPath)
+	assert.NoError(t, err)
+
+	resp, err := http.Get(baseURL + "/mocktracer/reset")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	eventPaths := []string{
+		"fixtures/traces/epic_story_subtask/01_epic_in_progress.json",
+		"fixtures/traces/epic_story_subtask/02_story_selected.json",
+		"fixtures/traces/epic_story_subtask/03_subtask_in_progress.json",
+		"fixtures/traces/epic_story_subtask/04_subtask_done.json",
+		"fixtures/traces/epic_story_subtask/05_story_done.json",
+		"fixtures/traces/epic_story_subtask/06_epic_done.json",
+	}
+	for _, eventPath := range eventPaths {
+		te, err := eventsources.NewTestEventFromFixturePath(eventPath)
+		assert.NoError(t, err)
+
+		rawPayload, err := json.Marshal(te.Payload)
+		assert.NoError(t, err)
+
+		eventResp, err := client.Post(u.String(), "application/json", bytes.NewReader(rawPayload))
+		assert.NoError(t, err)
+		eventResp.Body.Close()
+		assert.Equal(t, http.StatusOK, eventResp.StatusCode, eventPath)
+	}
+
+	spansResp, err := http.Get(baseURL + "/mocktracer/finished-spans")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, spansResp.StatusCode)
+
+	bs, err := ioutil.ReadAll(spansResp.Body)
+	assert.NoError(t, err)
+	spansResp.Body.Close()
+
+	var spans []tracers.TestSpan
+
+	err = json.Unmarshal(bs, &spans)
+	assert.NoError(t, err)
+
+	// each issue has a span along with a span for the last status it was in
+	assert.Equal(t, 6, len(spans))
+
+	issues := make(map[string]tracers.TestSpan)
+	for _, s := range spans {
+		if s.Span.OperationName == "issue" {
+			issues[s.Tags["issue.key"].(string)] = s
+		}
+	}
+
+	epic, story, subtask := issues["TP-1"], issues["TP-3"], issues["TP-4"]
+	if epic.Span == nil || story.Span == nil || subtask.Span == nil {
+		t.Fatalf("expected epic, story and sub-task spans, received: %+v", issues)
+	}
+
+	assert.Equal(t, 0, epic.Span.ParentID)
+	assert.Equal(t, epic.Span.SpanContext.SpanID, story.Span.ParentID)
+	assert.Equal(t, story.Span.SpanContext.SpanID, subtask.Span.ParentID)
+	assert.Equal(t, "TP-1", story.Tags["issue.epic.key"])
+	assert.Equal(t, "TP-3", subtask.Tags["issue.parent.key"])
+
+	for _, s := range spans {
+		assert.Equal(t, epic.Span.SpanContext.TraceID, s.Span.SpanContext.TraceID)
+	}
+}
@@ -0,0 +1,184 @@
+package jiracloud
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"github.com/ImpactInsights/valuestream/traces"
+	"github.com/andygrunwald/go-jira"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func testIssueEvent(from, to, category string) IssueEvent {
+	ws := DefaultWorkflows()
+	ws.Overrides = map[string]types.Workflow{
+		"TP": {
+			Statuses: map[string]eventsources.SpanState{
+				"Code Review": eventsources.TransitionState,
+			},
+		},
+	}
+
+	ie := IssueEvent{
+		Issue: jira.Issue{
+			Key: "TP-3",
+			Fields: &jira.IssueFields{
+				Project: jira.Project{Key: "TP"},
+				Status: &jira.Status{
+					Name: to,
+					StatusCategory: jira.StatusCategory{
+						Key: category,
+					},
+				},
+			},
+		},
+		source:    sourceName,
+		workflows: ws,
+	}
+
+	if from != "" || to != "" {
+		ie.Changelog = Changelog{
+			Items: []jira.ChangelogItems{
+				{Field: "status", FromString: from, ToString: to},
+			},
+		}
+	}
+	return ie
+}
+
+func TestIssueEvent_State(t *testing.T) {
+	started := eventsources.EventState(eventsources.StartState)
+
+	testCases := []struct {
+		name     string
+		event    IssueEvent
+		prev     *eventsources.EventState
+		expected eventsources.SpanState
+	}{
+		{"start", testIssueEvent("Backlog", "Selected for Development", "new"), nil, eventsources.StartState},
+		{"start_already_started", testIssueEvent("Backlog", "Selected for Development", "new"), &started, eventsources.IntermediaryState},
+		{"transition_starts", testIssueEvent("Backlog", "In Progress", "indeterminate"), nil, eventsources.StartState},
+		{"project_transition", testIssueEvent("In Progress", "Code Review", "new"), &started, eventsources.IntermediaryState},
+		{"end", testIssueEvent("In Progress", "Done", "done"), &started, eventsources.EndState},
+		{"end_not_started", testIssueEvent("In Progress", "Done", "done"), nil, eventsources.UnknownState},
+		{"unmapped_not_started", testIssueEvent("Backlog", "To Do", "new"), nil, eventsources.UnknownState},
+		{"no_status_change", testIssueEvent("", "", "indeterminate"), &started, eventsources.IntermediaryState},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := tt.event.State(tt.prev)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, state)
+		})
+	}
+}
+
+func TestIssueEvent_completed_MissingFields(t *testing.T) {
+	ie := testIssueEvent("In Progress", "Done", "done")
+	ie.Issue.Fields = nil
+
+	assert.True(t, ie.completed())
+
+	_, err := ie.State(nil)
+	assert.Error(t, err)
+}
+
+func TestIssueEvent_Children(t *testing.T) {
+	children, err := testIssueEvent("", "", "new").Children()
+	assert.NoError(t, err)
+	assert.Empty(t, children)
+
+	children, err = testIssueEvent("In Progress", "Code Review", "indeterminate").Children()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(children))
+
+	spanID, err := children[0].SpanID()
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-jiracloud-issue_status-TP-3", spanID)
+
+	parentID, err := eventsources.ParentSpanID(children[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-jiracloud-issue-TP-3", *parentID)
+}
+
+func TestIssueStatusEvent_State(t *testing.T) {
+	started := eventsources.EventState(eventsources.StartState)
+
+	testCases := []struct {
+		name     string
+		event    IssueEvent
+		prev     *eventsources.EventState
+		expected eventsources.SpanState
+	}{
+		{"first_status", testIssueEvent("Backlog", "Selected for Development", "new"), nil, eventsources.StartState},
+		{"next_status", testIssueEvent("In Progress", "Code Review", "indeterminate"), &started, eventsources.TransitionState},
+		{"unmapped_status", testIssueEvent("In Progress", "Blocked", "new"), &started, eventsources.TransitionState},
+		{"end", testIssueEvent("In Progress", "Done", "done"), &started, eventsources.EndState},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			children, err := tt.event.Children()
+			assert.NoError(t, err)
+
+			state, err := children[0].State(tt.prev)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, state)
+		})
+	}
+}
+
+func TestIssueEvent_SpanID_Referenced(t *testing.T) {
+	s, err := NewSource(nil, nil, DefaultWorkflows(), DefaultCustomFields(), nil)
+	assert.NoError(t, err)
+
+	te, err := eventsources.NewTestEventFromFixturePath("fixtures/events/issues/kanban/in_progress.json")
+	assert.NoError(t, err)
+
+	payload, err := json.Marshal(te.Payload)
+	assert.NoError(t, err)
+
+	e, err := s.Event(nil, payload)
+	assert.NoError(t, err)
+
+	spanID, err := e.SpanID()
+	assert.NoError(t, err)
+
+	// branches named after the issue are placed beneath its span
+	branch := "feature/" + e.(IssueEvent).Issue.Key + "-login"
+	assert.Equal(t, &spanID, traces.DefaultReferences().First("github", nil, branch))
+}
+
+func TestSource_CreatedAt(t *testing.T) {
+	opened := time.Date(2020, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	s, err := NewSource(nil, nil, DefaultWorkflows(), CustomFields{}, nil)
+	assert.NoError(t, err)
+
+	created, err := s.CreatedAt(context.Background(), "vstrace-jiracloud-issue-TP-3")
+	assert.NoError(t, err)
+	assert.Nil(t, created)
+
+	var looked []string
+	s.created = func(key string) (*time.Time, error) {
+		looked = append(looked, key)
+		return &opened, nil
+	}
+
+	created, err = s.CreatedAt(context.Background(), "vstrace-jiracloud-issue-TP-3")
+	assert.NoError(t, err)
+	assert.Equal(t, &opened, created)
+
+	// span ids of other sources and operations aren't looked up
+	created, err = s.CreatedAt(context.Background(), "vstrace-jiraserver-issue-TP-3")
+	assert.NoError(t, err)
+	assert.Nil(t, created)
+
+	created, err = s.CreatedAt(context.Background(), "vstrace-jiracloud-sprint-7")
+	assert.NoError(t, err)
+	assert.Nil(t, created)
+
+	assert.Equal(t, []string{"TP-3"}, looked)
+}
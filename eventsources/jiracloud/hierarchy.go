@@ -0,0 +1,93 @@
+package jiracloud
+
+import (
+	"fmt"
+	"github.com/andygrunwald/go-jira"
+	"strconv"
+	"strings"
+)
+
+// CustomFields are the ids of the jira custom fields which link an issue
+// to its epic and sprints.  Jira assigns these ids per instance, the
+// defaults are the ones used by jira cloud.
+type CustomFields struct {
+	EpicLink string
+	Sprint   string
+}
+
+func DefaultCustomFields() CustomFields {
+	return CustomFields{
+		EpicLink: "customfield_10014",
+		Sprint:   "customfield_10020",
+	}
+}
+
+// epicKey returns the key of the epic an issue belongs to.
+func (cf CustomFields) epicKey(fields *jira.IssueFields) *string {
+	if fields == nil || cf.EpicLink == "" {
+		return nil
+	}
+
+	key, ok := fields.Unknowns[cf.EpicLink].(string)
+	if !ok || key == "" {
+		return nil
+	}
+	return &key
+}
+
+// activeSprintID returns the id of the active sprint an issue is in.
+// Depending on the jira version sprints are sent either as objects or as
+// the string representation of greenhopper's sprint class:
+//
+//	com.atlassian.greenhopper.service.sprint.Sprint@1a2b3c[id=1,rapidViewId=2,state=ACTIVE,...]
+func (cf CustomFields) activeSprintID(fields *jira.IssueFields) *int {
+	if fields == nil || cf.Sprint == "" {
+		return nil
+	}
+
+	sprints, ok := fields.Unknowns[cf.Sprint].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	for _, s := range sprints {
+		var id, state string
+
+		switch sprint := s.(type) {
+		case string:
+			attrs := greenhopperSprintAttributes(sprint)
+			id, state = attrs["id"], attrs["state"]
+		case map[string]interface{}:
+			id = fmt.Sprintf("%v", sprint["id"])
+			state, _ = sprint["state"].(string)
+		}
+
+		if !strings.EqualFold(state, "active") {
+			continue
+		}
+
+		if sprintID, err := strconv.Atoi(id); err == nil {
+			return &sprintID
+		}
+	}
+
+	return nil
+}
+
+func greenhopperSprintAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+
+	start, end := strings.Index(s, "["), strings.LastIndex(s, "]")
+	if start == -1 || end < start {
+		return attrs
+	}
+
+	for _, attr := range strings.Split(s[start+1:end], ",") {
+		kv := strings.SplitN(attr, "=", 2)
+		if len(kv) == 2 {
+			attrs[kv[0]] = kv[1]
+		}
+	}
+
+	return attrs
+}
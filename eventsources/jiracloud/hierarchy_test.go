@@ -0,0 +1,111 @@
+package jiracloud
+
+import (
+	"encoding/json"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/andygrunwald/go-jira"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCustomFields_activeSprintID(t *testing.T) {
+	testCases := []struct {
+		name     string
+		sprints  interface{}
+		expected *int
+	}{
+		{
+			"greenhopper_strings",
+			[]interface{}{
+				"com.atlassian.greenhopper.service.sprint.Sprint@3877e566[id=1,rapidViewId=2,state=CLOSED,name=TS Sprint 1,sequence=1]",
+				"com.atlassian.greenhopper.service.sprint.Sprint@2168f711[id=2,rapidViewId=2,state=ACTIVE,name=TS Sprint 2,sequence=2]",
+			},
+			intPtr(2),
+		},
+		{
+			"objects",
+			[]interface{}{
+				map[string]interface{}{"id": float64(3), "state": "active", "name": "TS Sprint 3"},
+			},
+			intPtr(3),
+		},
+		{
+			"future_sprint",
+			[]interface{}{
+				"com.atlassian.greenhopper.service.sprint.Sprint@34d16b16[id=1,rapidViewId=2,state=FUTURE,name=TS Sprint 1]",
+			},
+			nil,
+		},
+		{
+			"missing",
+			nil,
+			nil,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := &jira.IssueFields{
+				Unknowns: map[string]interface{}{
+					"customfield_10020": tt.sprints,
+				},
+			}
+			assert.Equal(t, tt.expected, DefaultCustomFields().activeSprintID(fields))
+		})
+	}
+}
+
+func TestIssueEvent_ParentSpanID(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		expected *string
+	}{
+		{
+			"sub-task",
+			"fixtures/traces/epic_story_subtask/03_subtask_in_progress.json",
+			strPtr("vstrace-jiracloud-issue-TP-3"),
+		},
+		{
+			"epic_link",
+			"fixtures/traces/epic_story_subtask/02_story_selected.json",
+			strPtr("vstrace-jiracloud-issue-TP-1"),
+		},
+		{
+			"active_sprint",
+			"fixtures/events/issues/scrum/in_progress_active_sprint.json",
+			strPtr("vstrace-jiracloud-sprint-2"),
+		},
+		{
+			"none",
+			"fixtures/events/issues/kanban/in_progress.json",
+			nil,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSource(nil, nil, DefaultWorkflows(), DefaultCustomFields(), nil)
+			assert.NoError(t, err)
+
+			te, err := eventsources.NewTestEventFromFixturePath(tt.path)
+			assert.NoError(t, err)
+
+			payload, err := json.Marshal(te.Payload)
+			assert.NoError(t, err)
+
+			e, err := s.Event(nil, payload)
+			assert.NoError(t, err)
+
+			parentID, err := eventsources.ParentSpanID(e)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, parentID)
+		})
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -1,25 +1,40 @@
 package jiracloud
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"github.com/andygrunwald/go-jira"
 	"github.com/opentracing/opentracing-go"
 	"github.com/urfave/cli"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 )
 
 const (
-	sourceName string = "jiracloud"
+	sourceName       string = "jiracloud"
+	serverSourceName string = "jiraserver"
 )
 
+// Source handles webhooks from both Jira Cloud and Jira Server/Data Center,
+// which are identified by their name.
 type Source struct {
-	tracer opentracing.Tracer
+	name      string
+	tracer    opentracing.Tracer
+	secretKey []byte
+	workflows types.Workflows
+	fields    CustomFields
+	sprints   *sprintTracker
+	issues    SprintIssueLister
+	created   IssueCreatedLookup
 }
 
 func (s Source) Name() string {
-	return sourceName
+	return s.name
 }
 
 func (s *Source) Tracer() opentracing.Tracer {
@@ -27,15 +42,73 @@ func (s *Source) Tracer() opentracing.Tracer {
 }
 
 func (s *Source) SecretKey() []byte {
-	return nil
+	return s.secretKey
 }
 
+// CreatedAt looks up when the issue of a span id was created, when the
+// jira api is configured, so a placeholder started for an issue opened
+// before valuestream was installed is placed at that time.
+func (s *Source) CreatedAt(ctx context.Context, spanID string) (*time.Time, error) {
+	prefix := issueSpanID(s.name, "")
+	if s.created == nil || !strings.HasPrefix(spanID, prefix) {
+		return nil, nil
+	}
+	return s.created(strings.TrimPrefix(spanID, prefix))
+}
+
+// IssueCreatedLookup looks up when the issue of a key was created.
+type IssueCreatedLookup func(key string) (*time.Time, error)
+
+// NewIssueCreatedLookup looks up issues in jira at the url, authenticated
+// as the user with their api token or password.
+func NewIssueCreatedLookup(baseURL, user, token string) (IssueCreatedLookup, error) {
+	client, err := newClient(baseURL, user, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(key string) (*time.Time, error) {
+		issue, _, err := client.Issue.Get(key, &jira.GetQueryOptions{Fields: "created"})
+		if err != nil {
+			return nil, err
+		}
+		if issue.Fields == nil {
+			return nil, nil
+		}
+		created := time.Time(issue.Fields.Created).UTC()
+		return &created, nil
+	}, nil
+}
+
+// ValidatePayload supports webhooks registered with a secret, which are
+// signed using an HMAC of the payload, and Jira Cloud Connect apps which
+// authenticate using a JWT signed with the app's shared secret.
 func (s *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
 	if secretKey == nil {
 		return ioutil.ReadAll(r.Body)
 	}
 
-	return nil, fmt.Errorf("does not support signing right now")
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body")
+	}
+
+	auth := r.Header.Get(authorizationHeader)
+
+	switch {
+	case r.Header.Get(signatureHeader) != "":
+		err = signature.Validate(r, body, secretKey)
+	case s.name == sourceName && strings.HasPrefix(auth, jwtPrefix):
+		err = validateJWT(r, strings.TrimPrefix(auth, jwtPrefix), secretKey, time.Now())
+	default:
+		err = fmt.Errorf("request is not signed")
+	}
+
+	if err != nil {
+		return nil, eventsources.InvalidSignatureError{Err: err}
+	}
+
+	return body, nil
 }
 
 func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
@@ -47,24 +120,144 @@ func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, err
 	// get the specific event type from the wrapper even
 	switch e.Type() {
 	case sprintEvent:
-		var se SprintEvent
-		err := json.Unmarshal(payload, &se)
-		return se, err
+		se := SprintEvent{
+			source:  s.name,
+			sprints: s.sprints,
+			issues:  s.issues,
+		}
+		if err := json.Unmarshal(payload, &se); err != nil {
+			return nil, err
+		}
+		// the sprint is only finished, and its outcome recorded, once
+		// the event has been handled
+		if e.WebhookEvent == "sprint_closed" {
+			se.progress = s.sprints.progress(se.Sprint.ID)
+		}
+		return se, nil
 	case issueEvent:
-		var ie IssueEvent
-		err := json.Unmarshal(payload, &ie)
-		return ie, err
+		ie := IssueEvent{
+			source:       s.name,
+			workflows:    s.workflows,
+			customFields: s.fields,
+			sprints:      s.sprints,
+		}
+		if err := json.Unmarshal(payload, &ie); err != nil {
+			return nil, err
+		}
+		return ie, nil
 	}
 
 	return nil, fmt.Errorf("event type: %q, not supported", e.WebhookEvent)
 }
 
-func NewSource(tracer opentracing.Tracer) (*Source, error) {
+func NewSource(tracer opentracing.Tracer, secretKey []byte, workflows types.Workflows, fields CustomFields, issues SprintIssueLister) (*Source, error) {
+	return &Source{
+		name:      sourceName,
+		tracer:    tracer,
+		secretKey: secretKey,
+		workflows: workflows,
+		fields:    fields,
+		sprints:   newSprintTracker(),
+		issues:    issues,
+	}, nil
+}
+
+// NewServerSource handles webhooks from Jira Server and Data Center,
+// which identify users by their name and key instead of an account id.
+func NewServerSource(tracer opentracing.Tracer, secretKey []byte, workflows types.Workflows, fields CustomFields, issues SprintIssueLister) (*Source, error) {
 	return &Source{
-		tracer: tracer,
+		name:      serverSourceName,
+		tracer:    tracer,
+		secretKey: secretKey,
+		workflows: workflows,
+		fields:    fields,
+		sprints:   newSprintTracker(),
+		issues:    issues,
 	}, nil
 }
 
+// workflowsFromCLI loads the workflows file if one is configured.
+func workflowsFromCLI(c *cli.Context) (types.Workflows, error) {
+	path := c.String("jira-workflows")
+	if path == "" {
+		return DefaultWorkflows(), nil
+	}
+	return types.LoadWorkflows(path, "projects", DefaultWorkflows())
+}
+
+func customFieldsFromCLI(c *cli.Context) CustomFields {
+	return CustomFields{
+		EpicLink: c.String("jira-epic-link-field"),
+		Sprint:   c.String("jira-sprint-field"),
+	}
+}
+
+// sprintIssuesFromCLI lists the issues of sprints when the flags prefixed
+// by the source name configure the jira api.
+func sprintIssuesFromCLI(c *cli.Context, prefix string) (SprintIssueLister, error) {
+	url := c.String(prefix + "-url")
+	if url == "" {
+		return nil, nil
+	}
+	return NewSprintIssueLister(url, c.String(prefix+"-user"), c.String(prefix+"-token"))
+}
+
+// issueCreatedFromCLI looks up when issues were created when the flags
+// prefixed by the source name configure the jira api.
+func issueCreatedFromCLI(c *cli.Context, prefix string) (IssueCreatedLookup, error) {
+	url := c.String(prefix + "-url")
+	if url == "" {
+		return nil, nil
+	}
+	return NewIssueCreatedLookup(url, c.String(prefix+"-user"), c.String(prefix+"-token"))
+}
+
 func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
-	return NewSource(tracer)
+	var secretKey []byte
+	if secret := c.String("jira-secret"); secret != "" {
+		secretKey = []byte(secret)
+	}
+	workflows, err := workflowsFromCLI(c)
+	if err != nil {
+		return nil, err
+	}
+	issues, err := sprintIssuesFromCLI(c, "jira")
+	if err != nil {
+		return nil, err
+	}
+	created, err := issueCreatedFromCLI(c, "jira")
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewSource(tracer, secretKey, workflows, customFieldsFromCLI(c), issues)
+	if err != nil {
+		return nil, err
+	}
+	s.created = created
+	return s, nil
+}
+
+func NewServerFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
+	var secretKey []byte
+	if secret := c.String("jira-server-secret"); secret != "" {
+		secretKey = []byte(secret)
+	}
+	workflows, err := workflowsFromCLI(c)
+	if err != nil {
+		return nil, err
+	}
+	issues, err := sprintIssuesFromCLI(c, "jira-server")
+	if err != nil {
+		return nil, err
+	}
+	created, err := issueCreatedFromCLI(c, "jira-server")
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewServerSource(tracer, secretKey, workflows, customFieldsFromCLI(c), issues)
+	if err != nil {
+		return nil, err
+	}
+	s.created = created
+	return s, nil
 }
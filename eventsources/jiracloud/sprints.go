@@ -0,0 +1,324 @@
+package jiracloud
+
+import (
+	"context"
+	"fmt"
+	"github.com/andygrunwald/go-jira"
+	log "github.com/sirupsen/logrus"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	boardID, _      = tag.NewKey("board_id")
+	issueOutcome, _ = tag.NewKey("outcome")
+	closedEarly, _  = tag.NewKey("closed_early")
+
+	// Number of issues committed to, added, removed and completed during
+	// the last sprint closed on each board.
+	SprintIssues = stats.Int64(
+		"jira/sprint/issues",
+		"Number of issues in the last closed sprint by outcome",
+		stats.UnitDimensionless,
+	)
+
+	SprintIssuesView = &view.View{
+		Name:        "jira/sprint/issues",
+		Description: "Number of issues in the last closed sprint by outcome",
+		TagKeys:     []tag.Key{boardID, issueOutcome},
+		Measure:     SprintIssues,
+		Aggregation: view.LastValue(),
+	}
+
+	SprintClosedCount = stats.Int64(
+		"jira/sprint/closed/total",
+		"Number of sprints closed",
+		stats.UnitDimensionless,
+	)
+
+	SprintClosedCountView = &view.View{
+		Name:        "jira/sprint/closed/total",
+		Description: "Number of sprints closed",
+		TagKeys:     []tag.Key{boardID, closedEarly},
+		Measure:     SprintClosedCount,
+		Aggregation: view.Count(),
+	}
+
+	// Seconds the last sprint closed on each board ran over (positive) or
+	// under (negative) the duration planned for it.
+	SprintDurationDeltaSeconds = stats.Float64(
+		"jira/sprint/duration/delta_seconds",
+		"Actual minus planned duration of the last closed sprint",
+		"s",
+	)
+
+	SprintDurationDeltaView = &view.View{
+		Name:        "jira/sprint/duration/delta_seconds",
+		Description: "Actual minus planned duration of the last closed sprint",
+		TagKeys:     []tag.Key{boardID},
+		Measure:     SprintDurationDeltaSeconds,
+		Aggregation: view.LastValue(),
+	}
+
+	SprintClosedLateCount = stats.Int64(
+		"jira/sprint/closed_late/total",
+		"Number of sprints closed after their end date",
+		stats.UnitDimensionless,
+	)
+
+	SprintClosedLateCountView = &view.View{
+		Name:        "jira/sprint/closed_late/total",
+		Description: "Number of sprints closed after their end date",
+		TagKeys:     []tag.Key{boardID},
+		Measure:     SprintClosedLateCount,
+		Aggregation: view.Count(),
+	}
+)
+
+const (
+	outcomeCommitted string = "committed"
+	outcomeAdded     string = "added"
+	outcomeRemoved   string = "removed"
+	outcomeCompleted string = "completed"
+)
+
+// sprintProgress is the set of issues, by key, for each outcome.
+// Committed are the issues listed when the sprint started.  When they
+// can't be listed the issues already in the sprint are only known once an
+// event is received for them, so committed is the number of issues
+// observed in the sprint which weren't added after it started.
+type sprintProgress struct {
+	scoped    bool
+	committed map[string]struct{}
+	added     map[string]struct{}
+	removed   map[string]struct{}
+	completed map[string]struct{}
+}
+
+func (sp *sprintProgress) counts() map[string]int {
+	return map[string]int{
+		outcomeCommitted: len(sp.committed),
+		outcomeAdded:     len(sp.added),
+		outcomeRemoved:   len(sp.removed),
+		outcomeCompleted: len(sp.completed),
+	}
+}
+
+func (sp *sprintProgress) copy() *sprintProgress {
+	return &sprintProgress{
+		scoped:    sp.scoped,
+		committed: copyKeys(sp.committed),
+		added:     copyKeys(sp.added),
+		removed:   copyKeys(sp.removed),
+		completed: copyKeys(sp.completed),
+	}
+}
+
+func copyKeys(keys map[string]struct{}) map[string]struct{} {
+	c := make(map[string]struct{}, len(keys))
+	for key := range keys {
+		c[key] = struct{}{}
+	}
+	return c
+}
+
+func newSprintProgress() *sprintProgress {
+	return &sprintProgress{
+		committed: make(map[string]struct{}),
+		added:     make(map[string]struct{}),
+		removed:   make(map[string]struct{}),
+		completed: make(map[string]struct{}),
+	}
+}
+
+// sprintTracker follows the issues of each sprint while its span is open.
+type sprintTracker struct {
+	mu      *sync.Mutex
+	sprints map[int]*sprintProgress
+}
+
+// start tracks the sprint, committed to the issues listed when it
+// started, or to those observed in it when they couldn't be listed.
+func (st *sprintTracker) start(id int, committed []string, listed bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if _, ok := st.sprints[id]; ok {
+		return
+	}
+	sp := newSprintProgress()
+	sp.scoped = listed
+	for _, key := range committed {
+		sp.committed[key] = struct{}{}
+	}
+	st.sprints[id] = sp
+}
+
+// progress is a copy of the sprint's progress so far, if the sprint is
+// being tracked.
+func (st *sprintTracker) progress(id int) *sprintProgress {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	sp, ok := st.sprints[id]
+	if !ok {
+		return nil
+	}
+	return sp.copy()
+}
+
+// finish stops tracking the sprint.
+func (st *sprintTracker) finish(id int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.sprints, id)
+}
+
+func (st *sprintTracker) observe(ie IssueEvent) {
+	key := ie.Issue.Key
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if change := ie.Changelog.sprintChange(); change != nil {
+		from, to := sprintIDs(change.From), sprintIDs(change.To)
+		for id := range to {
+			sp, ok := st.sprints[id]
+			if !ok || from[id] {
+				continue
+			}
+			delete(sp.removed, key)
+			sp.added[key] = struct{}{}
+		}
+		for id := range from {
+			sp, ok := st.sprints[id]
+			if !ok || to[id] {
+				continue
+			}
+			delete(sp.committed, key)
+			delete(sp.added, key)
+			delete(sp.completed, key)
+			sp.removed[key] = struct{}{}
+		}
+	}
+
+	id := ie.customFields.activeSprintID(ie.Issue.Fields)
+	if id == nil {
+		return
+	}
+
+	sp, ok := st.sprints[*id]
+	if !ok {
+		return
+	}
+
+	if _, ok := sp.added[key]; !ok && !sp.scoped {
+		sp.committed[key] = struct{}{}
+	}
+
+	if ie.completed() {
+		sp.completed[key] = struct{}{}
+	}
+}
+
+func newSprintTracker() *sprintTracker {
+	return &sprintTracker{
+		mu:      &sync.Mutex{},
+		sprints: make(map[int]*sprintProgress),
+	}
+}
+
+// SprintIssueLister lists the keys of the issues in a sprint.  They're
+// listed as the issues committed to when the sprint starts, as issue events
+// only reveal the issues which changed during the sprint.
+type SprintIssueLister func(sprintID int) ([]string, error)
+
+// NewSprintIssueLister lists the issues of sprints by searching jira at
+// the url, authenticated as the user with their api token or password.
+func NewSprintIssueLister(baseURL, user, token string) (SprintIssueLister, error) {
+	client, err := newClient(baseURL, user, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(sprintID int) ([]string, error) {
+		var keys []string
+		err := client.Issue.SearchPages(
+			fmt.Sprintf("sprint = %d", sprintID),
+			&jira.SearchOptions{Fields: []string{"key"}},
+			func(issue jira.Issue) error {
+				keys = append(keys, issue.Key)
+				return nil
+			},
+		)
+		return keys, err
+	}, nil
+}
+
+// newClient connects to the jira api at the url, authenticated as the user
+// with their api token or password.
+func newClient(baseURL, user, token string) (*jira.Client, error) {
+	tp := jira.BasicAuthTransport{
+		Username: user,
+		Password: token,
+	}
+	return jira.NewClient(tp.Client(), baseURL)
+}
+
+// sprintIDs parses the comma separated sprint ids of a changelog item.
+func sprintIDs(v interface{}) map[int]bool {
+	ids := make(map[int]bool)
+	s, ok := v.(string)
+	if !ok {
+		return ids
+	}
+	for _, part := range strings.Split(s, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+func recordSprintClosed(ctx context.Context, se SprintEvent) {
+	early, _ := se.IsError()
+
+	ctx, err := tag.New(ctx,
+		tag.Insert(boardID, strconv.Itoa(se.Sprint.OriginBoardID)),
+		tag.Insert(closedEarly, strconv.FormatBool(early)),
+	)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Errorf("jira.recordSprintClosed")
+		return
+	}
+
+	stats.Record(ctx, SprintClosedCount.M(1))
+
+	start, end, complete := se.Sprint.StartDate, se.Sprint.EndDate, se.Sprint.CompleteDate
+	if start != nil && end != nil && complete != nil {
+		delta := complete.Sub(*start) - end.Sub(*start)
+		stats.Record(ctx, SprintDurationDeltaSeconds.M(delta.Seconds()))
+	}
+	if end != nil && complete != nil && complete.After(*end) {
+		stats.Record(ctx, SprintClosedLateCount.M(1))
+	}
+
+	if se.progress == nil {
+		return
+	}
+
+	for outcome, count := range se.progress.counts() {
+		outcomeCtx, err := tag.New(ctx, tag.Insert(issueOutcome, outcome))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Errorf("jira.recordSprintClosed")
+			return
+		}
+		stats.Record(outcomeCtx, SprintIssues.M(int64(count)))
+	}
+}
@@ -0,0 +1,193 @@
+package jiracloud
+
+import (
+	"context"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/andygrunwald/go-jira"
+	"github.com/stretchr/testify/assert"
+	"go.opencensus.io/stats/view"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func sprintIssueEvent(key string, activeSprint string, changes ...jira.ChangelogItems) IssueEvent {
+	ie := testIssueEvent("", "", "new")
+	ie.Issue.Key = key
+	ie.customFields = DefaultCustomFields()
+	ie.Changelog = Changelog{Items: changes}
+	if activeSprint != "" {
+		ie.Issue.Fields.Unknowns = map[string]interface{}{
+			"customfield_10020": []interface{}{
+				map[string]interface{}{"id": activeSprint, "state": "active"},
+			},
+		}
+	}
+	return ie
+}
+
+func TestSprintTracker_observe(t *testing.T) {
+	st := newSprintTracker()
+	st.start(2, nil, false)
+
+	// committed when the sprint started
+	st.observe(sprintIssueEvent("TS-1", "2"))
+
+	// added after the sprint started and then completed
+	st.observe(sprintIssueEvent("TS-2", "2", jira.ChangelogItems{
+		Field: "Sprint", From: "1", To: "1, 2",
+	}))
+	done := sprintIssueEvent("TS-2", "2", jira.ChangelogItems{
+		Field: "status", FromString: "In Progress", ToString: "Done",
+	})
+	done.Issue.Fields.Status.StatusCategory.Key = "done"
+	st.observe(done)
+
+	// committed and then moved out of the sprint
+	st.observe(sprintIssueEvent("TS-3", "2"))
+	st.observe(sprintIssueEvent("TS-3", "", jira.ChangelogItems{
+		Field: "Sprint", From: "2", To: "",
+	}))
+
+	// not tracked
+	st.observe(sprintIssueEvent("TS-4", "3"))
+
+	sp := st.progress(2)
+	assert.Equal(t, map[string]int{
+		outcomeCommitted: 1,
+		outcomeAdded:     1,
+		outcomeRemoved:   1,
+		outcomeCompleted: 1,
+	}, sp.counts())
+
+	st.finish(2)
+	assert.Nil(t, st.progress(2))
+}
+
+func TestSprintTracker_observe_Listed(t *testing.T) {
+	st := newSprintTracker()
+	st.start(2, []string{"TS-1", "TS-2"}, true)
+
+	// committed issues which never change are still counted, and issues
+	// observed in the sprint aren't committed to unless they were listed
+	st.observe(sprintIssueEvent("TS-1", "2"))
+	st.observe(sprintIssueEvent("TS-3", "2"))
+
+	assert.Equal(t, map[string]int{
+		outcomeCommitted: 2,
+		outcomeAdded:     0,
+		outcomeRemoved:   0,
+		outcomeCompleted: 0,
+	}, st.progress(2).counts())
+}
+
+func TestSource_Event_SprintHandled(t *testing.T) {
+	s, err := NewSource(nil, nil, DefaultWorkflows(), DefaultCustomFields(), func(id int) ([]string, error) {
+		return []string{"TS-1", "TS-2"}, nil
+	})
+	assert.NoError(t, err)
+
+	started, err := s.Event(nil, []byte(`{"webhookEvent": "sprint_started", "sprint": {"id": 2, "state": "active"}}`))
+	assert.NoError(t, err)
+	assert.Nil(t, s.sprints.progress(2), "sprints are only tracked once their event is handled")
+
+	started.(eventsources.HandledEvent).Handled(context.Background())
+	assert.Equal(t, 2, s.sprints.progress(2).counts()[outcomeCommitted])
+
+	closed, err := s.Event(nil, []byte(`{"webhookEvent": "sprint_closed", "sprint": {"id": 2, "state": "closed"}}`))
+	assert.NoError(t, err)
+	assert.NotNil(t, s.sprints.progress(2), "sprints are only finished once their event is handled")
+
+	tags, err := closed.(SprintEvent).EndTags()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, tags["sprint.issues.committed"])
+
+	closed.(eventsources.HandledEvent).Handled(context.Background())
+	assert.Nil(t, s.sprints.progress(2))
+}
+
+func TestSprintEvent_EndTags(t *testing.T) {
+	start := time.Date(2019, 11, 22, 0, 0, 0, 0, time.UTC)
+	end := start.Add(14 * 24 * time.Hour)
+	complete := start.Add(10 * 24 * time.Hour)
+
+	se := SprintEvent{
+		Sprint: jira.Sprint{
+			StartDate:    &start,
+			EndDate:      &end,
+			CompleteDate: &complete,
+		},
+	}
+
+	isErr, err := se.IsError()
+	assert.NoError(t, err)
+	assert.True(t, isErr)
+
+	tags, err := se.EndTags()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"sprint.complete_date":            &complete,
+		"sprint.duration.planned_seconds": float64(14 * 24 * 60 * 60),
+		"sprint.duration.actual_seconds":  float64(10 * 24 * 60 * 60),
+		"sprint.closed_early":             true,
+		"sprint.closed_late":              false,
+	}, tags)
+}
+
+func TestRecordSprintClosed(t *testing.T) {
+	assert.NoError(t, view.Register(SprintDurationDeltaView, SprintClosedLateCountView))
+	defer view.Unregister(SprintDurationDeltaView, SprintClosedLateCountView)
+
+	start := time.Date(2019, 11, 22, 0, 0, 0, 0, time.UTC)
+	end := start.Add(14 * 24 * time.Hour)
+
+	testCases := []struct {
+		name          string
+		board         int
+		complete      time.Time
+		expectedDelta float64
+		expectedLate  int64
+	}{
+		{"closed_early", 1, end.Add(-4 * 24 * time.Hour), -4 * 24 * 60 * 60, 0},
+		{"closed_on_time", 2, end, 0, 0},
+		{"closed_late", 3, end.Add(2 * time.Hour), 2 * 60 * 60, 1},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			complete := tt.complete
+			recordSprintClosed(context.Background(), SprintEvent{
+				Sprint: jira.Sprint{
+					OriginBoardID: tt.board,
+					StartDate:     &start,
+					EndDate:       &end,
+					CompleteDate:  &complete,
+				},
+			})
+
+			board := strconv.Itoa(tt.board)
+
+			rows, err := view.RetrieveData(SprintDurationDeltaView.Name)
+			assert.NoError(t, err)
+			var deltas []float64
+			for _, row := range rows {
+				if row.Tags[0].Value == board {
+					deltas = append(deltas, row.Data.(*view.LastValueData).Value)
+				}
+			}
+			if assert.Equal(t, 1, len(deltas)) {
+				assert.Equal(t, tt.expectedDelta, deltas[0])
+			}
+
+			rows, err = view.RetrieveData(SprintClosedLateCountView.Name)
+			assert.NoError(t, err)
+			var late int64
+			for _, row := range rows {
+				if row.Tags[0].Value == board {
+					late += row.Data.(*view.CountData).Value
+				}
+			}
+			assert.Equal(t, tt.expectedLate, late)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package jiracloud
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+)
+
+// DefaultWorkflows maps the columns of jira's default kanban board, along
+// with its status category keys (`new`, `indeterminate` or `done`).
+// Workflows files override them per project, keyed by the project key.
+func DefaultWorkflows() types.Workflows {
+	return types.Workflows{
+		Default: types.Workflow{
+			Statuses: map[string]eventsources.SpanState{
+				kanbanBacklog:              eventsources.EndState,
+				kanbanSelectForDevelopment: eventsources.StartState,
+				kanbanInProgress:           eventsources.TransitionState,
+				kanbanDone:                 eventsources.EndState,
+			},
+			Categories: map[string]eventsources.SpanState{
+				"indeterminate": eventsources.TransitionState,
+				"done":          eventsources.EndState,
+			},
+		},
+	}
+}
@@ -0,0 +1,138 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Root is the start of every path, the document itself.
+const Root string = "$"
+
+type segment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// Path is the subset of JSONPath which selects a single value of a
+// decoded json document, ie `$.build.steps[0].name` or
+// `$['build']['status']`.  Array elements may also be selected by a key
+// holding their index, ie `$.build.steps.0.name`.
+type Path struct {
+	segments []segment
+}
+
+// Compile compiles a path, which must start at the Root.
+func Compile(p string) (*Path, error) {
+	if !strings.HasPrefix(p, Root) {
+		return nil, fmt.Errorf("path: %q must start with %q", p, Root)
+	}
+
+	path := &Path{}
+	rest := strings.TrimPrefix(p, Root)
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("path: %q contains an empty key", p)
+			}
+			path.segments = append(path.segments, segment{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("path: %q contains an unterminated [", p)
+			}
+			s, err := compileSubscript(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("path: %q %s", p, err)
+			}
+			path.segments = append(path.segments, s)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("path: %q contains an unexpected %q", p, rest[0])
+		}
+	}
+	return path, nil
+}
+
+// MustCompile compiles a path known to be valid, ie a constant.
+func MustCompile(p string) *Path {
+	path, err := Compile(p)
+	if err != nil {
+		panic(err)
+	}
+	return path
+}
+
+func compileSubscript(s string) (segment, error) {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return segment{key: s[1 : len(s)-1]}, nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 0 {
+		return segment{}, fmt.Errorf("contains an unsupported subscript: %q", s)
+	}
+	return segment{index: i, isIndex: true}, nil
+}
+
+// Select is the value the path selects from a document decoded by
+// encoding/json into an interface{}, it's false when the path doesn't
+// select anything.
+func (p *Path) Select(doc interface{}) (interface{}, bool) {
+	v := doc
+	for _, s := range p.segments {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			if s.isIndex {
+				return nil, false
+			}
+			v = t[s.key]
+		case []interface{}:
+			i := s.index
+			if !s.isIndex {
+				var err error
+				if i, err = strconv.Atoi(s.key); err != nil {
+					return nil, false
+				}
+			}
+			if i < 0 || i >= len(t) {
+				return nil, false
+			}
+			v = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, v != nil
+}
+
+// Eval is the value the path selects as a string, objects and arrays are
+// encoded as json.
+func (p *Path) Eval(doc interface{}) (string, bool) {
+	v, ok := p.Select(doc)
+	if !ok {
+		return "", false
+	}
+
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(t), true
+	}
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(bs), true
+}
@@ -0,0 +1,58 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPath_Eval(t *testing.T) {
+	var doc interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"build": {
+			"number": 42,
+			"passed": true,
+			"steps": [{"name": "test"}, {"name": "deploy"}],
+			"labels": {"app.kubernetes.io/name": "checkout"}
+		}
+	}`), &doc))
+
+	testCases := []struct {
+		path     string
+		expected string
+		ok       bool
+	}{
+		{"$.build.number", "42", true},
+		{"$.build.passed", "true", true},
+		{"$.build.steps[1].name", "deploy", true},
+		{"$.build.steps.1.name", "deploy", true},
+		{"$['build']['labels']['app.kubernetes.io/name']", "checkout", true},
+		{"$.build.steps[0]", `{"name":"test"}`, true},
+		{"$.build.steps[2].name", "", false},
+		{"$.build.steps.name", "", false},
+		{"$.build[0]", "", false},
+		{"$.missing", "", false},
+	}
+	for _, tt := range testCases {
+		p, err := Compile(tt.path)
+		assert.NoError(t, err, tt.path)
+
+		v, ok := p.Eval(doc)
+		assert.Equal(t, tt.ok, ok, tt.path)
+		assert.Equal(t, tt.expected, v, tt.path)
+	}
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	for _, p := range []string{
+		"build.number",
+		"$..number",
+		"$.build[0",
+		"$.build[-1]",
+		"$.build[name]",
+		"$build",
+	} {
+		_, err := Compile(p)
+		assert.Error(t, err, p)
+	}
+}
@@ -0,0 +1,255 @@
+package kubernetes
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+const (
+	// TraceIDAnnotation is the annotation of a workload, or its pod
+	// template, holding the span id its rollouts are children of.
+	TraceIDAnnotation string = "valuestream.io/trace-id"
+	// revisionAnnotation is the revision of a deployment's pod template,
+	// which the deployment controller increments each time it rolls out a
+	// changed template.
+	revisionAnnotation string = "deployment.kubernetes.io/revision"
+
+	kindDeployment  string = "Deployment"
+	kindStatefulSet string = "StatefulSet"
+)
+
+type RolloutStatus string
+
+const (
+	RolloutProgressing RolloutStatus = "progressing"
+	RolloutComplete    RolloutStatus = "complete"
+	RolloutFailed      RolloutStatus = "failed"
+	// RolloutSuperseded is a rollout which a newer revision of the
+	// workload replaced before it completed.
+	RolloutSuperseded RolloutStatus = "superseded"
+)
+
+// Rollout is the rollout of a single revision of a workload's pod
+// template.  Changes which don't change the template, ie scaling it,
+// keep its revision and aren't rollouts.
+type Rollout struct {
+	Kind        string
+	Namespace   string
+	Name        string
+	Revision    string
+	Annotations map[string]string
+	// Images are the images of the pod template by container name.
+	Images  map[string]string
+	Status  RolloutStatus
+	Message string
+}
+
+func newRollout(kind string, meta metav1.Object, template corev1.PodTemplateSpec) Rollout {
+	annotations := make(map[string]string)
+	for k, v := range template.Annotations {
+		annotations[k] = v
+	}
+	for k, v := range meta.GetAnnotations() {
+		annotations[k] = v
+	}
+
+	images := make(map[string]string)
+	for _, c := range template.Spec.Containers {
+		images[c.Name] = c.Image
+	}
+
+	return Rollout{
+		Kind:        kind,
+		Namespace:   meta.GetNamespace(),
+		Name:        meta.GetName(),
+		Annotations: annotations,
+		Images:      images,
+	}
+}
+
+// DeploymentRollout is the rollout of the deployment's current
+// revision.  Its status follows `kubectl rollout status`, the rollout
+// is progressing until every replica has been updated and is available,
+// and has failed once its progress deadline is exceeded.
+func DeploymentRollout(d *appsv1.Deployment) Rollout {
+	r := newRollout(kindDeployment, d, d.Spec.Template)
+	r.Revision = d.Annotations[revisionAnnotation]
+	r.Status = RolloutComplete
+
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			r.Status = RolloutFailed
+			r.Message = c.Message
+			return r
+		}
+	}
+
+	switch {
+	case d.Status.ObservedGeneration < d.Generation,
+		d.Status.UpdatedReplicas < replicas,
+		d.Status.Replicas > d.Status.UpdatedReplicas,
+		d.Status.AvailableReplicas < d.Status.UpdatedReplicas:
+		r.Status = RolloutProgressing
+	}
+	return r
+}
+
+// StatefulSetRollout is the rollout of the stateful set's update
+// revision, it's progressing until every replica is ready and those
+// outside of a partition have been updated.
+func StatefulSetRollout(s *appsv1.StatefulSet) Rollout {
+	r := newRollout(kindStatefulSet, s, s.Spec.Template)
+	r.Revision = s.Status.UpdateRevision
+	r.Status = RolloutComplete
+
+	replicas := int32(1)
+	if s.Spec.Replicas != nil {
+		replicas = *s.Spec.Replicas
+	}
+
+	if s.Status.ObservedGeneration < s.Generation || s.Status.ReadyReplicas < replicas {
+		r.Status = RolloutProgressing
+		return r
+	}
+
+	if s.Spec.UpdateStrategy.Type != appsv1.RollingUpdateStatefulSetStrategyType {
+		return r
+	}
+
+	if ru := s.Spec.UpdateStrategy.RollingUpdate; ru != nil && ru.Partition != nil {
+		if s.Status.UpdatedReplicas < replicas-*ru.Partition {
+			r.Status = RolloutProgressing
+		}
+		return r
+	}
+
+	if s.Status.UpdateRevision != s.Status.CurrentRevision {
+		r.Status = RolloutProgressing
+	}
+	return r
+}
+
+// imageTag is the tag of an image reference, or its digest when it's
+// pinned to one.
+func imageTag(image string) string {
+	if i := strings.LastIndex(image, "@"); i != -1 {
+		return image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return "latest"
+}
+
+// RolloutEvent traces the rollout of a revision of a workload as a
+// deploy, from when the watcher sees it progressing until it completes,
+// fails or is superseded.
+type RolloutEvent struct {
+	Rollout Rollout
+	// Environment names the cluster the watcher watches, deploys of a
+	// workload ship the commits since it was last deployed to it.
+	Environment string
+}
+
+// ServiceName is the namespace and name of the workload, ie
+// `shop/checkout`, incidents follow from its most recent deploy.
+func (re RolloutEvent) ServiceName() string {
+	return re.Rollout.Namespace + "/" + re.Rollout.Name
+}
+
+func (re RolloutEvent) OperationName() string {
+	return types.DeployEventType
+}
+
+func (re RolloutEvent) SpanID() (string, error) {
+	r := re.Rollout
+	if r.Namespace == "" || r.Name == "" || r.Revision == "" {
+		return "", fmt.Errorf("rollout does not contain a namespace, name and revision")
+	}
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		types.DeployEventType,
+		strings.ToLower(r.Kind),
+		r.Namespace,
+		r.Name,
+		r.Revision,
+	}, "-"), nil
+}
+
+// References the trace of the annotation as the change the rollout
+// deploys.
+func (re RolloutEvent) References() ([]eventsources.Reference, error) {
+	id, ok := re.Rollout.Annotations[TraceIDAnnotation]
+	if !ok || id == "" {
+		return nil, nil
+	}
+	return []eventsources.Reference{{
+		SpanID:       id,
+		Relationship: eventsources.DeploysRelationship,
+	}}, nil
+}
+
+func (re RolloutEvent) IsError() (bool, error) {
+	return re.Rollout.Status == RolloutFailed, nil
+}
+
+// State only ends rollouts which were seen progressing, workloads which
+// were already rolled out when the watcher started aren't traced.
+func (re RolloutEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	switch re.Rollout.Status {
+	case RolloutProgressing:
+		if prev == nil {
+			return eventsources.StartState, nil
+		}
+	case RolloutComplete, RolloutFailed, RolloutSuperseded:
+		if prev != nil {
+			return eventsources.EndState, nil
+		}
+	}
+	return eventsources.UnknownState, nil
+}
+
+func (re RolloutEvent) Timings() (eventsources.EventTimings, error) {
+	return eventsources.EventTimings{}, nil
+}
+
+func (re RolloutEvent) Tags() (map[string]interface{}, error) {
+	r := re.Rollout
+
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+	tags["deploy.kind"] = r.Kind
+	tags["deploy.namespace"] = r.Namespace
+	tags["deploy.name"] = r.Name
+	tags["deploy.revision"] = r.Revision
+	if re.Environment != "" {
+		tags["deploy.environment"] = re.Environment
+	}
+
+	for container, image := range r.Images {
+		tags[fmt.Sprintf("deploy.image.%s", container)] = image
+		tags[fmt.Sprintf("deploy.image_tag.%s", container)] = imageTag(image)
+	}
+	return tags, nil
+}
+
+func (re RolloutEvent) EndTags() (map[string]interface{}, error) {
+	tags := map[string]interface{}{
+		"deploy.status": string(re.Rollout.Status),
+	}
+	if re.Rollout.Message != "" {
+		tags["deploy.message"] = re.Rollout.Message
+	}
+	return tags, nil
+}
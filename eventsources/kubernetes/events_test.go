@@ -0,0 +1,159 @@
+package kubernetes
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func TestDeploymentRollout_Status(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   appsv1.DeploymentStatus
+		expected RolloutStatus
+	}{
+		{"not_observed", appsv1.DeploymentStatus{
+			ObservedGeneration: 1,
+		}, RolloutProgressing},
+		{"updating", appsv1.DeploymentStatus{
+			ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 2,
+		}, RolloutProgressing},
+		{"terminating_old_replicas", appsv1.DeploymentStatus{
+			ObservedGeneration: 2, Replicas: 4, UpdatedReplicas: 3, AvailableReplicas: 3,
+		}, RolloutProgressing},
+		{"unavailable", appsv1.DeploymentStatus{
+			ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 2,
+		}, RolloutProgressing},
+		{"complete", appsv1.DeploymentStatus{
+			ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3,
+		}, RolloutComplete},
+		{"deadline_exceeded", appsv1.DeploymentStatus{
+			ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 2,
+			Conditions: []appsv1.DeploymentCondition{{
+				Type:   appsv1.DeploymentProgressing,
+				Reason: "ProgressDeadlineExceeded",
+			}},
+		}, RolloutFailed},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := DeploymentRollout(&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Generation: 2},
+				Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(3)},
+				Status:     tt.status,
+			})
+			assert.Equal(t, tt.expected, r.Status)
+		})
+	}
+}
+
+func TestStatefulSetRollout_Status(t *testing.T) {
+	rollingUpdate := appsv1.StatefulSetUpdateStrategy{
+		Type: appsv1.RollingUpdateStatefulSetStrategyType,
+	}
+	partitioned := appsv1.StatefulSetUpdateStrategy{
+		Type: appsv1.RollingUpdateStatefulSetStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{
+			Partition: int32Ptr(2),
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		strategy appsv1.StatefulSetUpdateStrategy
+		status   appsv1.StatefulSetStatus
+		expected RolloutStatus
+	}{
+		{"not_ready", rollingUpdate, appsv1.StatefulSetStatus{
+			ObservedGeneration: 2, ReadyReplicas: 2,
+		}, RolloutProgressing},
+		{"updating", rollingUpdate, appsv1.StatefulSetStatus{
+			ObservedGeneration: 2, ReadyReplicas: 3, CurrentRevision: "a", UpdateRevision: "b",
+		}, RolloutProgressing},
+		{"complete", rollingUpdate, appsv1.StatefulSetStatus{
+			ObservedGeneration: 2, ReadyReplicas: 3, CurrentRevision: "b", UpdateRevision: "b",
+		}, RolloutComplete},
+		{"partition_updating", partitioned, appsv1.StatefulSetStatus{
+			ObservedGeneration: 2, ReadyReplicas: 3, UpdatedReplicas: 0, CurrentRevision: "a", UpdateRevision: "b",
+		}, RolloutProgressing},
+		{"partition_complete", partitioned, appsv1.StatefulSetStatus{
+			ObservedGeneration: 2, ReadyReplicas: 3, UpdatedReplicas: 1, CurrentRevision: "a", UpdateRevision: "b",
+		}, RolloutComplete},
+		{"on_delete", appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}, appsv1.StatefulSetStatus{
+			ObservedGeneration: 2, ReadyReplicas: 3, CurrentRevision: "a", UpdateRevision: "b",
+		}, RolloutComplete},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := StatefulSetRollout(&appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Generation: 2},
+				Spec: appsv1.StatefulSetSpec{
+					Replicas:       int32Ptr(3),
+					UpdateStrategy: tt.strategy,
+				},
+				Status: tt.status,
+			})
+			assert.Equal(t, tt.expected, r.Status)
+		})
+	}
+}
+
+func TestRolloutEvent_State(t *testing.T) {
+	started := eventsources.EventState(eventsources.StartState)
+
+	testCases := []struct {
+		status   RolloutStatus
+		prev     *eventsources.EventState
+		expected eventsources.SpanState
+	}{
+		{RolloutProgressing, nil, eventsources.StartState},
+		{RolloutProgressing, &started, eventsources.UnknownState},
+		{RolloutComplete, &started, eventsources.EndState},
+		{RolloutComplete, nil, eventsources.UnknownState},
+		{RolloutFailed, &started, eventsources.EndState},
+		{RolloutSuperseded, &started, eventsources.EndState},
+	}
+	for _, tt := range testCases {
+		re := RolloutEvent{Rollout: Rollout{Status: tt.status}}
+		s, err := re.State(tt.prev)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, s, tt.status)
+	}
+}
+
+func TestImageTag(t *testing.T) {
+	testCases := []struct {
+		image    string
+		expected string
+	}{
+		{"nginx", "latest"},
+		{"nginx:1.19", "1.19"},
+		{"localhost:5000/acme/checkout", "latest"},
+		{"localhost:5000/acme/checkout:5d1c9a3", "5d1c9a3"},
+		{"acme/checkout@sha256:8f1e2a", "sha256:8f1e2a"},
+	}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.expected, imageTag(tt.image), tt.image)
+	}
+}
+
+func TestRolloutEvent_ServiceName(t *testing.T) {
+	re := RolloutEvent{Rollout: Rollout{Namespace: "shop", Name: "checkout"}}
+	assert.Equal(t, "shop/checkout", re.ServiceName())
+}
+
+func TestRolloutEvent_Tags_Environment(t *testing.T) {
+	tags, err := RolloutEvent{Rollout: Rollout{Namespace: "shop", Name: "checkout"}, Environment: "production"}.Tags()
+	assert.NoError(t, err)
+	assert.Equal(t, "production", tags["deploy.environment"])
+
+	tags, err = RolloutEvent{Rollout: Rollout{Namespace: "shop", Name: "checkout"}}.Tags()
+	assert.NoError(t, err)
+	assert.NotContains(t, tags, "deploy.environment")
+}
@@ -0,0 +1,180 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/opentracing/opentracing-go"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"net/http"
+	"time"
+)
+
+const sourceName string = "kubernetes"
+
+// Handler traces the events the watcher observes, ie a webhooks.Webhook.
+type Handler interface {
+	HandleEvent(ctx context.Context, e eventsources.Event) error
+}
+
+// Watcher watches the rollouts of deployments and stateful sets rather
+// than receiving webhooks.  It's an EventSource so its events go through
+// the same webhooks.Webhook as every other source's.
+type Watcher struct {
+	tracer    opentracing.Tracer
+	client    kubernetes.Interface
+	namespace string
+	resync    time.Duration
+	// environment names the cluster its rollouts deploy to.
+	environment string
+}
+
+func (w Watcher) Name() string {
+	return sourceName
+}
+
+func (w *Watcher) Tracer() opentracing.Tracer {
+	return w.tracer
+}
+
+func (w *Watcher) SecretKey() []byte {
+	return nil
+}
+
+func (w *Watcher) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
+	return nil, fmt.Errorf("kubernetes rollouts are watched, not sent to a webhook")
+}
+
+func (w *Watcher) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
+	return nil, fmt.Errorf("kubernetes rollouts are watched, not sent to a webhook")
+}
+
+// Run watches rollouts until the context is done, handling an event each
+// time a workload changes.  A rollout still progressing when a newer
+// revision replaces it is ended as superseded.
+func (w *Watcher) Run(ctx context.Context, h Handler) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		w.client,
+		w.resync,
+		informers.WithNamespace(w.namespace),
+	)
+
+	factory.Apps().V1().Deployments().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			w.handle(ctx, h, nil, DeploymentRollout(obj.(*appsv1.Deployment)))
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			prev := DeploymentRollout(oldObj.(*appsv1.Deployment))
+			w.handle(ctx, h, &prev, DeploymentRollout(newObj.(*appsv1.Deployment)))
+		},
+	})
+
+	factory.Apps().V1().StatefulSets().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			w.handle(ctx, h, nil, StatefulSetRollout(obj.(*appsv1.StatefulSet)))
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			prev := StatefulSetRollout(oldObj.(*appsv1.StatefulSet))
+			w.handle(ctx, h, &prev, StatefulSetRollout(newObj.(*appsv1.StatefulSet)))
+		},
+	})
+
+	factory.Start(ctx.Done())
+
+	for informer, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("unable to sync informer: %s", informer)
+		}
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// handle traces the rollout of a new revision, and the end of the rollout
+// in progress.  Changes to a workload whose rollout already ended which
+// keep its revision, ie scaling it, aren't traced, nor are workloads the
+// controller hasn't assigned a revision yet.
+func (w *Watcher) handle(ctx context.Context, h Handler, prev *Rollout, r Rollout) {
+	events := []eventsources.Event{}
+
+	if prev != nil && prev.Revision != r.Revision && prev.Status == RolloutProgressing {
+		superseded := *prev
+		superseded.Status = RolloutSuperseded
+		events = append(events, RolloutEvent{Rollout: superseded, Environment: w.environment})
+	}
+
+	rolledOut := prev != nil && prev.Revision == r.Revision && prev.Status != RolloutProgressing
+	if r.Revision != "" && !rolledOut {
+		events = append(events, RolloutEvent{Rollout: r, Environment: w.environment})
+	}
+
+	for _, e := range events {
+		if err := h.HandleEvent(ctx, e); err != nil {
+			log.WithFields(log.Fields{
+				"error":   err.Error(),
+				"rollout": e,
+			}).Errorf("kubernetes.Watcher.handle")
+		}
+	}
+}
+
+func NewWatcher(tracer opentracing.Tracer, client kubernetes.Interface, namespace string, resync time.Duration) (*Watcher, error) {
+	return &Watcher{
+		tracer:    tracer,
+		client:    client,
+		namespace: namespace,
+		resync:    resync,
+	}, nil
+}
+
+// NewFromCLI watches the cluster of the kubeconfig, or the cluster
+// valuestream is running in when there isn't one.  Its rollouts deploy to
+// the environment named by the flag, or else the kubeconfig's current
+// context.
+func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (*Watcher, error) {
+	var config *rest.Config
+	var err error
+
+	environment := c.String("kubernetes-environment")
+
+	if kubeconfig := c.String("kubeconfig"); kubeconfig != "" {
+		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+		if err == nil && environment == "" {
+			environment, err = currentContext(kubeconfig)
+		}
+	} else {
+		config, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := NewWatcher(tracer, client, c.String("kubernetes-namespace"), 0)
+	if err != nil {
+		return nil, err
+	}
+	w.environment = environment
+	return w, nil
+}
+
+// currentContext is the name of the kubeconfig's current context.
+func currentContext(kubeconfig string) (string, error) {
+	config, err := clientcmd.LoadFromFile(kubeconfig)
+	if err != nil {
+		return "", err
+	}
+	return config.CurrentContext, nil
+}
@@ -0,0 +1,160 @@
+package kubernetes
+
+import (
+	"context"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/webhooks"
+	"github.com/ImpactInsights/valuestream/traces"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func deployment(revision string, replicas int32, updated int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "shop",
+			Name:      "checkout",
+			Annotations: map[string]string{
+				TraceIDAnnotation:  "pr-1",
+				revisionAnnotation: revision,
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: int32Ptr(replicas),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "checkout", Image: "acme/checkout:5d1c9a3"},
+					},
+				},
+			},
+		},
+		Status: appsv1.DeploymentStatus{
+			Replicas:          updated,
+			UpdatedReplicas:   updated,
+			AvailableReplicas: updated,
+		},
+	}
+}
+
+func waitForSpans(t *testing.T, tracer *mocktracer.MockTracer, n int) []*mocktracer.MockSpan {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if spans := tracer.FinishedSpans(); len(spans) >= n {
+			return spans
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d finished spans, received: %d", n, len(tracer.FinishedSpans()))
+	return nil
+}
+
+func newTestWatcher(t *testing.T) (*fake.Clientset, *mocktracer.MockTracer, *webhooks.Webhook, context.CancelFunc) {
+	client := fake.NewSimpleClientset(deployment("1", 2, 2))
+	tracer := mocktracer.New()
+
+	w, err := NewWatcher(tracer, client, "", 0)
+	assert.NoError(t, err)
+
+	wh, err := webhooks.New(w, nil, traces.NewMemoryUnboundedSpanStore())
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go w.Run(ctx, wh)
+
+	return client, tracer, wh, cancel
+}
+
+func TestWatcher_Run_TracesRollout(t *testing.T) {
+	client, tracer, wh, cancel := newTestWatcher(t)
+	defer cancel()
+
+	assert.NoError(t, wh.HandleEvent(context.Background(), eventsources.StubEvent{
+		SpanIDReturn:        "pr-1",
+		OperationNameReturn: "pull_request",
+		StateReturn:         eventsources.StartState,
+	}))
+
+	// give the informer time to list the deployment, which is already
+	// rolled out and isn't traced
+	time.Sleep(100 * time.Millisecond)
+
+	_, err := client.AppsV1().Deployments("shop").Update(deployment("2", 2, 1))
+	assert.NoError(t, err)
+	_, err = client.AppsV1().Deployments("shop").Update(deployment("2", 2, 2))
+	assert.NoError(t, err)
+
+	spans := waitForSpans(t, tracer, 1)
+	assert.Equal(t, 1, len(spans))
+
+	deploy := spans[0]
+	assert.Equal(t, "deploy", deploy.OperationName)
+	assert.Equal(t, map[string]interface{}{
+		"error":                     false,
+		"service":                   "kubernetes",
+		"deploy.kind":               "Deployment",
+		"deploy.namespace":          "shop",
+		"deploy.name":               "checkout",
+		"deploy.revision":           "2",
+		"deploy.image.checkout":     "acme/checkout:5d1c9a3",
+		"deploy.image_tag.checkout": "5d1c9a3",
+		"deploy.status":             "complete",
+	}, deploy.Tags())
+
+	parent, err := wh.Spans.Get(context.Background(), tracer, "pr-1")
+	assert.NoError(t, err)
+	assert.Equal(t, parent.Span.Context().(mocktracer.MockSpanContext).SpanID, deploy.ParentID)
+}
+
+func TestWatcher_Run_SupersededRollout(t *testing.T) {
+	client, tracer, _, cancel := newTestWatcher(t)
+	defer cancel()
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, err := client.AppsV1().Deployments("shop").Update(deployment("2", 2, 1))
+	assert.NoError(t, err)
+	_, err = client.AppsV1().Deployments("shop").Update(deployment("3", 2, 0))
+	assert.NoError(t, err)
+	_, err = client.AppsV1().Deployments("shop").Update(deployment("3", 2, 2))
+	assert.NoError(t, err)
+
+	spans := waitForSpans(t, tracer, 2)
+	assert.Equal(t, 2, len(spans))
+
+	superseded, deployed := spans[0], spans[1]
+	assert.Equal(t, "2", superseded.Tag("deploy.revision"))
+	assert.Equal(t, "superseded", superseded.Tag("deploy.status"))
+	assert.Equal(t, "3", deployed.Tag("deploy.revision"))
+	assert.Equal(t, "complete", deployed.Tag("deploy.status"))
+}
+
+func TestWatcher_Run_ScaledWorkload(t *testing.T) {
+	client, tracer, _, cancel := newTestWatcher(t)
+	defer cancel()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// scaling keeps the revision of the rolled out template
+	_, err := client.AppsV1().Deployments("shop").Update(deployment("1", 4, 2))
+	assert.NoError(t, err)
+	_, err = client.AppsV1().Deployments("shop").Update(deployment("1", 4, 4))
+	assert.NoError(t, err)
+
+	_, err = client.AppsV1().Deployments("shop").Update(deployment("2", 4, 1))
+	assert.NoError(t, err)
+	_, err = client.AppsV1().Deployments("shop").Update(deployment("2", 4, 4))
+	assert.NoError(t, err)
+
+	waitForSpans(t, tracer, 1)
+	time.Sleep(100 * time.Millisecond)
+	spans := tracer.FinishedSpans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "2", spans[0].Tag("deploy.revision"))
+}
@@ -0,0 +1,215 @@
+package linear
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const (
+	actionCreate string = "create"
+	actionUpdate string = "update"
+	actionRemove string = "remove"
+
+	issueType string = "Issue"
+)
+
+type State struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type Team struct {
+	ID   string `json:"id"`
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+type User struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Issue struct {
+	ID            string     `json:"id"`
+	Identifier    string     `json:"identifier"`
+	Number        int        `json:"number"`
+	Title         string     `json:"title"`
+	Priority      int        `json:"priority"`
+	PriorityLabel string     `json:"priorityLabel"`
+	ParentID      string     `json:"parentId"`
+	State         State      `json:"state"`
+	Team          Team       `json:"team"`
+	Assignee      *User      `json:"assignee"`
+	CreatedAt     *time.Time `json:"createdAt"`
+	UpdatedAt     *time.Time `json:"updatedAt"`
+	StartedAt     *time.Time `json:"startedAt"`
+	CompletedAt   *time.Time `json:"completedAt"`
+	CanceledAt    *time.Time `json:"canceledAt"`
+}
+
+// IssueEvent tracks an issue from when it moves to a state which starts it
+// until it moves to a state which ends it, or it's removed.
+type IssueEvent struct {
+	Action           string                 `json:"action"`
+	Type             string                 `json:"type"`
+	Data             Issue                  `json:"data"`
+	UpdatedFrom      map[string]interface{} `json:"updatedFrom"`
+	URL              string                 `json:"url"`
+	WebhookTimestamp int64                  `json:"webhookTimestamp"`
+
+	workflows types.Workflows
+}
+
+func issueSpanID(id string) string {
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		types.IssueEventType,
+		id,
+	}, "-")
+}
+
+// stateChanged is true when the issue was created or moved to a new state.
+func (ie IssueEvent) stateChanged() bool {
+	if ie.Action == actionCreate {
+		return true
+	}
+	_, ok := ie.UpdatedFrom["stateId"]
+	return ie.Action == actionUpdate && ok
+}
+
+// workflowState maps the state the issue moved to using the workflow
+// configured for the issue's team.
+func (ie IssueEvent) workflowState() (eventsources.SpanState, bool) {
+	if !ie.stateChanged() {
+		return eventsources.UnknownState, false
+	}
+	return ie.workflows.State(ie.Data.State.Name, ie.Data.State.Type, ie.Data.Team.Key)
+}
+
+func (ie IssueEvent) Timings() (eventsources.EventTimings, error) {
+	if s, ok := ie.workflowState(); (ok && s == eventsources.EndState) || ie.Action == actionRemove {
+		end := ie.Data.CompletedAt
+		if end == nil {
+			end = ie.Data.CanceledAt
+		}
+		if end == nil {
+			end = ie.Data.UpdatedAt
+		}
+		return eventsources.EventTimings{EndTime: end}, nil
+	}
+
+	start := ie.Data.StartedAt
+	if start == nil {
+		start = ie.Data.UpdatedAt
+	}
+	return eventsources.EventTimings{StartTime: start}, nil
+}
+
+func (ie IssueEvent) SpanID() (string, error) {
+	if ie.Data.ID == "" {
+		return "", fmt.Errorf("event does not contain an issue id")
+	}
+	return issueSpanID(ie.Data.ID), nil
+}
+
+func (ie IssueEvent) OperationName() string {
+	return types.IssueEventType
+}
+
+// References nests sub-issues beneath their parent issue.
+func (ie IssueEvent) References() ([]eventsources.Reference, error) {
+	if ie.Data.ParentID == "" {
+		return nil, nil
+	}
+	id := issueSpanID(ie.Data.ParentID)
+	return eventsources.ChildOf(&id), nil
+}
+
+func (ie IssueEvent) IsError() (bool, error) {
+	return false, nil
+}
+
+// State is driven by the state the issue moved to, issues are only ever
+// started once.  Issues which aren't being tracked yet are ignored until
+// they move to a state which starts them.
+func (ie IssueEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	if ie.Action == actionRemove {
+		if prev == nil {
+			return eventsources.UnknownState, nil
+		}
+		return eventsources.EndState, nil
+	}
+
+	state, ok := ie.workflowState()
+
+	log.WithFields(log.Fields{
+		"prev_state": prev,
+		"state.name": ie.Data.State.Name,
+		"state.type": ie.Data.State.Type,
+		"workflow":   state,
+		"mapped":     ok,
+	}).Debugf("linear.IssueEvent.State()")
+
+	if prev == nil && (!ok || state == eventsources.EndState) {
+		return eventsources.UnknownState, nil
+	}
+
+	if !ok {
+		return eventsources.IntermediaryState, nil
+	}
+
+	switch state {
+	case eventsources.StartState, eventsources.TransitionState:
+		if prev == nil {
+			return eventsources.StartState, nil
+		}
+		return eventsources.IntermediaryState, nil
+	case eventsources.EndState:
+		return eventsources.EndState, nil
+	}
+
+	return eventsources.IntermediaryState, nil
+}
+
+func (ie IssueEvent) Tags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+
+	tags["issue.id"] = ie.Data.ID
+	tags["issue.key"] = ie.Data.Identifier
+	tags["issue.number"] = ie.Data.Number
+	tags["issue.title"] = ie.Data.Title
+	tags["issue.url"] = ie.URL
+	tags["issue.priority.name"] = ie.Data.PriorityLabel
+	tags["issue.status.name"] = ie.Data.State.Name
+	tags["issue.status.type"] = ie.Data.State.Type
+
+	tags["team.id"] = ie.Data.Team.ID
+	tags["team.key"] = ie.Data.Team.Key
+	tags["team.name"] = ie.Data.Team.Name
+
+	if ie.Data.Assignee != nil {
+		tags["user.display_name"] = ie.Data.Assignee.Name
+	}
+	if ie.Data.ParentID != "" {
+		tags["issue.parent.id"] = ie.Data.ParentID
+	}
+
+	return tags, nil
+}
+
+// EndTags record the state the issue ended in, ie whether it was
+// completed or canceled.
+func (ie IssueEvent) EndTags() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"issue.status.name": ie.Data.State.Name,
+		"issue.status.type": ie.Data.State.Type,
+		"issue.removed":     ie.Action == actionRemove,
+	}, nil
+}
@@ -0,0 +1,113 @@
+// +build service
+
+package linear
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/tracers"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+var baseURL string
+var linearPath string
+
+var urlEnvVar = "TEST_EVENTS_URL"
+var linearPathEnvVar = "TEST_EVENTS_LINEAR_PATH"
+
+func init() {
+	ok := true
+	baseURL, ok = os.LookupEnv(urlEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", urlEnvVar))
+	}
+	linearPath, ok = os.LookupEnv(linearPathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", linearPathEnvVar))
+	}
+}
+
+func issueTags(overrides map[string]interface{}) map[string]interface{} {
+	tags := map[string]interface{}{
+		"error":               false,
+		"issue.id":            "b2f7c1a0-5d3e-4f6a-9b8c-7d6e5f4a3b2c",
+		"issue.key":           "ENG-42",
+		"issue.number":        float64(42),
+		"issue.priority.name": "High",
+		"issue.removed":       false,
+		"issue.status.name":   "Done",
+		"issue.status.type":   "completed",
+		"issue.title":         "Export lead time report as csv",
+		"issue.url":           "https://linear.app/acme/issue/ENG-42/export-lead-time-report-as-csv",
+		"service":             "linear",
+		"team.id":             "3c4d5e6f-0a1b-4c2d-8e3f-9a0b1c2d3e4f",
+		"team.key":            "ENG",
+		"team.name":           "Engineering",
+		"user.display_name":   "Mona Lisa",
+	}
+	for k, v := range overrides {
+		tags[k] = v
+	}
+	return tags
+}
+
+var eventTests = []struct {
+	Name          string
+	EventPaths    []string
+	ExpectedSpans int
+	ExpectedTags  map[string]interface{}
+}{
+	{
+		Name: "created_started_completed",
+		EventPaths: []string{
+			"fixtures/events/issue_created.json",
+			"fixtures/events/issue_started.json",
+			"fixtures/events/issue_in_review.json",
+			"fixtures/events/issue_retitled.json",
+			"fixtures/events/issue_completed.json",
+		},
+		ExpectedSpans: 1,
+		ExpectedTags:  issueTags(nil),
+	},
+	{
+		Name: "started_canceled",
+		EventPaths: []string{
+			"fixtures/events/issue_started.json",
+			"fixtures/events/issue_canceled.json",
+		},
+		ExpectedSpans: 1,
+		ExpectedTags: issueTags(map[string]interface{}{
+			"issue.status.name": "Canceled",
+			"issue.status.type": "canceled",
+		}),
+	},
+	{
+		Name: "completed_untracked",
+		EventPaths: []string{
+			"fixtures/events/issue_created.json",
+			"fixtures/events/issue_completed.json",
+		},
+		ExpectedSpans: 0,
+	},
+}
+
+func TestServiceEvent_Linear(t *testing.T) {
+	for _, tt := range eventTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			tracers.ResetTracer(t, baseURL)
+
+			for _, eventPath := range tt.EventPaths {
+				tracers.PostFixture(t, baseURL, linearPath, eventPath)
+			}
+
+			spans := tracers.FinishedSpans(t, baseURL)
+
+			assert.Equal(t, tt.ExpectedSpans, len(spans))
+			if len(spans) == 1 {
+				assert.Equal(t, "issue", spans[0].Span.OperationName)
+				assert.Equal(t, tt.ExpectedTags, spans[0].Tags)
+			}
+		})
+	}
+}
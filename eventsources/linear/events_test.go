@@ -0,0 +1,67 @@
+package linear
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIssueEvent_State(t *testing.T) {
+	started := eventsources.EventState(eventsources.StartState)
+
+	ws := DefaultWorkflows()
+	ws.Overrides = map[string]types.Workflow{
+		"ENG": {
+			Statuses: map[string]eventsources.SpanState{
+				"Ready to Deploy": eventsources.EndState,
+			},
+		},
+	}
+
+	stateChange := map[string]interface{}{"stateId": "s-previous"}
+
+	testCases := []struct {
+		name        string
+		action      string
+		team        string
+		state       State
+		updatedFrom map[string]interface{}
+		prev        *eventsources.EventState
+		expected    eventsources.SpanState
+	}{
+		{"created_unstarted", actionCreate, "ENG", State{Name: "Todo", Type: "unstarted"}, nil, nil, eventsources.UnknownState},
+		{"created_started", actionCreate, "ENG", State{Name: "In Progress", Type: "started"}, nil, nil, eventsources.StartState},
+		{"started", actionUpdate, "ENG", State{Name: "In Progress", Type: "started"}, stateChange, nil, eventsources.StartState},
+		{"review", actionUpdate, "ENG", State{Name: "In Review", Type: "started"}, stateChange, &started, eventsources.IntermediaryState},
+		{"team_state", actionUpdate, "ENG", State{Name: "Ready to Deploy", Type: "started"}, stateChange, &started, eventsources.EndState},
+		{"other_team_state", actionUpdate, "OPS", State{Name: "Ready to Deploy", Type: "started"}, stateChange, &started, eventsources.IntermediaryState},
+		{"completed", actionUpdate, "ENG", State{Name: "Done", Type: "completed"}, stateChange, &started, eventsources.EndState},
+		{"completed_untracked", actionUpdate, "ENG", State{Name: "Done", Type: "completed"}, stateChange, nil, eventsources.UnknownState},
+		{"retitled", actionUpdate, "ENG", State{Name: "Done", Type: "completed"}, map[string]interface{}{"title": "old"}, &started, eventsources.IntermediaryState},
+		{"removed", actionRemove, "ENG", State{Name: "In Progress", Type: "started"}, nil, &started, eventsources.EndState},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ie := IssueEvent{
+				Action:      tt.action,
+				Data:        Issue{ID: "1", State: tt.state, Team: Team{Key: tt.team}},
+				UpdatedFrom: tt.updatedFrom,
+				workflows:   ws,
+			}
+			s, err := ie.State(tt.prev)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, s)
+		})
+	}
+}
+
+func TestIssueEvent_ParentSpanID(t *testing.T) {
+	parent, err := eventsources.ParentSpanID(IssueEvent{Data: Issue{ID: "2", ParentID: "1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-linear-issue-1", *parent)
+
+	parent, err = eventsources.ParentSpanID(IssueEvent{Data: Issue{ID: "1"}})
+	assert.NoError(t, err)
+	assert.Nil(t, parent)
+}
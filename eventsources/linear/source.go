@@ -0,0 +1,121 @@
+package linear
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"github.com/opentracing/opentracing-go"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	sourceName string = "linear"
+
+	signatureHeader string = "Linear-Signature"
+
+	// maxTimestampSkew bounds how old a signed payload may be, so that it
+	// can't be replayed.
+	maxTimestampSkew = time.Minute
+)
+
+var signature = eventsources.HMACSignature{
+	Hash:   sha256.New,
+	Header: signatureHeader,
+}
+
+type Source struct {
+	tracer    opentracing.Tracer
+	secretKey []byte
+	workflows types.Workflows
+}
+
+func (s Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *Source) SecretKey() []byte {
+	return s.secretKey
+}
+
+// ValidatePayload checks the `Linear-Signature` header, the hex encoded
+// hmac-sha256 of the body signed with the webhook's secret, along with
+// the `webhookTimestamp` of the signed payload.
+func (s *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body")
+	}
+
+	if secretKey == nil {
+		return body, nil
+	}
+
+	if err := signature.Validate(r, body, secretKey); err != nil {
+		return nil, eventsources.InvalidSignatureError{Err: err}
+	}
+
+	var payload struct {
+		WebhookTimestamp int64 `json:"webhookTimestamp"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	sent := time.Unix(0, payload.WebhookTimestamp*int64(time.Millisecond))
+	if skew := time.Since(sent); skew > maxTimestampSkew || skew < -maxTimestampSkew {
+		return nil, eventsources.InvalidSignatureError{
+			Err: fmt.Errorf("webhook timestamp: %s is outside of %s", sent, maxTimestampSkew),
+		}
+	}
+
+	return body, nil
+}
+
+func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
+	ie := IssueEvent{
+		workflows: s.workflows,
+	}
+	if err := json.Unmarshal(payload, &ie); err != nil {
+		return nil, err
+	}
+
+	if ie.Type != issueType {
+		return nil, fmt.Errorf("type: %q, not supported", ie.Type)
+	}
+
+	return ie, nil
+}
+
+func NewSource(tracer opentracing.Tracer, secretKey []byte, workflows types.Workflows) (*Source, error) {
+	return &Source{
+		tracer:    tracer,
+		secretKey: secretKey,
+		workflows: workflows,
+	}, nil
+}
+
+func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
+	var secretKey []byte
+	if secret := c.String("linear-secret"); secret != "" {
+		secretKey = []byte(secret)
+	}
+
+	workflows := DefaultWorkflows()
+	if path := c.String("linear-workflows"); path != "" {
+		var err error
+		if workflows, err = types.LoadWorkflows(path, "teams", DefaultWorkflows()); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewSource(tracer, secretKey, workflows)
+}
@@ -0,0 +1,69 @@
+package linear
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSource_ValidatePayload(t *testing.T) {
+	secretKey := []byte("secret")
+
+	payload := func(sent time.Time) []byte {
+		return []byte(fmt.Sprintf(`{"type": "Issue", "webhookTimestamp": %d}`, sent.UnixNano()/int64(time.Millisecond)))
+	}
+	sign := func(body []byte) string {
+		mac := hmac.New(sha256.New, secretKey)
+		mac.Write(body)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	current := payload(time.Now())
+	stale := payload(time.Now().Add(-5 * time.Minute))
+
+	testCases := []struct {
+		name        string
+		body        []byte
+		signature   string
+		secretKey   []byte
+		expectedErr bool
+	}{
+		{"signed", current, sign(current), secretKey, false},
+		{"replayed", stale, sign(stale), secretKey, true},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/linear", bytes.NewReader(tt.body))
+			assert.NoError(t, err)
+			if tt.signature != "" {
+				req.Header.Set(signatureHeader, tt.signature)
+			}
+
+			s, err := NewSource(nil, tt.secretKey, DefaultWorkflows())
+			assert.NoError(t, err)
+
+			body, err := s.ValidatePayload(req, s.SecretKey())
+			if tt.expectedErr {
+				assert.IsType(t, eventsources.InvalidSignatureError{}, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.body, body)
+		})
+	}
+}
+
+func TestSource_Event_Unsupported(t *testing.T) {
+	s, err := NewSource(nil, nil, DefaultWorkflows())
+	assert.NoError(t, err)
+
+	_, err = s.Event(nil, []byte(`{"action": "create", "type": "Comment", "data": {"id": "1"}}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,22 @@
+package linear
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+)
+
+// DefaultWorkflows maps linear's state types (`triage`, `backlog`,
+// `unstarted`, `started`, `completed` or `canceled`), issues are worked on
+// from when they're started until they're completed or canceled.
+// Workflows files override them per team, keyed by the team key.
+func DefaultWorkflows() types.Workflows {
+	return types.Workflows{
+		Default: types.Workflow{
+			Categories: map[string]eventsources.SpanState{
+				"started":   eventsources.TransitionState,
+				"completed": eventsources.EndState,
+				"canceled":  eventsources.EndState,
+			},
+		},
+	}
+}
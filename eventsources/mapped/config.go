@@ -0,0 +1,346 @@
+package mapped
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"hash"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	authHMAC  string = "hmac"
+	authToken string = "token"
+
+	encodingHex    string = "hex"
+	encodingBase64 string = "base64"
+)
+
+var algorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// Auth is how requests to the source are verified, either:
+//   - hmac: the `header` holds the `prefix` followed by the hmac of the
+//     body using the `algorithm` (sha1, sha256 or sha512), `hex` or
+//     `base64` encoded
+//   - token: the `header` holds the `prefix` followed by the secret
+//
+// The secret is read from the environmental variable `secret_env`,
+// requests aren't verified when it isn't set.
+type Auth struct {
+	Type      string `json:"type"`
+	Header    string `json:"header"`
+	Prefix    string `json:"prefix"`
+	Algorithm string `json:"algorithm"`
+	Encoding  string `json:"encoding"`
+	SecretEnv string `json:"secret_env"`
+
+	hash func() hash.Hash
+}
+
+func (a *Auth) compile() error {
+	switch a.Type {
+	case authToken:
+		if a.Header == "" {
+			a.Header = "Authorization"
+		}
+	case authHMAC:
+		if a.Header == "" {
+			return fmt.Errorf("hmac auth is missing a header")
+		}
+		if a.Algorithm == "" {
+			a.Algorithm = "sha256"
+		}
+		var ok bool
+		if a.hash, ok = algorithms[a.Algorithm]; !ok {
+			return fmt.Errorf("hmac auth has unsupported algorithm: %q", a.Algorithm)
+		}
+		if a.Encoding == "" {
+			a.Encoding = encodingHex
+		}
+		if a.Encoding != encodingHex && a.Encoding != encodingBase64 {
+			return fmt.Errorf("hmac auth has unsupported encoding: %q", a.Encoding)
+		}
+	default:
+		return fmt.Errorf("unsupported auth type: %q", a.Type)
+	}
+	return nil
+}
+
+// matcher matches the value of an expression against a pattern.
+type matcher struct {
+	expr    *Expr
+	pattern *regexp.Regexp
+}
+
+func (m matcher) matches(doc document) bool {
+	v, _ := m.expr.Eval(doc)
+	return m.pattern.MatchString(v)
+}
+
+// compileMatchers compiles expressions to patterns, ordered by their
+// expression so they're tried in the same order each time.
+func compileMatchers(ms map[string]string) ([]matcher, error) {
+	exprs := make([]string, 0, len(ms))
+	for expr := range ms {
+		exprs = append(exprs, expr)
+	}
+	sort.Strings(exprs)
+
+	matchers := make([]matcher, 0, len(ms))
+	for _, expr := range exprs {
+		e, err := CompileExpr(expr)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher{
+			expr:    e,
+			pattern: compilePattern(ms[expr]),
+		})
+	}
+	return matchers, nil
+}
+
+// compilePattern compiles a pattern where `*` matches any characters and
+// `|` separates alternatives.
+func compilePattern(p string) *regexp.Regexp {
+	alternatives := strings.Split(p, "|")
+	for i, alt := range alternatives {
+		parts := strings.Split(alt, "*")
+		for j, part := range parts {
+			parts[j] = regexp.QuoteMeta(part)
+		}
+		alternatives[i] = strings.Join(parts, ".*")
+	}
+	return regexp.MustCompile("^(?:" + strings.Join(alternatives, "|") + ")$")
+}
+
+// Rule maps the requests it matches onto a span.  Requests are matched
+// when the values of every expression in `match` match their pattern,
+// where `*` matches any characters and `|` separates alternatives.
+//
+// The remaining fields are expressions (see Expr) evaluated against the
+// request:
+//   - operation: the operation of the span, ie `build`
+//   - state: `start`, `end`, `transition` or `intermediary`, or a value
+//     which is mapped onto one of them by the patterns of `states`
+//   - id: identifies the span, requests sharing it start and end the
+//     same span
+//   - parent: the span id of the span's parent
+//   - error: patterns of expression values, the span is an error when
+//     any of them match
+//   - start_time and end_time: RFC3339 timestamps or unix times in
+//     seconds or milliseconds
+//   - revision: the commit the span was produced for
+//   - service: the deployed service the span concerns
+//   - tags: tag names to the expressions they're set to
+//
+// A `start` of a span which was already started leaves it untouched, an
+// `end` without a preceding `start` records the span from its start_time.
+type Rule struct {
+	Match     map[string]string                 `json:"match"`
+	Operation string                            `json:"operation"`
+	State     string                            `json:"state"`
+	States    map[string]eventsources.SpanState `json:"states"`
+	ID        string                            `json:"id"`
+	Parent    string                            `json:"parent"`
+	Error     map[string]string                 `json:"error"`
+	StartTime string                            `json:"start_time"`
+	EndTime   string                            `json:"end_time"`
+	Revision  string                            `json:"revision"`
+	Service   string                            `json:"service"`
+	Tags      map[string]string                 `json:"tags"`
+
+	matchers  []matcher
+	errors    []matcher
+	states    []stateMatcher
+	operation *Expr
+	state     *Expr
+	id        *Expr
+	parent    *Expr
+	startTime *Expr
+	endTime   *Expr
+	revision  *Expr
+	service   *Expr
+	tags      map[string]*Expr
+}
+
+type stateMatcher struct {
+	pattern *regexp.Regexp
+	state   eventsources.SpanState
+}
+
+func isSpanState(s eventsources.SpanState) bool {
+	switch s {
+	case eventsources.StartState, eventsources.EndState,
+		eventsources.TransitionState, eventsources.IntermediaryState:
+		return true
+	}
+	return false
+}
+
+func (r *Rule) compile() error {
+	if r.Operation == "" || r.State == "" || r.ID == "" {
+		return fmt.Errorf("rule is missing an operation, state or id")
+	}
+
+	var err error
+	if r.matchers, err = compileMatchers(r.Match); err != nil {
+		return err
+	}
+	if r.errors, err = compileMatchers(r.Error); err != nil {
+		return err
+	}
+
+	patterns := make([]string, 0, len(r.States))
+	for p, s := range r.States {
+		if !isSpanState(s) {
+			return fmt.Errorf("rule has unsupported state: %q", s)
+		}
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	for _, p := range patterns {
+		r.states = append(r.states, stateMatcher{
+			pattern: compilePattern(p),
+			state:   r.States[p],
+		})
+	}
+
+	exprs := []struct {
+		s    string
+		expr **Expr
+	}{
+		{r.Operation, &r.operation},
+		{r.State, &r.state},
+		{r.ID, &r.id},
+		{r.Parent, &r.parent},
+		{r.StartTime, &r.startTime},
+		{r.EndTime, &r.endTime},
+		{r.Revision, &r.revision},
+		{r.Service, &r.service},
+	}
+	for _, e := range exprs {
+		if *e.expr, err = CompileExpr(e.s); err != nil {
+			return err
+		}
+	}
+
+	r.tags = make(map[string]*Expr)
+	for name, s := range r.Tags {
+		if r.tags[name], err = CompileExpr(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Rule) matches(doc document) bool {
+	for _, m := range r.matchers {
+		if !m.matches(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Rule) isError(doc document) bool {
+	for _, m := range r.errors {
+		if v, ok := m.expr.Eval(doc); ok && m.pattern.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// spanState is the state of the request, unknown when the value of the
+// state expression isn't mapped to one.
+func (r *Rule) spanState(doc document) eventsources.SpanState {
+	v, _ := r.state.Eval(doc)
+	if len(r.states) == 0 {
+		if s := eventsources.SpanState(v); isSpanState(s) {
+			return s
+		}
+		return eventsources.UnknownState
+	}
+	for _, sm := range r.states {
+		if sm.pattern.MatchString(v) {
+			return sm.state
+		}
+	}
+	return eventsources.UnknownState
+}
+
+// Config declares a source, the url path it's served at and the rules
+// its requests are mapped by.  Rules are tried in order, a request is
+// mapped by the first rule it matches and ignored when it doesn't match
+// any.
+type Config struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Auth  *Auth  `json:"auth"`
+	Rules []Rule `json:"rules"`
+}
+
+// Compile validates the config and compiles its expressions, it must be
+// called before the config is used.
+func (c *Config) Compile() error {
+	if c.Name == "" || !strings.HasPrefix(c.Path, "/") {
+		return fmt.Errorf("source: %q requires a name and a path", c.Name)
+	}
+
+	if c.Auth != nil {
+		if err := c.Auth.compile(); err != nil {
+			return fmt.Errorf("source: %q %s", c.Name, err)
+		}
+	}
+
+	for i := range c.Rules {
+		if err := c.Rules[i].compile(); err != nil {
+			return fmt.Errorf("source: %q %s", c.Name, err)
+		}
+	}
+	return nil
+}
+
+// SecretKey is the secret of the source's auth, nil when requests aren't
+// verified.
+func (c Config) SecretKey() []byte {
+	if c.Auth == nil || c.Auth.SecretEnv == "" {
+		return nil
+	}
+	if secret := os.Getenv(c.Auth.SecretEnv); secret != "" {
+		return []byte(secret)
+	}
+	return nil
+}
+
+// LoadConfigs reads sources from a json file holding an array of them.
+func LoadConfigs(path string) ([]Config, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cs []Config
+	if err := json.Unmarshal(bs, &cs); err != nil {
+		return nil, err
+	}
+
+	for i := range cs {
+		if err := cs[i].Compile(); err != nil {
+			return nil, err
+		}
+	}
+	return cs, nil
+}
@@ -0,0 +1,186 @@
+package mapped
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const unmatchedOperationName string = "unmatched"
+
+// Event is a request mapped by the first rule it matched, requests
+// without a rule are ignored.
+type Event struct {
+	source string
+	doc    document
+	rule   *Rule
+}
+
+func (e Event) eval(expr *Expr) string {
+	v, _ := expr.Eval(e.doc)
+	return v
+}
+
+func (e Event) OperationName() string {
+	if e.rule == nil {
+		return unmatchedOperationName
+	}
+	return e.eval(e.rule.operation)
+}
+
+func (e Event) SpanID() (string, error) {
+	if e.rule == nil {
+		return strings.Join([]string{
+			eventsources.TracePrefix,
+			e.source,
+			unmatchedOperationName,
+		}, "-"), nil
+	}
+
+	id, ok := e.rule.id.Eval(e.doc)
+	if !ok || id == "" {
+		return "", fmt.Errorf("request does not contain a span id")
+	}
+
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		e.source,
+		e.OperationName(),
+		id,
+	}, "-"), nil
+}
+
+func (e Event) References() ([]eventsources.Reference, error) {
+	if e.rule == nil {
+		return nil, nil
+	}
+	if parent := e.eval(e.rule.parent); parent != "" {
+		return eventsources.ChildOf(&parent), nil
+	}
+	return nil, nil
+}
+
+// Revision is the commit the request was sent for, when its rule
+// references one.
+func (e Event) Revision() string {
+	if e.rule == nil {
+		return ""
+	}
+	return e.eval(e.rule.revision)
+}
+
+// ServiceName is the deployed service the request concerns, when its
+// rule references one.
+func (e Event) ServiceName() string {
+	if e.rule == nil {
+		return ""
+	}
+	return e.eval(e.rule.service)
+}
+
+// Incident is a request mapped to an incident or defect, it follows from
+// the most recent deploy of the service it concerns to the environment
+// named by its `incident.environment` or `defect.environment` tag.
+type Incident struct {
+	Event
+}
+
+func (i Incident) Environment() string {
+	return eventsources.TaggedEnvironment(i.ruleTags())
+}
+
+// incidentOf marks the incidents and defects, other spans concerning a
+// service don't follow from its deploys.
+func incidentOf(e Event) eventsources.Event {
+	switch e.OperationName() {
+	case types.IncidentEventType, types.DefectEventType:
+		return Incident{e}
+	}
+	return e
+}
+
+func (e Event) IsError() (bool, error) {
+	if e.rule == nil {
+		return false, nil
+	}
+	return e.rule.isError(e.doc), nil
+}
+
+func (e Event) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	if e.rule == nil {
+		return eventsources.UnknownState, nil
+	}
+	state := e.rule.spanState(e.doc)
+	switch {
+	case state == eventsources.StartState && prev != nil:
+		return eventsources.IntermediaryState, nil
+	case state == eventsources.EndState && prev == nil:
+		return eventsources.CompleteState, nil
+	}
+	return state, nil
+}
+
+// parseTime parses RFC3339 timestamps and unix times, which are in
+// milliseconds when they're too large to be in seconds.
+func parseTime(v string) (*time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return &t, nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse time: %q", v)
+	}
+	if f > 1e11 {
+		f = f / 1e3
+	}
+	t := time.Unix(0, int64(f*1e9)).UTC()
+	return &t, nil
+}
+
+func (e Event) Timings() (eventsources.EventTimings, error) {
+	var timings eventsources.EventTimings
+	if e.rule == nil {
+		return timings, nil
+	}
+
+	var err error
+	if v, ok := e.rule.startTime.Eval(e.doc); ok {
+		if timings.StartTime, err = parseTime(v); err != nil {
+			return timings, err
+		}
+	}
+	if v, ok := e.rule.endTime.Eval(e.doc); ok {
+		if timings.EndTime, err = parseTime(v); err != nil {
+			return timings, err
+		}
+	}
+	return timings, nil
+}
+
+func (e Event) ruleTags() map[string]interface{} {
+	tags := make(map[string]interface{})
+	if e.rule == nil {
+		return tags
+	}
+	for name, expr := range e.rule.tags {
+		if v, ok := expr.Eval(e.doc); ok {
+			tags[name] = v
+		}
+	}
+	return tags
+}
+
+func (e Event) Tags() (map[string]interface{}, error) {
+	tags := e.ruleTags()
+	tags["service"] = e.source
+	return tags, nil
+}
+
+// EndTags are the rule's tags as of the request ending the span.
+func (e Event) EndTags() (map[string]interface{}, error) {
+	return e.ruleTags(), nil
+}
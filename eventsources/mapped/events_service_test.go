@@ -0,0 +1,98 @@
+// +build service
+
+package mapped
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/tracers"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+var baseURL string
+var mappedPath string
+
+var urlEnvVar = "TEST_EVENTS_URL"
+var mappedPathEnvVar = "TEST_EVENTS_MAPPED_PATH"
+
+func init() {
+	ok := true
+	baseURL, ok = os.LookupEnv(urlEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", urlEnvVar))
+	}
+	mappedPath, ok = os.LookupEnv(mappedPathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", mappedPathEnvVar))
+	}
+}
+
+var buildTags = map[string]interface{}{
+	"build.number":   "20210903.1",
+	"build.pipeline": "valuestream",
+	"build.url":      "https://dev.azure.com/acme/valuestream/_build/results?buildId=42",
+	"service":        "azurepipelines",
+}
+
+var eventTests = []struct {
+	Name           string
+	EventPaths     []string
+	ExpectedError  bool
+	ExpectedResult string
+}{
+	{
+		Name: "in_progress_completed",
+		EventPaths: []string{
+			"fixtures/events/in_progress.json",
+			"fixtures/events/stage_changed.json",
+			"fixtures/events/completed.json",
+		},
+		ExpectedResult: "succeeded",
+	},
+	{
+		Name: "in_progress_failed",
+		EventPaths: []string{
+			"fixtures/events/in_progress.json",
+			"fixtures/events/failed.json",
+		},
+		ExpectedError:  true,
+		ExpectedResult: "failed",
+	},
+	{
+		Name: "completed_only",
+		EventPaths: []string{
+			"fixtures/events/completed.json",
+		},
+		ExpectedResult: "succeeded",
+	},
+}
+
+func TestServiceEvent_Mapped(t *testing.T) {
+	for _, tt := range eventTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			tracers.ResetTracer(t, baseURL)
+
+			for _, eventPath := range tt.EventPaths {
+				tracers.PostFixture(t, baseURL, mappedPath, eventPath)
+			}
+
+			spans := tracers.FinishedSpans(t, baseURL)
+			assert.Equal(t, 1, len(spans))
+			if len(spans) != 1 {
+				t.FailNow()
+			}
+
+			expected := make(map[string]interface{})
+			for k, v := range buildTags {
+				expected[k] = v
+			}
+			expected["error"] = tt.ExpectedError
+			expected["build.result"] = tt.ExpectedResult
+
+			build := spans[0]
+			assert.Equal(t, "build", build.Span.OperationName)
+			assert.Equal(t, expected, build.Tags)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package mapped
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources/jsonpath"
+	"net/http"
+	"strings"
+)
+
+const (
+	bodyRoot    string = jsonpath.Root
+	headersRoot string = "$headers."
+)
+
+// document is what expressions are evaluated against, the request's json
+// body and its headers.
+type document struct {
+	body    interface{}
+	headers http.Header
+}
+
+// jsonPath selects a single value of the body, ie `$.build.status`, or
+// `$headers.<Name>` selects a request header.
+type jsonPath struct {
+	header string
+	path   *jsonpath.Path
+}
+
+func compilePath(p string) (*jsonPath, error) {
+	if strings.HasPrefix(p, headersRoot) {
+		header := strings.TrimPrefix(p, headersRoot)
+		if header == "" {
+			return nil, fmt.Errorf("path: %q is missing a header name", p)
+		}
+		return &jsonPath{header: header}, nil
+	}
+
+	path, err := jsonpath.Compile(p)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonPath{path: path}, nil
+}
+
+func (jp *jsonPath) eval(doc document) (string, bool) {
+	if jp.header != "" {
+		v := doc.headers.Get(jp.header)
+		return v, v != ""
+	}
+	return jp.path.Eval(doc.body)
+}
+
+// exprPart is either literal text or a path.
+type exprPart struct {
+	literal string
+	path    *jsonPath
+}
+
+// Expr is a JSONPath, ie `$.build.status`, a template of JSONPaths
+// within literal text, ie `{{ $.repo.name }}-{{ $.build.number }}`, or
+// literal text, ie `build`.
+type Expr struct {
+	parts []exprPart
+}
+
+// CompileExpr compiles an expression, an empty expression evaluates to
+// nothing.
+func CompileExpr(s string) (*Expr, error) {
+	e := &Expr{}
+	if s == "" {
+		return e, nil
+	}
+
+	if !strings.Contains(s, "{{") {
+		if strings.HasPrefix(s, bodyRoot) {
+			p, err := compilePath(s)
+			if err != nil {
+				return nil, err
+			}
+			e.parts = append(e.parts, exprPart{path: p})
+			return e, nil
+		}
+		e.parts = append(e.parts, exprPart{literal: s})
+		return e, nil
+	}
+
+	rest := s
+	for rest != "" {
+		start := strings.Index(rest, "{{")
+		if start == -1 {
+			e.parts = append(e.parts, exprPart{literal: rest})
+			break
+		}
+		end := strings.Index(rest[start:], "}}")
+		if end == -1 {
+			return nil, fmt.Errorf("template: %q contains an unterminated {{", s)
+		}
+		if start > 0 {
+			e.parts = append(e.parts, exprPart{literal: rest[:start]})
+		}
+		p, err := compilePath(strings.TrimSpace(rest[start+2 : start+end]))
+		if err != nil {
+			return nil, err
+		}
+		e.parts = append(e.parts, exprPart{path: p})
+		rest = rest[start+end+2:]
+	}
+	return e, nil
+}
+
+// Eval is false when the expression is empty or any of its paths don't
+// select a value.
+func (e *Expr) Eval(doc document) (string, bool) {
+	if e == nil || len(e.parts) == 0 {
+		return "", false
+	}
+
+	var b strings.Builder
+	for _, p := range e.parts {
+		if p.path == nil {
+			b.WriteString(p.literal)
+			continue
+		}
+		v, ok := p.path.eval(doc)
+		if !ok {
+			return "", false
+		}
+		b.WriteString(v)
+	}
+	return b.String(), true
+}
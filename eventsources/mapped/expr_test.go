@@ -0,0 +1,69 @@
+package mapped
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestExpr_Eval(t *testing.T) {
+	doc := document{
+		body: map[string]interface{}{
+			"build": map[string]interface{}{
+				"number": float64(42),
+				"failed": false,
+				"steps": []interface{}{
+					map[string]interface{}{"name": "test"},
+				},
+				"labels": map[string]interface{}{"app.kubernetes.io/name": "checkout"},
+			},
+			"repo": "valuestream",
+		},
+		headers: http.Header{"X-Event-Key": []string{"build:finished"}},
+	}
+
+	testCases := []struct {
+		expr     string
+		expected string
+		ok       bool
+	}{
+		{"", "", false},
+		{"build", "build", true},
+		{"$.repo", "valuestream", true},
+		{"$.build.number", "42", true},
+		{"$.build.failed", "false", true},
+		{"$.build.steps[0].name", "test", true},
+		{"$['build']['steps'][0]['name']", "test", true},
+		{"$.build.labels['app.kubernetes.io/name']", "checkout", true},
+		{"$.build.steps[1].name", "", false},
+		{"$.build.missing", "", false},
+		{"$.build.steps[0]", `{"name":"test"}`, true},
+		{"$headers.X-Event-Key", "build:finished", true},
+		{"{{ $.repo }}-{{$.build.number}}", "valuestream-42", true},
+		{"build-{{ $.repo }}", "build-valuestream", true},
+		{"{{ $.repo }}-{{ $.build.missing }}", "", false},
+	}
+	for _, tt := range testCases {
+		e, err := CompileExpr(tt.expr)
+		assert.NoError(t, err, tt.expr)
+
+		v, ok := e.Eval(doc)
+		assert.Equal(t, tt.ok, ok, tt.expr)
+		assert.Equal(t, tt.expected, v, tt.expr)
+	}
+}
+
+func TestCompileExpr_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"$..build",
+		"$.build[",
+		"$.build[*]",
+		"$build",
+		"$headers.",
+		"{{ $.build",
+		"{{ build }}",
+	} {
+		_, err := CompileExpr(expr)
+		assert.Error(t, err, expr)
+	}
+}
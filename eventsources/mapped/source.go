@@ -0,0 +1,104 @@
+package mapped
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/opentracing/opentracing-go"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"net/http"
+)
+
+// Source is a source declared by its Config rather than written for a
+// particular tool.
+type Source struct {
+	tracer    opentracing.Tracer
+	secretKey []byte
+	config    Config
+}
+
+func (s Source) Name() string {
+	return s.config.Name
+}
+
+func (s *Source) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *Source) SecretKey() []byte {
+	return s.secretKey
+}
+
+// ValidatePayload verifies the request by the configured Auth.
+func (s *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body")
+	}
+
+	auth := s.config.Auth
+	if secretKey == nil || auth == nil {
+		return body, nil
+	}
+
+	if auth.Type == authToken {
+		token := eventsources.SecretToken{
+			Header: auth.Header,
+			Prefix: auth.Prefix,
+		}
+		if err := token.Validate(r, secretKey); err != nil {
+			return nil, eventsources.InvalidSignatureError{Err: err}
+		}
+		return body, nil
+	}
+
+	signature := eventsources.HMACSignature{
+		Hash:   auth.hash,
+		Header: auth.Header,
+		Prefix: auth.Prefix,
+		Base64: auth.Encoding == encodingBase64,
+	}
+	if err := signature.Validate(r, body, secretKey); err != nil {
+		return nil, eventsources.InvalidSignatureError{Err: err}
+	}
+
+	return body, nil
+}
+
+// Event maps the request by the first of the source's rules it matches.
+func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
+	doc := document{headers: r.Header}
+	if err := json.Unmarshal(payload, &doc.body); err != nil {
+		return nil, err
+	}
+
+	e := Event{
+		source: s.config.Name,
+		doc:    doc,
+	}
+	for i := range s.config.Rules {
+		if s.config.Rules[i].matches(doc) {
+			e.rule = &s.config.Rules[i]
+			break
+		}
+	}
+	return incidentOf(e), nil
+}
+
+// NewSource creates the source declared by a compiled config.
+func NewSource(tracer opentracing.Tracer, config Config) (*Source, error) {
+	return &Source{
+		tracer:    tracer,
+		secretKey: config.SecretKey(),
+		config:    config,
+	}, nil
+}
+
+// NewFromConfig builds the source from the cli, each declared source is
+// built by its own builder.
+func NewFromConfig(config Config) func(*cli.Context, opentracing.Tracer) (eventsources.EventSource, error) {
+	return func(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
+		return NewSource(tracer, config)
+	}
+}
@@ -0,0 +1,189 @@
+package mapped
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestSource_ValidatePayload(t *testing.T) {
+	secretKey := []byte("secret")
+	body := []byte(`{"build": {"status": "started"}}`)
+
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write(body)
+	sha256Hex := hex.EncodeToString(mac.Sum(nil))
+
+	mac = hmac.New(sha1.New, secretKey)
+	mac.Write(body)
+	sha1Base64 := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	hexAuth := &Auth{Type: authHMAC, Header: "X-Signature", Prefix: "sha256="}
+	base64Auth := &Auth{Type: authHMAC, Header: "X-Signature", Algorithm: "sha1", Encoding: encodingBase64}
+	tokenAuth := &Auth{Type: authToken, Prefix: "Bearer "}
+
+	testCases := []struct {
+		name        string
+		auth        *Auth
+		header      string
+		value       string
+		secretKey   []byte
+		expectedErr bool
+	}{
+		{"no_auth", nil, "", "", secretKey, false},
+		{"hmac_hex", hexAuth, "X-Signature", "sha256=" + sha256Hex, secretKey, false},
+		{"hmac_base64", base64Auth, "X-Signature", sha1Base64, secretKey, false},
+		{"token", tokenAuth, "Authorization", "Bearer secret", secretKey, false},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{Name: "ci", Path: "/ci", Auth: tt.auth}
+			assert.NoError(t, config.Compile())
+
+			req, err := http.NewRequest("POST", "/ci", bytes.NewReader(body))
+			assert.NoError(t, err)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+
+			s, err := NewSource(nil, config)
+			assert.NoError(t, err)
+
+			payload, err := s.ValidatePayload(req, tt.secretKey)
+			if tt.expectedErr {
+				assert.IsType(t, eventsources.InvalidSignatureError{}, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, body, payload)
+		})
+	}
+}
+
+func TestLoadConfigs_SecretKey(t *testing.T) {
+	os.Setenv("VS_AZURE_PIPELINES_TOKEN", "secret")
+	defer os.Unsetenv("VS_AZURE_PIPELINES_TOKEN")
+
+	configs, err := LoadConfigs("fixtures/sources.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(configs))
+	assert.Equal(t, []byte("secret"), configs[0].SecretKey())
+}
+
+func TestConfig_Compile_Invalid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config Config
+	}{
+		{"missing_path", Config{Name: "ci"}},
+		{"unsupported_auth", Config{Name: "ci", Path: "/ci", Auth: &Auth{Type: "basic"}}},
+		{"unsupported_algorithm", Config{Name: "ci", Path: "/ci", Auth: &Auth{
+			Type: authHMAC, Header: "X-Signature", Algorithm: "md5",
+		}}},
+		{"missing_id", Config{Name: "ci", Path: "/ci", Rules: []Rule{
+			{Operation: "build", State: "start"},
+		}}},
+		{"unsupported_state", Config{Name: "ci", Path: "/ci", Rules: []Rule{
+			{Operation: "build", State: "$.status", ID: "$.id", States: map[string]eventsources.SpanState{
+				"running": "started",
+			}},
+		}}},
+		{"invalid_expr", Config{Name: "ci", Path: "/ci", Rules: []Rule{
+			{Operation: "build", State: "start", ID: "{{ $.id"},
+		}}},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.config.Compile())
+		})
+	}
+}
+
+func fixtureEvent(t *testing.T, s *Source, path string) eventsources.Event {
+	te, err := eventsources.NewTestEventFromFixturePath(path)
+	assert.NoError(t, err)
+
+	payload, err := json.Marshal(te.Payload)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("POST", s.config.Path, bytes.NewReader(payload))
+	assert.NoError(t, err)
+	for k, v := range te.Headers {
+		req.Header.Set(k, v)
+	}
+
+	e, err := s.Event(req, payload)
+	assert.NoError(t, err)
+	return e
+}
+
+func TestSource_Event_Fixtures(t *testing.T) {
+	configs, err := LoadConfigs("fixtures/sources.json")
+	assert.NoError(t, err)
+
+	s, err := NewSource(nil, configs[0])
+	assert.NoError(t, err)
+
+	started := eventsources.EventState(eventsources.StartState)
+
+	testCases := []struct {
+		fixture       string
+		prev          *eventsources.EventState
+		expectedState eventsources.SpanState
+		expectedError bool
+	}{
+		{"fixtures/events/in_progress.json", nil, eventsources.StartState, false},
+		{"fixtures/events/in_progress.json", &started, eventsources.IntermediaryState, false},
+		{"fixtures/events/completed.json", &started, eventsources.EndState, false},
+		{"fixtures/events/completed.json", nil, eventsources.CompleteState, false},
+		{"fixtures/events/failed.json", &started, eventsources.EndState, true},
+		{"fixtures/events/stage_changed.json", &started, eventsources.UnknownState, false},
+	}
+	for _, tt := range testCases {
+		e := fixtureEvent(t, s, tt.fixture)
+
+		state, err := e.State(tt.prev)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expectedState, state, tt.fixture)
+
+		isError, err := e.IsError()
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expectedError, isError, tt.fixture)
+	}
+
+	e := fixtureEvent(t, s, "fixtures/events/completed.json")
+
+	spanID, err := e.SpanID()
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-azurepipelines-build-valuestream-42", spanID)
+	assert.Equal(t, "5d1c9a3e2b7f4a6c8e0d1f2a3b4c5d6e7f8a9b0c", e.(Event).Revision())
+
+	timings, err := e.Timings()
+	assert.NoError(t, err)
+	assert.Equal(t, "5m0s", timings.EndTime.Sub(*timings.StartTime).String())
+}
+
+func TestParseTime(t *testing.T) {
+	for _, v := range []string{
+		"2021-09-03T12:00:00Z",
+		"2021-09-03T14:00:00+02:00",
+		"1630670400",
+		"1630670400000",
+	} {
+		ts, err := parseTime(v)
+		assert.NoError(t, err, v)
+		assert.Equal(t, int64(1630670400), ts.Unix(), v)
+	}
+
+	_, err := parseTime("yesterday")
+	assert.Error(t, err)
+}
@@ -0,0 +1,260 @@
+package opsgenie
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"strings"
+	"time"
+)
+
+const (
+	actionCreate      string = "Create"
+	actionAcknowledge string = "Acknowledge"
+	actionClose       string = "Close"
+
+	// serviceTagPrefix marks the alert tag naming the service an alert
+	// was raised against, ie `service:checkout`
+	serviceTagPrefix string = "service:"
+	// environmentTagPrefix marks the alert tag naming the environment the
+	// alert was raised in, ie `environment:staging`
+	environmentTagPrefix string = "environment:"
+)
+
+type Alert struct {
+	AlertID  string   `json:"alertId"`
+	TinyID   string   `json:"tinyId"`
+	Alias    string   `json:"alias"`
+	Message  string   `json:"message"`
+	Entity   string   `json:"entity"`
+	Priority string   `json:"priority"`
+	Source   string   `json:"source"`
+	Team     string   `json:"team"`
+	Username string   `json:"username"`
+	Tags     []string `json:"tags"`
+	// CreatedAt is in milliseconds since the epoch
+	CreatedAt int64 `json:"createdAt"`
+	// UpdatedAt is in nanoseconds since the epoch
+	UpdatedAt int64 `json:"updatedAt"`
+}
+
+func (a Alert) createdAt() *time.Time {
+	if a.CreatedAt == 0 {
+		return nil
+	}
+	t := time.Unix(0, a.CreatedAt*int64(time.Millisecond)).UTC()
+	return &t
+}
+
+func (a Alert) updatedAt() *time.Time {
+	if a.UpdatedAt == 0 {
+		return nil
+	}
+	t := time.Unix(0, a.UpdatedAt).UTC()
+	return &t
+}
+
+func spanID(eventType string, alertID string) string {
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		eventType,
+		alertID,
+	}, "-")
+}
+
+func timings(start, end *time.Time) eventsources.EventTimings {
+	t := eventsources.EventTimings{
+		StartTime: start,
+		EndTime:   end,
+	}
+	if start != nil && end != nil {
+		d := end.Sub(*start)
+		t.Duration = &d
+	}
+	return t
+}
+
+// AlertEvent spans an alert from when it was created until it was
+// closed, the incident's time to restore.
+type AlertEvent struct {
+	Action          string `json:"action"`
+	Alert           Alert  `json:"alert"`
+	IntegrationName string `json:"integrationName"`
+
+	// acknowledgedAt is when the alert was acknowledged, if the source
+	// saw it happen.
+	acknowledgedAt *time.Time
+}
+
+// ServiceName is the service named by the alert's `service:<name>` tag,
+// falling back to the alert's entity.
+func (ae AlertEvent) ServiceName() string {
+	if service := ae.tag(serviceTagPrefix); service != "" {
+		return service
+	}
+	return ae.Alert.Entity
+}
+
+// Environment is the environment named by the alert's
+// `environment:<name>` tag, if any.
+func (ae AlertEvent) Environment() string {
+	return ae.tag(environmentTagPrefix)
+}
+
+// tag is the value of the alert's first tag with the prefix.
+func (ae AlertEvent) tag(prefix string) string {
+	for _, tag := range ae.Alert.Tags {
+		if strings.HasPrefix(tag, prefix) {
+			return strings.TrimPrefix(tag, prefix)
+		}
+	}
+	return ""
+}
+
+func (ae AlertEvent) OperationName() string {
+	return types.IncidentEventType
+}
+
+func (ae AlertEvent) SpanID() (string, error) {
+	if ae.Alert.AlertID == "" {
+		return "", fmt.Errorf("event does not contain an alert id")
+	}
+	return spanID(types.IncidentEventType, ae.Alert.AlertID), nil
+}
+
+// References are left to the webhook, which links incidents to the
+// most recent deploy of their service.
+func (ae AlertEvent) References() ([]eventsources.Reference, error) {
+	return nil, nil
+}
+
+func (ae AlertEvent) IsError() (bool, error) {
+	return false, nil
+}
+
+func (ae AlertEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	switch ae.Action {
+	case actionCreate, actionAcknowledge:
+		if prev == nil {
+			return eventsources.StartState, nil
+		}
+		return eventsources.IntermediaryState, nil
+	case actionClose:
+		if prev == nil {
+			return eventsources.CompleteState, nil
+		}
+		return eventsources.EndState, nil
+	}
+	return eventsources.UnknownState, nil
+}
+
+func (ae AlertEvent) Timings() (eventsources.EventTimings, error) {
+	var end *time.Time
+	if ae.Action == actionClose {
+		end = ae.Alert.updatedAt()
+	}
+	return timings(ae.Alert.createdAt(), end), nil
+}
+
+func (ae AlertEvent) Tags() (map[string]interface{}, error) {
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+	tags["incident.id"] = ae.Alert.AlertID
+	tags["incident.number"] = ae.Alert.TinyID
+	tags["incident.title"] = ae.Alert.Message
+	tags["incident.priority"] = ae.Alert.Priority
+	tags["incident.service"] = ae.ServiceName()
+
+	if ae.Alert.Team != "" {
+		tags["incident.team"] = ae.Alert.Team
+	}
+	if ae.IntegrationName != "" {
+		tags["incident.integration"] = ae.IntegrationName
+	}
+	return tags, nil
+}
+
+func (ae AlertEvent) EndTags() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"incident.status":      "closed",
+		"incident.priority":    ae.Alert.Priority,
+		"incident.resolved_by": ae.Alert.Username,
+	}, nil
+}
+
+// Children are the time it took to acknowledge the alert, and the time
+// it took to close it once acknowledged.
+func (ae AlertEvent) Children() ([]eventsources.Event, error) {
+	switch ae.Action {
+	case actionAcknowledge:
+		return []eventsources.Event{
+			ResponseEvent{
+				alert:         ae,
+				operationName: types.IncidentAckEventType,
+				start:         ae.Alert.createdAt(),
+			},
+		}, nil
+	case actionClose:
+		start := ae.acknowledgedAt
+		if start == nil {
+			start = ae.Alert.createdAt()
+		}
+		return []eventsources.Event{
+			ResponseEvent{
+				alert:         ae,
+				operationName: types.IncidentResolveEventType,
+				start:         start,
+			},
+		}, nil
+	}
+	return nil, nil
+}
+
+// ResponseEvent is a step of the response to an alert, it's complete
+// by the time opsgenie reports it.
+type ResponseEvent struct {
+	alert         AlertEvent
+	operationName string
+	start         *time.Time
+}
+
+func (re ResponseEvent) OperationName() string {
+	return re.operationName
+}
+
+func (re ResponseEvent) SpanID() (string, error) {
+	if re.alert.Alert.AlertID == "" {
+		return "", fmt.Errorf("event does not contain an alert id")
+	}
+	return spanID(re.operationName, re.alert.Alert.AlertID), nil
+}
+
+func (re ResponseEvent) References() ([]eventsources.Reference, error) {
+	id, err := re.alert.SpanID()
+	if err != nil {
+		return nil, err
+	}
+	return eventsources.ChildOf(&id), nil
+}
+
+func (re ResponseEvent) IsError() (bool, error) {
+	return false, nil
+}
+
+func (re ResponseEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	return eventsources.CompleteState, nil
+}
+
+func (re ResponseEvent) Timings() (eventsources.EventTimings, error) {
+	return timings(re.start, re.alert.Alert.updatedAt()), nil
+}
+
+func (re ResponseEvent) Tags() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"service":          sourceName,
+		"incident.id":      re.alert.Alert.AlertID,
+		"incident.service": re.alert.ServiceName(),
+		"incident.agent":   re.alert.Alert.Username,
+	}, nil
+}
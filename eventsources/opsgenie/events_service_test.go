@@ -0,0 +1,104 @@
+// +build service
+
+package opsgenie
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/tracers"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+var baseURL string
+var opsgeniePath string
+
+var urlEnvVar = "TEST_EVENTS_URL"
+var opsgeniePathEnvVar = "TEST_EVENTS_OPSGENIE_PATH"
+
+func init() {
+	ok := true
+	baseURL, ok = os.LookupEnv(urlEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", urlEnvVar))
+	}
+	opsgeniePath, ok = os.LookupEnv(opsgeniePathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", opsgeniePathEnvVar))
+	}
+}
+
+var incidentTags = map[string]interface{}{
+	"error":                false,
+	"incident.id":          "70413a06-38d6-4c85-92b8-5ebc900d42e2-1628589600000",
+	"incident.integration": "valuestream",
+	"incident.number":      "1791",
+	"incident.priority":    "P1",
+	"incident.resolved_by": "mona@example.com",
+	"incident.service":     "valuestream",
+	"incident.status":      "closed",
+	"incident.team":        "platform",
+	"incident.title":       "checkout error rate above 5%",
+	"service":              "opsgenie",
+}
+
+var eventTests = []struct {
+	Name               string
+	EventPaths         []string
+	ExpectedOperations []string
+}{
+	{
+		Name: "create_acknowledge_close",
+		EventPaths: []string{
+			"fixtures/events/create.json",
+			"fixtures/events/acknowledge.json",
+			"fixtures/events/close.json",
+		},
+		ExpectedOperations: []string{"incident_ack", "incident_resolve", "incident"},
+	},
+	{
+		Name: "create_close",
+		EventPaths: []string{
+			"fixtures/events/create.json",
+			"fixtures/events/close.json",
+		},
+		ExpectedOperations: []string{"incident_resolve", "incident"},
+	},
+	{
+		Name: "close_only",
+		EventPaths: []string{
+			"fixtures/events/close.json",
+		},
+		ExpectedOperations: []string{"incident_resolve", "incident"},
+	},
+}
+
+func TestServiceEvent_Opsgenie(t *testing.T) {
+	for _, tt := range eventTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			tracers.ResetTracer(t, baseURL)
+
+			for _, eventPath := range tt.EventPaths {
+				tracers.PostFixture(t, baseURL, opsgeniePath, eventPath)
+			}
+
+			spans := tracers.FinishedSpans(t, baseURL)
+
+			var operations []string
+			for _, s := range spans {
+				operations = append(operations, s.Span.OperationName)
+			}
+			assert.ElementsMatch(t, tt.ExpectedOperations, operations)
+
+			if len(spans) == 0 {
+				return
+			}
+
+			incident := spans[len(spans)-1]
+			assert.Equal(t, incidentTags, incident.Tags)
+			for _, child := range spans[:len(spans)-1] {
+				assert.Equal(t, incident.Span.SpanContext.SpanID, child.Span.ParentID)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package opsgenie
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAlertEvent_State(t *testing.T) {
+	started := eventsources.EventState(eventsources.StartState)
+
+	testCases := []struct {
+		action   string
+		prev     *eventsources.EventState
+		expected eventsources.SpanState
+	}{
+		{actionCreate, nil, eventsources.StartState},
+		{actionAcknowledge, &started, eventsources.IntermediaryState},
+		{actionClose, &started, eventsources.EndState},
+		{actionClose, nil, eventsources.CompleteState},
+		{"AddNote", &started, eventsources.UnknownState},
+	}
+	for _, tt := range testCases {
+		s, err := AlertEvent{Action: tt.action}.State(tt.prev)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, s, tt.action)
+	}
+}
+
+func TestAlertEvent_ServiceName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		alert    Alert
+		expected string
+	}{
+		{"tag", Alert{Tags: []string{"production", "service:checkout"}, Entity: "web"}, "checkout"},
+		{"entity", Alert{Tags: []string{"production"}, Entity: "web"}, "web"},
+		{"none", Alert{}, ""},
+	}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.expected, AlertEvent{Alert: tt.alert}.ServiceName(), tt.name)
+	}
+}
+
+func TestAlertEvent_Environment(t *testing.T) {
+	testCases := []struct {
+		name     string
+		alert    Alert
+		expected string
+	}{
+		{"tag", Alert{Tags: []string{"service:checkout", "environment:staging"}}, "staging"},
+		{"none", Alert{Tags: []string{"production"}}, ""},
+	}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.expected, AlertEvent{Alert: tt.alert}.Environment(), tt.name)
+	}
+}
+
+func TestAlertEvent_Timings(t *testing.T) {
+	ae := AlertEvent{
+		Action: actionClose,
+		Alert: Alert{
+			CreatedAt: 1627898400000,
+			UpdatedAt: 1627900800000000000,
+		},
+	}
+	timings, err := ae.Timings()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1627898400), timings.StartTime.Unix())
+	assert.Equal(t, int64(1627900800), timings.EndTime.Unix())
+	assert.Equal(t, "40m0s", timings.Duration.String())
+}
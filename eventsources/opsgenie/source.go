@@ -0,0 +1,111 @@
+package opsgenie
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/opentracing/opentracing-go"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	sourceName string = "opsgenie"
+)
+
+type Source struct {
+	tracer    opentracing.Tracer
+	secretKey []byte
+
+	mu           *sync.Mutex
+	acknowledged map[string]time.Time
+}
+
+func (s Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *Source) SecretKey() []byte {
+	return s.secretKey
+}
+
+// ValidatePayload checks the token the opsgenie webhook integration is
+// configured to send as a custom header:
+// `Authorization: Bearer <token>`
+func (s *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body")
+	}
+
+	if secretKey == nil {
+		return body, nil
+	}
+
+	if err := eventsources.BearerToken.Validate(r, secretKey); err != nil {
+		return nil, eventsources.InvalidSignatureError{Err: err}
+	}
+
+	return body, nil
+}
+
+func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
+	var ae AlertEvent
+	if err := json.Unmarshal(payload, &ae); err != nil {
+		return nil, err
+	}
+
+	if ae.Alert.AlertID == "" {
+		return nil, fmt.Errorf("payload does not contain an alert")
+	}
+
+	ae.acknowledgedAt = s.observe(ae)
+
+	return ae, nil
+}
+
+// observe remembers when each open alert was acknowledged, returning
+// the time it was acknowledged once it's closed.
+func (s *Source) observe(ae AlertEvent) *time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := ae.Alert.AlertID
+
+	switch ae.Action {
+	case actionAcknowledge:
+		if t := ae.Alert.updatedAt(); t != nil {
+			s.acknowledged[id] = *t
+		}
+	case actionClose:
+		if t, ok := s.acknowledged[id]; ok {
+			delete(s.acknowledged, id)
+			return &t
+		}
+	}
+	return nil
+}
+
+func NewSource(tracer opentracing.Tracer, secretKey []byte) (*Source, error) {
+	return &Source{
+		tracer:       tracer,
+		secretKey:    secretKey,
+		mu:           &sync.Mutex{},
+		acknowledged: make(map[string]time.Time),
+	}, nil
+}
+
+func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
+	var secretKey []byte
+	if token := c.String("opsgenie-token"); token != "" {
+		secretKey = []byte(token)
+	}
+	return NewSource(tracer, secretKey)
+}
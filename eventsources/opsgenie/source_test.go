@@ -0,0 +1,35 @@
+package opsgenie
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSource_Event_CloseFromAcknowledgement(t *testing.T) {
+	s, err := NewSource(nil, nil)
+	assert.NoError(t, err)
+
+	_, err = s.Event(nil, []byte(`{"action": "Acknowledge", "alert": {
+		"alertId": "7041", "createdAt": 1627898400000, "updatedAt": 1627898700000000000}}`))
+	assert.NoError(t, err)
+
+	e, err := s.Event(nil, []byte(`{"action": "Close", "alert": {
+		"alertId": "7041", "createdAt": 1627898400000, "updatedAt": 1627900800000000000}}`))
+	assert.NoError(t, err)
+
+	children, err := e.(AlertEvent).Children()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(children))
+
+	timings, err := children[0].Timings()
+	assert.NoError(t, err)
+	assert.Equal(t, "35m0s", timings.Duration.String())
+}
+
+func TestSource_Event_NoAlert(t *testing.T) {
+	s, err := NewSource(nil, nil)
+	assert.NoError(t, err)
+
+	_, err = s.Event(nil, []byte(`{"action": "Create"}`))
+	assert.Error(t, err)
+}
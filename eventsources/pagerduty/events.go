@@ -0,0 +1,258 @@
+package pagerduty
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"strings"
+	"time"
+)
+
+const (
+	eventIncidentTriggered    string = "incident.triggered"
+	eventIncidentAcknowledged string = "incident.acknowledged"
+	eventIncidentResolved     string = "incident.resolved"
+	eventIncidentReopened     string = "incident.reopened"
+)
+
+type Reference struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Summary string `json:"summary"`
+	HTMLURL string `json:"html_url"`
+}
+
+type Incident struct {
+	ID          string      `json:"id"`
+	Number      int         `json:"number"`
+	Title       string      `json:"title"`
+	Status      string      `json:"status"`
+	Urgency     string      `json:"urgency"`
+	IncidentKey string      `json:"incident_key"`
+	HTMLURL     string      `json:"html_url"`
+	CreatedAt   *time.Time  `json:"created_at"`
+	Service     *Reference  `json:"service"`
+	Priority    *Reference  `json:"priority"`
+	Teams       []Reference `json:"teams"`
+}
+
+// WebhookEvent is the `event` of a v3 webhook payload.
+type WebhookEvent struct {
+	ID           string     `json:"id"`
+	EventType    string     `json:"event_type"`
+	ResourceType string     `json:"resource_type"`
+	OccurredAt   *time.Time `json:"occurred_at"`
+	Agent        *Reference `json:"agent"`
+	Data         Incident   `json:"data"`
+}
+
+func spanID(eventType string, incidentID string) string {
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		eventType,
+		incidentID,
+	}, "-")
+}
+
+func timings(start, end *time.Time) eventsources.EventTimings {
+	t := eventsources.EventTimings{
+		StartTime: start,
+		EndTime:   end,
+	}
+	if start != nil && end != nil {
+		d := end.Sub(*start)
+		t.Duration = &d
+	}
+	return t
+}
+
+// IncidentEvent spans an incident from when it was triggered until it
+// was resolved.
+type IncidentEvent struct {
+	Event WebhookEvent `json:"event"`
+
+	// acknowledgedAt is when the incident was last acknowledged, if the
+	// source saw it happen.
+	acknowledgedAt *time.Time
+}
+
+func (ie IncidentEvent) incident() Incident {
+	return ie.Event.Data
+}
+
+// ServiceName is the name of the pagerduty service the incident was
+// raised against.
+func (ie IncidentEvent) ServiceName() string {
+	if ie.incident().Service == nil {
+		return ""
+	}
+	return ie.incident().Service.Summary
+}
+
+// Environment isn't known of pagerduty incidents, they're assumed to
+// affect production.
+func (ie IncidentEvent) Environment() string {
+	return ""
+}
+
+func (ie IncidentEvent) OperationName() string {
+	return types.IncidentEventType
+}
+
+func (ie IncidentEvent) SpanID() (string, error) {
+	if ie.incident().ID == "" {
+		return "", fmt.Errorf("event does not contain an incident id")
+	}
+	return spanID(types.IncidentEventType, ie.incident().ID), nil
+}
+
+// References are left to the webhook, which links incidents to the
+// most recent deploy of their service.
+func (ie IncidentEvent) References() ([]eventsources.Reference, error) {
+	return nil, nil
+}
+
+func (ie IncidentEvent) IsError() (bool, error) {
+	return false, nil
+}
+
+func (ie IncidentEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	switch ie.Event.EventType {
+	case eventIncidentTriggered, eventIncidentAcknowledged, eventIncidentReopened:
+		if prev == nil {
+			return eventsources.StartState, nil
+		}
+		return eventsources.IntermediaryState, nil
+	case eventIncidentResolved:
+		if prev == nil {
+			return eventsources.CompleteState, nil
+		}
+		return eventsources.EndState, nil
+	}
+	return eventsources.UnknownState, nil
+}
+
+func (ie IncidentEvent) Timings() (eventsources.EventTimings, error) {
+	var end *time.Time
+	if ie.Event.EventType == eventIncidentResolved {
+		end = ie.Event.OccurredAt
+	}
+	return timings(ie.incident().CreatedAt, end), nil
+}
+
+func (ie IncidentEvent) Tags() (map[string]interface{}, error) {
+	i := ie.incident()
+
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+	tags["incident.id"] = i.ID
+	tags["incident.number"] = i.Number
+	tags["incident.title"] = i.Title
+	tags["incident.url"] = i.HTMLURL
+	tags["incident.urgency"] = i.Urgency
+	tags["incident.service"] = ie.ServiceName()
+
+	if i.Priority != nil {
+		tags["incident.priority"] = i.Priority.Summary
+	}
+
+	for _, team := range i.Teams {
+		tags[fmt.Sprintf("incident.team.%s", team.ID)] = team.Summary
+	}
+	return tags, nil
+}
+
+// EndTags record the priority the incident ended up with, which is often
+// only set while the incident is being worked on.
+func (ie IncidentEvent) EndTags() (map[string]interface{}, error) {
+	tags := map[string]interface{}{
+		"incident.status": ie.incident().Status,
+	}
+	if p := ie.incident().Priority; p != nil {
+		tags["incident.priority"] = p.Summary
+	}
+	if a := ie.Event.Agent; a != nil {
+		tags["incident.resolved_by"] = a.Summary
+	}
+	return tags, nil
+}
+
+// Children are the time it took to acknowledge the incident, and the time
+// it took to resolve it once acknowledged.
+func (ie IncidentEvent) Children() ([]eventsources.Event, error) {
+	switch ie.Event.EventType {
+	case eventIncidentAcknowledged:
+		return []eventsources.Event{
+			ResponseEvent{
+				incident:      ie,
+				operationName: types.IncidentAckEventType,
+				start:         ie.incident().CreatedAt,
+			},
+		}, nil
+	case eventIncidentResolved:
+		start := ie.acknowledgedAt
+		if start == nil {
+			start = ie.incident().CreatedAt
+		}
+		return []eventsources.Event{
+			ResponseEvent{
+				incident:      ie,
+				operationName: types.IncidentResolveEventType,
+				start:         start,
+			},
+		}, nil
+	}
+	return nil, nil
+}
+
+// ResponseEvent is a step of the response to an incident, it's complete
+// by the time pagerduty reports it.
+type ResponseEvent struct {
+	incident      IncidentEvent
+	operationName string
+	start         *time.Time
+}
+
+func (re ResponseEvent) OperationName() string {
+	return re.operationName
+}
+
+func (re ResponseEvent) SpanID() (string, error) {
+	if re.incident.incident().ID == "" {
+		return "", fmt.Errorf("event does not contain an incident id")
+	}
+	return spanID(re.operationName, re.incident.incident().ID), nil
+}
+
+func (re ResponseEvent) References() ([]eventsources.Reference, error) {
+	id, err := re.incident.SpanID()
+	if err != nil {
+		return nil, err
+	}
+	return eventsources.ChildOf(&id), nil
+}
+
+func (re ResponseEvent) IsError() (bool, error) {
+	return false, nil
+}
+
+func (re ResponseEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	return eventsources.CompleteState, nil
+}
+
+func (re ResponseEvent) Timings() (eventsources.EventTimings, error) {
+	return timings(re.start, re.incident.Event.OccurredAt), nil
+}
+
+func (re ResponseEvent) Tags() (map[string]interface{}, error) {
+	tags := map[string]interface{}{
+		"service":          sourceName,
+		"incident.id":      re.incident.incident().ID,
+		"incident.service": re.incident.ServiceName(),
+	}
+	if a := re.incident.Event.Agent; a != nil {
+		tags["incident.agent"] = a.Summary
+	}
+	return tags, nil
+}
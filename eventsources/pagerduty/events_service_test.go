@@ -0,0 +1,132 @@
+// +build service
+
+package pagerduty
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/tracers"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+var baseURL string
+var pagerdutyPath string
+var argocdPath string
+
+var urlEnvVar = "TEST_EVENTS_URL"
+var pagerdutyPathEnvVar = "TEST_EVENTS_PAGERDUTY_PATH"
+var argocdPathEnvVar = "TEST_EVENTS_ARGOCD_PATH"
+
+func init() {
+	ok := true
+	baseURL, ok = os.LookupEnv(urlEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", urlEnvVar))
+	}
+	pagerdutyPath, ok = os.LookupEnv(pagerdutyPathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", pagerdutyPathEnvVar))
+	}
+	argocdPath, ok = os.LookupEnv(argocdPathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", argocdPathEnvVar))
+	}
+}
+
+var incidentTags = map[string]interface{}{
+	"error":                 false,
+	"incident.id":           "Q2R7ZLAZ7ZXD1C",
+	"incident.number":       float64(42),
+	"incident.priority":     "P1",
+	"incident.resolved_by":  "Mona Lisa",
+	"incident.service":      "valuestream",
+	"incident.status":       "resolved",
+	"incident.team.PFCVPS0": "Platform",
+	"incident.title":        "checkout error rate above 5%",
+	"incident.url":          "https://acme.pagerduty.com/incidents/Q2R7ZLAZ7ZXD1C",
+	"incident.urgency":      "high",
+	"service":               "pagerduty",
+}
+
+var eventTests = []struct {
+	Name               string
+	EventPaths         []string
+	ExpectedOperations []string
+}{
+	{
+		Name: "triggered_acknowledged_resolved",
+		EventPaths: []string{
+			"fixtures/events/triggered.json",
+			"fixtures/events/acknowledged.json",
+			"fixtures/events/resolved.json",
+		},
+		ExpectedOperations: []string{"incident_ack", "incident_resolve", "incident"},
+	},
+	{
+		Name: "triggered_resolved",
+		EventPaths: []string{
+			"fixtures/events/triggered.json",
+			"fixtures/events/resolved.json",
+		},
+		ExpectedOperations: []string{"incident_resolve", "incident"},
+	},
+	{
+		Name: "resolved_only",
+		EventPaths: []string{
+			"fixtures/events/resolved.json",
+		},
+		ExpectedOperations: []string{"incident_resolve", "incident"},
+	},
+}
+
+func TestServiceEvent_PagerDuty(t *testing.T) {
+	for _, tt := range eventTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			tracers.ResetTracer(t, baseURL)
+
+			for _, eventPath := range tt.EventPaths {
+				tracers.PostFixture(t, baseURL, pagerdutyPath, eventPath)
+			}
+
+			spans := tracers.FinishedSpans(t, baseURL)
+
+			var operations []string
+			for _, s := range spans {
+				operations = append(operations, s.Span.OperationName)
+			}
+			assert.ElementsMatch(t, tt.ExpectedOperations, operations)
+
+			if len(spans) == 0 {
+				return
+			}
+
+			incident := spans[len(spans)-1]
+			assert.Equal(t, incidentTags, incident.Tags)
+			for _, child := range spans[:len(spans)-1] {
+				assert.Equal(t, incident.Span.SpanContext.SpanID, child.Span.ParentID)
+			}
+		})
+	}
+}
+
+func TestServiceTrace_PagerDuty_IncidentFollowsFromDeploy(t *testing.T) {
+	tracers.ResetTracer(t, baseURL)
+
+	tracers.PostFixture(t, baseURL, argocdPath, "fixtures/traces/argocd_deployed.json")
+	tracers.PostFixture(t, baseURL, pagerdutyPath, "fixtures/events/triggered.json")
+	tracers.PostFixture(t, baseURL, pagerdutyPath, "fixtures/events/resolved.json")
+
+	spans := tracers.FinishedSpans(t, baseURL)
+
+	// deploy, incident_resolve and incident
+	assert.Equal(t, 3, len(spans))
+	if len(spans) != 3 {
+		t.FailNow()
+	}
+
+	deploy, incident := spans[0], spans[2]
+	assert.Equal(t, "deploy", deploy.Span.OperationName)
+	assert.Equal(t, "incident", incident.Span.OperationName)
+	assert.Equal(t, deploy.Span.SpanContext.SpanID, incident.Span.ParentID)
+}
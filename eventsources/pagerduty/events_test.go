@@ -0,0 +1,69 @@
+package pagerduty
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIncidentEvent_State(t *testing.T) {
+	started := eventsources.EventState(eventsources.StartState)
+
+	testCases := []struct {
+		eventType string
+		prev      *eventsources.EventState
+		expected  eventsources.SpanState
+	}{
+		{eventIncidentTriggered, nil, eventsources.StartState},
+		{eventIncidentAcknowledged, nil, eventsources.StartState},
+		{eventIncidentAcknowledged, &started, eventsources.IntermediaryState},
+		{eventIncidentReopened, &started, eventsources.IntermediaryState},
+		{eventIncidentResolved, &started, eventsources.EndState},
+		{eventIncidentResolved, nil, eventsources.CompleteState},
+		{"incident.annotated", &started, eventsources.UnknownState},
+	}
+	for _, tt := range testCases {
+		ie := IncidentEvent{Event: WebhookEvent{EventType: tt.eventType}}
+		s, err := ie.State(tt.prev)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, s, tt.eventType)
+	}
+}
+
+func TestIncidentEvent_Children(t *testing.T) {
+	testCases := []struct {
+		eventType string
+		expected  []string
+	}{
+		{eventIncidentTriggered, nil},
+		{eventIncidentAcknowledged, []string{types.IncidentAckEventType}},
+		{eventIncidentResolved, []string{types.IncidentResolveEventType}},
+	}
+	for _, tt := range testCases {
+		ie := IncidentEvent{Event: WebhookEvent{
+			EventType: tt.eventType,
+			Data:      Incident{ID: "Q2R7"},
+		}}
+		children, err := ie.Children()
+		assert.NoError(t, err)
+
+		var operations []string
+		for _, c := range children {
+			operations = append(operations, c.OperationName())
+
+			parent, err := eventsources.ParentSpanID(c)
+			assert.NoError(t, err)
+			assert.Equal(t, "vstrace-pagerduty-incident-Q2R7", *parent)
+		}
+		assert.Equal(t, tt.expected, operations, tt.eventType)
+	}
+}
+
+func TestIncidentEvent_ServiceName(t *testing.T) {
+	ie := IncidentEvent{Event: WebhookEvent{
+		Data: Incident{Service: &Reference{Summary: "valuestream"}},
+	}}
+	assert.Equal(t, "valuestream", ie.ServiceName())
+	assert.Equal(t, "", IncidentEvent{}.ServiceName())
+}
@@ -0,0 +1,121 @@
+package pagerduty
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/opentracing/opentracing-go"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	sourceName string = "pagerduty"
+
+	signatureHeader  string = "X-PagerDuty-Signature"
+	signatureVersion string = "v1="
+)
+
+var signature = eventsources.HMACSignature{
+	Hash:   sha256.New,
+	Header: signatureHeader,
+	Prefix: signatureVersion,
+}
+
+type Source struct {
+	tracer    opentracing.Tracer
+	secretKey []byte
+
+	mu           *sync.Mutex
+	acknowledged map[string]time.Time
+}
+
+func (s Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *Source) SecretKey() []byte {
+	return s.secretKey
+}
+
+// ValidatePayload checks the `X-PagerDuty-Signature` header, which holds
+// a signature for each of the webhook's secrets while they're rotated:
+// `v1=<hex encoded hmac of the body>,v1=<...>`
+func (s *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body")
+	}
+
+	if secretKey == nil {
+		return body, nil
+	}
+
+	if err := signature.Validate(r, body, secretKey); err != nil {
+		return nil, eventsources.InvalidSignatureError{Err: err}
+	}
+
+	return body, nil
+}
+
+func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
+	var ie IncidentEvent
+	if err := json.Unmarshal(payload, &ie); err != nil {
+		return nil, err
+	}
+
+	if ie.Event.ResourceType != "incident" {
+		return nil, fmt.Errorf("resource type: %q, not supported", ie.Event.ResourceType)
+	}
+
+	ie.acknowledgedAt = s.observe(ie)
+
+	return ie, nil
+}
+
+// observe remembers when each open incident was acknowledged, returning
+// the time it was acknowledged once it's resolved.
+func (s *Source) observe(ie IncidentEvent) *time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := ie.incident().ID
+
+	switch ie.Event.EventType {
+	case eventIncidentAcknowledged:
+		if ie.Event.OccurredAt != nil {
+			s.acknowledged[id] = *ie.Event.OccurredAt
+		}
+	case eventIncidentResolved:
+		if t, ok := s.acknowledged[id]; ok {
+			delete(s.acknowledged, id)
+			return &t
+		}
+	}
+	return nil
+}
+
+func NewSource(tracer opentracing.Tracer, secretKey []byte) (*Source, error) {
+	return &Source{
+		tracer:       tracer,
+		secretKey:    secretKey,
+		mu:           &sync.Mutex{},
+		acknowledged: make(map[string]time.Time),
+	}, nil
+}
+
+func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
+	var secretKey []byte
+	if secret := c.String("pagerduty-secret"); secret != "" {
+		secretKey = []byte(secret)
+	}
+	return NewSource(tracer, secretKey)
+}
@@ -0,0 +1,85 @@
+package pagerduty
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestSource_ValidatePayload(t *testing.T) {
+	secretKey := []byte("secret")
+	body := []byte(`{"event": {"event_type": "incident.triggered"}}`)
+
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write(body)
+	signature := signatureVersion + hex.EncodeToString(mac.Sum(nil))
+
+	testCases := []struct {
+		name        string
+		signature   string
+		secretKey   []byte
+		expectedErr bool
+	}{
+		{"signed", signature, secretKey, false},
+		{"unsupported_version", "v0=" + signature[len(signatureVersion):], secretKey, true},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/pagerduty", bytes.NewReader(body))
+			assert.NoError(t, err)
+			if tt.signature != "" {
+				req.Header.Set(signatureHeader, tt.signature)
+			}
+
+			s, err := NewSource(nil, tt.secretKey)
+			assert.NoError(t, err)
+
+			payload, err := s.ValidatePayload(req, s.SecretKey())
+			if tt.expectedErr {
+				assert.IsType(t, eventsources.InvalidSignatureError{}, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, body, payload)
+		})
+	}
+}
+
+func TestSource_Event_ResolveFromAcknowledgement(t *testing.T) {
+	s, err := NewSource(nil, nil)
+	assert.NoError(t, err)
+
+	_, err = s.Event(nil, []byte(`{"event": {
+		"event_type": "incident.acknowledged", "resource_type": "incident",
+		"occurred_at": "2021-08-02T10:05:00Z",
+		"data": {"id": "Q2R7", "created_at": "2021-08-02T10:00:00Z"}}}`))
+	assert.NoError(t, err)
+
+	e, err := s.Event(nil, []byte(`{"event": {
+		"event_type": "incident.resolved", "resource_type": "incident",
+		"occurred_at": "2021-08-02T10:45:00Z",
+		"data": {"id": "Q2R7", "created_at": "2021-08-02T10:00:00Z"}}}`))
+	assert.NoError(t, err)
+
+	children, err := e.(IncidentEvent).Children()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(children))
+
+	timings, err := children[0].Timings()
+	assert.NoError(t, err)
+	assert.Equal(t, "2021-08-02T10:05:00Z", timings.StartTime.Format("2006-01-02T15:04:05Z07:00"))
+	assert.Equal(t, "40m0s", timings.Duration.String())
+}
+
+func TestSource_Event_UnsupportedResource(t *testing.T) {
+	s, err := NewSource(nil, nil)
+	assert.NoError(t, err)
+
+	_, err = s.Event(nil, []byte(`{"event": {"event_type": "service.updated", "resource_type": "service"}}`))
+	assert.Error(t, err)
+}
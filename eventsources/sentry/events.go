@@ -0,0 +1,215 @@
+package sentry
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"strings"
+	"time"
+)
+
+const (
+	actionCreated    string = "created"
+	actionUnresolved string = "unresolved"
+	actionAssigned   string = "assigned"
+	actionResolved   string = "resolved"
+	actionIgnored    string = "ignored"
+	actionArchived   string = "archived"
+
+	tagEnvironment string = "environment"
+	tagRelease     string = "release"
+)
+
+type Project struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	Platform string `json:"platform"`
+}
+
+type Release struct {
+	Version     string `json:"version"`
+	VersionInfo struct {
+		BuildHash string `json:"buildHash"`
+	} `json:"versionInfo"`
+	LastCommit *struct {
+		ID string `json:"id"`
+	} `json:"lastCommit"`
+}
+
+// Tag is a summary of the values of a tag across the issue's events.
+type Tag struct {
+	Key       string `json:"key"`
+	TopValues []struct {
+		Value string `json:"value"`
+	} `json:"topValues"`
+}
+
+type Issue struct {
+	ID           string     `json:"id"`
+	ShortID      string     `json:"shortId"`
+	Title        string     `json:"title"`
+	Culprit      string     `json:"culprit"`
+	Level        string     `json:"level"`
+	Status       string     `json:"status"`
+	Platform     string     `json:"platform"`
+	WebURL       string     `json:"web_url"`
+	FirstSeen    *time.Time `json:"firstSeen"`
+	LastSeen     *time.Time `json:"lastSeen"`
+	Project      Project    `json:"project"`
+	FirstRelease *Release   `json:"firstRelease"`
+	Tags         []Tag      `json:"tags"`
+}
+
+// tag is the most common value of the tag across the issue's events.
+func (i Issue) tag(key string) string {
+	for _, t := range i.Tags {
+		if t.Key == key && len(t.TopValues) > 0 {
+			return t.TopValues[0].Value
+		}
+	}
+	return ""
+}
+
+type Actor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// IssueEvent tracks a sentry issue as a defect, from when it was first
+// seen until it was resolved or ignored.
+type IssueEvent struct {
+	Action string `json:"action"`
+	Data   struct {
+		Issue Issue `json:"issue"`
+	} `json:"data"`
+	Actor Actor `json:"actor"`
+}
+
+func (ie IssueEvent) issue() Issue {
+	return ie.Data.Issue
+}
+
+// release is the release the issue was first seen in.
+func (ie IssueEvent) release() string {
+	if r := ie.issue().FirstRelease; r != nil && r.Version != "" {
+		return r.Version
+	}
+	return ie.issue().tag(tagRelease)
+}
+
+// Releases are the release the issue was first seen in followed by the
+// commit it was built from, the deploy of either introduced the defect.
+func (ie IssueEvent) Releases() []string {
+	var releases []string
+	if r := ie.release(); r != "" {
+		releases = append(releases, r)
+	}
+	if r := ie.issue().FirstRelease; r != nil {
+		if r.VersionInfo.BuildHash != "" {
+			releases = append(releases, r.VersionInfo.BuildHash)
+		}
+		if r.LastCommit != nil && r.LastCommit.ID != "" {
+			releases = append(releases, r.LastCommit.ID)
+		}
+	}
+	return releases
+}
+
+// ServiceName is the slug of the sentry project, defects fall back to
+// following from the most recent deploy of it.
+func (ie IssueEvent) ServiceName() string {
+	return ie.issue().Project.Slug
+}
+
+// Environment is the environment the issue was seen in, by its
+// `environment` tag.
+func (ie IssueEvent) Environment() string {
+	return ie.issue().tag(tagEnvironment)
+}
+
+func (ie IssueEvent) OperationName() string {
+	return types.DefectEventType
+}
+
+func (ie IssueEvent) SpanID() (string, error) {
+	if ie.issue().ID == "" {
+		return "", fmt.Errorf("event does not contain an issue id")
+	}
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		types.DefectEventType,
+		ie.issue().Project.Slug,
+		ie.issue().ID,
+	}, "-"), nil
+}
+
+// References are left to the webhook, which links defects to the deploy
+// of the release they were first seen in.
+func (ie IssueEvent) References() ([]eventsources.Reference, error) {
+	return nil, nil
+}
+
+func (ie IssueEvent) IsError() (bool, error) {
+	return false, nil
+}
+
+func (ie IssueEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	switch ie.Action {
+	case actionCreated, actionUnresolved:
+		if prev == nil {
+			return eventsources.StartState, nil
+		}
+		return eventsources.IntermediaryState, nil
+	case actionAssigned:
+		return eventsources.IntermediaryState, nil
+	case actionResolved, actionIgnored, actionArchived:
+		if prev == nil {
+			return eventsources.CompleteState, nil
+		}
+		return eventsources.EndState, nil
+	}
+	return eventsources.UnknownState, nil
+}
+
+// Timings start the defect when it was first seen, it ends when it's
+// resolved.
+func (ie IssueEvent) Timings() (eventsources.EventTimings, error) {
+	return eventsources.EventTimings{
+		StartTime: ie.issue().FirstSeen,
+	}, nil
+}
+
+func (ie IssueEvent) Tags() (map[string]interface{}, error) {
+	i := ie.issue()
+
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+	tags["defect.id"] = i.ID
+	tags["defect.short_id"] = i.ShortID
+	tags["defect.title"] = i.Title
+	tags["defect.culprit"] = i.Culprit
+	tags["defect.level"] = i.Level
+	tags["defect.url"] = i.WebURL
+	tags["project.id"] = i.Project.ID
+	tags["project.name"] = i.Project.Name
+	tags["project.slug"] = i.Project.Slug
+
+	if r := ie.release(); r != "" {
+		tags["defect.release"] = r
+	}
+	if env := ie.Environment(); env != "" {
+		tags["defect.environment"] = env
+	}
+	return tags, nil
+}
+
+// EndTags record how the defect ended, resolved or ignored, and by whom.
+func (ie IssueEvent) EndTags() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"defect.status":      ie.issue().Status,
+		"defect.resolved_by": ie.Actor.Name,
+	}, nil
+}
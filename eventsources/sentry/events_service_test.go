@@ -0,0 +1,133 @@
+// +build service
+
+package sentry
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/tracers"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+var baseURL string
+var sentryPath string
+var argocdPath string
+
+var urlEnvVar = "TEST_EVENTS_URL"
+var sentryPathEnvVar = "TEST_EVENTS_SENTRY_PATH"
+var argocdPathEnvVar = "TEST_EVENTS_ARGOCD_PATH"
+
+func init() {
+	ok := true
+	baseURL, ok = os.LookupEnv(urlEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", urlEnvVar))
+	}
+	sentryPath, ok = os.LookupEnv(sentryPathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", sentryPathEnvVar))
+	}
+	argocdPath, ok = os.LookupEnv(argocdPathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", argocdPathEnvVar))
+	}
+}
+
+var defectTags = map[string]interface{}{
+	"defect.culprit":     "checkout/cart.js in computeTotal",
+	"defect.environment": "production",
+	"defect.id":          "1170820242",
+	"defect.level":       "error",
+	"defect.release":     "valuestream-api@1.4.0",
+	"defect.short_id":    "VALUESTREAM-API-3",
+	"defect.title":       "TypeError: Cannot read property 'total' of undefined",
+	"defect.url":         "https://sentry.io/organizations/acme/issues/1170820242/",
+	"error":              false,
+	"project.id":         "5",
+	"project.name":       "valuestream-api",
+	"project.slug":       "valuestream-api",
+	"service":            "sentry",
+}
+
+var eventTests = []struct {
+	Name           string
+	EventPaths     []string
+	ExpectedStatus string
+	ExpectedActor  string
+}{
+	{
+		Name: "created_resolved",
+		EventPaths: []string{
+			"fixtures/events/created.json",
+			"fixtures/events/resolved.json",
+		},
+		ExpectedStatus: "resolved",
+		ExpectedActor:  "Mona Lisa",
+	},
+	{
+		Name: "created_ignored",
+		EventPaths: []string{
+			"fixtures/events/created.json",
+			"fixtures/events/ignored.json",
+		},
+		ExpectedStatus: "ignored",
+		ExpectedActor:  "Mona Lisa",
+	},
+	{
+		Name: "resolved_only",
+		EventPaths: []string{
+			"fixtures/events/resolved.json",
+		},
+		ExpectedStatus: "resolved",
+		ExpectedActor:  "Mona Lisa",
+	},
+}
+
+func TestServiceEvent_Sentry(t *testing.T) {
+	for _, tt := range eventTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			tracers.ResetTracer(t, baseURL)
+
+			for _, eventPath := range tt.EventPaths {
+				tracers.PostFixture(t, baseURL, sentryPath, eventPath)
+			}
+
+			spans := tracers.FinishedSpans(t, baseURL)
+			assert.Equal(t, 1, len(spans))
+			if len(spans) != 1 {
+				t.FailNow()
+			}
+
+			expected := make(map[string]interface{})
+			for k, v := range defectTags {
+				expected[k] = v
+			}
+			expected["defect.status"] = tt.ExpectedStatus
+			expected["defect.resolved_by"] = tt.ExpectedActor
+
+			defect := spans[0]
+			assert.Equal(t, "defect", defect.Span.OperationName)
+			assert.Equal(t, expected, defect.Tags)
+		})
+	}
+}
+
+func TestServiceTrace_Sentry_DefectFollowsFromDeployOfCommit(t *testing.T) {
+	tracers.ResetTracer(t, baseURL)
+
+	tracers.PostFixture(t, baseURL, argocdPath, "fixtures/traces/argocd_deployed.json")
+	tracers.PostFixture(t, baseURL, sentryPath, "fixtures/events/created.json")
+	tracers.PostFixture(t, baseURL, sentryPath, "fixtures/events/resolved.json")
+
+	spans := tracers.FinishedSpans(t, baseURL)
+	assert.Equal(t, 2, len(spans))
+	if len(spans) != 2 {
+		t.FailNow()
+	}
+
+	deploy, defect := spans[0], spans[1]
+	assert.Equal(t, "deploy", deploy.Span.OperationName)
+	assert.Equal(t, "defect", defect.Span.OperationName)
+	assert.Equal(t, deploy.Span.SpanContext.SpanID, defect.Span.ParentID)
+}
@@ -0,0 +1,67 @@
+package sentry
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIssueEvent_State(t *testing.T) {
+	started := eventsources.EventState(eventsources.StartState)
+
+	testCases := []struct {
+		action   string
+		prev     *eventsources.EventState
+		expected eventsources.SpanState
+	}{
+		{actionCreated, nil, eventsources.StartState},
+		{actionUnresolved, nil, eventsources.StartState},
+		{actionUnresolved, &started, eventsources.IntermediaryState},
+		{actionAssigned, &started, eventsources.IntermediaryState},
+		{actionResolved, &started, eventsources.EndState},
+		{actionResolved, nil, eventsources.CompleteState},
+		{actionIgnored, &started, eventsources.EndState},
+		{actionArchived, nil, eventsources.CompleteState},
+		{"triggered", &started, eventsources.UnknownState},
+	}
+	for _, tt := range testCases {
+		ie := IssueEvent{Action: tt.action}
+		s, err := ie.State(tt.prev)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, s, tt.action)
+	}
+}
+
+func TestIssueEvent_Releases(t *testing.T) {
+	testCases := []struct {
+		name     string
+		issue    Issue
+		expected []string
+	}{
+		{"none", Issue{}, nil},
+		{
+			"release_tag",
+			Issue{Tags: []Tag{{Key: tagRelease, TopValues: []struct {
+				Value string `json:"value"`
+			}{{Value: "api@1.0.0"}}}}},
+			[]string{"api@1.0.0"},
+		},
+		{
+			"first_release",
+			Issue{FirstRelease: &Release{
+				Version: "api@1.0.0",
+				LastCommit: &struct {
+					ID string `json:"id"`
+				}{ID: "abc123"},
+			}},
+			[]string{"api@1.0.0", "abc123"},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ie := IssueEvent{}
+			ie.Data.Issue = tt.issue
+			assert.Equal(t, tt.expected, ie.Releases())
+		})
+	}
+}
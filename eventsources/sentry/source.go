@@ -0,0 +1,90 @@
+package sentry
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/opentracing/opentracing-go"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	sourceName string = "sentry"
+
+	signatureHeader string = "Sentry-Hook-Signature"
+	resourceHeader  string = "Sentry-Hook-Resource"
+	resourceIssue   string = "issue"
+)
+
+var signature = eventsources.HMACSignature{
+	Hash:   sha256.New,
+	Header: signatureHeader,
+}
+
+type Source struct {
+	tracer    opentracing.Tracer
+	secretKey []byte
+}
+
+func (s Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *Source) SecretKey() []byte {
+	return s.secretKey
+}
+
+// ValidatePayload checks the `Sentry-Hook-Signature` header, the hex
+// encoded hmac-sha256 of the body signed with the integration's client
+// secret.
+func (s *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body")
+	}
+
+	if secretKey == nil {
+		return body, nil
+	}
+
+	if err := signature.Validate(r, body, secretKey); err != nil {
+		return nil, eventsources.InvalidSignatureError{Err: err}
+	}
+
+	return body, nil
+}
+
+func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
+	if resource := r.Header.Get(resourceHeader); resource != resourceIssue {
+		return nil, fmt.Errorf("resource: %q, not supported", resource)
+	}
+
+	var ie IssueEvent
+	if err := json.Unmarshal(payload, &ie); err != nil {
+		return nil, err
+	}
+
+	return ie, nil
+}
+
+func NewSource(tracer opentracing.Tracer, secretKey []byte) (*Source, error) {
+	return &Source{
+		tracer:    tracer,
+		secretKey: secretKey,
+	}, nil
+}
+
+func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
+	var secretKey []byte
+	if secret := c.String("sentry-client-secret"); secret != "" {
+		secretKey = []byte(secret)
+	}
+	return NewSource(tracer, secretKey)
+}
@@ -0,0 +1,62 @@
+package sentry
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestSource_ValidatePayload(t *testing.T) {
+	secretKey := []byte("secret")
+	body := []byte(`{"action": "created", "data": {"issue": {"id": "1"}}}`)
+
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write(body)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	testCases := []struct {
+		name        string
+		signature   string
+		secretKey   []byte
+		expectedErr bool
+	}{
+		{"signed", signature, secretKey, false},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/sentry", bytes.NewReader(body))
+			assert.NoError(t, err)
+			if tt.signature != "" {
+				req.Header.Set(signatureHeader, tt.signature)
+			}
+
+			s, err := NewSource(nil, tt.secretKey)
+			assert.NoError(t, err)
+
+			payload, err := s.ValidatePayload(req, s.SecretKey())
+			if tt.expectedErr {
+				assert.IsType(t, eventsources.InvalidSignatureError{}, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, body, payload)
+		})
+	}
+}
+
+func TestSource_Event_UnsupportedResource(t *testing.T) {
+	s, err := NewSource(nil, nil)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("POST", "/sentry", nil)
+	assert.NoError(t, err)
+	req.Header.Set(resourceHeader, "event_alert")
+
+	_, err = s.Event(req, []byte(`{"action": "triggered"}`))
+	assert.Error(t, err)
+}
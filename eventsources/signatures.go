@@ -0,0 +1,98 @@
+package eventsources
+
+import (
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// HMACSignature is how a source signs its payloads: the HMAC of the
+// payload, using Hash and the source's secret, sent in Header following
+// Prefix, ie `X-Hub-Signature: sha256=<hex encoded hmac>`.  Signatures are
+// hex encoded unless Base64 is set.  Headers may hold several comma
+// separated signatures, ie while secrets are rotated, the request is
+// signed if any of them match.
+type HMACSignature struct {
+	Hash   func() hash.Hash
+	Header string
+	Prefix string
+	Base64 bool
+}
+
+// Validate checks the request's Header holds a signature of the message,
+// which is usually the request's body.
+func (s HMACSignature) Validate(r *http.Request, message []byte, secretKey []byte) error {
+	return s.Verify(r.Header.Get(s.Header), message, secretKey)
+}
+
+// Verify checks any of the comma separated signatures is the signature
+// of the message.
+func (s HMACSignature) Verify(signatures string, message []byte, secretKey []byte) error {
+	if signatures == "" {
+		return fmt.Errorf("request is not signed")
+	}
+
+	mac := hmac.New(s.Hash, secretKey)
+	mac.Write(message)
+	expected := mac.Sum(nil)
+
+	for _, sig := range strings.Split(signatures, ",") {
+		sig = strings.TrimSpace(sig)
+		if !strings.HasPrefix(sig, s.Prefix) {
+			continue
+		}
+
+		received, err := s.decode(strings.TrimPrefix(sig, s.Prefix))
+		if err != nil || len(received) == 0 {
+			continue
+		}
+
+		if hmac.Equal(received, expected) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid event signature")
+}
+
+func (s HMACSignature) decode(sig string) ([]byte, error) {
+	if s.Base64 {
+		return base64.StdEncoding.DecodeString(sig)
+	}
+	return hex.DecodeString(sig)
+}
+
+// BearerToken is the secret sent as `Authorization: Bearer <secret>`.
+var BearerToken = SecretToken{Header: "Authorization", Prefix: "Bearer "}
+
+// SecretToken is how a source authenticates its requests when it can't
+// sign them: the source's secret itself, sent in Header following Prefix.
+type SecretToken struct {
+	Header string
+	Prefix string
+}
+
+// Validate checks the request's Header holds the secret.
+func (t SecretToken) Validate(r *http.Request, secretKey []byte) error {
+	return t.Verify(r.Header.Get(t.Header), secretKey)
+}
+
+// Verify checks the value, following Prefix, is the secret.  The secret is
+// compared in constant time so it can't be guessed from how long
+// rejecting a request takes.
+func (t SecretToken) Verify(value string, secretKey []byte) error {
+	if value == "" || !strings.HasPrefix(value, t.Prefix) {
+		return fmt.Errorf("request does not contain a token")
+	}
+
+	token := strings.TrimPrefix(value, t.Prefix)
+	if subtle.ConstantTimeCompare([]byte(token), secretKey) != 1 {
+		return fmt.Errorf("invalid token")
+	}
+	return nil
+}
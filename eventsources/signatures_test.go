@@ -0,0 +1,82 @@
+package eventsources
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestHMACSignature_Verify(t *testing.T) {
+	secretKey := []byte("secret")
+	message := []byte(`{"event": "build"}`)
+
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write(message)
+	sha256Hex := hex.EncodeToString(mac.Sum(nil))
+
+	mac = hmac.New(sha1.New, secretKey)
+	mac.Write(message)
+	sha1Base64 := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	hexSignature := HMACSignature{Hash: sha256.New, Prefix: "v1="}
+	base64Signature := HMACSignature{Hash: sha1.New, Base64: true}
+
+	testCases := []struct {
+		name        string
+		signature   HMACSignature
+		signatures  string
+		expectedErr bool
+	}{
+		{"hex", hexSignature, "v1=" + sha256Hex, false},
+		{"base64", base64Signature, sha1Base64, false},
+		{"rotating_secrets", hexSignature, "v1=00, v1=" + sha256Hex, false},
+		{"other_prefix", hexSignature, "v0=" + sha256Hex, true},
+		{"missing_prefix", hexSignature, sha256Hex, true},
+		{"not_encoded", hexSignature, "v1=invalid", true},
+		{"invalid_signature", hexSignature, "v1=00", true},
+		{"unsigned", hexSignature, "", true},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.signature.Verify(tt.signatures, message, secretKey)
+			assert.Equal(t, tt.expectedErr, err != nil, err)
+		})
+	}
+}
+
+func TestSecretToken_Validate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		token       SecretToken
+		header      string
+		value       string
+		expectedErr bool
+	}{
+		{"bearer", BearerToken, "Authorization", "Bearer secret", false},
+		{"bearer_invalid", BearerToken, "Authorization", "Bearer wrong", true},
+		{"bearer_basic_auth", BearerToken, "Authorization", "Basic c2VjcmV0", true},
+		{"bearer_missing_prefix", BearerToken, "Authorization", "secret", true},
+		{"header", SecretToken{Header: "X-Token"}, "X-Token", "secret", false},
+		{"header_prefix_of_secret", SecretToken{Header: "X-Token"}, "X-Token", "secre", true},
+		{"header_invalid", SecretToken{Header: "X-Token"}, "X-Token", "secreT", true},
+		{"other_header", SecretToken{Header: "X-Token"}, "X-Other-Token", "secret", true},
+		{"no_token", BearerToken, "", "", true},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest("POST", "/", nil)
+			assert.NoError(t, err)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+
+			err = tt.token.Validate(r, []byte("secret"))
+			assert.Equal(t, tt.expectedErr, err != nil, err)
+		})
+	}
+}
@@ -5,3 +5,21 @@ const (
 	SpanIDTag       string = "vs.span.id"
 	ParentSpanIDTag string = "vs.parent.span.id"
 )
+
+// The tags naming the environment an incident or defect affects, when
+// they're mapped by rules.
+const (
+	IncidentEnvironmentTag string = "incident.environment"
+	DefectEnvironmentTag   string = "defect.environment"
+)
+
+// TaggedEnvironment is the environment named by an incident or defect's
+// tags, empty when they don't name one.
+func TaggedEnvironment(tags map[string]interface{}) string {
+	for _, tag := range []string{IncidentEnvironmentTag, DefectEnvironmentTag} {
+		if env, ok := tags[tag].(string); ok && env != "" {
+			return env
+		}
+	}
+	return ""
+}
@@ -42,6 +42,7 @@ type StubEvent struct {
 	OperationNameReturn     string
 	ParentSpanIDReturn      *string
 	ParentSpanIDReturnError error
+	ReferencesReturn        []Reference
 	IsErrorReturn           bool
 	IsErrorReturnError      error
 	StateReturn             SpanState
@@ -50,6 +51,7 @@ type StubEvent struct {
 	TraceIDReturnError      error
 	TagsReturn              map[string]interface{}
 	TagsReturnError         error
+	TimingsReturn           EventTimings
 }
 
 func (s StubEvent) SpanID() (string, error) {
@@ -58,8 +60,11 @@ func (s StubEvent) SpanID() (string, error) {
 func (s StubEvent) OperationName() string {
 	return s.OperationNameReturn
 }
-func (s StubEvent) ParentSpanID() (*string, error) {
-	return s.ParentSpanIDReturn, s.ParentSpanIDReturnError
+func (s StubEvent) References() ([]Reference, error) {
+	if s.ReferencesReturn != nil {
+		return s.ReferencesReturn, nil
+	}
+	return ChildOf(s.ParentSpanIDReturn), s.ParentSpanIDReturnError
 }
 func (s StubEvent) IsError() (bool, error) {
 	return s.IsErrorReturn, s.IsErrorReturnError
@@ -74,7 +79,44 @@ func (s StubEvent) Tags() (map[string]interface{}, error) {
 	return s.TagsReturn, s.TagsReturnError
 }
 
-func (s StubEvent) Timings() (EventTimings, error) { return EventTimings{}, nil }
+func (s StubEvent) Timings() (EventTimings, error) { return s.TimingsReturn, nil }
+
+type StubParentEvent struct {
+	StubEvent
+	ChildrenReturn      []Event
+	ChildrenReturnError error
+}
+
+func (s StubParentEvent) Children() ([]Event, error) {
+	return s.ChildrenReturn, s.ChildrenReturnError
+}
+
+type StubRevisionEvent struct {
+	StubEvent
+	RevisionReturn string
+}
+
+func (s StubRevisionEvent) Revision() string {
+	return s.RevisionReturn
+}
+
+type StubServiceEvent struct {
+	StubEvent
+	ServiceNameReturn string
+}
+
+func (s StubServiceEvent) ServiceName() string {
+	return s.ServiceNameReturn
+}
+
+type StubIncidentEvent struct {
+	StubServiceEvent
+	EnvironmentReturn string
+}
+
+func (s StubIncidentEvent) Environment() string {
+	return s.EnvironmentReturn
+}
 
 type TestEvent struct {
 	Headers map[string]string
@@ -101,3 +143,12 @@ func NewTestEventFromFixturePath(path string) (*TestEvent, error) {
 
 	return &te, nil
 }
+
+type StubReleaseEvent struct {
+	StubEvent
+	ReleasesReturn []string
+}
+
+func (s StubReleaseEvent) Releases() []string {
+	return s.ReleasesReturn
+}
@@ -0,0 +1,225 @@
+package trello
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const (
+	actionCreateCard      string = "createCard"
+	actionCopyCard        string = "copyCard"
+	actionUpdateCard      string = "updateCard"
+	actionMoveCardToBoard string = "moveCardToBoard"
+	actionDeleteCard      string = "deleteCard"
+)
+
+type Board struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	ShortLink string `json:"shortLink"`
+}
+
+type List struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Card struct {
+	ID        string `json:"id"`
+	IDShort   int    `json:"idShort"`
+	Name      string `json:"name"`
+	ShortLink string `json:"shortLink"`
+	Closed    bool   `json:"closed"`
+}
+
+type Member struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	FullName string `json:"fullName"`
+}
+
+type ActionData struct {
+	Card       *Card                  `json:"card"`
+	Board      Board                  `json:"board"`
+	List       *List                  `json:"list"`
+	ListBefore *List                  `json:"listBefore"`
+	ListAfter  *List                  `json:"listAfter"`
+	Old        map[string]interface{} `json:"old"`
+}
+
+type Action struct {
+	ID            string     `json:"id"`
+	Type          string     `json:"type"`
+	Date          *time.Time `json:"date"`
+	Data          ActionData `json:"data"`
+	MemberCreator Member     `json:"memberCreator"`
+}
+
+// CardEvent tracks a card as an issue, from when it's put in a list which
+// starts it until it's put in a list which ends it, or it's archived.
+type CardEvent struct {
+	Action Action `json:"action"`
+
+	workflows types.Workflows
+}
+
+// list is the list the card was created in or moved to, if the action
+// put it in one.
+func (ce CardEvent) list() *List {
+	switch ce.Action.Type {
+	case actionCreateCard, actionCopyCard, actionMoveCardToBoard:
+		return ce.Action.Data.List
+	case actionUpdateCard:
+		if ce.Action.Data.ListAfter != nil {
+			return ce.Action.Data.ListAfter
+		}
+		// restored from the archive to the list it was in
+		if closed, ok := ce.Action.Data.Old["closed"]; ok && closed == true {
+			return ce.Action.Data.List
+		}
+	}
+	return nil
+}
+
+// listState maps the list using the workflow of the card's board, by its
+// short link and then its name.
+func (ce CardEvent) listState(list string) (eventsources.SpanState, bool) {
+	board := ce.Action.Data.Board
+	return ce.workflows.State(list, "", board.ShortLink, board.Name)
+}
+
+// archived is true when the action archived or deleted the card.
+func (ce CardEvent) archived() bool {
+	if ce.Action.Type == actionDeleteCard {
+		return true
+	}
+	if ce.Action.Type != actionUpdateCard || ce.Action.Data.Card == nil {
+		return false
+	}
+	_, closedChanged := ce.Action.Data.Old["closed"]
+	return closedChanged && ce.Action.Data.Card.Closed
+}
+
+func (ce CardEvent) Timings() (eventsources.EventTimings, error) {
+	if ce.archived() {
+		return eventsources.EventTimings{EndTime: ce.Action.Date}, nil
+	}
+	if l := ce.list(); l != nil {
+		if s, ok := ce.listState(l.Name); ok && s == eventsources.EndState {
+			return eventsources.EventTimings{EndTime: ce.Action.Date}, nil
+		}
+	}
+	return eventsources.EventTimings{StartTime: ce.Action.Date}, nil
+}
+
+func (ce CardEvent) SpanID() (string, error) {
+	if ce.Action.Data.Card == nil || ce.Action.Data.Card.ID == "" {
+		return "", fmt.Errorf("action: %q does not contain a card", ce.Action.Type)
+	}
+
+	return strings.Join([]string{
+		eventsources.TracePrefix,
+		sourceName,
+		types.IssueEventType,
+		ce.Action.Data.Card.ID,
+	}, "-"), nil
+}
+
+func (ce CardEvent) OperationName() string {
+	return types.IssueEventType
+}
+
+func (ce CardEvent) References() ([]eventsources.Reference, error) {
+	return nil, nil
+}
+
+func (ce CardEvent) IsError() (bool, error) {
+	return false, nil
+}
+
+// State is driven by the list the card is put in, cards are only ever
+// started once.  Cards which aren't being tracked yet are ignored until
+// they're put in a list which starts them.
+func (ce CardEvent) State(prev *eventsources.EventState) (eventsources.SpanState, error) {
+	if ce.archived() {
+		if prev == nil {
+			return eventsources.UnknownState, nil
+		}
+		return eventsources.EndState, nil
+	}
+
+	var state eventsources.SpanState
+	var ok bool
+	if l := ce.list(); l != nil {
+		state, ok = ce.listState(l.Name)
+
+		log.WithFields(log.Fields{
+			"prev_state": prev,
+			"list.name":  l.Name,
+			"workflow":   state,
+			"mapped":     ok,
+		}).Debugf("trello.CardEvent.State()")
+	}
+
+	if prev == nil && (!ok || state == eventsources.EndState) {
+		return eventsources.UnknownState, nil
+	}
+
+	if !ok {
+		return eventsources.IntermediaryState, nil
+	}
+
+	switch state {
+	case eventsources.StartState, eventsources.TransitionState:
+		if prev == nil {
+			return eventsources.StartState, nil
+		}
+		return eventsources.IntermediaryState, nil
+	case eventsources.EndState:
+		return eventsources.EndState, nil
+	}
+
+	return eventsources.IntermediaryState, nil
+}
+
+func (ce CardEvent) Tags() (map[string]interface{}, error) {
+	card := ce.Action.Data.Card
+
+	tags := make(map[string]interface{})
+	tags["service"] = sourceName
+	tags["user.username"] = ce.Action.MemberCreator.Username
+	tags["user.display_name"] = ce.Action.MemberCreator.FullName
+
+	tags["issue.id"] = card.ID
+	tags["issue.key"] = card.ShortLink
+	tags["issue.number"] = card.IDShort
+	tags["issue.title"] = card.Name
+	if card.ShortLink != "" {
+		tags["issue.url"] = "https://trello.com/c/" + card.ShortLink
+	}
+
+	tags["board.id"] = ce.Action.Data.Board.ID
+	tags["board.name"] = ce.Action.Data.Board.Name
+
+	if l := ce.list(); l != nil {
+		tags["issue.list.name"] = l.Name
+	}
+
+	return tags, nil
+}
+
+// EndTags record whether the card was archived or moved to a list which
+// ends it.
+func (ce CardEvent) EndTags() (map[string]interface{}, error) {
+	tags := map[string]interface{}{
+		"issue.archived": ce.archived(),
+	}
+	if l := ce.list(); l != nil {
+		tags["issue.list.name"] = l.Name
+	}
+	return tags, nil
+}
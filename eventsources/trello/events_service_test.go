@@ -0,0 +1,109 @@
+// +build service
+
+package trello
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/tracers"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+var baseURL string
+var trelloPath string
+
+var urlEnvVar = "TEST_EVENTS_URL"
+var trelloPathEnvVar = "TEST_EVENTS_TRELLO_PATH"
+
+func init() {
+	ok := true
+	baseURL, ok = os.LookupEnv(urlEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", urlEnvVar))
+	}
+	trelloPath, ok = os.LookupEnv(trelloPathEnvVar)
+	if !ok {
+		panic(fmt.Sprintf("requires: %q", trelloPathEnvVar))
+	}
+}
+
+func cardTags(overrides map[string]interface{}) map[string]interface{} {
+	tags := map[string]interface{}{
+		"board.id":          "5f1e0c2b8a4d3e1f2a3b4c5d",
+		"board.name":        "Product",
+		"error":             false,
+		"issue.archived":    false,
+		"issue.id":          "610790c1d9a4c4175a8e3b21",
+		"issue.key":         "Ab3dEf9H",
+		"issue.list.name":   "Done",
+		"issue.number":      float64(42),
+		"issue.title":       "Export lead time report as csv",
+		"issue.url":         "https://trello.com/c/Ab3dEf9H",
+		"service":           "trello",
+		"user.display_name": "Mona Lisa",
+		"user.username":     "monalisa",
+	}
+	for k, v := range overrides {
+		tags[k] = v
+	}
+	return tags
+}
+
+var eventTests = []struct {
+	Name          string
+	EventPaths    []string
+	ExpectedSpans int
+	ExpectedTags  map[string]interface{}
+}{
+	{
+		Name: "created_doing_done",
+		EventPaths: []string{
+			"fixtures/events/card_created.json",
+			"fixtures/events/card_moved_doing.json",
+			"fixtures/events/card_moved_done.json",
+		},
+		ExpectedSpans: 1,
+		ExpectedTags:  cardTags(nil),
+	},
+	{
+		Name: "doing_archived",
+		EventPaths: []string{
+			"fixtures/events/card_moved_doing.json",
+			"fixtures/events/card_archived.json",
+		},
+		ExpectedSpans: 1,
+		ExpectedTags: cardTags(map[string]interface{}{
+			"issue.archived":  true,
+			"issue.list.name": "Doing",
+		}),
+	},
+	{
+		Name: "done_untracked",
+		EventPaths: []string{
+			"fixtures/events/card_created.json",
+			"fixtures/events/card_moved_done.json",
+		},
+		ExpectedSpans: 0,
+	},
+}
+
+func TestServiceEvent_Trello(t *testing.T) {
+	for _, tt := range eventTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			tracers.ResetTracer(t, baseURL)
+
+			for _, eventPath := range tt.EventPaths {
+				tracers.PostFixture(t, baseURL, trelloPath, eventPath)
+			}
+
+			spans := tracers.FinishedSpans(t, baseURL)
+
+			assert.Equal(t, tt.ExpectedSpans, len(spans))
+			if len(spans) == 1 {
+				assert.Equal(t, "issue", spans[0].Span.OperationName)
+				assert.Equal(t, tt.ExpectedTags, spans[0].Tags)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package trello
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCardEvent_State(t *testing.T) {
+	started := eventsources.EventState(eventsources.StartState)
+
+	ws := DefaultWorkflows()
+	ws.Overrides = map[string]types.Workflow{
+		"Product": {
+			Statuses: map[string]eventsources.SpanState{
+				"Ready": eventsources.StartState,
+			},
+		},
+	}
+
+	card := &Card{ID: "1"}
+	moved := func(to string) Action {
+		return Action{
+			Type: actionUpdateCard,
+			Data: ActionData{
+				Card:       card,
+				Board:      Board{Name: "Product"},
+				ListBefore: &List{Name: "To Do"},
+				ListAfter:  &List{Name: to},
+				Old:        map[string]interface{}{"idList": "0"},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		action   Action
+		prev     *eventsources.EventState
+		expected eventsources.SpanState
+	}{
+		{"created_unmapped", Action{Type: actionCreateCard, Data: ActionData{Card: card, List: &List{Name: "To Do"}}}, nil, eventsources.UnknownState},
+		{"created_doing", Action{Type: actionCreateCard, Data: ActionData{Card: card, List: &List{Name: "Doing"}}}, nil, eventsources.StartState},
+		{"moved_board_list", moved("Ready"), nil, eventsources.StartState},
+		{"moved_doing", moved("Doing"), nil, eventsources.StartState},
+		{"moved_doing_started", moved("Doing"), &started, eventsources.IntermediaryState},
+		{"moved_done", moved("Done"), &started, eventsources.EndState},
+		{"moved_done_untracked", moved("Done"), nil, eventsources.UnknownState},
+		{"renamed", Action{Type: actionUpdateCard, Data: ActionData{Card: card, Old: map[string]interface{}{"name": "old"}}}, &started, eventsources.IntermediaryState},
+		{"archived", Action{Type: actionUpdateCard, Data: ActionData{Card: &Card{ID: "1", Closed: true}, Old: map[string]interface{}{"closed": false}}}, &started, eventsources.EndState},
+		{"archived_untracked", Action{Type: actionUpdateCard, Data: ActionData{Card: &Card{ID: "1", Closed: true}, Old: map[string]interface{}{"closed": false}}}, nil, eventsources.UnknownState},
+		{"restored", Action{Type: actionUpdateCard, Data: ActionData{Card: card, List: &List{Name: "Doing"}, Old: map[string]interface{}{"closed": true}}}, nil, eventsources.StartState},
+		{"deleted", Action{Type: actionDeleteCard, Data: ActionData{Card: card}}, &started, eventsources.EndState},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := CardEvent{Action: tt.action, workflows: ws}.State(tt.prev)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, s)
+		})
+	}
+}
+
+func TestCardEvent_SpanID(t *testing.T) {
+	id, err := CardEvent{Action: Action{Data: ActionData{Card: &Card{ID: "610790c1"}}}}.SpanID()
+	assert.NoError(t, err)
+	assert.Equal(t, "vstrace-trello-issue-610790c1", id)
+
+	_, err = CardEvent{}.SpanID()
+	assert.Error(t, err)
+}
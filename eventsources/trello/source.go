@@ -0,0 +1,129 @@
+package trello
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+	"github.com/opentracing/opentracing-go"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	sourceName string = "trello"
+
+	signatureHeader string = "X-Trello-Webhook"
+)
+
+var signature = eventsources.HMACSignature{
+	Hash:   sha1.New,
+	Header: signatureHeader,
+	Base64: true,
+}
+
+type Source struct {
+	tracer    opentracing.Tracer
+	secretKey []byte
+
+	// callbackURL is the url the webhook was registered with, it's part
+	// of the signed content.
+	callbackURL string
+	workflows   types.Workflows
+}
+
+func (s Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *Source) SecretKey() []byte {
+	return s.secretKey
+}
+
+// ValidatePayload checks the `X-Trello-Webhook` header, the base64 encoded
+// hmac-sha1 of the body followed by the callback url, signed with the
+// application's secret.
+// VerifiesCallback answers the HEAD request trello makes to the callback
+// url as the webhook is created.
+func (s *Source) VerifiesCallback() bool {
+	return true
+}
+
+func (s *Source) ValidatePayload(r *http.Request, secretKey []byte) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body")
+	}
+
+	if secretKey == nil {
+		return body, nil
+	}
+
+	message := append(append([]byte{}, body...), s.callback(r)...)
+	if err := signature.Validate(r, message, secretKey); err != nil {
+		return nil, eventsources.InvalidSignatureError{Err: err}
+	}
+
+	return body, nil
+}
+
+// callback is the configured callback url, or the url the request was
+// made to when there isn't one.
+func (s *Source) callback(r *http.Request) string {
+	if s.callbackURL != "" {
+		return s.callbackURL
+	}
+
+	scheme := "https"
+	if r.TLS == nil && r.Header.Get("X-Forwarded-Proto") == "http" {
+		scheme = "http"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+func (s *Source) Event(r *http.Request, payload []byte) (eventsources.Event, error) {
+	ce := CardEvent{
+		workflows: s.workflows,
+	}
+	if err := json.Unmarshal(payload, &ce); err != nil {
+		return nil, err
+	}
+
+	if ce.Action.Data.Card == nil {
+		return nil, fmt.Errorf("action type: %q, not supported", ce.Action.Type)
+	}
+
+	return ce, nil
+}
+
+func NewSource(tracer opentracing.Tracer, secretKey []byte, callbackURL string, workflows types.Workflows) (*Source, error) {
+	return &Source{
+		tracer:      tracer,
+		secretKey:   secretKey,
+		callbackURL: callbackURL,
+		workflows:   workflows,
+	}, nil
+}
+
+func NewFromCLI(c *cli.Context, tracer opentracing.Tracer) (eventsources.EventSource, error) {
+	var secretKey []byte
+	if secret := c.String("trello-secret"); secret != "" {
+		secretKey = []byte(secret)
+	}
+
+	workflows := DefaultWorkflows()
+	if path := c.String("trello-workflows"); path != "" {
+		var err error
+		if workflows, err = types.LoadWorkflows(path, "boards", DefaultWorkflows()); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewSource(tracer, secretKey, c.String("trello-callback-url"), workflows)
+}
@@ -0,0 +1,65 @@
+package trello
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestSource_ValidatePayload(t *testing.T) {
+	secretKey := []byte("secret")
+	callbackURL := "https://valuestream.example.com/trello"
+	body := []byte(`{"action": {"type": "createCard"}}`)
+
+	sign := func(url string) string {
+		mac := hmac.New(sha1.New, secretKey)
+		mac.Write(body)
+		mac.Write([]byte(url))
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	testCases := []struct {
+		name        string
+		signature   string
+		callbackURL string
+		secretKey   []byte
+		expectedErr bool
+	}{
+		{"signed", sign(callbackURL), callbackURL, secretKey, false},
+		{"signed_request_url", sign("https://valuestream.example.com/trello?board=product"), "", secretKey, false},
+		{"other_callback_url", sign("https://other.example.com/trello"), callbackURL, secretKey, true},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "https://valuestream.example.com/trello?board=product", bytes.NewReader(body))
+			assert.NoError(t, err)
+			if tt.signature != "" {
+				req.Header.Set(signatureHeader, tt.signature)
+			}
+
+			s, err := NewSource(nil, tt.secretKey, tt.callbackURL, DefaultWorkflows())
+			assert.NoError(t, err)
+
+			payload, err := s.ValidatePayload(req, s.SecretKey())
+			if tt.expectedErr {
+				assert.IsType(t, eventsources.InvalidSignatureError{}, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, body, payload)
+		})
+	}
+}
+
+func TestSource_Event_Unsupported(t *testing.T) {
+	s, err := NewSource(nil, nil, "", DefaultWorkflows())
+	assert.NoError(t, err)
+
+	_, err = s.Event(nil, []byte(`{"action": {"type": "updateList", "data": {"list": {"name": "Doing"}}}}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,26 @@
+package trello
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
+)
+
+const (
+	listDoing string = "Doing"
+	listDone  string = "Done"
+)
+
+// DefaultWorkflows maps the lists of trello's default board, by the name
+// of the list a card is created in or moved to.  Workflows files override
+// them per board, keyed by the board's short link or name.  Archiving or
+// deleting a card always ends its span.
+func DefaultWorkflows() types.Workflows {
+	return types.Workflows{
+		Default: types.Workflow{
+			Statuses: map[string]eventsources.SpanState{
+				listDoing: eventsources.TransitionState,
+				listDone:  eventsources.EndState,
+			},
+		},
+	}
+}
@@ -2,8 +2,26 @@ package types
 
 const (
 	IssueEventType       string = "issue"
+	IssueStatusEventType string = "issue_status"
 	PullRequestEventType string = "pull_request"
 	BuildEventType       string = "build"
+	PipelineEventType    string = "pipeline"
+	QueuedEventType      string = "queued"
+	JobEventType         string = "job"
+	ArtifactEventType    string = "artifact"
+	StageEventType       string = "stage"
 	DeployEventType      string = "deploy"
+	ReleaseEventType     string = "release"
 	SprintEventType      string = "sprint"
+
+	// An incident spans from when it was triggered until it was resolved,
+	// with children for the time it took to be acknowledged and then
+	// resolved once acknowledged.
+	IncidentEventType        string = "incident"
+	IncidentAckEventType     string = "incident_ack"
+	IncidentResolveEventType string = "incident_resolve"
+
+	// A defect spans from when an error was first seen in production
+	// until it was resolved or ignored.
+	DefectEventType string = "defect"
 )
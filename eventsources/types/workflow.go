@@ -0,0 +1,118 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"io/ioutil"
+)
+
+// Workflow maps the statuses of an issue tracker onto the lifecycle of an
+// issue span.  A status is looked up by its name first and then by its
+// category, ie jira's status category or linear's state type, and can be
+// mapped to:
+//   - start: work on the issue begins, its span is started
+//   - transition: the issue moves between working statuses, its span is
+//     started if it isn't already
+//   - end: work on the issue is finished, its span is ended
+//
+// Statuses that aren't mapped leave the issue span untouched.
+type Workflow struct {
+	Statuses   map[string]eventsources.SpanState `json:"statuses"`
+	Categories map[string]eventsources.SpanState `json:"categories"`
+}
+
+func (w Workflow) State(status, category string) (eventsources.SpanState, bool) {
+	if s, ok := w.Statuses[status]; ok {
+		return s, true
+	}
+	s, ok := w.Categories[category]
+	return s, ok
+}
+
+func (w Workflow) Validate() error {
+	for _, mapping := range []map[string]eventsources.SpanState{w.Statuses, w.Categories} {
+		for status, s := range mapping {
+			switch s {
+			case eventsources.StartState, eventsources.TransitionState, eventsources.EndState:
+			default:
+				return fmt.Errorf("status: %q mapped to unsupported state: %q", status, s)
+			}
+		}
+	}
+	return nil
+}
+
+func (w Workflow) empty() bool {
+	return len(w.Statuses) == 0 && len(w.Categories) == 0
+}
+
+// Workflows holds the Default workflow along with the Overrides of the
+// projects, boards or teams whose statuses differ, keyed by their key or
+// name.  Statuses missing from an override fall back to the Default.
+type Workflows struct {
+	Default   Workflow
+	Overrides map[string]Workflow
+}
+
+// State maps the status by the first override of the keys which maps it,
+// ie a board's short link and then its name, or else the Default.
+func (ws Workflows) State(status, category string, keys ...string) (eventsources.SpanState, bool) {
+	for _, key := range keys {
+		if w, ok := ws.Overrides[key]; ok {
+			if s, ok := w.State(status, category); ok {
+				return s, true
+			}
+		}
+	}
+	return ws.Default.State(status, category)
+}
+
+func (ws Workflows) Validate() error {
+	if err := ws.Default.Validate(); err != nil {
+		return err
+	}
+	for key, w := range ws.Overrides {
+		if err := w.Validate(); err != nil {
+			return fmt.Errorf("%q: %s", key, err)
+		}
+	}
+	return nil
+}
+
+// LoadWorkflows reads workflows from a json file holding the `default`
+// workflow and the overrides under the scope, ie `projects`.  The
+// defaults' Default workflow is used when the file doesn't specify one.
+func LoadWorkflows(path string, scope string, defaults Workflows) (Workflows, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Workflows{}, err
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(bs, &doc); err != nil {
+		return Workflows{}, err
+	}
+
+	var ws Workflows
+	if raw, ok := doc["default"]; ok {
+		if err := json.Unmarshal(raw, &ws.Default); err != nil {
+			return Workflows{}, err
+		}
+	}
+	if raw, ok := doc[scope]; ok {
+		if err := json.Unmarshal(raw, &ws.Overrides); err != nil {
+			return Workflows{}, err
+		}
+	}
+
+	if ws.Default.empty() {
+		ws.Default = defaults.Default
+	}
+
+	if err := ws.Validate(); err != nil {
+		return Workflows{}, err
+	}
+
+	return ws, nil
+}
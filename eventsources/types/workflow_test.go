@@ -0,0 +1,134 @@
+package types
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testDefaults() Workflows {
+	return Workflows{
+		Default: Workflow{
+			Statuses: map[string]eventsources.SpanState{
+				"Selected for Development": eventsources.StartState,
+			},
+			Categories: map[string]eventsources.SpanState{
+				"indeterminate": eventsources.TransitionState,
+				"done":          eventsources.EndState,
+			},
+		},
+	}
+}
+
+func TestWorkflows_State(t *testing.T) {
+	ws := testDefaults()
+	ws.Overrides = map[string]Workflow{
+		"TP": {
+			Statuses: map[string]eventsources.SpanState{
+				"Code Review":     eventsources.TransitionState,
+				"Ready to Deploy": eventsources.EndState,
+			},
+		},
+		"Product": {
+			Statuses: map[string]eventsources.SpanState{
+				"Ready to Deploy": eventsources.StartState,
+			},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		keys     []string
+		status   string
+		category string
+		expected eventsources.SpanState
+		ok       bool
+	}{
+		{"default_status", []string{"TP"}, "Selected for Development", "new", eventsources.StartState, true},
+		{"override_status", []string{"TP"}, "Ready to Deploy", "indeterminate", eventsources.EndState, true},
+		{"override_status_other_key", []string{"OTHER"}, "Ready to Deploy", "indeterminate", eventsources.TransitionState, true},
+		{"first_override_key", []string{"qKz3xY7a", "Product", "TP"}, "Ready to Deploy", "", eventsources.StartState, true},
+		{"default_category", []string{"TP"}, "QA", "done", eventsources.EndState, true},
+		{"no_keys", nil, "Code Review", "", "", false},
+		{"unmapped", []string{"TP"}, "To Do", "new", "", false},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			state, ok := ws.State(tt.status, tt.category, tt.keys...)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, state)
+		})
+	}
+}
+
+func TestLoadWorkflows(t *testing.T) {
+	dir, err := ioutil.TempDir("", "workflows")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		name     string
+		contents string
+		err      bool
+		check    func(t *testing.T, ws Workflows)
+	}{
+		{
+			"override_only_uses_default",
+			`{"projects": {"TP": {"statuses": {"QA": "transition"}}}}`,
+			false,
+			func(t *testing.T, ws Workflows) {
+				assert.Equal(t, testDefaults().Default, ws.Default)
+				assert.Equal(t, eventsources.TransitionState, ws.Overrides["TP"].Statuses["QA"])
+			},
+		},
+		{
+			"other_scope_ignored",
+			`{"boards": {"TP": {"statuses": {"QA": "transition"}}}}`,
+			false,
+			func(t *testing.T, ws Workflows) {
+				assert.Empty(t, ws.Overrides)
+			},
+		},
+		{
+			"default_replaced",
+			`{"default": {"categories": {"new": "start"}}}`,
+			false,
+			func(t *testing.T, ws Workflows) {
+				assert.Equal(t, Workflow{
+					Categories: map[string]eventsources.SpanState{
+						"new": eventsources.StartState,
+					},
+				}, ws.Default)
+			},
+		},
+		{
+			"unsupported_state",
+			`{"projects": {"TP": {"statuses": {"QA": "intermediary"}}}}`,
+			true,
+			nil,
+		},
+		{
+			"invalid_json",
+			`{"default": `,
+			true,
+			nil,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".json")
+			assert.NoError(t, ioutil.WriteFile(path, []byte(tt.contents), 0644))
+
+			ws, err := LoadWorkflows(path, "projects", testDefaults())
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			tt.check(t, ws)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package webhooks
+
+import (
+	"context"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/traces"
+	"github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+	log "github.com/sirupsen/logrus"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"time"
+)
+
+// EnvironmentTag is the tag deploys are started with which names the
+// environment they deploy to.
+const EnvironmentTag string = "deploy.environment"
+
+var (
+	deployService, _ = tag.NewKey("service")
+
+	// Records the time from each pull request being opened to a deploy
+	// shipping it finishing successfully.
+	LeadTimeToProductionMs = stats.Float64(
+		"webhooks/deploy/lead_time",
+		"The lead time to production of pull requests in milliseconds",
+		"ms",
+	)
+
+	LeadTimeToProductionView = &view.View{
+		Name:        "webhooks/deploy/lead_time",
+		Description: "Lead time to production of pull requests",
+		TagKeys:     []tag.Key{eventSource, deployService},
+		Measure:     LeadTimeToProductionMs,
+		Aggregation: EventLatencyView.Aggregation,
+	}
+)
+
+// shipment is the commit a deploy ships of a service to an environment.
+type shipment struct {
+	service     string
+	environment string
+	sha         string
+}
+
+// shipmentOf a deploy, false when it doesn't know its service or commit.
+func shipmentOf(e eventsources.Event, tags map[string]interface{}) (shipment, bool) {
+	var s shipment
+
+	se, ok := e.(eventsources.ServiceEvent)
+	if !ok {
+		return s, false
+	}
+	s.service = se.ServiceName()
+
+	if re, ok := e.(eventsources.RevisionEvent); ok {
+		s.sha = re.Revision()
+	}
+	for _, t := range []string{traces.RevisionTag, "scm.commit.sha"} {
+		if sha, ok := tags[t].(string); ok && s.sha == "" {
+			s.sha = sha
+		}
+	}
+
+	s.environment, _ = tags[EnvironmentTag].(string)
+
+	return s, s.service != "" && s.sha != ""
+}
+
+// containedChanges are the pull requests a deploy ships: those of the
+// commits since its service was last deployed successfully to the
+// environment.
+func (wh *Webhook) containedChanges(s shipment) []traces.Change {
+	if wh.Changes == nil || wh.Revisions == nil || wh.Deploys == nil {
+		return nil
+	}
+	return wh.Changes.Between(
+		wh.Revisions,
+		wh.Deploys.Shipped(s.service, s.environment),
+		s.sha,
+	)
+}
+
+// appendContained references the changes a deploy contains, unless the
+// deploy already references them.  The context of changes loaded from a
+// path is extracted by the deploy's tracer.
+func appendContained(tracer opentracing.Tracer, refs []tracedReference, changes []traces.Change) []tracedReference {
+	referenced := make(map[string]bool)
+	for _, ref := range refs {
+		if ref.SpanID != "" {
+			referenced[ref.SpanID] = true
+		}
+	}
+
+	for _, change := range changes {
+		if referenced[change.SpanID] {
+			continue
+		}
+		ctx, err := change.SpanContext(tracer)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":   err.Error(),
+				"span_id": change.SpanID,
+			}).Warnf("webhooks.appendContained unable to extract change")
+			continue
+		}
+		refs = append(refs, tracedReference{
+			SpanContext:  ctx,
+			Relationship: eventsources.ContainsRelationship,
+			SpanID:       change.SpanID,
+		})
+	}
+	return refs
+}
+
+// recordShipped records the lead time to production of each pull request
+// a successful deploy shipped, on its span and as a metric, and remembers
+// the commit it shipped for the service's next deploy.
+func (wh *Webhook) recordShipped(ctx context.Context, e eventsources.Event, tags map[string]interface{}, span opentracing.Span, finishedAt time.Time) error {
+	s, ok := shipmentOf(e, tags)
+	if !ok || wh.Deploys == nil {
+		return nil
+	}
+
+	ctx, err := tag.New(ctx,
+		tag.Insert(deployService, s.service),
+	)
+	if err != nil {
+		return err
+	}
+
+	changes := wh.containedChanges(s)
+	for _, change := range changes {
+		leadTime := finishedAt.Sub(change.StartTime)
+		stats.Record(ctx, LeadTimeToProductionMs.M(float64(leadTime.Nanoseconds()/1e6)))
+		span.LogFields(
+			otlog.String("event", "shipped"),
+			otlog.String("change", change.SpanID),
+			otlog.Int64("lead_time_ms", leadTime.Nanoseconds()/1e6),
+		)
+	}
+	if len(changes) > 0 {
+		span.SetTag("deploy.changes.count", len(changes))
+	}
+
+	wh.Deploys.SetShipped(s.service, s.environment, s.sha)
+	return nil
+}
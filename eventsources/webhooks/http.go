@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
 	"github.com/ImpactInsights/valuestream/traces"
 	"github.com/opentracing/opentracing-go"
 	log "github.com/sirupsen/logrus"
@@ -13,6 +14,8 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -56,6 +59,22 @@ var (
 		Aggregation: view.Count(),
 	}
 
+	// Counts requests which failed EventSource validation, ie because
+	// their signature or token did not match the configured secret.
+	RequestRejectedCount = stats.Int64(
+		"webhooks/request/rejected/total",
+		"Number of requests rejected",
+		stats.UnitDimensionless,
+	)
+
+	RequestRejectedCountView = &view.View{
+		Name:        "webhooks/request/rejected/total",
+		Description: "Number of requests rejected",
+		TagKeys:     []tag.Key{eventSource},
+		Measure:     RequestRejectedCount,
+		Aggregation: view.Count(),
+	}
+
 	EventLatencyMs = stats.Float64(
 		"webhooks/event/duration",
 		"The latency in milliseconds",
@@ -105,6 +124,35 @@ type Webhook struct {
 	EventSource eventsources.EventSource
 	Tracers     Tracers
 	Spans       traces.SpanStore
+	// Revisions, when set, links events which only know their commit to
+	// the span which was started for it.  It's shared between sources.
+	Revisions *traces.Revisions
+	// Deploys, when set, links events concerning a service, ie incidents,
+	// to the most recent deploy of it.  It's shared between sources.
+	Deploys *traces.Deploys
+	// Relationships, when set, decides which references of events their
+	// spans are nested beneath, rather than the DefaultRelationships.
+	Relationships RelationshipModel
+	// Changes, when set, links deploys to the pull requests they ship and
+	// records their lead time to production.  It needs Revisions and
+	// Deploys, and is shared between sources.
+	Changes *traces.Changes
+	// Placeholders, when set, starts placeholder spans for the parents of
+	// events which haven't been traced, rather than losing the link to
+	// them.  It's shared between sources.
+	Placeholders *Placeholders
+	// TimedSpans starts and finishes spans at the times their events'
+	// Timings report, rather than when the events are handled, for
+	// sources which deliver events after the fact, ie a build's queued
+	// time reported once it has started.
+	TimedSpans bool
+}
+
+func (wh Webhook) relationships() RelationshipModel {
+	if wh.Relationships == nil {
+		return DefaultRelationships()
+	}
+	return wh.Relationships
 }
 
 // secretKey inspects the request for a contexted define key
@@ -124,6 +172,13 @@ func (wh *Webhook) Handler(w http.ResponseWriter, r *http.Request) {
 	var err error
 	var e eventsources.Event
 
+	// sources such as trello verify their callback url with a HEAD
+	// request before sending any events to it
+	if cs, ok := wh.EventSource.(eventsources.CallbackSource); ok && r.Method == http.MethodHead && cs.VerifiesCallback() {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	secretKey := wh.secretKey(r)
 
 	if payload, err = wh.EventSource.ValidatePayload(r, secretKey); err != nil {
@@ -131,6 +186,13 @@ func (wh *Webhook) Handler(w http.ResponseWriter, r *http.Request) {
 			"error":   err.Error(),
 			"payload": payload,
 		}).Errorf("unable to validate request")
+
+		if _, ok := err.(eventsources.InvalidSignatureError); ok {
+			wh.recordRejected(r.Context())
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
 		http.Error(w, "error", http.StatusBadRequest)
 		return
 	}
@@ -166,9 +228,42 @@ func (wh *Webhook) Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	handled(r.Context(), e)
+
 	w.Write([]byte("success"))
 }
 
+// HandleEvent traces an event which wasn't sent to the Handler, ie a
+// change a watcher observed, with the source's tracer.
+func (wh *Webhook) HandleEvent(ctx context.Context, e eventsources.Event) error {
+	if err := wh.handleEvent(ctx, wh.EventSource.Tracer(), e); err != nil {
+		return err
+	}
+	handled(ctx, e)
+	return nil
+}
+
+// handled lets events which update their source know they were traced.
+func handled(ctx context.Context, e eventsources.Event) {
+	if he, ok := e.(eventsources.HandledEvent); ok {
+		he.Handled(ctx)
+	}
+}
+
+func (wh *Webhook) recordRejected(ctx context.Context) {
+	ctx, err := tag.New(ctx,
+		tag.Insert(eventSource, wh.EventSource.Name()),
+	)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Errorf("webhooks.recordRejected")
+		return
+	}
+
+	stats.Record(ctx, RequestRejectedCount.M(1))
+}
+
 func (wh *Webhook) handleStartEvent(ctx context.Context, tracer opentracing.Tracer, e eventsources.Event) error {
 	ctx, err := tag.New(ctx,
 		tag.Insert(eventSource, wh.EventSource.Name()),
@@ -180,55 +275,375 @@ func (wh *Webhook) handleStartEvent(ctx context.Context, tracer opentracing.Trac
 
 	stats.Record(ctx, EventStartCount.M(1))
 
-	// check to see if this event has a parent span
-	parentID, err := e.ParentSpanID()
+	tags, err := e.Tags()
 	if err != nil {
 		return err
 	}
 
-	opts := make([]opentracing.StartSpanOption, 0)
+	spanID, err := e.SpanID()
+	if err != nil {
+		return err
+	}
 
-	// if it does than make sure to establish the ChildOf relationship
-	if parentID != nil {
-		entry, err := wh.Spans.Get(ctx, tracer, *parentID)
-		if err != nil {
-			return err
-		}
+	// a placeholder started for this event's span by one of its children
+	// is adopted rather than started again
+	placeholder, err := wh.placeholderOf(ctx, tracer, spanID)
+	if err != nil {
+		return err
+	}
 
-		if entry != nil {
-			opts = append(opts, opentracing.ChildOf(entry.Span.Context()))
-		}
+	timings, err := e.Timings()
+	if err != nil {
+		return err
 	}
 
-	// Actually start the span
-	span := tracer.StartSpan(
-		e.OperationName(),
-		opts...,
-	)
+	// events of timed sources, which know when they started, ie because
+	// they're received after the fact, are started at that time
+	var startTime *time.Time
+	if wh.TimedSpans {
+		startTime = timings.StartTime
+	}
 
-	// Tag the span with all information present
-	tags, err := e.Tags()
+	// check to see if this event has parent spans
+	parents, err := wh.parentSpans(ctx, tracer, e, spanID, tags, timings)
 	if err != nil {
 		return err
 	}
 
+	isDeploy := e.OperationName() == types.DeployEventType
+
+	if !isDeploy {
+		if deploy := wh.deployOf(e); deploy != nil {
+			parents = append(parents, tracedReference{
+				SpanContext:  deploy,
+				Relationship: eventsources.FollowsRelationship,
+			})
+		}
+	}
+
+	// deploys contain the pull requests they ship
+	if s, ok := shipmentOf(e, tags); ok && isDeploy {
+		parents = appendContained(tracer, parents, wh.containedChanges(s))
+	}
+
+	var span opentracing.Span
+	if placeholder != nil && adoptable(*placeholder, parents, startTime) {
+		span = adoptSynthetic(e, *placeholder)
+	} else {
+		// if it does than make sure to establish the ChildOf relationship
+		// with the first which the relationship model nests spans beneath,
+		// the span follows from the rest
+		opts := wh.relationships().StartSpanOptions(parents)
+
+		// a placeholder which can't be adopted keeps its children, the
+		// event's span follows from it
+		if placeholder != nil {
+			opts = append(opts, opentracing.FollowsFrom(placeholder.Span.Context()))
+		}
+
+		if startTime != nil {
+			opts = append(opts, opentracing.StartTime(*startTime))
+		}
+
+		// Actually start the span
+		span = tracer.StartSpan(
+			e.OperationName(),
+			opts...,
+		)
+
+		if placeholder != nil {
+			placeholder.Span.Finish()
+		}
+	}
+
+	if e.OperationName() == types.PullRequestEventType && wh.Changes != nil {
+		started := time.Now().UTC()
+		if timings.StartTime != nil {
+			started = *timings.StartTime
+		}
+		// the context is carried as a text map too, which is what's saved
+		// of it
+		carrier := opentracing.TextMapCarrier{}
+		if err := tracer.Inject(span.Context(), opentracing.TextMap, carrier); err != nil {
+			log.WithFields(log.Fields{
+				"error":   err.Error(),
+				"span_id": spanID,
+			}).Warnf("webhooks.handleStartEvent unable to inject change")
+		}
+		wh.Changes.Set(traces.Change{
+			SpanID:    spanID,
+			Context:   span.Context(),
+			Carrier:   carrier,
+			StartTime: started,
+		})
+	}
+
+	// Tag the span with all information present
 	for k, v := range tags {
 		span.SetTag(k, v)
 	}
 
 	// else we need to just set the span for future events
-	spanID, err := e.SpanID()
-	if err != nil {
-		return err
+	if wh.Revisions != nil {
+		wh.Revisions.SetTags(tags, spanID, e.OperationName())
 	}
 
-	if err := wh.Spans.Set(ctx, spanID, traces.NewStoreEntryFromSpan(span)); err != nil {
+	if isDeploy && wh.Deploys != nil {
+		wh.setDeploy(e, tags, span.Context())
+	}
+
+	entry := traces.NewStoreEntryFromSpan(span)
+	if placeholder != nil && span == placeholder.Span {
+		entry.CreatedAt = placeholder.CreatedAt
+	}
+	started := eventsources.EventState(eventsources.StartState)
+	entry.State = &started
+
+	if err := wh.Spans.Set(ctx, spanID, entry); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// placeholderOf takes the placeholder started for the span id, if any.
+func (wh *Webhook) placeholderOf(ctx context.Context, tracer opentracing.Tracer, spanID string) (*traces.StoreEntry, error) {
+	if wh.Placeholders != nil {
+		return wh.Placeholders.take(ctx, wh.Spans, tracer, spanID)
+	}
+
+	entry, err := wh.Spans.Get(ctx, tracer, spanID)
+	if err != nil || entry == nil || !entry.Synthetic {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// adoptable placeholders can be made the event's own span.  Spans can't
+// be re-parented or moved once they're started, so a placeholder is only
+// adopted when the event has no parents of its own and didn't start before
+// it.
+func adoptable(placeholder traces.StoreEntry, parents []tracedReference, startTime *time.Time) bool {
+	if len(parents) > 0 {
+		return false
+	}
+	return startTime == nil || !startTime.Before(placeholder.CreatedAt)
+}
+
+// adoptSynthetic makes the placeholder span started for an event's span
+// the event's own, its children remain beneath it.
+func adoptSynthetic(e eventsources.Event, placeholder traces.StoreEntry) opentracing.Span {
+	placeholder.Span.SetOperationName(e.OperationName())
+	placeholder.Span.SetTag(traces.SyntheticTag, false)
+	return placeholder.Span
+}
+
+// startSynthetic starts a placeholder span for a parent which hasn't been
+// traced, ie an issue opened before valuestream was installed, so its
+// children aren't lost.  It's adopted when the parent's own event
+// arrives.  Only the span ids of the sources Placeholders are configured
+// for are placeheld, their operation is taken from the id.  Sources which
+// can look up when the parent was created place the placeholder at that
+// time.
+func (wh *Webhook) startSynthetic(ctx context.Context, tracer opentracing.Tracer, parentID string, timings eventsources.EventTimings) (*traces.StoreEntry, error) {
+	parts := strings.SplitN(parentID, "-", 4)
+	if len(parts) < 4 || parts[0] != eventsources.TracePrefix {
+		return nil, nil
+	}
+
+	source, ok := wh.Placeholders.source(parts[1])
+	if !ok {
+		return nil, nil
+	}
+
+	var startTime *time.Time
+	if wh.TimedSpans {
+		startTime = timings.StartTime
+	}
+
+	if cs, ok := source.(eventsources.CreatedSource); ok {
+		created, err := cs.CreatedAt(ctx, parentID)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":   err.Error(),
+				"span_id": parentID,
+			}).Warnf("webhooks.startSynthetic unable to look up created time")
+		}
+		if created != nil {
+			startTime = created
+		}
+	}
+
+	opts := make([]opentracing.StartSpanOption, 0)
+	if startTime != nil {
+		opts = append(opts, opentracing.StartTime(*startTime))
+	}
+
+	span := tracer.StartSpan(parts[2], opts...)
+	span.SetTag(traces.SyntheticTag, true)
+	span.SetTag("service", parts[1])
+
+	// the state is left unset so the parent's first event is handled as
+	// if its span was never started
+	entry := traces.NewStoreEntryFromSpan(span)
+	if startTime != nil {
+		entry.CreatedAt = *startTime
+	}
+	entry.Synthetic = true
+
+	if err := wh.Spans.Set(ctx, parentID, entry); err != nil {
+		return nil, err
+	}
+
+	wh.Placeholders.add(parentID, time.Now().UTC())
+
+	return &entry, nil
+}
+
+// tracedReference is a span being traced which an event's span relates
+// to.
+type tracedReference struct {
+	opentracing.SpanContext
+	Relationship eventsources.Relationship
+	// SpanID is empty when the span isn't being traced, ie a deploy
+	SpanID string
+}
+
+// parentSpans are the spans being traced which an event references.  When
+// none of them are, the event is placed beneath the first span being
+// traced of those started for the commits and branches it refers to, ie a
+// build beneath the pull request of its commit, or else placeholders are
+// started for its references when Placeholders are set.
+func (wh *Webhook) parentSpans(ctx context.Context, tracer opentracing.Tracer, e eventsources.Event, spanID string, tags map[string]interface{}, timings eventsources.EventTimings) ([]tracedReference, error) {
+	refs, err := e.References()
+	if err != nil {
+		return nil, err
+	}
+
+	var parents []tracedReference
+	var missing []eventsources.Reference
+	for _, ref := range refs {
+		entry, err := wh.Spans.Get(ctx, tracer, ref.SpanID)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			parents = append(parents, tracedReference{entry.Span.Context(), ref.Relationship, ref.SpanID})
+			continue
+		}
+		missing = append(missing, ref)
+	}
+
+	if len(parents) == 0 && wh.Revisions != nil && revisionOperations[e.OperationName()] {
+		parentID, parent, err := wh.revisionParent(ctx, tracer, e, spanID, tags)
+		if err != nil {
+			return nil, err
+		}
+		if parent != nil {
+			relationship := eventsources.ChildOfRelationship
+			if e.OperationName() == types.DeployEventType {
+				relationship = eventsources.DeploysRelationship
+			}
+			return []tracedReference{{parent, relationship, parentID}}, nil
+		}
+	}
+
+	if wh.Placeholders == nil {
+		return parents, nil
+	}
+
+	for _, ref := range missing {
+		entry, err := wh.startSynthetic(ctx, tracer, ref.SpanID, timings)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			parents = append(parents, tracedReference{entry.Span.Context(), ref.Relationship, ref.SpanID})
+		}
+	}
+
+	return parents, nil
+}
+
+// revisionOperations are placed beneath the spans started for their
+// commits and branches when they don't refer to a span being traced.  The
+// rest aren't, as a pull request or issue isn't caused by an earlier build
+// of its branch.
+var revisionOperations = map[string]bool{
+	types.BuildEventType:    true,
+	types.PipelineEventType: true,
+	types.JobEventType:      true,
+	types.StageEventType:    true,
+	types.ArtifactEventType: true,
+	types.DeployEventType:   true,
+}
+
+// revisionParent is the first span being traced of those started for the
+// commits and branches an event is for, preferring the pull requests and
+// issues of them.
+func (wh *Webhook) revisionParent(ctx context.Context, tracer opentracing.Tracer, e eventsources.Event, spanID string, tags map[string]interface{}) (string, opentracing.SpanContext, error) {
+	var commits []string
+	if re, ok := e.(eventsources.RevisionEvent); ok {
+		commits = append(commits, re.Revision())
+	}
+	candidates := wh.Revisions.TagSpans(commits, tags, types.PullRequestEventType, types.IssueEventType)
+
+	for _, id := range candidates {
+		if id == spanID {
+			continue
+		}
+		entry, err := wh.Spans.Get(ctx, tracer, id)
+		if err != nil {
+			return "", nil, err
+		}
+		if entry != nil {
+			return id, entry.Span.Context(), nil
+		}
+	}
+
+	return "", nil, nil
+}
+
+// deployOf finds the deploy an event follows from: the deploy of the
+// release a defect was observed in, or else the most recent deploy of the
+// service an incident or defect affects to the environment it affects.
+func (wh *Webhook) deployOf(e eventsources.Event) opentracing.SpanContext {
+	if wh.Deploys == nil {
+		return nil
+	}
+
+	if re, ok := e.(eventsources.ReleaseEvent); ok {
+		for _, release := range re.Releases() {
+			if deploy := wh.Deploys.GetRevision(release); deploy != nil {
+				return deploy
+			}
+		}
+	}
+
+	if ie, ok := e.(eventsources.IncidentEvent); ok {
+		return wh.Deploys.Get(ie.ServiceName(), ie.Environment())
+	}
+
+	return nil
+}
+
+// setDeploy remembers a deploy by the service and environment it deployed
+// to, and the revision it shipped.
+func (wh *Webhook) setDeploy(e eventsources.Event, tags map[string]interface{}, ctx opentracing.SpanContext) {
+	if se, ok := e.(eventsources.ServiceEvent); ok {
+		environment, _ := tags[EnvironmentTag].(string)
+		wh.Deploys.Set(se.ServiceName(), environment, ctx)
+	}
+
+	if re, ok := e.(eventsources.RevisionEvent); ok {
+		wh.Deploys.SetRevision(re.Revision(), ctx)
+	}
+
+	if sha, ok := tags[traces.RevisionTag].(string); ok {
+		wh.Deploys.SetRevision(sha, ctx)
+	}
+}
+
 func (wh *Webhook) handleEndEvent(ctx context.Context, tracer opentracing.Tracer, e eventsources.Event) error {
 	isE, err := e.IsError()
 	if err != nil {
@@ -262,9 +677,19 @@ func (wh *Webhook) handleEndEvent(ctx context.Context, tracer opentracing.Tracer
 		}
 	}
 
+	timings, err := e.Timings()
+	if err != nil {
+		return err
+	}
+
+	if timings.Duration == nil && timings.StartTime != nil && timings.EndTime != nil {
+		d := timings.EndTime.Sub(*timings.StartTime)
+		timings.Duration = &d
+	}
+
 	// If there's timing on the event than this should be treated as the
 	// "source-of-truth" since it comes from the event source
-	if timings, err := e.Timings(); err != nil && timings.Duration != nil {
+	if timings.Duration != nil {
 		stats.Record(ctx, EventLatencyMs.M(float64(timings.Duration.Nanoseconds()/1e6)))
 	} else {
 		// there's no timing, we're unable to parse the timing or .... ?
@@ -272,9 +697,46 @@ func (wh *Webhook) handleEndEvent(ctx context.Context, tracer opentracing.Tracer
 		stats.Record(ctx, EventLatencyMs.M(float64(entry.Duration().Nanoseconds()/1e6)))
 	}
 
-	// TODO add tags on end event
+	if et, ok := e.(eventsources.EndTagger); ok {
+		tags, err := et.EndTags()
+		if err != nil {
+			return err
+		}
+		for k, v := range tags {
+			entry.Span.SetTag(k, v)
+		}
+	}
+
 	entry.Span.SetTag("error", isE)
-	entry.Span.Finish()
+
+	// events learn of commits as their spans end, ie the merge commit of
+	// a pull request, or the commit a deploy shipped
+	tags, err := e.Tags()
+	if err != nil {
+		return err
+	}
+
+	if wh.Revisions != nil {
+		wh.Revisions.SetTags(tags, spanID, e.OperationName())
+	}
+
+	if e.OperationName() == types.DeployEventType && !isE {
+		finishedAt := time.Now().UTC()
+		if timings.EndTime != nil {
+			finishedAt = *timings.EndTime
+		}
+		if err := wh.recordShipped(ctx, e, tags, entry.Span, finishedAt); err != nil {
+			return err
+		}
+	}
+
+	if wh.TimedSpans && timings.EndTime != nil {
+		entry.Span.FinishWithOptions(opentracing.FinishOptions{
+			FinishTime: *timings.EndTime,
+		})
+	} else {
+		entry.Span.Finish()
+	}
 
 	if err := wh.Spans.Delete(ctx, spanID); err != nil {
 		return err
@@ -311,11 +773,40 @@ func (wh *Webhook) handleEvent(ctx context.Context, tracer opentracing.Tracer, e
 		return err
 	}
 
+	if state == eventsources.UnknownState {
+		return nil
+	}
+
+	var children []eventsources.Event
+	if pe, ok := e.(eventsources.ParentEvent); ok {
+		if children, err = pe.Children(); err != nil {
+			return err
+		}
+	}
+
+	// children need their parent's span to start beneath it, and
+	// should be finished before their parent is
 	switch state {
-	case eventsources.StartState:
-		return wh.handleStartEvent(ctx, tracer, e)
 	case eventsources.EndState:
+		if err := wh.handleEvents(ctx, tracer, children); err != nil {
+			return err
+		}
+		return wh.handleEndEvent(ctx, tracer, e)
+	case eventsources.CompleteState:
+		if err := wh.handleStartEvent(ctx, tracer, e); err != nil {
+			return err
+		}
+		if err := wh.handleEvents(ctx, tracer, children); err != nil {
+			return err
+		}
 		return wh.handleEndEvent(ctx, tracer, e)
+	}
+
+	switch state {
+	case eventsources.StartState:
+		if err := wh.handleStartEvent(ctx, tracer, e); err != nil {
+			return err
+		}
 	case eventsources.TransitionState:
 		// handleTransitionEvent(ctx, tracer, e)
 		if err := wh.handleEndEvent(ctx, tracer, e); err != nil {
@@ -323,8 +814,19 @@ func (wh *Webhook) handleEvent(ctx context.Context, tracer opentracing.Tracer, e
 				"error": err.Error(),
 			}).Errorf("webhooks.handleEvent")
 		}
-		return wh.handleStartEvent(ctx, tracer, e)
+		if err := wh.handleStartEvent(ctx, tracer, e); err != nil {
+			return err
+		}
 	}
 
+	return wh.handleEvents(ctx, tracer, children)
+}
+
+func (wh *Webhook) handleEvents(ctx context.Context, tracer opentracing.Tracer, events []eventsources.Event) error {
+	for _, e := range events {
+		if err := wh.handleEvent(ctx, tracer, e); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -3,14 +3,22 @@ package webhooks
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"github.com/ImpactInsights/valuestream/eventsources"
+	vsgithub "github.com/ImpactInsights/valuestream/eventsources/github"
+	"github.com/ImpactInsights/valuestream/eventsources/jenkins"
+	"github.com/ImpactInsights/valuestream/eventsources/types"
 	"github.com/ImpactInsights/valuestream/tracers"
 	"github.com/ImpactInsights/valuestream/traces"
+	"github.com/google/go-github/github"
+	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/stretchr/testify/assert"
+	"go.opencensus.io/stats/view"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestWebhook_secretKey(t *testing.T) {
@@ -132,6 +140,826 @@ func TestWebhook_handleStartEvent_WithTrace_Success(t *testing.T) {
 	assert.Equal(t, e.OperationName(), entry.Span.(*mocktracer.MockSpan).OperationName)
 }
 
+func TestWebhook_handleEvent_Children(t *testing.T) {
+	tracer := mocktracer.New()
+
+	wh := &Webhook{
+		Spans: traces.NewMemoryUnboundedSpanStore(),
+		EventSource: eventsources.StubEventSource{
+			TracerReturn: tracer,
+		},
+	}
+
+	parentID := "span-test-parent"
+	parent := func(state eventsources.SpanState) eventsources.StubParentEvent {
+		return eventsources.StubParentEvent{
+			StubEvent: eventsources.StubEvent{
+				OperationNameReturn: "parent",
+				SpanIDReturn:        parentID,
+				StateReturn:         state,
+			},
+			ChildrenReturn: []eventsources.Event{
+				eventsources.StubEvent{
+					OperationNameReturn: "child",
+					SpanIDReturn:        "span-test-child",
+					ParentSpanIDReturn:  &parentID,
+					StateReturn:         state,
+				},
+			},
+		}
+	}
+
+	ctx := context.Background()
+
+	assert.NoError(t, wh.handleEvent(ctx, tracer, parent(eventsources.UnknownState)))
+	numSpans, _ := wh.Spans.Count()
+	assert.Equal(t, 0, numSpans)
+
+	assert.NoError(t, wh.handleEvent(ctx, tracer, parent(eventsources.StartState)))
+	numSpans, _ = wh.Spans.Count()
+	assert.Equal(t, 2, numSpans)
+
+	assert.NoError(t, wh.handleEvent(ctx, tracer, parent(eventsources.EndState)))
+	numSpans, _ = wh.Spans.Count()
+	assert.Equal(t, 0, numSpans)
+
+	spans := tracer.FinishedSpans()
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, "child", spans[0].OperationName)
+	assert.Equal(t, "parent", spans[1].OperationName)
+	assert.Equal(t, spans[1].SpanContext.SpanID, spans[0].ParentID)
+}
+
+func TestWebhook_handleEvent_Complete_UsesTimings(t *testing.T) {
+	tracer := mocktracer.New()
+
+	wh := &Webhook{
+		Spans: traces.NewMemoryUnboundedSpanStore(),
+		EventSource: eventsources.StubEventSource{
+			TracerReturn: tracer,
+		},
+		TimedSpans: true,
+	}
+
+	start := time.Date(2019, 11, 22, 17, 13, 0, 0, time.UTC)
+	end := start.Add(time.Minute)
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "queued",
+		SpanIDReturn:        "span-test-1",
+		StateReturn:         eventsources.CompleteState,
+		TimingsReturn: eventsources.EventTimings{
+			StartTime: &start,
+			EndTime:   &end,
+		},
+	}))
+
+	numSpans, _ := wh.Spans.Count()
+	assert.Equal(t, 0, numSpans)
+
+	spans := tracer.FinishedSpans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, start, spans[0].StartTime)
+	assert.Equal(t, end, spans[0].FinishTime)
+}
+
+func TestWebhook_handleEvent_Untimed_IgnoresTimings(t *testing.T) {
+	tracer := mocktracer.New()
+
+	wh := &Webhook{
+		Spans: traces.NewMemoryUnboundedSpanStore(),
+		EventSource: eventsources.StubEventSource{
+			TracerReturn: tracer,
+		},
+	}
+
+	// ie a reopened github issue, which reports when it was created
+	created := time.Date(2019, 11, 22, 17, 13, 0, 0, time.UTC)
+	closed := created.Add(time.Hour)
+	handled := time.Now()
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "issue",
+		SpanIDReturn:        "span-test-1",
+		StateReturn:         eventsources.StartState,
+		TimingsReturn: eventsources.EventTimings{
+			StartTime: &created,
+		},
+	}))
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "issue",
+		SpanIDReturn:        "span-test-1",
+		StateReturn:         eventsources.EndState,
+		TimingsReturn: eventsources.EventTimings{
+			StartTime: &created,
+			EndTime:   &closed,
+		},
+	}))
+
+	spans := tracer.FinishedSpans()
+	assert.Equal(t, 1, len(spans))
+	assert.False(t, spans[0].StartTime.Before(handled))
+	assert.False(t, spans[0].FinishTime.Before(handled))
+}
+
+func TestWebhook_handleEndEvent_RecordsSourceLatency(t *testing.T) {
+	assert.NoError(t, view.Register(EventLatencyView))
+	defer view.Unregister(EventLatencyView)
+
+	created := time.Date(2019, 11, 22, 17, 13, 0, 0, time.UTC)
+	closed := created.Add(time.Hour)
+	finished := created.Add(5 * time.Minute)
+	action, repo, number := "closed", "valuestream", 1
+
+	testCases := []struct {
+		name     string
+		event    eventsources.Event
+		expected float64
+	}{
+		{
+			"github_duration",
+			vsgithub.IssuesEvent{IssuesEvent: &github.IssuesEvent{
+				Action: &action,
+				Repo:   &github.Repository{Name: &repo},
+				Issue: &github.Issue{
+					Number:    &number,
+					CreatedAt: &created,
+					ClosedAt:  &closed,
+				},
+			}},
+			float64(time.Hour / time.Millisecond),
+		},
+		{
+			"jenkins_duration",
+			jenkins.BuildEvent{
+				JobName:  "test",
+				Number:   1,
+				Result:   "SUCCESS",
+				Duration: 9452,
+			},
+			9452,
+		},
+		{
+			"start_and_end_times",
+			eventsources.StubEvent{
+				OperationNameReturn: "build",
+				SpanIDReturn:        "span-test-1",
+				TimingsReturn: eventsources.EventTimings{
+					StartTime: &created,
+					EndTime:   &finished,
+				},
+			},
+			float64(5 * time.Minute / time.Millisecond),
+		},
+		{
+			"stored_duration",
+			eventsources.StubEvent{
+				OperationNameReturn: "issue",
+				SpanIDReturn:        "span-test-1",
+			},
+			0,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer := mocktracer.New()
+			wh := &Webhook{
+				Spans: traces.NewMemoryUnboundedSpanStore(),
+				EventSource: eventsources.StubEventSource{
+					NameReturn:   tt.name,
+					TracerReturn: tracer,
+				},
+			}
+
+			spanID, _ := tt.event.SpanID()
+			span := tracer.StartSpan("test")
+			assert.NoError(t, wh.Spans.Set(context.Background(), spanID, traces.NewStoreEntryFromSpan(span)))
+			assert.NoError(t, wh.handleEndEvent(context.Background(), tracer, tt.event))
+
+			rows, err := view.RetrieveData(EventLatencyView.Name)
+			assert.NoError(t, err)
+
+			var recorded []float64
+			for _, row := range rows {
+				for _, tag := range row.Tags {
+					if tag.Key == eventSource && tag.Value == tt.name {
+						recorded = append(recorded, row.Data.(*view.DistributionData).Max)
+					}
+				}
+			}
+			if assert.Equal(t, 1, len(recorded)) {
+				assert.InDelta(t, tt.expected, recorded[0], 1000)
+			}
+		})
+	}
+}
+
+func TestWebhook_handleEvent_RevisionParent(t *testing.T) {
+	tracer := mocktracer.New()
+
+	wh := &Webhook{
+		Spans:     traces.NewMemoryUnboundedSpanStore(),
+		Revisions: traces.NewRevisions(10, 0),
+		EventSource: eventsources.StubEventSource{
+			TracerReturn: tracer,
+		},
+	}
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "build",
+		SpanIDReturn:        "build-1",
+		StateReturn:         eventsources.StartState,
+		TagsReturn: map[string]interface{}{
+			traces.RevisionTag: "abc123",
+		},
+	}))
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubRevisionEvent{
+		StubEvent: eventsources.StubEvent{
+			OperationNameReturn: "deploy",
+			SpanIDReturn:        "deploy-1",
+			StateReturn:         eventsources.CompleteState,
+		},
+		RevisionReturn: "abc123",
+	}))
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubRevisionEvent{
+		StubEvent: eventsources.StubEvent{
+			OperationNameReturn: "deploy",
+			SpanIDReturn:        "deploy-2",
+			StateReturn:         eventsources.CompleteState,
+		},
+		RevisionReturn: "unknown",
+	}))
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "build",
+		SpanIDReturn:        "build-1",
+		StateReturn:         eventsources.EndState,
+	}))
+
+	spans := tracer.FinishedSpans()
+	assert.Equal(t, 3, len(spans))
+	deploy, orphan, build := spans[0], spans[1], spans[2]
+	assert.Equal(t, build.SpanContext.SpanID, deploy.ParentID)
+	assert.Equal(t, 0, orphan.ParentID)
+}
+
+func TestWebhook_handleEvent_RevisionsFallback(t *testing.T) {
+	tracer := mocktracer.New()
+
+	wh := &Webhook{
+		Spans:     traces.NewMemoryUnboundedSpanStore(),
+		Revisions: traces.NewRevisions(10, 0),
+		EventSource: eventsources.StubEventSource{
+			TracerReturn: tracer,
+		},
+	}
+
+	// a build of the branch before its pull request was opened
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "build",
+		SpanIDReturn:        "build-0",
+		StateReturn:         eventsources.StartState,
+		TagsReturn: map[string]interface{}{
+			"scm.branch": "feature/login",
+		},
+	}))
+
+	// the pull request isn't caused by the earlier build of its branch
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "pull_request",
+		SpanIDReturn:        "pr-1",
+		StateReturn:         eventsources.StartState,
+		TagsReturn: map[string]interface{}{
+			traces.RevisionTag: "abc123",
+			"scm.head.ref":     "feature/login",
+		},
+	}))
+
+	// the build only knows its branch, its parent isn't a span
+	parentID := "feature/login"
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "build",
+		SpanIDReturn:        "build-1",
+		ParentSpanIDReturn:  &parentID,
+		StateReturn:         eventsources.CompleteState,
+		TagsReturn: map[string]interface{}{
+			"scm.branch": "feature/login",
+		},
+	}))
+
+	// the job only knows its commit
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "build",
+		SpanIDReturn:        "job-1",
+		StateReturn:         eventsources.CompleteState,
+		TagsReturn: map[string]interface{}{
+			"scm.commit.sha": "abc123",
+		},
+	}))
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "pull_request",
+		SpanIDReturn:        "pr-1",
+		StateReturn:         eventsources.EndState,
+	}))
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "build",
+		SpanIDReturn:        "build-0",
+		StateReturn:         eventsources.EndState,
+	}))
+
+	spans := tracer.FinishedSpans()
+	assert.Equal(t, 4, len(spans))
+	build, job, pr, earlier := spans[0], spans[1], spans[2], spans[3]
+	assert.Equal(t, 0, pr.ParentID)
+	assert.Equal(t, pr.SpanContext.SpanID, build.ParentID)
+	assert.Equal(t, pr.SpanContext.SpanID, job.ParentID)
+	assert.Equal(t, 0, earlier.ParentID)
+}
+
+// synthetic placeholds the span ids of the sources.
+func synthetic(sources ...eventsources.EventSource) *Placeholders {
+	var names []string
+	for _, es := range sources {
+		names = append(names, es.Name())
+	}
+	p := NewPlaceholders(time.Hour, names...)
+	for _, es := range sources {
+		p.Register(es)
+	}
+	return p
+}
+
+func TestWebhook_handleEvent_SyntheticParentAdopted(t *testing.T) {
+	tracer := mocktracer.New()
+
+	wh := &Webhook{
+		Spans:        traces.NewMemoryUnboundedSpanStore(),
+		Placeholders: synthetic(eventsources.StubEventSource{NameReturn: "github"}),
+		EventSource: eventsources.StubEventSource{
+			TracerReturn: tracer,
+		},
+	}
+
+	issueID := "vstrace-github-issue-valuestream-1"
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "pull_request",
+		SpanIDReturn:        "pr-1",
+		ParentSpanIDReturn:  &issueID,
+		StateReturn:         eventsources.CompleteState,
+	}))
+
+	// branches aren't placeheld
+	branch := "feature/login"
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "build",
+		SpanIDReturn:        "build-1",
+		ParentSpanIDReturn:  &branch,
+		StateReturn:         eventsources.CompleteState,
+	}))
+
+	// nor are the span ids of sources which aren't configured
+	jiraID := "vstrace-jiracloud-issue-ABC-1"
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "pull_request",
+		SpanIDReturn:        "pr-2",
+		ParentSpanIDReturn:  &jiraID,
+		StateReturn:         eventsources.StartState,
+	}))
+
+	unconfigured, err := wh.Spans.Get(context.Background(), tracer, jiraID)
+	assert.NoError(t, err)
+	assert.Nil(t, unconfigured)
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "pull_request",
+		SpanIDReturn:        "pr-2",
+		StateReturn:         eventsources.EndState,
+	}))
+
+	placeholder, err := wh.Spans.Get(context.Background(), tracer, issueID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, placeholder) {
+		assert.True(t, placeholder.Synthetic)
+		assert.Nil(t, placeholder.State)
+	}
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "issue",
+		SpanIDReturn:        issueID,
+		StateReturn:         eventsources.StartState,
+		TagsReturn: map[string]interface{}{
+			"issue.number": 1,
+		},
+	}))
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "issue",
+		SpanIDReturn:        issueID,
+		StateReturn:         eventsources.EndState,
+	}))
+
+	count, err := wh.Spans.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	spans := tracer.FinishedSpans()
+	assert.Equal(t, 4, len(spans))
+	pr, build, pr2, issue := spans[0], spans[1], spans[2], spans[3]
+	assert.Equal(t, issue.SpanContext.SpanID, pr.ParentID)
+	assert.Equal(t, 0, build.ParentID)
+	assert.Equal(t, 0, pr2.ParentID)
+	assert.Equal(t, "issue", issue.OperationName)
+	assert.Equal(t, false, issue.Tag(traces.SyntheticTag))
+	assert.Equal(t, 1, issue.Tag("issue.number"))
+}
+
+func TestWebhook_handleEvent_SyntheticParentOrphaned(t *testing.T) {
+	tracer := mocktracer.New()
+
+	wh := &Webhook{
+		Spans:        traces.NewMemoryUnboundedSpanStore(),
+		Placeholders: synthetic(eventsources.StubEventSource{NameReturn: "github"}),
+		EventSource: eventsources.StubEventSource{
+			TracerReturn: tracer,
+		},
+	}
+
+	issueID := "vstrace-github-issue-valuestream-1"
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "pull_request",
+		SpanIDReturn:        "pr-1",
+		ParentSpanIDReturn:  &issueID,
+		StateReturn:         eventsources.CompleteState,
+	}))
+
+	// placeholders are left until the ttl elapses
+	assert.NoError(t, wh.Placeholders.expire(context.Background(), wh.Spans, time.Now().UTC()))
+	count, err := wh.Spans.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	assert.NoError(t, wh.Placeholders.expire(context.Background(), wh.Spans, time.Now().UTC().Add(2*time.Hour)))
+	count, err = wh.Spans.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	spans := tracer.FinishedSpans()
+	assert.Equal(t, 2, len(spans))
+	pr, issue := spans[0], spans[1]
+	assert.Equal(t, issue.SpanContext.SpanID, pr.ParentID)
+	assert.Equal(t, true, issue.Tag(traces.SyntheticTag))
+	assert.Equal(t, true, issue.Tag(OrphanedTag))
+}
+
+func TestWebhook_handleEvent_SyntheticParentAdoptedOrExpired(t *testing.T) {
+	issueID := "vstrace-github-issue-valuestream-1"
+	issue := eventsources.StubEvent{
+		OperationNameReturn: "issue",
+		SpanIDReturn:        issueID,
+		StateReturn:         eventsources.StartState,
+	}
+
+	setUp := func() (*mocktracer.MockTracer, *Webhook) {
+		tracer := mocktracer.New()
+		wh := &Webhook{
+			Spans:        traces.NewMemoryUnboundedSpanStore(),
+			Placeholders: synthetic(eventsources.StubEventSource{NameReturn: "github"}),
+			EventSource: eventsources.StubEventSource{
+				TracerReturn: tracer,
+			},
+		}
+		assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+			OperationNameReturn: "pull_request",
+			SpanIDReturn:        "pr-1",
+			ParentSpanIDReturn:  &issueID,
+			StateReturn:         eventsources.CompleteState,
+		}))
+		return tracer, wh
+	}
+
+	t.Run("adopted_then_expired", func(t *testing.T) {
+		tracer, wh := setUp()
+		assert.NoError(t, wh.handleEvent(context.Background(), tracer, issue))
+		assert.NoError(t, wh.Placeholders.expire(context.Background(), wh.Spans, time.Now().UTC().Add(2*time.Hour)))
+
+		entry, err := wh.Spans.Get(context.Background(), tracer, issueID)
+		assert.NoError(t, err)
+		if assert.NotNil(t, entry) {
+			assert.False(t, entry.Synthetic)
+		}
+		assert.Equal(t, 1, len(tracer.FinishedSpans()))
+	})
+
+	t.Run("expired_then_started", func(t *testing.T) {
+		tracer, wh := setUp()
+		assert.NoError(t, wh.Placeholders.expire(context.Background(), wh.Spans, time.Now().UTC().Add(2*time.Hour)))
+		assert.NoError(t, wh.handleEvent(context.Background(), tracer, issue))
+
+		entry, err := wh.Spans.Get(context.Background(), tracer, issueID)
+		assert.NoError(t, err)
+		spans := tracer.FinishedSpans()
+		if assert.NotNil(t, entry) && assert.Equal(t, 2, len(spans)) {
+			orphan := spans[1]
+			assert.Equal(t, true, orphan.Tag(OrphanedTag))
+			assert.NotEqual(t, orphan.SpanContext.SpanID, entry.Span.(*mocktracer.MockSpan).SpanContext.SpanID)
+		}
+	})
+}
+
+func TestWebhook_handleEvent_SyntheticParentReplaced(t *testing.T) {
+	tracer := mocktracer.New()
+
+	wh := &Webhook{
+		Spans:        traces.NewMemoryUnboundedSpanStore(),
+		Placeholders: synthetic(eventsources.StubEventSource{NameReturn: "github"}),
+		TimedSpans:   true,
+		EventSource: eventsources.StubEventSource{
+			TracerReturn: tracer,
+		},
+	}
+
+	opened := time.Date(2020, 3, 1, 9, 0, 0, 0, time.UTC)
+	reviewed := opened.Add(48 * time.Hour)
+
+	issueID := "vstrace-github-issue-valuestream-1"
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "pull_request",
+		SpanIDReturn:        "pr-1",
+		ParentSpanIDReturn:  &issueID,
+		StateReturn:         eventsources.CompleteState,
+		TimingsReturn:       eventsources.EventTimings{StartTime: &reviewed, EndTime: &reviewed},
+	}))
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "epic",
+		SpanIDReturn:        "epic-1",
+		StateReturn:         eventsources.StartState,
+	}))
+
+	// the issue's own parent and start time can't be given to the
+	// placeholder, its span is started with them instead
+	epicID := "epic-1"
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "issue",
+		SpanIDReturn:        issueID,
+		ParentSpanIDReturn:  &epicID,
+		StateReturn:         eventsources.StartState,
+		TimingsReturn:       eventsources.EventTimings{StartTime: &opened},
+	}))
+
+	entry, err := wh.Spans.Get(context.Background(), tracer, issueID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.False(t, entry.Synthetic)
+	}
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "issue",
+		SpanIDReturn:        issueID,
+		StateReturn:         eventsources.EndState,
+	}))
+
+	spans := tracer.FinishedSpans()
+	assert.Equal(t, 3, len(spans))
+	pr, placeholder, issue := spans[0], spans[1], spans[2]
+	assert.Equal(t, placeholder.SpanContext.SpanID, pr.ParentID)
+	assert.Equal(t, true, placeholder.Tag(traces.SyntheticTag))
+	epic, err := wh.Spans.Get(context.Background(), tracer, epicID)
+	assert.NoError(t, err)
+	assert.Equal(t, epic.Span.(*mocktracer.MockSpan).SpanContext.SpanID, issue.ParentID)
+	assert.Equal(t, opened, issue.StartTime)
+}
+
+type createdEventSource struct {
+	eventsources.StubEventSource
+	created time.Time
+}
+
+func (s createdEventSource) CreatedAt(ctx context.Context, spanID string) (*time.Time, error) {
+	return &s.created, nil
+}
+
+func TestWebhook_handleEvent_SyntheticParentCreated(t *testing.T) {
+	tracer := mocktracer.New()
+
+	opened := time.Date(2020, 3, 1, 9, 0, 0, 0, time.UTC)
+	wh := &Webhook{
+		Spans: traces.NewMemoryUnboundedSpanStore(),
+		Placeholders: synthetic(createdEventSource{
+			StubEventSource: eventsources.StubEventSource{NameReturn: "jiracloud"},
+			created:         opened,
+		}),
+		EventSource: eventsources.StubEventSource{
+			TracerReturn: tracer,
+		},
+	}
+
+	issueID := "vstrace-jiracloud-issue-ABC-1"
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "pull_request",
+		SpanIDReturn:        "pr-1",
+		ParentSpanIDReturn:  &issueID,
+		StateReturn:         eventsources.CompleteState,
+	}))
+
+	// the placeholder is back-filled to when the issue was created, and
+	// adopted by the issue's event
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "issue",
+		SpanIDReturn:        issueID,
+		StateReturn:         eventsources.CompleteState,
+	}))
+
+	spans := tracer.FinishedSpans()
+	assert.Equal(t, 2, len(spans))
+	pr, issue := spans[0], spans[1]
+	assert.Equal(t, issue.SpanContext.SpanID, pr.ParentID)
+	assert.Equal(t, opened, issue.StartTime)
+	assert.Equal(t, false, issue.Tag(traces.SyntheticTag))
+}
+
+func TestPlaceholders_Validate(t *testing.T) {
+	p := NewPlaceholders(time.Hour, "github", " jiracloud")
+	p.Register(eventsources.StubEventSource{NameReturn: "github"})
+	p.Register(eventsources.StubEventSource{NameReturn: "gitlab"})
+	assert.EqualError(t, p.Validate(), "synthetic parents of unknown sources: jiracloud")
+
+	p.Register(eventsources.StubEventSource{NameReturn: "jiracloud"})
+	assert.NoError(t, p.Validate())
+
+	_, ok := p.source("gitlab")
+	assert.False(t, ok)
+}
+
+func TestWebhook_handleEvent_MultipleParents(t *testing.T) {
+	tracer := mocktracer.New()
+
+	wh := &Webhook{
+		Spans: traces.NewMemoryUnboundedSpanStore(),
+		EventSource: eventsources.StubEventSource{
+			TracerReturn: tracer,
+		},
+	}
+
+	for _, id := range []string{"issue-1", "issue-2"} {
+		assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+			OperationNameReturn: "issue",
+			SpanIDReturn:        id,
+			StateReturn:         eventsources.StartState,
+		}))
+	}
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: "pull_request",
+		SpanIDReturn:        "pr-1",
+		StateReturn:         eventsources.CompleteState,
+		ReferencesReturn:    eventsources.Fixes([]string{"issue-3", "issue-2", "issue-1"}),
+	}))
+
+	for _, id := range []string{"issue-1", "issue-2"} {
+		assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+			OperationNameReturn: "issue",
+			SpanIDReturn:        id,
+			StateReturn:         eventsources.EndState,
+		}))
+	}
+
+	spans := tracer.FinishedSpans()
+	assert.Equal(t, 3, len(spans))
+	pr, issue2 := spans[0], spans[2]
+	assert.Equal(t, issue2.SpanContext.SpanID, pr.ParentID)
+}
+
+func TestWebhook_handleEvent_IncidentFollowsFromDeploy(t *testing.T) {
+	tracer := mocktracer.New()
+
+	wh := &Webhook{
+		Spans:   traces.NewMemoryUnboundedSpanStore(),
+		Deploys: traces.NewDeploys(10),
+		EventSource: eventsources.StubEventSource{
+			TracerReturn: tracer,
+		},
+	}
+
+	deploy := func(spanID string, environment string) eventsources.Event {
+		tags := map[string]interface{}{}
+		if environment != "" {
+			tags[EnvironmentTag] = environment
+		}
+		return eventsources.StubServiceEvent{
+			StubEvent: eventsources.StubEvent{
+				OperationNameReturn: types.DeployEventType,
+				SpanIDReturn:        spanID,
+				StateReturn:         eventsources.CompleteState,
+				TagsReturn:          tags,
+			},
+			ServiceNameReturn: "Checkout",
+		}
+	}
+	incident := func(spanID string, service string, environment string) eventsources.Event {
+		return eventsources.StubIncidentEvent{
+			StubServiceEvent: eventsources.StubServiceEvent{
+				StubEvent: eventsources.StubEvent{
+					OperationNameReturn: types.IncidentEventType,
+					SpanIDReturn:        spanID,
+					StateReturn:         eventsources.CompleteState,
+				},
+				ServiceNameReturn: service,
+			},
+			EnvironmentReturn: environment,
+		}
+	}
+
+	events := []eventsources.Event{
+		deploy("deploy-production", ""),
+		deploy("deploy-staging", "Staging"),
+		incident("incident-production", "checkout", ""),
+		incident("incident-staging", "checkout", "staging"),
+		incident("incident-other-service", "payments", ""),
+		incident("incident-other-environment", "checkout", "qa"),
+		// builds of the service aren't caused by its deploys
+		eventsources.StubServiceEvent{
+			StubEvent: eventsources.StubEvent{
+				OperationNameReturn: types.BuildEventType,
+				SpanIDReturn:        "build-1",
+				StateReturn:         eventsources.CompleteState,
+			},
+			ServiceNameReturn: "checkout",
+		},
+	}
+	for _, e := range events {
+		assert.NoError(t, wh.handleEvent(context.Background(), tracer, e))
+	}
+
+	spans := tracer.FinishedSpans()
+	if assert.Equal(t, len(events), len(spans)) {
+		production, staging := spans[0].SpanContext.SpanID, spans[1].SpanContext.SpanID
+		assert.Equal(t, production, spans[2].ParentID)
+		assert.Equal(t, staging, spans[3].ParentID)
+		assert.Equal(t, 0, spans[4].ParentID)
+		assert.Equal(t, 0, spans[5].ParentID)
+		assert.Equal(t, 0, spans[6].ParentID)
+	}
+}
+
+func TestWebhook_handleEvent_DefectFollowsFromDeployOfRelease(t *testing.T) {
+	tracer := mocktracer.New()
+
+	wh := &Webhook{
+		Spans:   traces.NewMemoryUnboundedSpanStore(),
+		Deploys: traces.NewDeploys(10),
+		EventSource: eventsources.StubEventSource{
+			TracerReturn: tracer,
+		},
+	}
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubRevisionEvent{
+		StubEvent: eventsources.StubEvent{
+			OperationNameReturn: types.DeployEventType,
+			SpanIDReturn:        "deploy-1",
+			StateReturn:         eventsources.CompleteState,
+		},
+		RevisionReturn: "abc123",
+	}))
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+		OperationNameReturn: types.DeployEventType,
+		SpanIDReturn:        "deploy-2",
+		StateReturn:         eventsources.CompleteState,
+		TagsReturn: map[string]interface{}{
+			traces.RevisionTag: "def456",
+		},
+	}))
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubReleaseEvent{
+		StubEvent: eventsources.StubEvent{
+			OperationNameReturn: types.DefectEventType,
+			SpanIDReturn:        "defect-1",
+			StateReturn:         eventsources.CompleteState,
+		},
+		ReleasesReturn: []string{"checkout@1.0.0", "abc123"},
+	}))
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubReleaseEvent{
+		StubEvent: eventsources.StubEvent{
+			OperationNameReturn: types.DefectEventType,
+			SpanIDReturn:        "defect-2",
+			StateReturn:         eventsources.CompleteState,
+		},
+		ReleasesReturn: []string{"def456"},
+	}))
+
+	spans := tracer.FinishedSpans()
+	assert.Equal(t, 4, len(spans))
+	assert.Equal(t, spans[0].SpanContext.SpanID, spans[2].ParentID)
+	assert.Equal(t, spans[1].SpanContext.SpanID, spans[3].ParentID)
+}
+
 func TestWebhook_Handler_Success(t *testing.T) {
 	req, err := http.NewRequest(
 		"GET",
@@ -160,3 +988,226 @@ func TestWebhook_Handler_Success(t *testing.T) {
 	wh.Handler(rr, req)
 	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
 }
+
+type callbackEventSource struct {
+	eventsources.StubEventSource
+}
+
+func (s callbackEventSource) VerifiesCallback() bool {
+	return true
+}
+
+func TestWebhook_Handler_Head(t *testing.T) {
+	unsigned := eventsources.StubEventSource{
+		ValidatePayloadFn: func(r *http.Request, secretKey []byte) ([]byte, error) {
+			return nil, eventsources.InvalidSignatureError{
+				Err: fmt.Errorf("request is not signed"),
+			}
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		source   eventsources.EventSource
+		expected int
+	}{
+		{"callback_source_verified", callbackEventSource{unsigned}, http.StatusOK},
+		{"other_source_validated", unsigned, http.StatusUnauthorized},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("HEAD", "/test", nil)
+			assert.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+
+			wh := &Webhook{
+				Tracers:     tracers.NewRequestScopedUsingSources(),
+				EventSource: tt.source,
+				Spans:       traces.NewMemoryUnboundedSpanStore(),
+			}
+			wh.Handler(rr, req)
+			assert.Equal(t, tt.expected, rr.Result().StatusCode)
+		})
+	}
+}
+
+func TestWebhook_Handler_InvalidSignature_Unauthorized(t *testing.T) {
+	req, err := http.NewRequest(
+		"POST",
+		"/test",
+		bytes.NewReader([]byte(`throwaway`)),
+	)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+
+	wh := &Webhook{
+		Tracers: tracers.NewRequestScopedUsingSources(),
+		EventSource: eventsources.StubEventSource{
+			NameReturn: "stub",
+			ValidatePayloadFn: func(r *http.Request, secretKey []byte) ([]byte, error) {
+				return nil, eventsources.InvalidSignatureError{
+					Err: fmt.Errorf("invalid signature"),
+				}
+			},
+		},
+		Spans: traces.NewMemoryUnboundedSpanStore(),
+	}
+	wh.Handler(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode)
+}
+
+func TestWebhook_Handler_InvalidPayload_BadRequest(t *testing.T) {
+	req, err := http.NewRequest(
+		"POST",
+		"/test",
+		bytes.NewReader([]byte(`throwaway`)),
+	)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+
+	wh := &Webhook{
+		Tracers: tracers.NewRequestScopedUsingSources(),
+		EventSource: eventsources.StubEventSource{
+			ValidatePayloadFn: func(r *http.Request, secretKey []byte) ([]byte, error) {
+				return nil, fmt.Errorf("unable to read body")
+			},
+		},
+		Spans: traces.NewMemoryUnboundedSpanStore(),
+	}
+	wh.Handler(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Result().StatusCode)
+}
+
+func TestWebhook_handleEvent_DeployContainsChanges(t *testing.T) {
+	tracer := mocktracer.New()
+
+	wh := &Webhook{
+		Spans:     traces.NewMemoryUnboundedSpanStore(),
+		Revisions: traces.NewRevisions(10, 0),
+		Deploys:   traces.NewDeploys(10),
+		Changes:   traces.NewChanges(10),
+		EventSource: eventsources.StubEventSource{
+			TracerReturn: tracer,
+		},
+	}
+
+	merge := func(repository, id, head, merged string) {
+		assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+			OperationNameReturn: types.PullRequestEventType,
+			SpanIDReturn:        id,
+			StateReturn:         eventsources.StartState,
+			TagsReturn: map[string]interface{}{
+				traces.RevisionTag:         head,
+				"scm.repository.full_name": repository,
+			},
+		}))
+		assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubEvent{
+			OperationNameReturn: types.PullRequestEventType,
+			SpanIDReturn:        id,
+			StateReturn:         eventsources.EndState,
+			TagsReturn: map[string]interface{}{
+				traces.RevisionTag:         head,
+				"scm.merge.sha":            merged,
+				"scm.repository.full_name": repository,
+			},
+		}))
+	}
+
+	deploy := func(id, sha string, failed bool) {
+		assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubServiceEvent{
+			StubEvent: eventsources.StubEvent{
+				OperationNameReturn: types.DeployEventType,
+				SpanIDReturn:        id,
+				StateReturn:         eventsources.CompleteState,
+				IsErrorReturn:       failed,
+				TagsReturn: map[string]interface{}{
+					traces.RevisionTag: sha,
+					EnvironmentTag:     "production",
+				},
+			},
+			ServiceNameReturn: "checkout",
+		}))
+	}
+
+	merge("shop/checkout", "pr-1", "a1", "m1")
+	deploy("deploy-1", "m1", false)
+	merge("shop/checkout", "pr-2", "a2", "m2")
+	// changes to other repositories aren't shipped
+	merge("shop/payments", "pr-4", "b1", "n1")
+	merge("shop/checkout", "pr-3", "a3", "m3")
+	deploy("deploy-2", "m3", true)
+	deploy("deploy-3", "m3", false)
+
+	assert.Equal(t, "m3", wh.Deploys.Shipped("checkout", "production"))
+
+	var deploys []*mocktracer.MockSpan
+	for _, span := range tracer.FinishedSpans() {
+		if span.OperationName == types.DeployEventType {
+			deploys = append(deploys, span)
+		}
+	}
+
+	if assert.Equal(t, 3, len(deploys)) {
+		assert.Equal(t, 1, deploys[0].Tag("deploy.changes.count"))
+		assert.Equal(t, 1, len(deploys[0].Logs()))
+		assert.Nil(t, deploys[1].Tag("deploy.changes.count"))
+		assert.Equal(t, 2, deploys[2].Tag("deploy.changes.count"))
+		assert.Equal(t, 2, len(deploys[2].Logs()))
+	}
+}
+
+func TestWebhook_handleEvent_DeployContainsLoadedChanges(t *testing.T) {
+	tracer := mocktracer.New()
+
+	// a pull request traced before a restart is only known by the
+	// context it carried
+	pr := tracer.StartSpan(types.PullRequestEventType)
+	pr.Finish()
+	carrier := opentracing.TextMapCarrier{}
+	assert.NoError(t, tracer.Inject(pr.Context(), opentracing.TextMap, carrier))
+
+	changes := traces.NewChanges(10)
+	changes.Set(traces.Change{
+		SpanID:    "pr-1",
+		Carrier:   carrier,
+		StartTime: time.Now().UTC().Add(-time.Hour),
+	})
+
+	revisions := traces.NewRevisions(10, 0)
+	revisions.SetTags(map[string]interface{}{
+		"scm.merge.sha":            "m1",
+		"scm.repository.full_name": "shop/checkout",
+	}, "pr-1", types.PullRequestEventType)
+
+	wh := &Webhook{
+		Spans:     traces.NewMemoryUnboundedSpanStore(),
+		Revisions: revisions,
+		Deploys:   traces.NewDeploys(10),
+		Changes:   changes,
+		EventSource: eventsources.StubEventSource{
+			TracerReturn: tracer,
+		},
+	}
+
+	assert.NoError(t, wh.handleEvent(context.Background(), tracer, eventsources.StubServiceEvent{
+		StubEvent: eventsources.StubEvent{
+			OperationNameReturn: types.DeployEventType,
+			SpanIDReturn:        "deploy-1",
+			StateReturn:         eventsources.CompleteState,
+			TagsReturn: map[string]interface{}{
+				traces.RevisionTag: "m1",
+			},
+		},
+		ServiceNameReturn: "checkout",
+	}))
+
+	spans := tracer.FinishedSpans()
+	if assert.Equal(t, 2, len(spans)) {
+		deploy := spans[1]
+		assert.Equal(t, pr.(*mocktracer.MockSpan).SpanContext.SpanID, deploy.ParentID)
+		assert.Equal(t, 1, deploy.Tag("deploy.changes.count"))
+	}
+}
@@ -0,0 +1,162 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/traces"
+	"github.com/opentracing/opentracing-go"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OrphanedTag flags the placeholder spans which were finished because
+// their own events never arrived.
+const OrphanedTag string = "synthetic.orphaned"
+
+// Placeholders are the synthetic parents started for the span ids of the
+// sources named, shared between the webhooks of all sources.  Span ids of
+// sources which aren't named, or which aren't configured, are never
+// placeheld, as their events will never arrive to adopt them.  A
+// placeholder whose own event hasn't arrived within the ttl is finished as
+// an orphan so it doesn't hold a place in the span store forever.
+type Placeholders struct {
+	mu      *sync.Mutex
+	named   map[string]bool
+	sources map[string]eventsources.EventSource
+	started map[string]time.Time
+	ttl     time.Duration
+}
+
+// Register configures the source, its span ids are placeheld if it was
+// named.
+func (p *Placeholders) Register(es eventsources.EventSource) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.named[es.Name()] {
+		p.sources[es.Name()] = es
+	}
+}
+
+// Validate checks each source named was registered.
+func (p *Placeholders) Validate() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var missing []string
+	for name := range p.named {
+		if _, ok := p.sources[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("synthetic parents of unknown sources: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// source is the configured source of the span id, if its spans are
+// placeheld.
+func (p *Placeholders) source(name string) (eventsources.EventSource, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	es, ok := p.sources[name]
+	return es, ok
+}
+
+func (p *Placeholders) add(spanID string, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.started[spanID] = at
+}
+
+// take looks up the placeholder started for the span id and removes it, so
+// a placeholder is either adopted by its own event or expired as an orphan,
+// never both.  It's nil when the span isn't a placeholder, or was already
+// expired.
+func (p *Placeholders) take(ctx context.Context, spans traces.SpanStore, tracer opentracing.Tracer, spanID string) (*traces.StoreEntry, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, err := spans.Get(ctx, tracer, spanID)
+	if err != nil || entry == nil || !entry.Synthetic {
+		return nil, err
+	}
+	delete(p.started, spanID)
+	return entry, nil
+}
+
+// expire finishes the placeholders whose own events haven't arrived within
+// the ttl, tagged as orphaned.  Placeholders are expired under the same
+// lock they're taken by their events with, so one being adopted is left to
+// its event.
+func (p *Placeholders) expire(ctx context.Context, spans traces.SpanStore, now time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for id, at := range p.started {
+		if now.Sub(at) <= p.ttl {
+			continue
+		}
+		delete(p.started, id)
+
+		entry, err := spans.Get(ctx, nil, id)
+		if err != nil {
+			return err
+		}
+		if entry == nil || !entry.Synthetic {
+			continue
+		}
+
+		entry.Span.SetTag(OrphanedTag, true)
+		entry.Span.Finish()
+
+		if err := spans.Delete(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Expire finishes orphaned placeholders every interval until the context
+// is cancelled.
+func (p *Placeholders) Expire(ctx context.Context, spans traces.SpanStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.expire(ctx, spans, time.Now().UTC()); err != nil {
+				log.WithFields(log.Fields{
+					"error": err.Error(),
+				}).Errorf("webhooks.Placeholders.Expire")
+			}
+		case <-ctx.Done():
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+// NewPlaceholders placeholds the span ids of the sources named, once
+// they're registered.
+func NewPlaceholders(ttl time.Duration, names ...string) *Placeholders {
+	named := make(map[string]bool)
+	for _, name := range names {
+		named[strings.TrimSpace(name)] = true
+	}
+
+	return &Placeholders{
+		mu:      &sync.Mutex{},
+		named:   named,
+		sources: make(map[string]eventsources.EventSource),
+		started: make(map[string]time.Time),
+		ttl:     ttl,
+	}
+}
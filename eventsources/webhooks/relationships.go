@@ -0,0 +1,95 @@
+package webhooks
+
+import (
+	"fmt"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/opentracing/opentracing-go"
+	"strings"
+)
+
+// RelationshipModel decides how each relationship between spans is
+// emitted.  A span is the child of the first span it references by a
+// relationship modeled as ChildOfRef, and follows from every other span
+// it references, which tracers supporting them show as span links.
+// Relationships which aren't modeled are emitted as FollowsFromRef.
+type RelationshipModel map[eventsources.Relationship]opentracing.SpanReferenceType
+
+// DefaultRelationships nest changes beneath the issues they fix and
+// deploys beneath the changes they deploy, so each trace is the tree of
+// an issue's work.
+func DefaultRelationships() RelationshipModel {
+	return RelationshipModel{
+		eventsources.ChildOfRelationship:  opentracing.ChildOfRef,
+		eventsources.FixesRelationship:    opentracing.ChildOfRef,
+		eventsources.DeploysRelationship:  opentracing.ChildOfRef,
+		eventsources.ContainsRelationship: opentracing.FollowsFromRef,
+		eventsources.FollowsRelationship:  opentracing.FollowsFromRef,
+	}
+}
+
+// LinkRelationships only nest spans beneath their parent, ie a job
+// beneath its pipeline, and link changes to the issues they fix and
+// deploys to what they deploy.
+func LinkRelationships() RelationshipModel {
+	return RelationshipModel{
+		eventsources.ChildOfRelationship: opentracing.ChildOfRef,
+	}
+}
+
+// ParseRelationshipModel parses `tree` as the DefaultRelationships,
+// `links` as the LinkRelationships, or a comma separated list of
+// `relationship=child_of|follows_from` overriding the
+// DefaultRelationships, ie `fixes=follows_from,contains=child_of`.
+func ParseRelationshipModel(spec string) (RelationshipModel, error) {
+	switch spec {
+	case "", "tree":
+		return DefaultRelationships(), nil
+	case "links":
+		return LinkRelationships(), nil
+	}
+
+	model := DefaultRelationships()
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("relationship %q must be relationship=child_of|follows_from", pair)
+		}
+
+		switch parts[1] {
+		case "child_of":
+			model[eventsources.Relationship(parts[0])] = opentracing.ChildOfRef
+		case "follows_from":
+			model[eventsources.Relationship(parts[0])] = opentracing.FollowsFromRef
+		default:
+			return nil, fmt.Errorf("relationship %q must be emitted as child_of or follows_from", parts[0])
+		}
+	}
+	return model, nil
+}
+
+// Type is how the relationship is emitted.
+func (m RelationshipModel) Type(r eventsources.Relationship) opentracing.SpanReferenceType {
+	if t, ok := m[r]; ok {
+		return t
+	}
+	return opentracing.FollowsFromRef
+}
+
+// StartSpanOptions reference the spans by their relationships, the
+// parent first as some tracers take the first reference as the parent.
+func (m RelationshipModel) StartSpanOptions(refs []tracedReference) []opentracing.StartSpanOption {
+	var parent opentracing.StartSpanOption
+	follows := make([]opentracing.StartSpanOption, 0, len(refs))
+	for _, ref := range refs {
+		if m.Type(ref.Relationship) == opentracing.ChildOfRef && parent == nil {
+			parent = opentracing.ChildOf(ref.SpanContext)
+			continue
+		}
+		follows = append(follows, opentracing.FollowsFrom(ref.SpanContext))
+	}
+
+	if parent == nil {
+		return follows
+	}
+	return append([]opentracing.StartSpanOption{parent}, follows...)
+}
@@ -0,0 +1,64 @@
+package webhooks
+
+import (
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseRelationshipModel(t *testing.T) {
+	model, err := ParseRelationshipModel("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultRelationships(), model)
+
+	model, err = ParseRelationshipModel("links")
+	assert.NoError(t, err)
+	assert.Equal(t, opentracing.FollowsFromRef, model.Type(eventsources.FixesRelationship))
+	assert.Equal(t, opentracing.ChildOfRef, model.Type(eventsources.ChildOfRelationship))
+
+	model, err = ParseRelationshipModel("fixes=follows_from, contains=child_of")
+	assert.NoError(t, err)
+	assert.Equal(t, opentracing.FollowsFromRef, model.Type(eventsources.FixesRelationship))
+	assert.Equal(t, opentracing.ChildOfRef, model.Type(eventsources.ContainsRelationship))
+	assert.Equal(t, opentracing.ChildOfRef, model.Type(eventsources.DeploysRelationship))
+
+	_, err = ParseRelationshipModel("fixes")
+	assert.Error(t, err)
+	_, err = ParseRelationshipModel("fixes=parent")
+	assert.Error(t, err)
+}
+
+func TestRelationshipModel_StartSpanOptions(t *testing.T) {
+	tracer := mocktracer.New()
+	issue := tracer.StartSpan("issue").Context()
+	deploy := tracer.StartSpan("deploy").Context()
+	pipeline := tracer.StartSpan("pipeline").Context()
+
+	refs := []tracedReference{
+		{deploy, eventsources.FollowsRelationship, ""},
+		{issue, eventsources.FixesRelationship, "issue-1"},
+		{pipeline, eventsources.ChildOfRelationship, "pipeline-1"},
+	}
+
+	references := func(model RelationshipModel) []opentracing.SpanReference {
+		var opts opentracing.StartSpanOptions
+		for _, o := range model.StartSpanOptions(refs) {
+			o.Apply(&opts)
+		}
+		return opts.References
+	}
+
+	assert.Equal(t, []opentracing.SpanReference{
+		{Type: opentracing.ChildOfRef, ReferencedContext: issue},
+		{Type: opentracing.FollowsFromRef, ReferencedContext: deploy},
+		{Type: opentracing.FollowsFromRef, ReferencedContext: pipeline},
+	}, references(DefaultRelationships()))
+
+	assert.Equal(t, []opentracing.SpanReference{
+		{Type: opentracing.ChildOfRef, ReferencedContext: pipeline},
+		{Type: opentracing.FollowsFromRef, ReferencedContext: deploy},
+		{Type: opentracing.FollowsFromRef, ReferencedContext: issue},
+	}, references(LinkRelationships()))
+}
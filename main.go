@@ -6,16 +6,31 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"contrib.go.opencensus.io/exporter/prometheus"
 	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/ImpactInsights/valuestream/eventsources/argocd"
+	"github.com/ImpactInsights/valuestream/eventsources/buildkite"
+	"github.com/ImpactInsights/valuestream/eventsources/circleci"
+	"github.com/ImpactInsights/valuestream/eventsources/cloudevents"
+	"github.com/ImpactInsights/valuestream/eventsources/drone"
+	"github.com/ImpactInsights/valuestream/eventsources/flux"
 	"github.com/ImpactInsights/valuestream/eventsources/github"
 	"github.com/ImpactInsights/valuestream/eventsources/gitlab"
 	customhttp "github.com/ImpactInsights/valuestream/eventsources/http"
 	"github.com/ImpactInsights/valuestream/eventsources/jenkins"
 	"github.com/ImpactInsights/valuestream/eventsources/jiracloud"
+	"github.com/ImpactInsights/valuestream/eventsources/kubernetes"
+	"github.com/ImpactInsights/valuestream/eventsources/linear"
+	"github.com/ImpactInsights/valuestream/eventsources/mapped"
+	"github.com/ImpactInsights/valuestream/eventsources/opsgenie"
+	"github.com/ImpactInsights/valuestream/eventsources/pagerduty"
+	"github.com/ImpactInsights/valuestream/eventsources/sentry"
+	"github.com/ImpactInsights/valuestream/eventsources/trello"
 	"github.com/ImpactInsights/valuestream/eventsources/webhooks"
 	"github.com/ImpactInsights/valuestream/tracers"
 	"github.com/ImpactInsights/valuestream/traces"
@@ -33,6 +48,15 @@ import (
 
 const envLogLevel string = "VS_LOG_LEVEL"
 
+type source struct {
+	urlPath   string
+	name      string
+	builderFn func(*cli.Context, opentracing.Tracer) (eventsources.EventSource, error)
+	// timed sources deliver events after the fact, their spans are placed
+	// at the times their events report
+	timed bool
+}
+
 func init() {
 	// Log as JSON instead of the default ASCII formatter.
 	log.SetFormatter(&log.JSONFormatter{})
@@ -72,9 +96,271 @@ func main() {
 			Usage:  "Tracer access token",
 			EnvVar: "VS_TRACER_ACCESS_TOKEN",
 		},
+		cli.StringFlag{
+			Name:   "drone-secret",
+			Value:  "",
+			Usage:  "Secret drone webhooks are signed with (DRONE_WEBHOOK_SECRET)",
+			EnvVar: "VS_DRONE_SECRET",
+		},
+		cli.StringFlag{
+			Name:   "flux-secret",
+			Value:  "",
+			Usage:  "Secret of the flux generic-hmac provider, sent as X-Signature",
+			EnvVar: "VS_FLUX_SECRET",
+		},
+		cli.StringFlag{
+			Name:   "flux-environment",
+			Value:  "",
+			Usage:  "Environment flux deploys to, when the environment of its events' metadata isn't set",
+			EnvVar: "VS_FLUX_ENVIRONMENT",
+		},
+		cli.StringFlag{
+			Name:   "gitlab-secret-token",
+			Value:  "",
+			Usage:  "Secret token gitlab webhooks are configured with, sent as X-Gitlab-Token",
+			EnvVar: "VS_GITLAB_SECRET_TOKEN",
+		},
+		cli.StringFlag{
+			Name:   "argocd-token",
+			Value:  "",
+			Usage:  "Token argo cd notifications are configured to send as a bearer token",
+			EnvVar: "VS_ARGOCD_TOKEN",
+		},
+		cli.StringFlag{
+			Name:   "buildkite-token",
+			Value:  "",
+			Usage:  "Token buildkite webhooks are configured with, sent as X-Buildkite-Token or used to sign X-Buildkite-Signature",
+			EnvVar: "VS_BUILDKITE_TOKEN",
+		},
+		cli.StringFlag{
+			Name:   "circleci-secret",
+			Value:  "",
+			Usage:  "Secret circleci webhooks are signed with, sent as circleci-signature",
+			EnvVar: "VS_CIRCLECI_SECRET",
+		},
+		cli.StringFlag{
+			Name:   "cloudevents-token",
+			Value:  "",
+			Usage:  "Access token cloud events are delivered with, sent as a bearer token",
+			EnvVar: "VS_CLOUDEVENTS_TOKEN",
+		},
+		cli.StringFlag{
+			Name:   "cloudevents-rules",
+			Value:  "",
+			Usage:  "Path to a json file of rules mapping cloud events to spans",
+			EnvVar: "VS_CLOUDEVENTS_RULES",
+		},
+		cli.StringFlag{
+			Name:   "jenkins-secret",
+			Value:  "",
+			Usage:  "Secret jenkins payloads are signed with, or sent as a token",
+			EnvVar: "VS_JENKINS_SECRET",
+		},
+		cli.BoolFlag{
+			Name:   "kubernetes-watch",
+			Usage:  "Trace the rollouts of kubernetes deployments and stateful sets as deploys",
+			EnvVar: "VS_KUBERNETES_WATCH",
+		},
+		cli.StringFlag{
+			Name:   "kubeconfig",
+			Value:  "",
+			Usage:  "Path to the kubeconfig of the cluster to watch, defaults to the cluster valuestream is running in",
+			EnvVar: "VS_KUBECONFIG",
+		},
+		cli.StringFlag{
+			Name:   "kubernetes-namespace",
+			Value:  "",
+			Usage:  "Namespace to watch rollouts in, defaults to every namespace",
+			EnvVar: "VS_KUBERNETES_NAMESPACE",
+		},
+		cli.StringFlag{
+			Name:   "kubernetes-environment",
+			Value:  "",
+			Usage:  "Environment the watched cluster's rollouts deploy to, defaults to the kubeconfig's current context",
+			EnvVar: "VS_KUBERNETES_ENVIRONMENT",
+		},
+		cli.StringFlag{
+			Name:   "linear-secret",
+			Value:  "",
+			Usage:  "Secret linear webhooks are signed with, sent as Linear-Signature",
+			EnvVar: "VS_LINEAR_SECRET",
+		},
+		cli.StringFlag{
+			Name:   "linear-workflows",
+			Value:  "",
+			Usage:  "Path to a json file mapping linear workflow states to issue span states",
+			EnvVar: "VS_LINEAR_WORKFLOWS",
+		},
+		cli.StringFlag{
+			Name:   "mapped-sources",
+			Value:  "",
+			Usage:  "Path to a json file declaring sources which map requests onto spans by json paths",
+			EnvVar: "VS_MAPPED_SOURCES",
+		},
+		cli.StringFlag{
+			Name:   "opsgenie-token",
+			Value:  "",
+			Usage:  "Token the opsgenie webhook integration is configured to send as a bearer token",
+			EnvVar: "VS_OPSGENIE_TOKEN",
+		},
+		cli.StringFlag{
+			Name:   "pagerduty-secret",
+			Value:  "",
+			Usage:  "Secret pagerduty webhook subscriptions are signed with, sent as X-PagerDuty-Signature",
+			EnvVar: "VS_PAGERDUTY_SECRET",
+		},
+		cli.StringFlag{
+			Name:   "revisions-path",
+			Value:  "",
+			Usage:  "Path to a json file the spans started for each commit and branch are saved to, so they're correlated across restarts",
+			EnvVar: "VS_REVISIONS_PATH",
+		},
+		cli.DurationFlag{
+			Name:   "revisions-ttl",
+			Value:  time.Hour * 24 * 30,
+			Usage:  "How long the span started for a commit or branch is correlated with later events",
+			EnvVar: "VS_REVISIONS_TTL",
+		},
+		cli.StringFlag{
+			Name:   "changes-path",
+			Value:  "",
+			Usage:  "Path to a json file the pull requests deploys may ship are saved to, so their lead time is measured across restarts",
+			EnvVar: "VS_CHANGES_PATH",
+		},
+		cli.StringFlag{
+			Name:   "deploys-path",
+			Value:  "",
+			Usage:  "Path to a json file the commit each service last shipped to each environment is saved to, so it's remembered across restarts",
+			EnvVar: "VS_DEPLOYS_PATH",
+		},
+		cli.StringFlag{
+			Name:   "sentry-client-secret",
+			Value:  "",
+			Usage:  "Client secret of the sentry integration, used to verify Sentry-Hook-Signature",
+			EnvVar: "VS_SENTRY_CLIENT_SECRET",
+		},
+		cli.StringFlag{
+			Name:   "synthetic-parents",
+			Value:  "",
+			Usage:  "Comma separated names of the sources, ie jiracloud,github, whose referenced spans which haven't been traced are started as placeholders, adopted when their own events arrive",
+			EnvVar: "VS_SYNTHETIC_PARENTS",
+		},
+		cli.DurationFlag{
+			Name:   "synthetic-ttl",
+			Value:  time.Hour * 24 * 7,
+			Usage:  "How long a placeholder span waits for its own event before it's finished as orphaned",
+			EnvVar: "VS_SYNTHETIC_TTL",
+		},
+		cli.StringFlag{
+			Name:   "trace-references",
+			Value:  "",
+			Usage:  "Path to a json file of patterns of references to spans in branches and descriptions, and the span ids they refer to",
+			EnvVar: "VS_TRACE_REFERENCES",
+		},
+		cli.StringFlag{
+			Name:   "jira-projects",
+			Value:  "",
+			Usage:  "Comma separated keys of the jira projects whose issue keys the default trace references refer to, ie ABC,OPS",
+			EnvVar: "VS_JIRA_PROJECTS",
+		},
+		cli.StringFlag{
+			Name:   "trace-relationships",
+			Value:  "tree",
+			Usage:  "How relationships between spans are traced: tree, links, or overrides of tree as relationship=child_of|follows_from, ie fixes=follows_from",
+			EnvVar: "VS_TRACE_RELATIONSHIPS",
+		},
+		cli.StringFlag{
+			Name:   "trello-secret",
+			Value:  "",
+			Usage:  "Secret of the trello application webhooks are registered with, used to verify X-Trello-Webhook",
+			EnvVar: "VS_TRELLO_SECRET",
+		},
+		cli.StringFlag{
+			Name:   "trello-callback-url",
+			Value:  "",
+			Usage:  "Callback url trello webhooks are registered with, defaults to the url requests are made to",
+			EnvVar: "VS_TRELLO_CALLBACK_URL",
+		},
+		cli.StringFlag{
+			Name:   "trello-workflows",
+			Value:  "",
+			Usage:  "Path to a json file mapping trello lists to issue span states",
+			EnvVar: "VS_TRELLO_WORKFLOWS",
+		},
+		cli.StringFlag{
+			Name:   "jira-secret",
+			Value:  "",
+			Usage:  "Jira Cloud webhook secret or Connect app shared secret",
+			EnvVar: "VS_JIRA_SECRET",
+		},
+		cli.StringFlag{
+			Name:   "jira-server-secret",
+			Value:  "",
+			Usage:  "Jira Server/Data Center webhook secret",
+			EnvVar: "VS_JIRA_SERVER_SECRET",
+		},
+		cli.StringFlag{
+			Name:   "jira-workflows",
+			Value:  "",
+			Usage:  "Path to a json file mapping jira statuses to issue span states",
+			EnvVar: "VS_JIRA_WORKFLOWS",
+		},
+		cli.StringFlag{
+			Name:   "jira-epic-link-field",
+			Value:  "customfield_10014",
+			Usage:  "Id of the jira custom field linking issues to their epic",
+			EnvVar: "VS_JIRA_EPIC_LINK_FIELD",
+		},
+		cli.StringFlag{
+			Name:   "jira-sprint-field",
+			Value:  "customfield_10020",
+			Usage:  "Id of the jira custom field holding the sprints of an issue",
+			EnvVar: "VS_JIRA_SPRINT_FIELD",
+		},
+		cli.StringFlag{
+			Name:   "jira-url",
+			Value:  "",
+			Usage:  "Jira Cloud url, lists the issues committed to when sprints start and looks up when placeheld issues were created",
+			EnvVar: "VS_JIRA_URL",
+		},
+		cli.StringFlag{
+			Name:   "jira-user",
+			Value:  "",
+			Usage:  "Jira Cloud user listing the issues of sprints",
+			EnvVar: "VS_JIRA_USER",
+		},
+		cli.StringFlag{
+			Name:   "jira-token",
+			Value:  "",
+			Usage:  "Jira Cloud api token of the jira-user",
+			EnvVar: "VS_JIRA_TOKEN",
+		},
+		cli.StringFlag{
+			Name:   "jira-server-url",
+			Value:  "",
+			Usage:  "Jira Server/Data Center url, lists the issues committed to when sprints start and looks up when placeheld issues were created",
+			EnvVar: "VS_JIRA_SERVER_URL",
+		},
+		cli.StringFlag{
+			Name:   "jira-server-user",
+			Value:  "",
+			Usage:  "Jira Server/Data Center user listing the issues of sprints",
+			EnvVar: "VS_JIRA_SERVER_USER",
+		},
+		cli.StringFlag{
+			Name:   "jira-server-token",
+			Value:  "",
+			Usage:  "Jira Server/Data Center password or token of the jira-server-user",
+			EnvVar: "VS_JIRA_SERVER_TOKEN",
+		},
 	}
 	app.Action = func(c *cli.Context) error {
-		ctx := context.Background()
+		// ctx is cancelled on shutdown, which stops the background work
+		// tracked by the wait group, ie saving revisions one last time
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		background := &sync.WaitGroup{}
+
 		// get the tracer
 		initialzeTracer := tracers.InitializerFromCLI(c, c.String("tracer"))
 
@@ -85,11 +371,59 @@ func main() {
 
 		go spans.Monitor(ctx, time.Second*5, "spans")
 
-		sources := []struct {
-			urlPath   string
-			name      string
-			builderFn func(*cli.Context, opentracing.Tracer) (eventsources.EventSource, error)
-		}{
+		revisions := traces.NewRevisions(1000, c.Duration("revisions-ttl"))
+		if path := c.String("revisions-path"); path != "" {
+			revisions, err = traces.LoadRevisions(path, 1000, c.Duration("revisions-ttl"))
+			if err != nil {
+				return err
+			}
+
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				revisions.Persist(ctx, time.Second*5)
+			}()
+		}
+
+		deploys := traces.NewDeploys(1000)
+		if path := c.String("deploys-path"); path != "" {
+			deploys, err = traces.LoadDeploys(path, 1000)
+			if err != nil {
+				return err
+			}
+
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				deploys.Persist(ctx, time.Second*5)
+			}()
+		}
+
+		changes := traces.NewChanges(1000)
+		if path := c.String("changes-path"); path != "" {
+			changes, err = traces.LoadChanges(path, 1000)
+			if err != nil {
+				return err
+			}
+
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				changes.Persist(ctx, time.Second*5)
+			}()
+		}
+
+		var placeholders *webhooks.Placeholders
+		if names := c.String("synthetic-parents"); names != "" {
+			placeholders = webhooks.NewPlaceholders(c.Duration("synthetic-ttl"), strings.Split(names, ",")...)
+		}
+
+		relationships, err := webhooks.ParseRelationshipModel(c.String("trace-relationships"))
+		if err != nil {
+			return err
+		}
+
+		sources := []source{
 			{
 				urlPath:   "/github",
 				name:      "github",
@@ -105,16 +439,103 @@ func main() {
 				name:      "customhttp",
 				builderFn: customhttp.NewFromCLI,
 			},
+			{
+				urlPath:   "/argocd",
+				name:      "argocd",
+				builderFn: argocd.NewFromCLI,
+				timed:     true,
+			},
+			{
+				urlPath:   "/buildkite",
+				name:      "buildkite",
+				builderFn: buildkite.NewFromCLI,
+				timed:     true,
+			},
+			{
+				urlPath:   "/circleci",
+				name:      "circleci",
+				builderFn: circleci.NewFromCLI,
+				timed:     true,
+			},
+			{
+				urlPath:   "/cloudevents",
+				name:      "cloudevents",
+				builderFn: cloudevents.NewFromCLI,
+				timed:     true,
+			},
+			{
+				urlPath:   "/drone",
+				name:      "drone",
+				builderFn: drone.NewFromCLI,
+				timed:     true,
+			},
+			{
+				urlPath:   "/flux",
+				name:      "flux",
+				builderFn: flux.NewFromCLI,
+				timed:     true,
+			},
 			{
 				urlPath:   "/jenkins",
 				name:      "jenkins",
 				builderFn: jenkins.NewFromCLI,
+				timed:     true,
 			},
 			{
 				urlPath:   "/jira",
 				name:      "jira",
 				builderFn: jiracloud.NewFromCLI,
 			},
+			{
+				urlPath:   "/jiraserver",
+				name:      "jiraserver",
+				builderFn: jiracloud.NewServerFromCLI,
+			},
+			{
+				urlPath:   "/linear",
+				name:      "linear",
+				builderFn: linear.NewFromCLI,
+				timed:     true,
+			},
+			{
+				urlPath:   "/opsgenie",
+				name:      "opsgenie",
+				builderFn: opsgenie.NewFromCLI,
+				timed:     true,
+			},
+			{
+				urlPath:   "/pagerduty",
+				name:      "pagerduty",
+				builderFn: pagerduty.NewFromCLI,
+				timed:     true,
+			},
+			{
+				urlPath:   "/sentry",
+				name:      "sentry",
+				builderFn: sentry.NewFromCLI,
+				timed:     true,
+			},
+			{
+				urlPath:   "/trello",
+				name:      "trello",
+				builderFn: trello.NewFromCLI,
+				timed:     true,
+			},
+		}
+
+		if path := c.String("mapped-sources"); path != "" {
+			configs, err := mapped.LoadConfigs(path)
+			if err != nil {
+				return err
+			}
+			for _, config := range configs {
+				sources = append(sources, source{
+					urlPath:   config.Path,
+					name:      config.Name,
+					builderFn: mapped.NewFromConfig(config),
+					timed:     true,
+				})
+			}
 		}
 
 		r := mux.NewRouter()
@@ -142,6 +563,19 @@ func main() {
 				tracers.NewRequestScopedUsingSources(),
 				spans,
 			)
+			if err != nil {
+				return err
+			}
+			webhook.Revisions = revisions
+			webhook.Deploys = deploys
+			webhook.Changes = changes
+			webhook.Relationships = relationships
+			webhook.TimedSpans = s.timed
+
+			if placeholders != nil {
+				placeholders.Register(source)
+				webhook.Placeholders = placeholders
+			}
 
 			r.Handle(s.urlPath,
 				ochttp.WithRouteTag(
@@ -151,6 +585,63 @@ func main() {
 			)
 		}
 
+		if c.Bool("kubernetes-watch") {
+			log.Infof("initializing watcher: %q", "kubernetes")
+
+			tracer, closer, err := initialzeTracer(ctx, "kubernetes")
+			if err != nil {
+				return err
+			}
+
+			defer func() {
+				if err := closer.Close(); err != nil {
+					panic(err)
+				}
+			}()
+
+			watcher, err := kubernetes.NewFromCLI(c, tracer)
+			if err != nil {
+				return err
+			}
+			webhook, err := webhooks.New(
+				watcher,
+				tracers.NewRequestScopedUsingSources(),
+				spans,
+			)
+			if err != nil {
+				return err
+			}
+			webhook.Revisions = revisions
+			webhook.Deploys = deploys
+			webhook.Changes = changes
+			webhook.Relationships = relationships
+
+			if placeholders != nil {
+				placeholders.Register(watcher)
+				webhook.Placeholders = placeholders
+			}
+
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				if err := watcher.Run(ctx, webhook); err != nil {
+					log.Fatal(err)
+				}
+			}()
+		}
+
+		if placeholders != nil {
+			if err := placeholders.Validate(); err != nil {
+				return err
+			}
+
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				placeholders.Expire(ctx, spans, time.Minute)
+			}()
+		}
+
 		if c.String("tracer") == "mock" {
 			tracer, _, err := initialzeTracer(ctx, "global")
 			if err != nil {
@@ -180,6 +671,12 @@ func main() {
 			webhooks.EventStartCountView,
 			webhooks.EventEndCountView,
 			webhooks.EventLatencyView,
+			webhooks.RequestRejectedCountView,
+			webhooks.LeadTimeToProductionView,
+			jiracloud.SprintIssuesView,
+			jiracloud.SprintClosedCountView,
+			jiracloud.SprintDurationDeltaView,
+			jiracloud.SprintClosedLateCountView,
 		); err != nil {
 			return fmt.Errorf("failed to register ochttp Server views: %v", err)
 		}
@@ -207,12 +704,12 @@ func main() {
 
 		go func() {
 			log.Infof("Starting Server: %q", c.String("addr"))
-			if err := srv.ListenAndServe(); err != nil {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 				log.Fatal(err)
 			}
 		}()
 
-		waitForShutdown(srv)
+		waitForShutdown(srv, cancel, background)
 
 		return nil
 	}
@@ -223,7 +720,10 @@ func main() {
 	}
 }
 
-func waitForShutdown(srv *http.Server) {
+// waitForShutdown blocks until the process is interrupted, then stops the
+// server, cancels the background work and waits for it to finish, so
+// revisions are saved and tracers are closed before exiting.
+func waitForShutdown(srv *http.Server, cancel context.CancelFunc, background *sync.WaitGroup) {
 	interruptChan := make(chan os.Signal, 1)
 	signal.Notify(interruptChan, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
@@ -231,10 +731,11 @@ func waitForShutdown(srv *http.Server) {
 	<-interruptChan
 
 	// Create a deadline to wait for.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*1)
-	defer cancel()
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), time.Second*1)
+	defer cancelShutdown()
 	srv.Shutdown(ctx)
 
 	log.Println("Shutting down")
-	os.Exit(0)
+	cancel()
+	background.Wait()
 }
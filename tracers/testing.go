@@ -0,0 +1,55 @@
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ImpactInsights/valuestream/eventsources"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+)
+
+// PostFixture sends the test event at eventPath to the path of the
+// service at baseURL, asserting it was handled.
+func PostFixture(t assert.TestingT, baseURL string, path string, eventPath string) {
+	te, err := eventsources.NewTestEventFromFixturePath(eventPath)
+	assert.NoError(t, err)
+
+	rawPayload, err := json.Marshal(te.Payload)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("POST", baseURL+path, bytes.NewReader(rawPayload))
+	assert.NoError(t, err)
+	for k, v := range te.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, eventPath)
+}
+
+// FinishedSpans are the spans the HTTPMockTracer of the service at baseURL
+// has finished.
+func FinishedSpans(t assert.TestingT, baseURL string) []TestSpan {
+	resp, err := http.Get(baseURL + "/mocktracer/finished-spans")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	bs, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	var spans []TestSpan
+	assert.NoError(t, json.Unmarshal(bs, &spans))
+	return spans
+}
+
+// ResetTracer resets the HTTPMockTracer of the service at baseURL.
+func ResetTracer(t assert.TestingT, baseURL string) {
+	resp, err := http.Get(baseURL + "/mocktracer/reset")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
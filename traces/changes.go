@@ -0,0 +1,161 @@
+package traces
+
+import (
+	"context"
+	"fmt"
+	"github.com/opentracing/opentracing-go"
+	"sync"
+	"time"
+)
+
+// Change is a pull request which a deploy may ship.
+type Change struct {
+	SpanID  string                  `json:"span_id"`
+	Context opentracing.SpanContext `json:"-"`
+	// Carrier is the Context injected as a text map, which is all of it
+	// that's saved.  Changes loaded from a path only have their Carrier.
+	Carrier   map[string]string `json:"carrier"`
+	StartTime time.Time         `json:"start_time"`
+}
+
+// SpanContext is the change's Context, or else the context the tracer
+// extracts from its Carrier.
+func (c Change) SpanContext(tracer opentracing.Tracer) (opentracing.SpanContext, error) {
+	if c.Context != nil {
+		return c.Context, nil
+	}
+	if len(c.Carrier) == 0 {
+		return nil, fmt.Errorf("change: %q has no span context", c.SpanID)
+	}
+	return tracer.Extract(opentracing.TextMap, opentracing.TextMapCarrier(c.Carrier))
+}
+
+// Changes remembers the spans of pull requests, so the deploys which ship
+// them are linked to them and measure their lead time to production.  The
+// pull requests have usually been merged by then, so their span context
+// is kept rather than their id.  Only the last maxChanges pull requests
+// started are remembered.
+//
+// Changes loaded from a path are saved back to it by Persist, so the pull
+// requests deploys ship after a restart still measure their lead time.
+type Changes struct {
+	mu         *sync.Mutex
+	changes    map[string]Change
+	order      []string
+	maxChanges int
+
+	path  string
+	dirty bool
+}
+
+func (c *Changes) Set(change Change) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.changes[change.SpanID]; !ok {
+		c.order = append(c.order, change.SpanID)
+	}
+	c.changes[change.SpanID] = change
+	c.evict()
+	c.dirty = true
+}
+
+// evict forgets the oldest changes beyond maxChanges, c.mu must be held.
+func (c *Changes) evict() {
+	for len(c.order) > c.maxChanges {
+		delete(c.changes, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+func (c *Changes) Get(spanID string) *Change {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	change, ok := c.changes[spanID]
+	if !ok {
+		return nil
+	}
+	return &change
+}
+
+// Between are the changes of the commits the revisions indexed after the
+// commit from, up to and including the commit to, in the order they were
+// indexed.  Without from, ie the first deploy of a service, only the
+// changes of to are.
+func (c *Changes) Between(revisions *Revisions, from, to string) []Change {
+	var changes []Change
+	seen := make(map[string]bool)
+	for _, sha := range revisions.Range(from, to) {
+		for _, spanID := range revisions.Spans(sha) {
+			if seen[spanID] {
+				continue
+			}
+			seen[spanID] = true
+			if change := c.Get(spanID); change != nil {
+				changes = append(changes, *change)
+			}
+		}
+	}
+	return changes
+}
+
+// Save writes the changes which carry their context to the path they
+// were loaded from, if any.
+func (c *Changes) Save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	changes := make([]Change, 0, len(c.order))
+	for _, id := range c.order {
+		if change := c.changes[id]; len(change.Carrier) > 0 {
+			changes = append(changes, change)
+		}
+	}
+	c.dirty = false
+	c.mu.Unlock()
+
+	return saveJSON(c.path, changes)
+}
+
+func (c *Changes) changed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dirty
+}
+
+// Persist saves the changes every interval they've changed, until the
+// context is done.
+func (c *Changes) Persist(ctx context.Context, interval time.Duration) {
+	persist(ctx, c, interval, c.path, "traces.Changes.Persist")
+}
+
+func NewChanges(maxChanges int) *Changes {
+	return &Changes{
+		mu:         &sync.Mutex{},
+		changes:    make(map[string]Change),
+		maxChanges: maxChanges,
+	}
+}
+
+// LoadChanges are the changes saved to the path, which is created when
+// they're first saved.
+func LoadChanges(path string, maxChanges int) (*Changes, error) {
+	c := NewChanges(maxChanges)
+	c.path = path
+
+	var changes []Change
+	if err := loadJSON(path, &changes); err != nil {
+		return nil, err
+	}
+
+	for _, change := range changes {
+		if _, ok := c.changes[change.SpanID]; !ok {
+			c.order = append(c.order, change.SpanID)
+		}
+		c.changes[change.SpanID] = change
+	}
+	c.evict()
+	return c, nil
+}
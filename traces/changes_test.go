@@ -0,0 +1,159 @@
+package traces
+
+import (
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// merged indexes the merge commit of a pull request of the repository.
+func merged(r *Revisions, repository, sha, spanID string) {
+	r.SetTags(map[string]interface{}{
+		"scm.merge.sha":            sha,
+		"scm.repository.full_name": repository,
+	}, spanID, "pull_request")
+}
+
+func TestRevisions_Range(t *testing.T) {
+	r := NewRevisions(10, 0)
+	merged(r, "shop/checkout", "a", "build-1")
+	r.SetBranch("feature/login", "pr-1")
+	merged(r, "shop/checkout", "b", "pr-1")
+	merged(r, "shop/payments", "x", "pr-3")
+	// the head of a branch which hasn't been merged yet
+	r.SetTags(map[string]interface{}{
+		RevisionTag:                "h",
+		"scm.repository.full_name": "shop/checkout",
+	}, "pr-4", "pull_request")
+	merged(r, "shop/checkout", "c", "pr-2")
+	r.Set("d", "build-2")
+	// a commit pushed to the branch and built without a pull request
+	r.SetTags(map[string]interface{}{
+		"build.sha":                "e",
+		"scm.repository.full_name": "shop/checkout",
+	}, "build-3", "build")
+
+	assert.Equal(t, []string{"b", "c"}, r.Range("a", "c"))
+	assert.Equal(t, []string{"c"}, r.Range("unknown", "c"))
+	assert.Equal(t, []string{"c"}, r.Range("", "c"))
+	assert.Nil(t, r.Range("a", "unknown"))
+	assert.Nil(t, r.Range("c", "a"))
+
+	// commits whose position on the branch isn't known are skipped,
+	// unless they're an end of the range
+	assert.Equal(t, []string{"c", "e"}, r.Range("b", "e"))
+	assert.Equal(t, []string{"h"}, r.Range("b", "h"))
+	assert.Equal(t, []string{"c"}, r.Range("h", "c"))
+
+	// commits are only ranged within their repository
+	assert.Equal(t, []string{"x"}, r.Range("a", "x"))
+	assert.Equal(t, []string{"d"}, r.Range("a", "d"))
+}
+
+func TestRepositoryKey(t *testing.T) {
+	for _, repository := range []string{
+		"Shop/Checkout",
+		"https://github.com/shop/checkout.git",
+		"https://api.github.com/repos/shop/checkout",
+		"git@github.com:shop/checkout.git",
+		"https://gitlab.com/org/shop/checkout/",
+	} {
+		assert.Equal(t, "shop/checkout", repositoryKey(repository), repository)
+	}
+}
+
+func TestChanges_Between(t *testing.T) {
+	tracer := mocktracer.New()
+	started := time.Date(2000, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	c := NewChanges(10)
+	for _, id := range []string{"pr-1", "pr-2"} {
+		c.Set(Change{
+			SpanID:    id,
+			Context:   tracer.StartSpan("pull_request").Context(),
+			StartTime: started,
+		})
+	}
+
+	r := NewRevisions(10, 0)
+	merged(r, "shop/checkout", "a", "build-1")
+	merged(r, "shop/checkout", "b", "pr-1")
+	r.Set("b", "build-2")
+	merged(r, "shop/checkout", "c", "pr-2")
+	r.Set("c", "pr-1")
+
+	var ids []string
+	for _, change := range c.Between(r, "a", "c") {
+		ids = append(ids, change.SpanID)
+	}
+	assert.Equal(t, []string{"pr-1", "pr-2"}, ids)
+
+	assert.Empty(t, c.Between(r, "a", "a"))
+}
+
+func TestChanges_Set_EvictsOldest(t *testing.T) {
+	c := NewChanges(1)
+	c.Set(Change{SpanID: "pr-1"})
+	c.Set(Change{SpanID: "pr-2"})
+
+	assert.Nil(t, c.Get("pr-1"))
+	assert.NotNil(t, c.Get("pr-2"))
+}
+
+func TestLoadChanges_Saved(t *testing.T) {
+	dir, err := ioutil.TempDir("", "changes")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "changes.json")
+
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("pull_request")
+	carrier := opentracing.TextMapCarrier{}
+	assert.NoError(t, tracer.Inject(span.Context(), opentracing.TextMap, carrier))
+	started := time.Date(2000, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	c, err := LoadChanges(path, 10)
+	assert.NoError(t, err)
+	c.Set(Change{SpanID: "pr-1", Context: span.Context(), Carrier: carrier, StartTime: started})
+	// changes without a carrier can't be restored
+	c.Set(Change{SpanID: "pr-2", Context: span.Context(), StartTime: started})
+	assert.True(t, c.changed())
+	assert.NoError(t, c.Save())
+	assert.False(t, c.changed())
+
+	loaded, err := LoadChanges(path, 10)
+	assert.NoError(t, err)
+	assert.Nil(t, loaded.Get("pr-2"))
+	if change := loaded.Get("pr-1"); assert.NotNil(t, change) {
+		assert.Nil(t, change.Context)
+		assert.Equal(t, started, change.StartTime)
+
+		ctx, err := change.SpanContext(tracer)
+		assert.NoError(t, err)
+		assert.Equal(t, span.Context().(mocktracer.MockSpanContext).SpanID, ctx.(mocktracer.MockSpanContext).SpanID)
+	}
+}
+
+func TestLoadDeploys_Saved(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deploys")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "deploys.json")
+
+	d, err := LoadDeploys(path, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "", d.Shipped("checkout", "production"))
+	d.SetShipped("checkout", "production", "m1")
+	d.SetShipped("checkout", "staging", "m2")
+	assert.NoError(t, d.Save())
+
+	loaded, err := LoadDeploys(path, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "m1", loaded.Shipped("Checkout", "production"))
+	assert.Equal(t, "m2", loaded.Shipped("checkout", "staging"))
+}
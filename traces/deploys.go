@@ -0,0 +1,170 @@
+package traces
+
+import (
+	"context"
+	"github.com/opentracing/opentracing-go"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProductionEnvironment is assumed of deploys, and of the incidents
+// following from them, which don't know their environment.
+const ProductionEnvironment string = "production"
+
+// Deploys remembers the span of the most recent deploy of each service to
+// each environment, incidents affecting a service in an environment are
+// linked to it.  The deploy has usually finished by then, so its span
+// context is kept rather than its id.
+//
+// Deploys are also remembered by the revision they shipped, a commit or a
+// release name, so that defects observed in a release can be linked to
+// the deploy which introduced them.  Only the last maxRevisions revisions
+// deployed are remembered.
+//
+// The commit each service last deployed successfully to an environment
+// is remembered too, the next deploy of it ships the commits since.  Only
+// the shipped commits of Deploys loaded from a path are saved back to it
+// by Persist, so the first deploy after a restart doesn't ship every
+// change since the beginning.
+type Deploys struct {
+	mu       *sync.Mutex
+	services map[string]opentracing.SpanContext
+	shipped  map[string]string
+
+	revisions    map[string]opentracing.SpanContext
+	order        []string
+	maxRevisions int
+
+	path  string
+	dirty bool
+}
+
+func (d *Deploys) Set(service, environment string, ctx opentracing.SpanContext) {
+	if service == "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.services[serviceKey(service, orProduction(environment))] = ctx
+}
+
+func (d *Deploys) Get(service, environment string) opentracing.SpanContext {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.services[serviceKey(service, orProduction(environment))]
+}
+
+func orProduction(environment string) string {
+	if environment == "" {
+		return ProductionEnvironment
+	}
+	return environment
+}
+
+func (d *Deploys) SetRevision(revision string, ctx opentracing.SpanContext) {
+	if revision == "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.revisions[revision]; !ok {
+		d.order = append(d.order, revision)
+	}
+	d.revisions[revision] = ctx
+
+	for len(d.order) > d.maxRevisions {
+		delete(d.revisions, d.order[0])
+		d.order = d.order[1:]
+	}
+}
+
+func (d *Deploys) GetRevision(revision string) opentracing.SpanContext {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.revisions[revision]
+}
+
+// SetShipped remembers the commit a service was successfully deployed at
+// to an environment.
+func (d *Deploys) SetShipped(service, environment, sha string) {
+	if service == "" || sha == "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.shipped[serviceKey(service, environment)] = sha
+	d.dirty = true
+}
+
+// Shipped is the commit a service was last successfully deployed at to an
+// environment, empty when it hasn't been.
+func (d *Deploys) Shipped(service, environment string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.shipped[serviceKey(service, environment)]
+}
+
+// serviceKey matches services and environments by name, ignoring case.
+func serviceKey(service, environment string) string {
+	return strings.ToLower(service) + "/" + strings.ToLower(environment)
+}
+
+// Save writes the shipped commits to the path the deploys were loaded
+// from, if any.
+func (d *Deploys) Save() error {
+	if d.path == "" {
+		return nil
+	}
+
+	d.mu.Lock()
+	shipped := make(map[string]string, len(d.shipped))
+	for key, sha := range d.shipped {
+		shipped[key] = sha
+	}
+	d.dirty = false
+	d.mu.Unlock()
+
+	return saveJSON(d.path, shipped)
+}
+
+func (d *Deploys) changed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dirty
+}
+
+// Persist saves the shipped commits every interval they've changed, until
+// the context is done.
+func (d *Deploys) Persist(ctx context.Context, interval time.Duration) {
+	persist(ctx, d, interval, d.path, "traces.Deploys.Persist")
+}
+
+func NewDeploys(maxRevisions int) *Deploys {
+	return &Deploys{
+		mu:           &sync.Mutex{},
+		services:     make(map[string]opentracing.SpanContext),
+		shipped:      make(map[string]string),
+		revisions:    make(map[string]opentracing.SpanContext),
+		maxRevisions: maxRevisions,
+	}
+}
+
+// LoadDeploys are the deploys whose shipped commits were saved to the
+// path, which is created when they're first saved.
+func LoadDeploys(path string, maxRevisions int) (*Deploys, error) {
+	d := NewDeploys(maxRevisions)
+	d.path = path
+
+	if err := loadJSON(path, &d.shipped); err != nil {
+		return nil, err
+	}
+	if d.shipped == nil {
+		d.shipped = make(map[string]string)
+	}
+	return d, nil
+}
@@ -0,0 +1,85 @@
+package traces
+
+import (
+	"context"
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// persisted indexes are saved back to the path they were loaded from, so
+// they survive restarts.
+type persisted interface {
+	// changed reports whether the index changed since it was last saved.
+	changed() bool
+	Save() error
+}
+
+// persist saves the index every interval it's changed, and once more when
+// the context is done.
+func persist(ctx context.Context, p persisted, interval time.Duration, path string, name string) {
+	ticker := time.NewTicker(interval)
+
+	save := func() {
+		if !p.changed() {
+			return
+		}
+		if err := p.Save(); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"path":  path,
+			}).Errorf(name)
+		}
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			save()
+		case <-ctx.Done():
+			ticker.Stop()
+			save()
+			return
+		}
+	}
+}
+
+// saveJSON replaces the file at the path with v, written to a temporary
+// file first so a crash never leaves it half written.
+func saveJSON(path string, v interface{}) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bs); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadJSON reads the file at the path into v, it's left untouched when the
+// file hasn't been saved yet.
+func loadJSON(path string, v interface{}) error {
+	bs, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, v)
+}
@@ -0,0 +1,256 @@
+package traces
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// Reference is a pattern of references to a span in free text, ie a
+// branch name, description or commit message, and the template of the
+// span id it refers to.  Templates are expanded with `${0}` the whole
+// match, `${name}` a named group of the pattern, or else a variable of
+// the event the text is from, ie `${repo}` the name of its repository.
+// Matches which leave a template variable unset are skipped.  A reference
+// with Sources only applies to the events of those sources.
+type Reference struct {
+	Pattern  string   `json:"pattern"`
+	Template string   `json:"template"`
+	Sources  []string `json:"sources"`
+
+	re *regexp.Regexp
+}
+
+var templateVar = regexp.MustCompile(`\$\{(\w+)\}`)
+
+func (r Reference) appliesTo(source string) bool {
+	if len(r.Sources) == 0 {
+		return true
+	}
+	for _, s := range r.Sources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// expand the template with the groups of a match, false when a variable
+// isn't set.
+func (r Reference) expand(match []string, vars map[string]string) (string, bool) {
+	ok := true
+	id := templateVar.ReplaceAllStringFunc(r.Template, func(v string) string {
+		name := templateVar.FindStringSubmatch(v)[1]
+		if name == "0" {
+			return match[0]
+		}
+		for i, group := range r.re.SubexpNames() {
+			if group == name && match[i] != "" {
+				return match[i]
+			}
+		}
+		if value := vars[name]; value != "" {
+			return value
+		}
+		ok = false
+		return ""
+	})
+	return id, ok
+}
+
+// References are tried in order, span ids are extracted by every
+// reference which applies to the event's source.
+type References []Reference
+
+var defaultReferences = mustCompile(References{
+	{
+		Pattern:  `vstrace-[0-9A-Za-z]+-[0-9A-Za-z_]+-[0-9A-Za-z-]+-[0-9A-Za-z-]+`,
+		Template: "${0}",
+	},
+	{
+		Pattern:  `(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?):?\s+(?P<owner>[\w.-]+)/(?P<repo>[\w.-]+)#(?P<number>\d+)\b`,
+		Template: "vstrace-github-issue-${repo}-${number}",
+		Sources:  []string{"github"},
+	},
+	{
+		Pattern:  `(?:^|[^\w/])#(?P<number>\d+)\b`,
+		Template: "vstrace-github-issue-${repo}-${number}",
+		Sources:  []string{"github"},
+	},
+	{
+		Pattern:  `(?P<namespace>[\w.-]+)/(?P<project>[\w.-]+)#(?P<iid>\d+)\b`,
+		Template: "vstrace-gitlab-issue-${project}-${iid}",
+		Sources:  []string{"gitlab"},
+	},
+	{
+		Pattern:  `(?:^|[^\w/])#(?P<iid>\d+)\b`,
+		Template: "vstrace-gitlab-issue-${project}-${iid}",
+		Sources:  []string{"gitlab"},
+	},
+	{
+		Pattern:  `(?:^|[^\w/])!(?P<iid>\d+)\b`,
+		Template: "vstrace-gitlab-pull_request-${project}-${iid}",
+		Sources:  []string{"gitlab"},
+	},
+	JiraReference(),
+})
+
+// jiraIssueTemplate is the span id of an issue traced by the jiracloud
+// source, ie `vstrace-jiracloud-issue-ABC-123`.
+const jiraIssueTemplate string = "vstrace-jiracloud-issue-${key}"
+
+// JiraReference refers to the jira issues of the projects by their keys,
+// ie `ABC-123`.  Without projects any key is referred to, but only where
+// keys are placed in branches, titles and commit messages: at the start
+// of the text or after a `/`, `[` or `(`, and followed by the end of the
+// text or one of `/`, `]`, `)`, `:`, `-` or `_`.  So `feature/ABC-123-login`
+// and `[ABC-123] login` refer to ABC-123, but `uses SHA-256` does not.
+func JiraReference(projects ...string) Reference {
+	if len(projects) == 0 {
+		return Reference{
+			Pattern:  `(?:^|[/\[(])(?P<key>[A-Z][A-Z0-9_]+-\d+)(?:$|[/\]):_-])`,
+			Template: jiraIssueTemplate,
+		}
+	}
+
+	keys := make([]string, len(projects))
+	for i, p := range projects {
+		keys[i] = regexp.QuoteMeta(p)
+	}
+	return Reference{
+		Pattern:  `\b(?P<key>(?:` + strings.Join(keys, "|") + `)-\d+)\b`,
+		Template: jiraIssueTemplate,
+	}
+}
+
+func mustCompile(rs References) References {
+	if err := rs.Compile(); err != nil {
+		panic(err)
+	}
+	return rs
+}
+
+// DefaultReferences extract the span ids of vstrace ids, jira keys
+// (`ABC-123`), github issues (`#123`, `Closes org/repo#9`) and gitlab
+// issues and merge requests (`#123`, `group/project#9`, `!45`).  Jira
+// keys are restricted to the jira projects, when there are any.
+func DefaultReferences(projects ...string) References {
+	if len(projects) == 0 {
+		return defaultReferences
+	}
+
+	rs := make(References, len(defaultReferences))
+	copy(rs, defaultReferences)
+	rs[len(rs)-1] = JiraReference(projects...)
+	return mustCompile(rs)
+}
+
+// Compile validates the references and compiles their patterns, it must
+// be called before the references are used.
+func (rs References) Compile() error {
+	for i := range rs {
+		if rs[i].Pattern == "" || rs[i].Template == "" {
+			return fmt.Errorf("reference is missing a pattern or template")
+		}
+		re, err := regexp.Compile(rs[i].Pattern)
+		if err != nil {
+			return err
+		}
+		rs[i].re = re
+	}
+	return nil
+}
+
+// Extract returns the span ids the text of a source's event refers to,
+// in the order of the references then of their position in the text.
+// Nil References extract by the DefaultReferences.
+func (rs References) Extract(source string, text string, vars map[string]string) []string {
+	if rs == nil {
+		rs = defaultReferences
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, r := range rs {
+		if !r.appliesTo(source) {
+			continue
+		}
+		for _, match := range r.re.FindAllStringSubmatch(text, -1) {
+			id, ok := r.expand(match, vars)
+			if !ok || seen[id] {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// First is the first span id the texts refer to, the texts are tried in
+// order.  It's nil when none of them refer to a span.
+func (rs References) First(source string, vars map[string]string, texts ...string) *string {
+	for _, text := range texts {
+		if ids := rs.Extract(source, text, vars); len(ids) > 0 {
+			return &ids[0]
+		}
+	}
+	return nil
+}
+
+// All are the span ids any of the texts refer to, in the order of the
+// texts, without duplicates.
+func (rs References) All(source string, vars map[string]string, texts ...string) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, id := range rs.Extract(source, text, vars) {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// LoadReferences reads references from a json file holding an array of
+// them.
+func LoadReferences(path string) (References, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rs References
+	if err := json.Unmarshal(bs, &rs); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Compile(); err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+// ReferencesFromCLI are the references in the file of the
+// `trace-references` flag, or the DefaultReferences of the projects of the
+// `jira-projects` flag when it isn't set.
+func ReferencesFromCLI(c *cli.Context) (References, error) {
+	path := c.String("trace-references")
+	if path == "" {
+		var projects []string
+		for _, p := range strings.Split(c.String("jira-projects"), ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				projects = append(projects, p)
+			}
+		}
+		return DefaultReferences(projects...), nil
+	}
+	return LoadReferences(path)
+}
@@ -0,0 +1,142 @@
+package traces
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReferences_Extract_Defaults(t *testing.T) {
+	testCases := []struct {
+		name     string
+		source   string
+		text     string
+		vars     map[string]string
+		expected []string
+	}{
+		{
+			"vstrace_id",
+			"jenkins",
+			"feature/vstrace-github-pull_request-valuestream-1/hi",
+			nil,
+			[]string{"vstrace-github-pull_request-valuestream-1"},
+		},
+		{
+			"jira_key",
+			"jenkins",
+			"feature/ABC-123-login",
+			nil,
+			[]string{"vstrace-jiracloud-issue-ABC-123"},
+		},
+		{
+			"jira_key_prefixed_title",
+			"github",
+			"[ABC-123] login",
+			nil,
+			[]string{"vstrace-jiracloud-issue-ABC-123"},
+		},
+		{
+			"not_jira_keys",
+			"jenkins",
+			"Encode as UTF-8, sign with SHA-256 and format as ISO-8601",
+			nil,
+			nil,
+		},
+		{
+			"github_issue",
+			"github",
+			"Fixes #12, see #14",
+			map[string]string{"repo": "valuestream"},
+			[]string{"vstrace-github-issue-valuestream-12", "vstrace-github-issue-valuestream-14"},
+		},
+		{
+			"github_closes_other_repo",
+			"github",
+			"Closes ImpactInsights/valuestream-deploy#9",
+			map[string]string{"repo": "valuestream"},
+			[]string{"vstrace-github-issue-valuestream-deploy-9"},
+		},
+		{
+			"github_issue_without_repo",
+			"github",
+			"Fixes #12",
+			nil,
+			nil,
+		},
+		{
+			"gitlab_merge_request_and_issue",
+			"gitlab",
+			"Depends on !45, closes #8 and group/other#3",
+			map[string]string{"project": "valuestream"},
+			[]string{
+				"vstrace-gitlab-issue-other-3",
+				"vstrace-gitlab-issue-valuestream-8",
+				"vstrace-gitlab-pull_request-valuestream-45",
+			},
+		},
+		{
+			"github_references_not_gitlab",
+			"gitlab",
+			"!45",
+			map[string]string{"repo": "valuestream"},
+			nil,
+		},
+		{
+			"no_references",
+			"github",
+			"feature/login",
+			map[string]string{"repo": "valuestream"},
+			nil,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, DefaultReferences().Extract(tt.source, tt.text, tt.vars))
+		})
+	}
+}
+
+func TestDefaultReferences_JiraProjects(t *testing.T) {
+	rs := DefaultReferences("ABC", "OPS")
+
+	assert.Equal(t, []string{
+		"vstrace-jiracloud-issue-OPS-7",
+		"vstrace-jiracloud-issue-ABC-12",
+	}, rs.All("jenkins", nil, "Fix OPS-7 with SHA-256", "feature/ABC-12-login", "XYZ-3"))
+
+	// the defaults are left alone
+	assert.Equal(t, []string{"vstrace-jiracloud-issue-XYZ-3"}, DefaultReferences().Extract("jenkins", "XYZ-3", nil))
+}
+
+func TestReferences_First(t *testing.T) {
+	rs := References{
+		{Pattern: `(?P<key>[A-Z]+-\d+)`, Template: "vstrace-jiraserver-issue-${key}"},
+	}
+	assert.NoError(t, rs.Compile())
+
+	parent := rs.First("jenkins", nil, "feature/login", "OPS-7: fix login")
+	assert.Equal(t, "vstrace-jiraserver-issue-OPS-7", *parent)
+
+	assert.Nil(t, rs.First("jenkins", nil, "feature/login"))
+}
+
+func TestReferences_All(t *testing.T) {
+	vars := map[string]string{"repo": "valuestream"}
+
+	ids := DefaultReferences().All("github", vars,
+		"ABC-1-login",
+		"Fixes #3, closes #4",
+		"ABC-1: fixes #3",
+	)
+	assert.Equal(t, []string{
+		"vstrace-jiracloud-issue-ABC-1",
+		"vstrace-github-issue-valuestream-3",
+		"vstrace-github-issue-valuestream-4",
+	}, ids)
+
+	assert.Nil(t, DefaultReferences().All("github", vars, "feature/login"))
+}
+
+func TestReferences_Compile_Invalid(t *testing.T) {
+	assert.Error(t, References{{Pattern: "(", Template: "${0}"}}.Compile())
+	assert.Error(t, References{{Pattern: "ABC-\\d+"}}.Compile())
+}
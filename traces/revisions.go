@@ -0,0 +1,403 @@
+package traces
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RevisionTag is the tag spans are started with which names the commit
+// they were built or reviewed at.
+const RevisionTag string = "scm.head.sha"
+
+// mergeTag names the commit a pull request was merged as, which landed on
+// the branch it was merged into.
+const mergeTag string = "scm.merge.sha"
+
+var (
+	// revisionTags name the commit a span was built, reviewed or
+	// deployed at, and branchTags its branch, by the sources which
+	// tag them.
+	revisionTags = []string{RevisionTag, "scm.commit.sha", mergeTag, "build.sha"}
+	branchTags   = []string{"scm.branch", "scm.head.ref", "build.ref"}
+	// repositoryTags name the repository of a span's commits, by its name
+	// or url, in the order they're preferred.
+	repositoryTags = []string{
+		"scm.repository.full_name",
+		"project.path_with_namespace",
+		"scm.base.repo.full_name",
+		"scm.repository.url",
+		"scm.url",
+		"scm.head.url",
+		"scm.repository",
+	}
+)
+
+const branchPrefix = "branch:"
+
+type revisionSpan struct {
+	SpanID    string    `json:"span_id"`
+	Operation string    `json:"operation,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type revisionEntry struct {
+	Key        string         `json:"key"`
+	Repository string         `json:"repository,omitempty"`
+	Merge      bool           `json:"merge,omitempty"`
+	Spans      []revisionSpan `json:"spans"`
+}
+
+// repositoryKey identifies a repository by the last two segments of its
+// name or url, so `owner/repo`, `https://github.com/owner/repo.git`,
+// `git@github.com:owner/repo` and the api url of it are the same
+// repository.
+func repositoryKey(repository string) string {
+	repository = strings.ToLower(strings.TrimSpace(repository))
+	repository = strings.TrimSuffix(strings.TrimSuffix(repository, "/"), ".git")
+	segments := strings.FieldsFunc(repository, func(r rune) bool {
+		return r == '/' || r == ':'
+	})
+	if len(segments) > 2 {
+		segments = segments[len(segments)-2:]
+	}
+	return strings.Join(segments, "/")
+}
+
+// repositoryOf the first of the tags naming a repository.
+func repositoryOf(tags map[string]interface{}) string {
+	for _, t := range repositoryTags {
+		if repository, ok := tags[t].(string); ok && repository != "" {
+			return repositoryKey(repository)
+		}
+	}
+	return ""
+}
+
+// Revisions indexes the spans started for each commit and branch, so
+// events which only know the commit or branch they're for can be placed
+// beneath them, ie a jenkins build beneath the github pull request of
+// its commit.  Only the last maxRevisions commits and branches seen are
+// remembered, for ttl when it's set.
+//
+// The repository of each commit is remembered when the span is tagged with
+// it, so the commits shipped by a deploy are only those of its repository.
+//
+// Commits are ordered by when they were first indexed.  Merge commits are
+// assumed to be indexed in the order they landed on the branch they were
+// merged into, as pull requests are merged one at a time, so the merges
+// between two commits of a repository are those indexed between them.
+// Other commits, ie the head of a branch or a build of it, have no known
+// position on the branch deployed and are only ranged as its endpoints.
+//
+// Revisions loaded from a path are saved back to it by Persist, so they
+// survive restarts.
+type Revisions struct {
+	mu           *sync.Mutex
+	spans        map[string][]revisionSpan
+	repositories map[string]string
+	merges       map[string]bool
+	order        []string
+	maxRevisions int
+	ttl          time.Duration
+
+	path  string
+	dirty bool
+	now   func() time.Time
+}
+
+// Set remembers the span started for a commit.
+func (r *Revisions) Set(sha, spanID string) {
+	if sha == "" {
+		return
+	}
+	r.set(sha, "", spanID, "")
+}
+
+// SetBranch remembers the span started for a branch.
+func (r *Revisions) SetBranch(branch, spanID string) {
+	if branch == "" {
+		return
+	}
+	r.set(branchPrefix+branch, "", spanID, "")
+}
+
+// SetTags remembers the span of the operation for every commit and branch
+// it's tagged with, and the repository of its commits.
+func (r *Revisions) SetTags(tags map[string]interface{}, spanID, operation string) {
+	repository := repositoryOf(tags)
+	for _, t := range revisionTags {
+		if sha, ok := tags[t].(string); ok && sha != "" {
+			r.set(sha, repository, spanID, operation)
+			if t == mergeTag {
+				r.setMerge(sha)
+			}
+		}
+	}
+	for _, t := range branchTags {
+		if branch, ok := tags[t].(string); ok && branch != "" {
+			r.set(branchPrefix+branch, "", spanID, operation)
+		}
+	}
+}
+
+func (r *Revisions) set(key, repository, spanID, operation string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	spans, ok := r.spans[key]
+	if !ok {
+		r.order = append(r.order, key)
+	}
+	if _, ok := r.repositories[key]; !ok && repository != "" {
+		r.repositories[key] = repository
+	}
+
+	live := make([]revisionSpan, 0, len(spans)+1)
+	for _, s := range r.live(spans) {
+		if s.SpanID != spanID {
+			live = append(live, s)
+		}
+	}
+	span := revisionSpan{SpanID: spanID, Operation: operation}
+	if r.ttl > 0 {
+		span.ExpiresAt = r.now().Add(r.ttl)
+	}
+	r.spans[key] = append(live, span)
+
+	r.evict()
+	r.dirty = true
+}
+
+func (r *Revisions) setMerge(sha string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.spans[sha]; ok && !r.merges[sha] {
+		r.merges[sha] = true
+		r.dirty = true
+	}
+}
+
+// evict forgets the oldest commits and branches beyond maxRevisions, r.mu
+// must be held.
+func (r *Revisions) evict() {
+	for len(r.order) > r.maxRevisions {
+		delete(r.spans, r.order[0])
+		delete(r.repositories, r.order[0])
+		delete(r.merges, r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+// live are the spans which haven't expired, r.mu must be held.
+func (r *Revisions) live(spans []revisionSpan) []revisionSpan {
+	now := r.now()
+	live := make([]revisionSpan, 0, len(spans))
+	for _, s := range spans {
+		if s.ExpiresAt.IsZero() || s.ExpiresAt.After(now) {
+			live = append(live, s)
+		}
+	}
+	return live
+}
+
+// Get is the span which last started for a commit.
+func (r *Revisions) Get(sha string) *string {
+	spans := r.Spans(sha)
+	if len(spans) == 0 {
+		return nil
+	}
+	return &spans[len(spans)-1]
+}
+
+// Spans are the spans started for a commit, in the order they started.
+func (r *Revisions) Spans(sha string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ids []string
+	for _, s := range r.live(r.spans[sha]) {
+		ids = append(ids, s.SpanID)
+	}
+	return ids
+}
+
+// BranchSpans are the spans started for a branch, in the order they
+// started.
+func (r *Revisions) BranchSpans(branch string) []string {
+	return r.Spans(branchPrefix + branch)
+}
+
+// TagSpans are the spans started for the commits, then every commit and
+// then every branch of the tags, in the order they started.  Spans of the
+// preferred operations come before the rest, ie a build prefers the pull
+// request of its commit over an earlier build of it.
+func (r *Revisions) TagSpans(commits []string, tags map[string]interface{}, preferred ...string) []string {
+	keys := append([]string(nil), commits...)
+	for _, t := range revisionTags {
+		if sha, ok := tags[t].(string); ok {
+			keys = append(keys, sha)
+		}
+	}
+	for _, t := range branchTags {
+		if branch, ok := tags[t].(string); ok && branch != "" {
+			keys = append(keys, branchPrefix+branch)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var first, rest []string
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		for _, s := range r.live(r.spans[key]) {
+			if isOperation(s.Operation, preferred) {
+				first = append(first, s.SpanID)
+			} else {
+				rest = append(rest, s.SpanID)
+			}
+		}
+	}
+	return append(first, rest...)
+}
+
+func isOperation(operation string, operations []string) bool {
+	for _, o := range operations {
+		if o == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// Range are the merge commits of the repository of the commit to which were
+// indexed after the commit from, and to itself, in the order they were
+// first indexed.  Commits are only ranged within the repository they were
+// tagged with, as the order commits of different repositories arrive in
+// says nothing about which was built on which, and other commits are
+// skipped as their position on the branch isn't known.  It's only to when
+// from isn't indexed in the repository, or to's repository isn't known,
+// and empty when to isn't indexed or was indexed before from, ie a
+// rollback.
+func (r *Revisions) Range(from, to string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.spans[to]; !ok {
+		return nil
+	}
+
+	repository := r.repositories[to]
+	if repository == "" {
+		return []string{to}
+	}
+
+	var commits []string
+	for _, key := range r.order {
+		if r.repositories[key] != repository {
+			continue
+		}
+		if key == from || key == to || r.merges[key] {
+			commits = append(commits, key)
+		}
+	}
+
+	start, end := -1, -1
+	for i, key := range commits {
+		switch key {
+		case from:
+			start = i
+		case to:
+			end = i
+		}
+	}
+
+	if start == -1 {
+		return []string{to}
+	}
+	if start >= end {
+		return nil
+	}
+	return commits[start+1 : end+1]
+}
+
+// Save writes the live revisions to the path they were loaded from, if
+// any.
+func (r *Revisions) Save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	entries := make([]revisionEntry, 0, len(r.order))
+	for _, key := range r.order {
+		if spans := r.live(r.spans[key]); len(spans) > 0 {
+			entries = append(entries, revisionEntry{
+				Key:        key,
+				Repository: r.repositories[key],
+				Merge:      r.merges[key],
+				Spans:      spans,
+			})
+		}
+	}
+	r.dirty = false
+	r.mu.Unlock()
+
+	return saveJSON(r.path, entries)
+}
+
+func (r *Revisions) changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dirty
+}
+
+// Persist saves the revisions every interval they've changed, until the
+// context is done.
+func (r *Revisions) Persist(ctx context.Context, interval time.Duration) {
+	persist(ctx, r, interval, r.path, "traces.Revisions.Persist")
+}
+
+func NewRevisions(maxRevisions int, ttl time.Duration) *Revisions {
+	return &Revisions{
+		mu:           &sync.Mutex{},
+		spans:        make(map[string][]revisionSpan),
+		repositories: make(map[string]string),
+		merges:       make(map[string]bool),
+		maxRevisions: maxRevisions,
+		ttl:          ttl,
+		now:          time.Now,
+	}
+}
+
+// LoadRevisions are the revisions saved to the path, which is created
+// when they're first saved.
+func LoadRevisions(path string, maxRevisions int, ttl time.Duration) (*Revisions, error) {
+	r := NewRevisions(maxRevisions, ttl)
+	r.path = path
+
+	var entries []revisionEntry
+	if err := loadJSON(path, &entries); err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if _, ok := r.spans[e.Key]; !ok {
+			r.order = append(r.order, e.Key)
+		}
+		r.spans[e.Key] = e.Spans
+		if e.Repository != "" {
+			r.repositories[e.Key] = e.Repository
+		}
+		if e.Merge {
+			r.merges[e.Key] = true
+		}
+	}
+	r.evict()
+	return r, nil
+}
@@ -0,0 +1,101 @@
+package traces
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRevisions_Set_EvictsOldest(t *testing.T) {
+	r := NewRevisions(2, 0)
+	r.Set("a", "span-a")
+	r.Set("b", "span-b")
+	r.Set("a", "span-a2")
+	r.Set("c", "span-c")
+
+	assert.Nil(t, r.Get("a"))
+	if assert.NotNil(t, r.Get("b")) {
+		assert.Equal(t, "span-b", *r.Get("b"))
+	}
+	if assert.NotNil(t, r.Get("c")) {
+		assert.Equal(t, "span-c", *r.Get("c"))
+	}
+}
+
+func TestRevisions_Get_Expired(t *testing.T) {
+	now := time.Date(2000, 12, 1, 0, 0, 0, 0, time.UTC)
+	r := NewRevisions(10, time.Hour)
+	r.now = func() time.Time { return now }
+
+	r.Set("a", "span-a")
+	now = now.Add(30 * time.Minute)
+	r.Set("a", "span-a2")
+
+	assert.Equal(t, []string{"span-a", "span-a2"}, r.Spans("a"))
+
+	now = now.Add(45 * time.Minute)
+	assert.Equal(t, []string{"span-a2"}, r.Spans("a"))
+
+	now = now.Add(time.Hour)
+	assert.Nil(t, r.Get("a"))
+}
+
+func TestRevisions_TagSpans(t *testing.T) {
+	r := NewRevisions(10, 0)
+	r.SetTags(map[string]interface{}{
+		"scm.branch": "feature/login",
+	}, "build-1", "build")
+	r.SetTags(map[string]interface{}{
+		RevisionTag:    "abc123",
+		"scm.head.ref": "feature/login",
+	}, "pr-1", "pull_request")
+	r.Set("def456", "build-2")
+
+	assert.Equal(t, []string{"build-1", "pr-1"}, r.BranchSpans("feature/login"))
+	assert.Equal(t, []string{"build-2", "pr-1", "build-1", "pr-1"}, r.TagSpans([]string{"def456"}, map[string]interface{}{
+		"scm.commit.sha": "abc123",
+		"scm.branch":     "feature/login",
+	}))
+	assert.Equal(t, []string{"pr-1", "pr-1", "build-2", "build-1"}, r.TagSpans([]string{"def456"}, map[string]interface{}{
+		"scm.commit.sha": "abc123",
+		"scm.branch":     "feature/login",
+	}, "pull_request", "issue"))
+	assert.Nil(t, r.Spans("feature/login"))
+	assert.Nil(t, r.TagSpans([]string{"feature/login"}, nil))
+}
+
+func TestLoadRevisions_Saved(t *testing.T) {
+	dir, err := ioutil.TempDir("", "revisions")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "revisions.json")
+
+	r, err := LoadRevisions(path, 10, time.Hour)
+	assert.NoError(t, err)
+	r.Set("abc123", "build-1")
+	r.SetBranch("feature/login", "pr-1")
+	r.SetTags(map[string]interface{}{
+		"scm.merge.sha":            "def456",
+		"scm.repository.full_name": "shop/checkout",
+	}, "pr-1", "pull_request")
+	r.SetTags(map[string]interface{}{
+		"scm.merge.sha":            "fed654",
+		"scm.repository.full_name": "shop/checkout",
+	}, "pr-2", "pull_request")
+	r.SetTags(map[string]interface{}{
+		"scm.merge.sha":            "cba987",
+		"scm.repository.full_name": "shop/checkout",
+	}, "pr-3", "pull_request")
+	assert.NoError(t, r.Save())
+
+	loaded, err := LoadRevisions(path, 10, time.Hour)
+	assert.NoError(t, err)
+	if assert.NotNil(t, loaded.Get("abc123")) {
+		assert.Equal(t, "build-1", *loaded.Get("abc123"))
+	}
+	assert.Equal(t, []string{"pr-1"}, loaded.BranchSpans("feature/login"))
+	assert.Equal(t, []string{"fed654", "cba987"}, loaded.Range("def456", "cba987"))
+}
@@ -42,10 +42,17 @@ var (
 	}
 )
 
+// SyntheticTag flags the placeholder spans started for parents which
+// haven't been traced yet.
+const SyntheticTag string = "vs.synthetic"
+
 type StoreEntry struct {
 	Span      opentracing.Span
 	State     *eventsources.EventState
 	CreatedAt time.Time
+	// Synthetic entries are placeholders for spans whose own events
+	// haven't been received.
+	Synthetic bool
 }
 
 func NewStoreEntryFromSpan(span opentracing.Span) StoreEntry {